@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sifatulrabbi/cli-agent/internals/agent"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Work with the Model Context Protocol",
+}
+
+var mcpServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the agent's tools over MCP on stdin and stdout",
+	Long: `Serve the agent's workspace tools (read_files, patch_file, ls, search, ...) to
+other agents and editors over MCP on stdin and stdout. The tools are limited to
+the working directory and the --add-dir directories, skip the ignored files and
+are checked against the permission rules of the settings.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return agent.ServeMCP(ctx, os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	mcpCmd.AddCommand(mcpServeCmd)
+	rootCmd.AddCommand(mcpCmd)
+}
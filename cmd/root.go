@@ -8,14 +8,35 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
 	"github.com/sifatulrabbi/cli-agent/internals/tui"
 )
 
+var (
+	addDirs        []string
+	sandboxMode    string
+	sandboxNetwork bool
+)
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "cli-agent",
 	Short: "CLI Agent",
 	Long:  `CLI Agent long...long...`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Flags().Changed("sandbox") {
+			mode, err := sandbox.ParseMode(sandboxMode)
+			if err != nil {
+				return err
+			}
+			configs.AppSettings.Sandbox.Mode = string(mode)
+		}
+		if cmd.Flags().Changed("sandbox-network") {
+			configs.AppSettings.Sandbox.Network = &sandboxNetwork
+		}
+		return configs.AddExtraRoots(addDirs...)
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
@@ -38,6 +59,9 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cli-agent.yaml)")
+	rootCmd.PersistentFlags().StringSliceVar(&addDirs, "add-dir", nil, "additional directory the agent's file tools may access (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&sandboxMode, "sandbox", "", `how the agent's commands are sandboxed: "workspace" (the default on Linux) or "off"`)
+	rootCmd.PersistentFlags().BoolVar(&sandboxNetwork, "sandbox-network", false, "allow the sandboxed commands to use the network")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
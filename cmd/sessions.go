@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/db"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Inspect the saved sessions",
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the sessions of the current working directory",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessions, err := db.ListSessions(configs.WorkingPath)
+		if err != nil {
+			return err
+		}
+		for _, s := range sessions {
+			line := fmt.Sprintf("%s  %s  %d messages", s.SessionID, s.UpdatedAt.Format("2006-01-02 15:04"), len(s.Messages))
+			if s.ParentSessionID != "" {
+				line += "  (forked from " + s.ParentSessionID + ")"
+			}
+			fmt.Println(line)
+		}
+		return nil
+	},
+}
+
+var sessionsDiffCmd = &cobra.Command{
+	Use:   "diff <session-id>",
+	Short: "Show the cumulative file changes made by the agent in a session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		history, err := db.GetSession(args[0])
+		if err != nil {
+			return fmt.Errorf("session %q not found: %w", args[0], err)
+		}
+		store, err := db.GetCheckpointStore(history.SessionID)
+		if err != nil {
+			return err
+		}
+		changes, err := store.Changes()
+		if err != nil {
+			return err
+		}
+		if len(changes) < 1 {
+			fmt.Println("No file changes in this session.")
+			return nil
+		}
+		for _, c := range changes {
+			rel, err := filepath.Rel(history.WorkingPath, c.Path)
+			if err != nil {
+				rel = c.Path
+			}
+			fromName, toName := "a/"+rel, "b/"+rel
+			if !c.Original.Existed {
+				fromName = "/dev/null"
+			}
+			if !c.Current.Existed {
+				toName = "/dev/null"
+			}
+			fmt.Print(utils.UnifiedDiff(fromName, toName, string(c.Original.Content), string(c.Current.Content), 3))
+		}
+		return nil
+	},
+}
+
+var undoAll bool
+
+var sessionsUndoCmd = &cobra.Command{
+	Use:   "undo <session-id>",
+	Short: "Revert the file changes of the last turn of a session, e.g. the last tool call served over MCP",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		history, err := db.GetSession(args[0])
+		if err != nil {
+			return fmt.Errorf("session %q not found: %w", args[0], err)
+		}
+		store, err := db.GetCheckpointStore(history.SessionID)
+		if err != nil {
+			return err
+		}
+		undo := store.UndoLastTurn
+		if undoAll {
+			undo = store.UndoAll
+		}
+		restored, err := undo()
+		for _, p := range restored {
+			if rel, err := filepath.Rel(history.WorkingPath, p); err == nil {
+				p = rel
+			}
+			fmt.Println("Reverted", p)
+		}
+		if err != nil {
+			return err
+		}
+		if len(restored) < 1 {
+			fmt.Println("Nothing to undo.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	sessionsUndoCmd.Flags().BoolVar(&undoAll, "all", false, "revert the changes of the whole session")
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsDiffCmd, sessionsUndoCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
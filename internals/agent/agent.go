@@ -1,15 +1,61 @@
 package agent
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
 	"strings"
+	"sync"
 
+	"github.com/sifatulrabbi/cli-agent/internals/agent/permissions"
+	"github.com/sifatulrabbi/cli-agent/internals/agent/tools"
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
 	"github.com/sifatulrabbi/cli-agent/internals/db"
+	"github.com/sifatulrabbi/cli-agent/internals/lsp"
+	"github.com/sifatulrabbi/cli-agent/internals/mcp"
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
+)
+
+const (
+	UpdateSig   = "update"
+	ApprovalSig = "approval" // a tool call is waiting for the user, see CLIAgent.PendingApproval
+
+	maxIterations = 100
 )
 
 type CLIAgent struct {
-	History       *db.AgentHistory `json:"history"`
-	ModelProvider ModelProvider    `json:"modelProvider"`
-	AgentMode     string           `json:"agentMode"` // Agent or Plan
+	History       *db.AgentHistory    `json:"history"`
+	Checkpoints   *db.CheckpointStore `json:"-"`
+	Artifacts     *db.ArtifactStore   `json:"-"`
+	Policy        *permissions.Policy `json:"-"`
+	ModelProvider ModelProvider       `json:"modelProvider"`
+	AgentMode     string              `json:"agentMode"` // Agent or Plan
+
+	// historyMu guards the History, the turn running in the background
+	// changes it while the TUI reads it through Messages.
+	historyMu  sync.RWMutex
+	cancel     context.CancelFunc
+	approvalMu sync.Mutex
+	approval   *ApprovalRequest
+}
+
+type ApprovalAnswer int
+
+const (
+	ApproveOnce ApprovalAnswer = iota
+	ApproveAlways
+	Reject
+)
+
+// ApprovalRequest is a tool call the permission policy wants the user to
+// confirm. SuggestedRules are what "always allow" records for the session.
+type ApprovalRequest struct {
+	ToolName       string
+	Subjects       []string
+	SuggestedRules []string
+
+	answer chan ApprovalAnswer
 }
 
 func NewAgent(history *db.AgentHistory) *CLIAgent {
@@ -18,20 +64,291 @@ func NewAgent(history *db.AgentHistory) *CLIAgent {
 	if len(modelNameParts) == 2 {
 		modelProvider.ModelName = modelNameParts[0]
 		modelProvider.ReasoningEffort = modelNameParts[1]
-	} else {
+	} else if modelNameParts[0] != "" {
 		modelProvider.ModelName = modelNameParts[0]
 	}
+	checkpoints, err := db.GetCheckpointStore(history.SessionID)
+	if err != nil {
+		log.Println("ERROR: Failed to load the session checkpoints, starting with an empty store.", err)
+		checkpoints = &db.CheckpointStore{SessionID: history.SessionID}
+	}
+	policy, err := permissions.NewPolicy(configs.AppSettings.Permissions)
+	if err != nil {
+		log.Println("ERROR: Invalid permission rules, asking before every edit and command.", err)
+		policy, _ = permissions.NewPolicy(configs.PermissionSettings{})
+	}
+	if history.Todos == nil {
+		history.Todos = &db.TodoList{}
+	}
+	artifacts := db.NewArtifactStore(history.SessionID)
+	tools.UseTodos(history.Todos)
+	tools.UseArtifacts(artifacts)
+	tools.UseSandbox(sandbox.FromSettings(configs.AppSettings.Sandbox))
+	tools.UseFormatters(configs.AppSettings.Format)
+	tools.UseWeb(configs.AppSettings.Web)
+	tools.UseLanguageServers(lsp.NewManager(configs.WorkingPath, lsp.ServersFromSettings(configs.AppSettings.LSP)))
+	tools.UseMCP(mcp.NewManager(configs.WorkingPath, mcp.ServersFromSettings(configs.AppSettings.MCP)))
+	if err := tools.UsePlugins(configs.AppSettings.Plugins); err != nil {
+		log.Println("ERROR: Skipping the invalid plugins.", err)
+	}
 	return &CLIAgent{
 		ModelProvider: modelProvider,
 		History:       history,
+		Checkpoints:   checkpoints,
+		Artifacts:     artifacts,
+		Policy:        policy,
 		AgentMode:     "Agent",
 	}
 }
 
-func (a CLIAgent) ListAvailableModels() {
+func (a *CLIAgent) ListAvailableModels() {
 }
 
-func (a CLIAgent) Invoke(userInput string) chan string {
-	ch := make(chan string)
+// Invoke appends the user's input to the history and runs the agent loop in
+// the background. An UpdateSig is sent every time the history changes and the
+// channel is closed once the agent finishes its turn or is cancelled.
+func (a *CLIAgent) Invoke(userInput string) chan string {
+	ch := make(chan string, 1024)
+	sendUpdateSig := func() {
+		select {
+		case ch <- UpdateSig:
+		default:
+		}
+	}
+
+	a.historyMu.Lock()
+	if len(a.History.Messages) == 0 {
+		a.History.Messages = append(a.History.Messages, db.HistoryMessage{Role: db.MsgRoleSystem, Text: SysPrompt})
+	}
+	a.History.Messages = append(a.History.Messages, db.HistoryMessage{Role: db.MsgRoleUser, Text: userInput})
+	tools.UseCheckpoints(a.Checkpoints, len(a.History.Messages)-1)
+	a.historyMu.Unlock()
+	sendUpdateSig()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+
+	go func() {
+		defer close(ch)
+		defer cancel()
+		defer func() {
+			a.historyMu.Lock()
+			defer a.historyMu.Unlock()
+			if err := db.SaveHistory(a.History); err != nil {
+				log.Println("ERROR: Failed to save the session history.", err)
+			}
+		}()
+
+		for range maxIterations {
+			if ctx.Err() != nil {
+				return
+			}
+			messages, err := a.ModelProvider.Invoke(a.Messages())
+			if err != nil {
+				a.appendMessage(db.HistoryMessage{
+					Role: db.MsgRoleAI,
+					Text: fmt.Sprintf("Error contacting the model: %v", err),
+				})
+				sendUpdateSig()
+				return
+			}
+			a.historyMu.Lock()
+			a.History.Messages = messages
+			a.historyMu.Unlock()
+			sendUpdateSig()
+
+			last := messages[len(messages)-1]
+			if !last.IsAI() || len(last.ToolCalls) == 0 {
+				return
+			}
+
+			for _, tc := range last.ToolCalls {
+				// Every tool call needs a result, even the ones that were
+				// skipped because the user cancelled the turn.
+				idx := a.appendMessage(db.HistoryMessage{
+					Role:       db.MsgRoleTool,
+					ToolCallID: tc.CallID,
+				})
+				tools.UseContext(ctx, func(output string) {
+					a.setMessageText(idx, output)
+					sendUpdateSig()
+				})
+
+				toolOutput := ""
+				if ctx.Err() != nil {
+					toolOutput = "The tool call was cancelled by the user."
+				} else if handler, ok := tools.Handler(tc.Name); !ok {
+					toolOutput = fmt.Sprintf("Tool '%s' not found", tc.Name)
+				} else if allowed, reason := a.authorize(ctx, ch, tc); !allowed {
+					toolOutput = reason
+				} else if out, err := handler(tc.Args); err != nil {
+					toolOutput = fmt.Sprintf("Error executing tool '%s': %v", tc.Name, err)
+				} else {
+					toolOutput = tools.LimitOutput(tc.Name, out)
+				}
+				a.setMessageText(idx, toolOutput)
+				sendUpdateSig()
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		a.appendMessage(db.HistoryMessage{
+			Role: db.MsgRoleAI,
+			Text: "Max loop reached! Forcefully shutting down the agent.",
+		})
+		sendUpdateSig()
+	}()
+
 	return ch
 }
+
+// Messages returns a copy of the conversation, which can be read while a
+// turn changes it.
+func (a *CLIAgent) Messages() []db.HistoryMessage {
+	a.historyMu.RLock()
+	defer a.historyMu.RUnlock()
+	return slices.Clone(a.History.Messages)
+}
+
+// appendMessage adds the message to the history and returns its index.
+func (a *CLIAgent) appendMessage(msg db.HistoryMessage) int {
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
+	a.History.Messages = append(a.History.Messages, msg)
+	return len(a.History.Messages) - 1
+}
+
+func (a *CLIAgent) setMessageText(idx int, text string) {
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
+	if idx < len(a.History.Messages) {
+		a.History.Messages[idx].Text = text
+	}
+}
+
+// authorize checks the tool call against the permission policy, asking the
+// user through an ApprovalSig when the policy says so. When the call may not
+// run the reason is returned for the model.
+func (a *CLIAgent) authorize(ctx context.Context, ch chan string, tc db.ToolCall) (bool, string) {
+	subjects := tools.PermissionSubjects(tc.Name, tc.Args)
+	decision, rule := a.Policy.Evaluate(tc.Name, subjects, tools.ReadOnly(tc.Name, tc.Args))
+	if decision == permissions.Allow && tools.AlwaysAsk(tc.Name, tc.Args) {
+		decision = permissions.Ask
+	}
+	switch decision {
+	case permissions.Allow:
+		return true, ""
+	case permissions.Deny:
+		return false, fmt.Sprintf("The tool call was denied by the permission rule '%s'. Do not retry it; find another way or ask the user.", rule)
+	}
+
+	req := &ApprovalRequest{
+		ToolName:       tc.Name,
+		Subjects:       subjects,
+		SuggestedRules: tools.SuggestRules(tc.Name, subjects),
+		answer:         make(chan ApprovalAnswer, 1),
+	}
+	a.setApproval(req)
+	defer a.setApproval(nil)
+
+	select {
+	case ch <- ApprovalSig:
+	case <-ctx.Done():
+		return false, "The tool call was cancelled by the user."
+	}
+	select {
+	case answer := <-req.answer:
+		if answer == Reject {
+			return false, "The user rejected the tool call. Ask them how to proceed if it is unclear why."
+		}
+		if answer == ApproveAlways {
+			if err := a.Policy.AllowForSession(req.SuggestedRules...); err != nil {
+				log.Println("ERROR: Failed to record the session permission.", err)
+			}
+		}
+		return true, ""
+	case <-ctx.Done():
+		return false, "The tool call was cancelled by the user."
+	}
+}
+
+// PendingApproval returns the tool call waiting for the user's answer, if any.
+func (a *CLIAgent) PendingApproval() *ApprovalRequest {
+	a.approvalMu.Lock()
+	defer a.approvalMu.Unlock()
+	return a.approval
+}
+
+// Answer lets the pending tool call run, or not.
+func (a *CLIAgent) Answer(answer ApprovalAnswer) {
+	if req := a.PendingApproval(); req != nil {
+		select {
+		case req.answer <- answer:
+		default:
+		}
+	}
+}
+
+func (a *CLIAgent) setApproval(req *ApprovalRequest) {
+	a.approvalMu.Lock()
+	defer a.approvalMu.Unlock()
+	a.approval = req
+}
+
+// Cancel stops the running turn, killing the command that is running if any.
+func (a *CLIAgent) Cancel() {
+	if a.cancel != nil {
+		a.cancel()
+	}
+}
+
+// Rewind truncates the conversation right before the user message at msgIdx
+// and returns that message so it can be edited and resubmitted. The
+// conversation as it was before the rewind is preserved as a forked session
+// whose id is returned as well. When restoreFiles is set the file edits made
+// after the message are reverted too.
+func (a *CLIAgent) Rewind(msgIdx int, restoreFiles bool) (string, string, error) {
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
+	if msgIdx < 0 || msgIdx >= len(a.History.Messages) || !a.History.Messages[msgIdx].IsUser() {
+		return "", "", fmt.Errorf("message %d is not a user message", msgIdx)
+	}
+
+	fork := a.History.Fork(msgIdx)
+	if err := db.SaveHistory(fork); err != nil {
+		return "", "", fmt.Errorf("failed to preserve the original conversation: %w", err)
+	}
+	if err := a.Checkpoints.CopyTo(fork.SessionID); err != nil {
+		log.Println("ERROR: Failed to copy the checkpoints to the forked session.", err)
+	}
+	if err := a.Artifacts.CopyTo(fork.SessionID); err != nil {
+		log.Println("ERROR: Failed to copy the stored outputs to the forked session.", err)
+	}
+	// The checkpoints after the message belong to the fork now, either way
+	// the next turns reuse their turn indexes.
+	if restoreFiles {
+		if _, err := a.Checkpoints.UndoSince(msgIdx); err != nil {
+			return "", "", fmt.Errorf("failed to restore the files: %w", err)
+		}
+	} else if err := a.Checkpoints.DropSince(msgIdx); err != nil {
+		log.Println("ERROR: Failed to drop the checkpoints of the rewound turns.", err)
+	}
+
+	userInput := a.History.Messages[msgIdx].Text
+	a.History.Messages = a.History.Messages[:msgIdx]
+	if err := db.SaveHistory(a.History); err != nil {
+		return "", "", err
+	}
+	return userInput, fork.SessionID, nil
+}
+
+// Undo reverts the file edits of the last turn, or of the whole session when
+// all is set, and returns the restored paths.
+func (a *CLIAgent) Undo(all bool) ([]string, error) {
+	if all {
+		return a.Checkpoints.UndoAll()
+	}
+	return a.Checkpoints.UndoLastTurn()
+}
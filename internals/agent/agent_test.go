@@ -2,14 +2,24 @@ package agent
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 
+	"github.com/sifatulrabbi/cli-agent/internals/agent/permissions"
 	"github.com/sifatulrabbi/cli-agent/internals/configs"
 	"github.com/sifatulrabbi/cli-agent/internals/db"
 )
 
 func TestAgent(t *testing.T) {
 	configs.Prepare()
+	if configs.OpenRouterAPIKey == "" {
+		t.Skip("OPENROUTER_API_KEY is not set")
+	}
 	modelProvider := NewDefaultProviderAndModel()
 	messages := []db.HistoryMessage{
 		{
@@ -43,3 +53,132 @@ func TestAgent(t *testing.T) {
 		fmt.Println()
 	}
 }
+
+// testAgent returns an agent with a new session and no model.
+func testAgent(t *testing.T) *CLIAgent {
+	t.Helper()
+	configs.SessionsPath = t.TempDir()
+	configs.WorkingPath = t.TempDir()
+	history := db.NewHistory(configs.WorkingPath, "")
+	policy, _ := permissions.NewPolicy(configs.PermissionSettings{})
+	return &CLIAgent{
+		History:     history,
+		Checkpoints: &db.CheckpointStore{SessionID: history.SessionID},
+		Artifacts:   db.NewArtifactStore(history.SessionID),
+		Policy:      policy,
+	}
+}
+
+func TestRewind(t *testing.T) {
+	a := testAgent(t)
+	a.History.Messages = []db.HistoryMessage{
+		{Role: db.MsgRoleSystem, Text: "system"},
+		{Role: db.MsgRoleUser, Text: "first"},
+		{Role: db.MsgRoleAI, Text: "done"},
+		{Role: db.MsgRoleUser, Text: "second\nline"},
+		{Role: db.MsgRoleAI, Text: "done too"},
+	}
+	p := filepath.Join(configs.WorkingPath, "main.go")
+	edit := func(turn int, content string) {
+		if err := a.Checkpoints.Snapshot(turn, "write_file", p); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	edit(1, "v1")
+	edit(3, "v2")
+
+	if _, _, err := a.Rewind(2, false); err == nil {
+		t.Error("expected rewinding to an AI message to fail")
+	}
+	input, forkID, err := a.Rewind(3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input != "second\nline" || len(a.Messages()) != 3 {
+		t.Fatalf("unexpected rewind to %q with %d messages left", input, len(a.Messages()))
+	}
+	if data, _ := os.ReadFile(p); string(data) != "v1" {
+		t.Errorf("expected the edit of the later turn to be reverted, got %q", data)
+	}
+
+	fork, err := db.GetSession(forkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fork.Messages) != 5 || fork.ParentSessionID != a.History.SessionID || fork.ForkedAt != 3 {
+		t.Errorf("unexpected fork %+v", fork)
+	}
+	saved, err := db.GetSession(a.History.SessionID)
+	if err != nil || len(saved.Messages) != 3 {
+		t.Errorf("expected the rewound session to be saved, got %+v, %v", saved, err)
+	}
+}
+
+// TestInvokeRace reads the conversation while a turn with a tool call runs,
+// go test -race reports the unguarded accesses.
+func TestInvokeRace(t *testing.T) {
+	a := testAgent(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		message := `{"role": "assistant", "content": "Done."}`
+		if calls.Add(1) == 1 {
+			message = `{"role": "assistant", "content": "", "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "ls", "arguments": "{}"}}]}`
+		}
+		fmt.Fprintf(w, `{"id": "1", "object": "chat.completion", "created": 0, "model": "test", "choices": [{"index": 0, "finish_reason": "stop", "message": %s}]}`, message)
+	}))
+	t.Cleanup(srv.Close)
+	configs.OpenRouterBaseURL = srv.URL
+	a.ModelProvider = ModelProvider{ModelName: "test", Provider: ProviderOpenRouter}
+
+	ch := a.Invoke("list the files")
+	for range ch {
+		for _, msg := range a.Messages() {
+			_ = msg.Text
+		}
+	}
+	messages := a.Messages()
+	if len(messages) != 5 || !messages[3].IsTool() || messages[4].Text != "Done." {
+		t.Fatalf("unexpected conversation %+v", messages)
+	}
+	if strings.Contains(messages[3].Text, "not found") {
+		t.Errorf("unexpected tool output %q", messages[3].Text)
+	}
+	if saved, err := db.GetSession(a.History.SessionID); err != nil || len(saved.Messages) != 5 {
+		t.Errorf("expected the turn to be saved, got %v", err)
+	}
+}
+
+func TestRewindKeepingTheFiles(t *testing.T) {
+	a := testAgent(t)
+	a.History.Messages = []db.HistoryMessage{
+		{Role: db.MsgRoleUser, Text: "first"},
+		{Role: db.MsgRoleAI, Text: "done"},
+	}
+	p := filepath.Join(configs.WorkingPath, "main.go")
+	if err := a.Checkpoints.Snapshot(0, "write_file", p); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, forkID, err := a.Rewind(0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(p); string(data) != "edited" {
+		t.Errorf("expected the file to be kept, got %q", data)
+	}
+	// The next turn reuses the index 0, its undo must not revert the edit of
+	// the abandoned one.
+	if a.Checkpoints.HasChangesSince(0) {
+		t.Error("expected the checkpoints of the rewound turns to be dropped")
+	}
+	if fork, _ := db.GetCheckpointStore(forkID); !fork.HasChangesSince(0) {
+		t.Error("expected the fork to keep the checkpoints")
+	}
+}
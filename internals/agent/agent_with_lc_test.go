@@ -1,3 +1,9 @@
+//go:build langchain_reasoning
+
+// This demo calls the providers and needs the reasoning API of a newer
+// langchaingo than the one in go.mod, so it only builds with
+// -tags langchain_reasoning.
+
 package agent
 
 import (
@@ -16,7 +16,6 @@ import (
 
 	"github.com/sifatulrabbi/cli-agent/internals/agent/tools"
 	"github.com/sifatulrabbi/cli-agent/internals/configs"
-	"github.com/sifatulrabbi/cli-agent/internals/utils"
 )
 
 type HistoryMessage struct {
@@ -281,7 +280,7 @@ func ChatWithLLM(question string) chan string {
 // potentially destructive or file-modifying and thus needs user approval.
 func requiresApproval(toolName string) bool {
 	switch toolName {
-	case tools.ToolAppendFile, tools.ToolPatchFile, tools.ToolBash, tools.ToolAddTodo, tools.ToolMarkTodoAsDone:
+	case tools.ToolAppendFile, tools.ToolPatchFile, tools.ToolBash:
 		return true
 	default:
 		return false
@@ -289,24 +288,7 @@ func requiresApproval(toolName string) bool {
 }
 
 func GetFormattedTodoList() string {
-	formatted := ""
-	c, err := os.ReadFile(configs.TodosFile)
-	if err != nil {
-		log.Println("ERROR: reading todo list file.", err)
-		return formatted
-	}
-	todoList := []tools.TodoItem{}
-	if err = json.Unmarshal(c, &todoList); err != nil {
-		log.Println("ERROR: Unmarshaling todo list.", err)
-		return formatted
-	}
-	if len(todoList) < 1 {
-		return formatted
-	}
-	for _, todo := range todoList {
-		formatted += fmt.Sprintf("%s %d. %s\n", utils.Ternary(todo.Done, "[x]", "[ ]"), todo.Id, todo.Task)
-	}
-	return formatted
+	return tools.CurrentTodos().String()
 }
 
 // ----------------------
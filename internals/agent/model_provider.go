@@ -10,6 +10,7 @@ import (
 	"github.com/openai/openai-go/v2/packages/param"
 	"github.com/openai/openai-go/v2/shared"
 	"github.com/openai/openai-go/v2/shared/constant"
+	"github.com/sifatulrabbi/cli-agent/internals/agent/tools"
 	"github.com/sifatulrabbi/cli-agent/internals/configs"
 	"github.com/sifatulrabbi/cli-agent/internals/db"
 )
@@ -68,6 +69,14 @@ func (m ModelProvider) invokeOpenAICompatibleProvider(messages []db.HistoryMessa
 		params.ReasoningEffort = shared.ReasoningEffort(m.ReasoningEffort)
 	}
 
+	for _, def := range tools.AllDefinitions() {
+		params.Tools = append(params.Tools, openai.ChatCompletionFunctionTool(shared.FunctionDefinitionParam{
+			Name:        def.Name,
+			Description: param.NewOpt(def.Description),
+			Parameters:  def.Parameters,
+		}))
+	}
+
 	for _, msg := range messages {
 		if msg.IsSystem() {
 			if m.ReasoningEffort != "" {
@@ -0,0 +1,222 @@
+package permissions
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+type Decision string
+
+const (
+	Allow Decision = "allow"
+	Ask   Decision = "ask"
+	Deny  Decision = "deny"
+)
+
+// Rule matches the calls of a tool, optionally only the ones whose subjects
+// (the command of bash, the paths of the file tools) match a glob pattern.
+type Rule struct {
+	Tool    string
+	Pattern string
+	re      *regexp.Regexp
+}
+
+// ParseRule parses "tool" or "tool(pattern)". The tool name may be a glob
+// itself. In the pattern "*" matches anything, "/" included, "**/" any number
+// of leading directories and "?" a single character. A trailing " *" makes
+// the arguments optional, so "go test *" covers a bare "go test" as well.
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	r := Rule{Tool: s}
+	if open := strings.IndexByte(s, '('); open >= 0 {
+		if !strings.HasSuffix(s, ")") {
+			return r, fmt.Errorf("invalid permission rule %q: missing the closing parenthesis", s)
+		}
+		r.Tool = strings.TrimSpace(s[:open])
+		r.Pattern = strings.TrimSpace(s[open+1 : len(s)-1])
+	}
+	if r.Tool == "" {
+		return r, fmt.Errorf("invalid permission rule %q: no tool name", s)
+	}
+	if _, err := path.Match(r.Tool, ""); err != nil {
+		return r, fmt.Errorf("invalid permission rule %q: %w", s, err)
+	}
+	if r.Pattern != "" && r.Pattern != "*" {
+		re, err := regexp.Compile("^" + globToRegexp(r.Pattern) + "$")
+		if err != nil {
+			return r, fmt.Errorf("invalid permission rule %q: %w", s, err)
+		}
+		r.re = re
+	}
+	return r, nil
+}
+
+func (r Rule) String() string {
+	if r.Pattern == "" {
+		return r.Tool
+	}
+	return r.Tool + "(" + r.Pattern + ")"
+}
+
+func (r Rule) matchesTool(tool string) bool {
+	ok, _ := path.Match(r.Tool, tool)
+	return ok
+}
+
+// matches reports whether the rule covers the subject. A rule without a
+// pattern covers every call of its tool.
+func (r Rule) matches(subject string) bool {
+	return r.re == nil || r.re.MatchString(subject)
+}
+
+// Policy decides whether a tool call may run. Deny rules win over ask rules,
+// which win over allow rules; calls no rule covers are allowed when the tool
+// is read-only and asked about otherwise.
+type Policy struct {
+	mu      sync.Mutex
+	allow   []Rule
+	ask     []Rule
+	deny    []Rule
+	session []Rule // allowed by the user for the rest of the session
+}
+
+func NewPolicy(settings configs.PermissionSettings) (*Policy, error) {
+	p := &Policy{}
+	for _, list := range []struct {
+		raw   []string
+		rules *[]Rule
+	}{{settings.Allow, &p.allow}, {settings.Ask, &p.ask}, {settings.Deny, &p.deny}} {
+		for _, raw := range list.raw {
+			r, err := ParseRule(raw)
+			if err != nil {
+				return nil, err
+			}
+			*list.rules = append(*list.rules, r)
+		}
+	}
+	return p, nil
+}
+
+// Evaluate decides on a call of the tool with the given subjects, returning
+// the rule the decision is based on, if any. A call is denied (or asked
+// about) when any of its subjects is, and only allowed by the rules when all
+// of them are.
+func (p *Policy) Evaluate(tool string, subjects []string, readOnly bool) (Decision, *Rule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if r := matchAny(p.deny, tool, subjects); r != nil {
+		return Deny, r
+	}
+	if r := matchAny(p.ask, tool, subjects); r != nil {
+		return Ask, r
+	}
+	if r := matchAll(slices.Concat(p.allow, p.session), tool, subjects); r != nil {
+		return Allow, r
+	}
+	if readOnly {
+		return Allow, nil
+	}
+	return Ask, nil
+}
+
+// AllowForSession records the "always allow" decisions of the user, which are
+// forgotten when the program exits.
+func (p *Policy) AllowForSession(rules ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, raw := range rules {
+		r, err := ParseRule(raw)
+		if err != nil {
+			return err
+		}
+		p.session = append(p.session, r)
+	}
+	return nil
+}
+
+// Summary describes the policy in a few words for the status bar.
+func (p *Policy) Summary() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.allow)+len(p.ask)+len(p.deny)+len(p.session) == 0 {
+		return "asking before edits and commands"
+	}
+	s := fmt.Sprintf("permission rules: %d allow, %d ask, %d deny", len(p.allow), len(p.ask), len(p.deny))
+	if len(p.session) > 0 {
+		s += fmt.Sprintf(", %d allowed this session", len(p.session))
+	}
+	return s
+}
+
+func matchAny(rules []Rule, tool string, subjects []string) *Rule {
+	for i, r := range rules {
+		if !r.matchesTool(tool) {
+			continue
+		}
+		if r.re == nil {
+			return &rules[i]
+		}
+		for _, s := range subjects {
+			if r.matches(s) {
+				return &rules[i]
+			}
+		}
+	}
+	return nil
+}
+
+func matchAll(rules []Rule, tool string, subjects []string) *Rule {
+	var last *Rule
+	for _, s := range subjects {
+		found := false
+		for i, r := range rules {
+			if r.matchesTool(tool) && r.matches(s) {
+				found, last = true, &rules[i]
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	if len(subjects) == 0 {
+		// Without subjects only the rules covering the whole tool apply.
+		for i, r := range rules {
+			if r.matchesTool(tool) && r.re == nil {
+				return &rules[i]
+			}
+		}
+	}
+	return last
+}
+
+func globToRegexp(glob string) string {
+	if prefix, ok := strings.CutSuffix(glob, " *"); ok {
+		return globToRegexp(prefix) + "(?: .*)?"
+	}
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*':
+			sb.WriteString(".*")
+			for i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+			}
+		case c == '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
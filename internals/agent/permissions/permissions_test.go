@@ -0,0 +1,70 @@
+package permissions
+
+import (
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	p, err := NewPolicy(configs.PermissionSettings{
+		Allow: []string{"bash(go test *)", "bash(ls)", "edit_file", "mcp__docs__*"},
+		Ask:   []string{"edit_file(**/*.sql)"},
+		Deny:  []string{"bash(git push *)", "read_files(.env*)"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		tool     string
+		subjects []string
+		readOnly bool
+		want     Decision
+	}{
+		{"bash", []string{"go test ./..."}, false, Allow},
+		{"bash", []string{"go test"}, false, Allow},
+		{"bash", []string{"go testing"}, false, Ask},
+		{"bash", []string{"ls"}, false, Allow},
+		{"bash", []string{"ls -la"}, false, Ask},
+		{"bash", []string{"go test ./...", "rm -rf /"}, false, Ask},
+		{"bash", []string{"go test ./...", "git push origin main"}, false, Deny},
+		{"edit_file", []string{"main.go"}, false, Allow},
+		{"edit_file", []string{"schema.sql"}, false, Ask},
+		{"edit_file", []string{"db/migrations/001.sql"}, false, Ask},
+		{"read_files", []string{"main.go", ".env.local"}, true, Deny},
+		{"read_files", []string{"main.go"}, true, Allow},
+		{"apply_patch", []string{"main.go"}, false, Ask},
+		{"mcp__docs__search", nil, false, Allow},
+	}
+	for _, c := range cases {
+		if got, _ := p.Evaluate(c.tool, c.subjects, c.readOnly); got != c.want {
+			t.Errorf("Evaluate(%s, %q) = %s, want %s", c.tool, c.subjects, got, c.want)
+		}
+	}
+
+	if err := p.AllowForSession("apply_patch(main.go)", "bash(rm *)"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := p.Evaluate("apply_patch", []string{"main.go"}, false); got != Allow {
+		t.Errorf("expected the session rule to allow the call, got %s", got)
+	}
+	if got, _ := p.Evaluate("bash", []string{"rm x", "git push"}, false); got != Deny {
+		t.Errorf("expected the deny rules to win over the session rules, got %s", got)
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	for _, bad := range []string{"", "(x)", "bash(go test", "[(x)"} {
+		if _, err := ParseRule(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+	r, err := ParseRule(" bash( npm run * ) ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != "bash(npm run *)" {
+		t.Errorf("unexpected rule %q", r)
+	}
+}
@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/sifatulrabbi/cli-agent/internals/agent/permissions"
+	"github.com/sifatulrabbi/cli-agent/internals/agent/tools"
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/db"
+	"github.com/sifatulrabbi/cli-agent/internals/lsp"
+	"github.com/sifatulrabbi/cli-agent/internals/mcp"
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
+)
+
+// mcpServerInstructions tell the client's model how the tools see the
+// workspace.
+const mcpServerInstructions = "The tools work on the workspace cli-agent was started in: paths are relative to it, files outside of it " +
+	"(and of the extra directories it was given) can't be touched, and ignored files are skipped. " +
+	"Calls the permission policy asks about need the user's approval."
+
+// ServeMCP offers the built-in tools to an MCP client reading from r and
+// writing to w, e.g. stdin and stdout, until r ends or ctx is done. The calls
+// are checked against the permission policy like in the TUI, the ones it
+// asks about are confirmed by the client's user through elicitation, or
+// refused when the client can't ask. The server runs in a session of its own,
+// whose checkpoints let every call's edits be undone.
+func ServeMCP(ctx context.Context, r io.Reader, w io.Writer) error {
+	history := db.NewHistory(configs.WorkingPath, "")
+	if err := db.SaveHistory(history); err != nil {
+		return fmt.Errorf("unable to save the session: %w", err)
+	}
+	defer func() {
+		if err := db.SaveHistory(history); err != nil {
+			log.Println("ERROR: Failed to save the session.", err)
+		}
+	}()
+	log.Println("Serving the tools over MCP in the session", history.SessionID)
+	checkpoints := &db.CheckpointStore{SessionID: history.SessionID}
+	tools.UseTodos(history.Todos)
+	tools.UseArtifacts(db.NewArtifactStore(history.SessionID))
+	defer tools.UseCheckpoints(nil, 0)
+
+	policy, err := permissions.NewPolicy(configs.AppSettings.Permissions)
+	if err != nil {
+		log.Println("ERROR: Invalid permission rules, asking before every edit and command.", err)
+		policy, _ = permissions.NewPolicy(configs.PermissionSettings{})
+	}
+	if len(configs.IgnoredProjectSettings) > 0 {
+		log.Println("ERROR: Ignored the settings of the untrusted project:", strings.Join(configs.IgnoredProjectSettings, ", "))
+	}
+	tools.UseSandbox(sandbox.FromSettings(configs.AppSettings.Sandbox))
+	tools.UseFormatters(configs.AppSettings.Format)
+	tools.UseWeb(configs.AppSettings.Web)
+	tools.UseLanguageServers(lsp.NewManager(configs.WorkingPath, lsp.ServersFromSettings(configs.AppSettings.LSP)))
+	if err := tools.UsePlugins(configs.AppSettings.Plugins); err != nil {
+		log.Println("ERROR: Skipping the invalid plugins.", err)
+	}
+	defer tools.CloseShell()
+	defer tools.CloseLanguageServers()
+
+	turn := 0
+	server := &mcp.Server{
+		Name:         "cli-agent",
+		Version:      "0.1.0",
+		Instructions: mcpServerInstructions,
+		Tools:        mcpTools,
+		CallTool: func(ctx context.Context, session *mcp.ServerSession, name string, args json.RawMessage) (*mcp.CallToolResult, error) {
+			// The calls run one at a time, each is a turn of its own.
+			turn++
+			tools.UseCheckpoints(checkpoints, turn)
+			return callToolForMCP(ctx, policy, session, name, args)
+		},
+	}
+	return server.Serve(ctx, r, w)
+}
+
+// mcpTools are the built-in and the plugin tools. The MCP servers of the
+// settings are not connected to, so their tools are not passed on.
+func mcpTools() []mcp.Tool {
+	defs := tools.AllDefinitions()
+	list := make([]mcp.Tool, 0, len(defs))
+	for _, def := range defs {
+		list = append(list, mcp.Tool{Name: def.Name, Description: def.Description, InputSchema: def.Parameters})
+	}
+	return list
+}
+
+func callToolForMCP(ctx context.Context, policy *permissions.Policy, session *mcp.ServerSession, name string, args json.RawMessage) (*mcp.CallToolResult, error) {
+	handler, ok := tools.Handler(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown tool %q", name)
+	}
+	argsJSON := strings.TrimSpace(string(args))
+	if argsJSON == "" || argsJSON == "null" {
+		argsJSON = "{}"
+	}
+	if allowed, reason := authorizeMCPCall(ctx, policy, session, name, argsJSON); !allowed {
+		return textResult(reason, true), nil
+	}
+
+	tools.UseContext(ctx, nil)
+	defer tools.UseContext(context.Background(), nil)
+	out, err := handler(argsJSON)
+	if err != nil {
+		return textResult(fmt.Sprintf("Error executing tool '%s': %v", name, err), true), nil
+	}
+	return textResult(out, false), nil
+}
+
+// authorizeMCPCall is CLIAgent.authorize for the calls of an MCP client,
+// whose user is asked through elicitation.
+func authorizeMCPCall(ctx context.Context, policy *permissions.Policy, session *mcp.ServerSession, name, argsJSON string) (bool, string) {
+	subjects := tools.PermissionSubjects(name, argsJSON)
+	decision, rule := policy.Evaluate(name, subjects, tools.ReadOnly(name, argsJSON))
+	if decision == permissions.Allow && tools.AlwaysAsk(name, argsJSON) {
+		decision = permissions.Ask
+	}
+	switch decision {
+	case permissions.Allow:
+		return true, ""
+	case permissions.Deny:
+		return false, fmt.Sprintf("The tool call was denied by the permission rule '%s'. Do not retry it; find another way or ask the user.", rule)
+	}
+	if !session.CanElicit() {
+		return false, "The permission policy asks the user before this tool call, and this MCP client can't ask. " +
+			"Add an allow rule to the cli-agent settings to let it run."
+	}
+
+	suggested := tools.SuggestRules(name, subjects)
+	text := "Allow " + name
+	if len(subjects) > 0 {
+		text += " on " + strings.Join(subjects, ", ")
+	}
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"always": map[string]any{
+				"type":    "boolean",
+				"title":   "Always allow " + strings.Join(suggested, ", ") + " while the server runs",
+				"default": false,
+			},
+		},
+	}
+	answer, err := session.Elicit(ctx, text+"?", schema)
+	if err != nil {
+		return false, fmt.Sprintf("Unable to ask the user for permission: %v", err)
+	}
+	if answer.Action != "accept" {
+		return false, "The user rejected the tool call. Ask them how to proceed if it is unclear why."
+	}
+	if always, _ := answer.Content["always"].(bool); always {
+		if err := policy.AllowForSession(suggested...); err != nil {
+			log.Println("ERROR: Failed to record the session permission.", err)
+		}
+	}
+	return true, ""
+}
+
+func textResult(text string, isError bool) *mcp.CallToolResult {
+	return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: text}}, IsError: isError}
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/db"
+)
+
+func TestServeMCPCheckpointsTheEdits(t *testing.T) {
+	configs.SessionsPath = t.TempDir()
+	configs.WorkingPath = t.TempDir()
+	configs.AppSettings = configs.Settings{
+		Sandbox:     configs.SandboxSettings{Mode: "off"},
+		Permissions: configs.PermissionSettings{Allow: []string{"write_file"}},
+	}
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeMCP(context.Background(), inR, outW)
+		outW.Close()
+	}()
+	lines := bufio.NewScanner(outR)
+	call := func(line string) string {
+		t.Helper()
+		if _, err := io.WriteString(inW, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+		if !lines.Scan() {
+			t.Fatal("the server closed its output")
+		}
+		return lines.Text()
+	}
+
+	call(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{}}}`)
+	call(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"write_file","arguments":{"filePath":"a.txt","content":"one\n"}}}`)
+	if out := call(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"write_file","arguments":{"filePath":"a.txt","content":"two\n","overwrite":true}}}`); !strings.Contains(out, "Overwrote") {
+		t.Fatalf("unexpected response %s", out)
+	}
+	inW.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := db.ListSessions(configs.WorkingPath)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("expected the server's session to be saved, got %v, %v", sessions, err)
+	}
+	store, err := db.GetCheckpointStore(sessions[0].SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := filepath.Join(configs.WorkingPath, "a.txt")
+	// Every call is a turn of its own.
+	if _, err := store.UndoLastTurn(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(a); string(data) != "one\n" {
+		t.Errorf("expected the last call to be undone, got %q", data)
+	}
+	if _, err := store.UndoAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(a); !os.IsNotExist(err) {
+		t.Error("expected every call to be undone")
+	}
+}
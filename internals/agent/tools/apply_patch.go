@@ -0,0 +1,608 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+const (
+	defaultPatchFuzz      = 2
+	defaultPatchMaxOffset = 200
+)
+
+type ApplyPatchToolArgs struct {
+	Patch     string `json:"patch"`
+	Fuzz      *int   `json:"fuzz"`
+	MaxOffset *int   `json:"maxOffset"`
+}
+
+type patchHunk struct {
+	oldStart int
+	header   string
+	lines    []string // prefixed with ' ', '-' or '+'
+}
+
+type filePatch struct {
+	oldPath string // empty when the file is created
+	newPath string // empty when the file is deleted
+	hunks   []patchHunk
+
+	oldFull, newFull string // resolved paths inside the workspace
+
+	// "\ No newline at end of file" after a line of the old or the new side.
+	oldNoEOL, newNoEOL bool
+
+	newMode fs.FileMode // of a created file, from its "new file mode" line
+}
+
+// patchResult is the fully applied state of a file patch, kept in memory until
+// every file of the diff applied cleanly.
+type patchResult struct {
+	fp       filePatch
+	content  string
+	original string // the content of the old file, to roll back to
+	mode     fs.FileMode
+	notes    []string
+}
+
+// commitPatchResult is replaced by the tests to make a write fail.
+var commitPatchResult = movePatchResult
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// handleApplyPatch applies a multi-file unified diff. Either every hunk of
+// every file applies or nothing is written.
+func handleApplyPatch(argsJSON string) (string, error) {
+	var args ApplyPatchToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	fuzz, maxOffset := defaultPatchFuzz, defaultPatchMaxOffset
+	if args.Fuzz != nil {
+		fuzz = max(*args.Fuzz, 0)
+	}
+	if args.MaxOffset != nil {
+		maxOffset = max(*args.MaxOffset, 0)
+	}
+
+	patches, err := parseUnifiedDiff(args.Patch)
+	if err != nil {
+		return fmt.Sprintf("Could not parse the patch: %s", err), nil
+	}
+
+	var results []patchResult
+	var rejects []string
+	patched := map[string]string{}
+	for _, fp := range patches {
+		if err := resolvePatchPaths(&fp); err != nil {
+			rejects = append(rejects, err.Error())
+			continue
+		}
+		// Each section is applied to the file on disk, a second one would
+		// undo the first.
+		if dup := samePatchedFile(patched, fp); dup != "" {
+			rejects = append(rejects, fmt.Sprintf("%s: the file is changed by more than one section of the patch, merge them into one", dup))
+			continue
+		}
+		res, rej := applyFilePatch(fp, fuzz, maxOffset)
+		if len(rej) > 0 {
+			rejects = append(rejects, rej...)
+			continue
+		}
+		results = append(results, res)
+	}
+	if len(rejects) > 0 {
+		return "The patch was not applied, no files were changed. Fix the rejected hunks and send the whole patch again:\n\n" +
+			strings.Join(rejects, "\n\n"), nil
+	}
+
+	var touched []string
+	for _, res := range results {
+		if res.fp.oldFull != "" {
+			touched = append(touched, res.fp.oldFull)
+		}
+		if res.fp.newFull != "" && res.fp.newFull != res.fp.oldFull {
+			touched = append(touched, res.fp.newFull)
+		}
+	}
+	if err := snapshotFiles(ToolApplyPatch, touched...); err != nil {
+		return "", err
+	}
+
+	if err := writePatchResults(results); err != nil {
+		return "", fmt.Errorf("the patch was not applied, no files were changed: %w", err)
+	}
+	var summary []string
+	for _, res := range results {
+		summary = append(summary, patchSummary(res))
+	}
+	return "Applied the patch:\n" + strings.Join(summary, "\n"), nil
+}
+
+// samePatchedFile records the paths the file patch touches and returns the
+// one an earlier patch touched too, if any.
+func samePatchedFile(patched map[string]string, fp filePatch) string {
+	for _, p := range []string{fp.oldFull, fp.newFull} {
+		if p == "" {
+			continue
+		}
+		if name, ok := patched[p]; ok {
+			return name
+		}
+	}
+	for _, p := range []string{fp.oldFull, fp.newFull} {
+		if p != "" {
+			patched[p] = utils.Ternary(fp.newPath != "", fp.newPath, fp.oldPath)
+		}
+	}
+	return ""
+}
+
+// writePatchResults writes every file or none: the new contents are staged
+// next to their files first, then moved in place, and the files already
+// changed are restored when a step fails.
+func writePatchResults(results []patchResult) error {
+	staged := make([]string, len(results))
+	var createdDirs []string
+	// rollBack restores the files up to the one at done and removes what was
+	// staged or created for the others.
+	rollBack := func(done int) error {
+		var errs []error
+		for i := done; i >= 0; i-- {
+			errs = append(errs, restorePatchResult(results[i]))
+		}
+		for _, tmp := range staged {
+			if tmp != "" {
+				os.Remove(tmp)
+			}
+		}
+		for i := len(createdDirs) - 1; i >= 0; i-- {
+			os.Remove(createdDirs[i]) // only when it is still empty
+		}
+		return errors.Join(errs...)
+	}
+
+	for i, res := range results {
+		if res.fp.newPath == "" {
+			continue
+		}
+		createdDirs = append(createdDirs, missingDirs(filepath.Dir(res.fp.newFull))...)
+		tmp, err := stageFile(res.fp.newFull, []byte(res.content), res.mode)
+		if err != nil {
+			return errors.Join(err, rollBack(-1))
+		}
+		staged[i] = tmp
+	}
+	for i, res := range results {
+		if err := commitPatchResult(res, staged[i]); err != nil {
+			return errors.Join(err, rollBack(i))
+		}
+		staged[i] = ""
+	}
+	return nil
+}
+
+// movePatchResult moves the staged content of the file in place and removes
+// the deleted or renamed file.
+func movePatchResult(res patchResult, staged string) error {
+	fp := res.fp
+	if fp.newPath != "" {
+		if err := os.Rename(staged, fp.newFull); err != nil {
+			return err
+		}
+	}
+	if fp.oldPath != "" && fp.oldFull != fp.newFull {
+		return os.Remove(fp.oldFull)
+	}
+	return nil
+}
+
+// restorePatchResult puts the file back as it was before the patch.
+func restorePatchResult(res patchResult) error {
+	fp := res.fp
+	if fp.newFull != "" && fp.newFull != fp.oldFull {
+		if err := os.Remove(fp.newFull); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if fp.oldFull != "" {
+		return writeFileAtomic(fp.oldFull, []byte(res.original), res.mode)
+	}
+	return nil
+}
+
+// missingDirs returns the directories of the path that don't exist yet, from
+// the outermost one.
+func missingDirs(dir string) []string {
+	var missing []string
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		missing = append([]string{dir}, missing...)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return missing
+}
+
+func patchSummary(res patchResult) string {
+	fp := res.fp
+	line := ""
+	switch {
+	case fp.newPath == "":
+		return "D " + fp.oldPath
+	case fp.oldPath == "":
+		line = "A " + fp.newPath
+	case fp.oldPath != fp.newPath:
+		line = fmt.Sprintf("R %s -> %s", fp.oldPath, fp.newPath)
+	default:
+		line = "M " + fp.newPath
+	}
+	if len(fp.hunks) > 0 {
+		line += fmt.Sprintf(" (%d hunk(s))", len(fp.hunks))
+	}
+	for _, n := range res.notes {
+		line += "\n  " + n
+	}
+	return line
+}
+
+func applyFilePatch(fp filePatch, fuzz, maxOffset int) (patchResult, []string) {
+	res := patchResult{fp: fp, mode: utils.Ternary(fp.newMode != 0, fp.newMode, 0o644)}
+	name := fp.newPath
+	if name == "" {
+		name = fp.oldPath
+	}
+
+	existing := ""
+	if fp.oldPath != "" {
+		full := fp.oldFull
+		info, err := os.Stat(full)
+		if err != nil {
+			return res, []string{fmt.Sprintf("%s: cannot patch the file: %s", fp.oldPath, err)}
+		}
+		data, err := os.ReadFile(full)
+		if err != nil {
+			return res, []string{fmt.Sprintf("%s: cannot read the file: %s", fp.oldPath, err)}
+		}
+		existing = string(data)
+		res.original = existing
+		res.mode = info.Mode().Perm()
+	}
+	if fp.newPath != "" && fp.newPath != fp.oldPath {
+		if _, err := os.Stat(fp.newFull); err == nil {
+			return res, []string{fmt.Sprintf("%s: the file already exists", fp.newPath)}
+		}
+	}
+
+	eol := "\n"
+	if fp.oldPath != "" {
+		eol = detectEOL(existing)
+	}
+	lines := safeSplit(existing)
+	trailingEOL := len(lines) > 0 && lines[len(lines)-1] == ""
+	if trailingEOL {
+		lines = lines[:len(lines)-1]
+	}
+
+	var rejects []string
+	delta, minPos := 0, 0
+	for i, h := range fp.hunks {
+		oldLines, newLines := hunkSides(h.lines)
+		pos, usedFuzz, ok := locateHunk(lines, h, oldLines, h.oldStart-1+delta, minPos, fuzz, maxOffset)
+		if !ok {
+			rejects = append(rejects, rejectReport(name, i+1, h, lines, h.oldStart-1+delta, fuzz, maxOffset))
+			continue
+		}
+		// With fuzz the ignored context lines stay as they are in the file.
+		head, tail := leadingContext(h.lines, usedFuzz), trailingContext(h.lines, usedFuzz)
+		oldLines = oldLines[head : len(oldLines)-tail]
+		newLines = newLines[head : len(newLines)-tail]
+
+		lines = append(append(append([]string{}, lines[:pos]...), newLines...), lines[pos+len(oldLines):]...)
+		expected := h.oldStart - 1 + delta + head
+		if offset := pos - expected; offset != 0 || usedFuzz > 0 {
+			res.notes = append(res.notes, fmt.Sprintf("hunk #%d applied at offset %+d with fuzz %d", i+1, offset, usedFuzz))
+		}
+		delta += len(newLines) - len(oldLines)
+		minPos = pos + len(newLines)
+	}
+	if len(rejects) > 0 {
+		return res, rejects
+	}
+
+	if fp.newPath == "" {
+		if len(lines) > 0 {
+			return res, []string{fmt.Sprintf("%s: the file is not empty after removing the patch's lines, refusing to delete it", fp.oldPath)}
+		}
+		return res, nil
+	}
+	// A marker means the patch reaches the end of the file, whose new side
+	// decides the final newline.
+	if fp.oldPath == "" || fp.oldNoEOL || fp.newNoEOL {
+		trailingEOL = !fp.newNoEOL
+	}
+	res.content = strings.Join(lines, eol)
+	if trailingEOL && len(lines) > 0 {
+		res.content += eol
+	}
+	return res, nil
+}
+
+// locateHunk finds where the hunk's old side is in the file, trying the
+// expected position first and then moving away from it up to maxOffset lines.
+// When the hunk does not match, its outer context lines are ignored one by
+// one up to fuzz lines. It returns the position of the hunk without the
+// ignored context lines.
+func locateHunk(lines []string, h patchHunk, oldLines []string, expected, minPos, fuzz, maxOffset int) (int, int, bool) {
+	for f := 0; f <= fuzz; f++ {
+		head, tail := leadingContext(h.lines, f), trailingContext(h.lines, f)
+		if f > 0 && (head+tail == 0 || head+tail >= len(oldLines)) {
+			break
+		}
+		want := oldLines[head : len(oldLines)-tail]
+		base := expected + head
+		for off := 0; off <= maxOffset; off++ {
+			for _, pos := range []int{base + off, base - off} {
+				if pos < minPos || pos+len(want) > len(lines) {
+					continue
+				}
+				if linesEqual(lines[pos:pos+len(want)], want) {
+					return pos, f, true
+				}
+				if off == 0 {
+					break
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// leadingContext returns how many of the hunk's leading context lines, up to
+// fuzz, can be ignored.
+func leadingContext(hunkLines []string, fuzz int) int {
+	n := 0
+	for n < fuzz && n < len(hunkLines) && hunkLines[n][0] == ' ' {
+		n++
+	}
+	return n
+}
+
+func trailingContext(hunkLines []string, fuzz int) int {
+	n := 0
+	for n < fuzz && n < len(hunkLines) && hunkLines[len(hunkLines)-1-n][0] == ' ' {
+		n++
+	}
+	return n
+}
+
+func hunkSides(hunkLines []string) ([]string, []string) {
+	var oldLines, newLines []string
+	for _, l := range hunkLines {
+		switch l[0] {
+		case ' ':
+			oldLines = append(oldLines, l[1:])
+			newLines = append(newLines, l[1:])
+		case '-':
+			oldLines = append(oldLines, l[1:])
+		case '+':
+			newLines = append(newLines, l[1:])
+		}
+	}
+	return oldLines, newLines
+}
+
+func linesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func rejectReport(name string, idx int, h patchHunk, lines []string, expected, fuzz, maxOffset int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: hunk #%d FAILED at line %d (no match within %d lines of it, fuzz %d).\n", name, idx, expected+1, maxOffset, fuzz)
+	sb.WriteString(h.header + "\n")
+	sb.WriteString(strings.Join(h.lines, "\n"))
+
+	oldLen := 0
+	for _, l := range h.lines {
+		if l[0] != '+' {
+			oldLen++
+		}
+	}
+	start := max(0, min(expected, len(lines))-3)
+	end := min(len(lines), max(expected, 0)+oldLen+3)
+	if start < end {
+		fmt.Fprintf(&sb, "\nThe file currently has at lines %d-%d:\n", start+1, end)
+		width := len(strconv.Itoa(end))
+		for i := start; i < end; i++ {
+			fmt.Fprintf(&sb, "%*d | %s\n", width, i+1, lines[i])
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func parseUnifiedDiff(patch string) ([]filePatch, error) {
+	lines := safeSplit(patch)
+	var patches []filePatch
+	var cur *filePatch
+	var renameFrom, renameTo string
+
+	flush := func() {
+		if cur != nil {
+			patches = append(patches, *cur)
+			cur = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		switch {
+		case strings.HasPrefix(l, "diff --git "):
+			flush()
+			renameFrom, renameTo = "", ""
+			// The paths are only used for pure renames that have no ---/+++ lines.
+			if parts := strings.Fields(strings.TrimPrefix(l, "diff --git ")); len(parts) == 2 {
+				cur = &filePatch{oldPath: stripPatchPath(parts[0]), newPath: stripPatchPath(parts[1])}
+			}
+
+		// Without ---/+++ lines, e.g. for an empty file, these tell that the
+		// file is created or deleted.
+		case strings.HasPrefix(l, "new file mode "):
+			if cur != nil {
+				cur.oldPath = ""
+				if mode, err := strconv.ParseUint(strings.TrimPrefix(l, "new file mode "), 8, 32); err == nil {
+					cur.newMode = fs.FileMode(mode).Perm()
+				}
+			}
+		case strings.HasPrefix(l, "deleted file mode "):
+			if cur != nil {
+				cur.newPath = ""
+			}
+
+		case strings.HasPrefix(l, "rename from "):
+			renameFrom = strings.TrimPrefix(l, "rename from ")
+		case strings.HasPrefix(l, "rename to "):
+			renameTo = strings.TrimPrefix(l, "rename to ")
+			if cur != nil && renameFrom != "" {
+				cur.oldPath, cur.newPath = renameFrom, renameTo
+			}
+
+		case strings.HasPrefix(l, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if cur == nil || len(cur.hunks) > 0 {
+				flush()
+				cur = &filePatch{}
+			}
+			cur.oldPath = stripPatchPath(strings.TrimPrefix(l, "--- "))
+			cur.newPath = stripPatchPath(strings.TrimPrefix(lines[i+1], "+++ "))
+			i++
+
+		case strings.HasPrefix(l, "@@"):
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: hunk without a file header (--- / +++ lines)", i+1)
+			}
+			m := hunkHeaderRe.FindStringSubmatch(l)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: invalid hunk header %q", i+1, l)
+			}
+			oldStart, _ := strconv.Atoi(m[1])
+			oldCount, newCount := 1, 1
+			if m[2] != "" {
+				oldCount, _ = strconv.Atoi(m[2])
+			}
+			if m[4] != "" {
+				newCount, _ = strconv.Atoi(m[4])
+			}
+			h := patchHunk{oldStart: max(oldStart, 1), header: l}
+			if oldCount == 0 {
+				// An empty old side means the lines go after oldStart.
+				h.oldStart = oldStart + 1
+			}
+			for oldCount > 0 || newCount > 0 {
+				i++
+				if i >= len(lines) {
+					return nil, fmt.Errorf("hunk %q is truncated", l)
+				}
+				hl := lines[i]
+				if hl == "" {
+					// Some models drop the space prefix of empty context lines.
+					hl = " "
+				}
+				switch hl[0] {
+				case ' ':
+					oldCount--
+					newCount--
+				case '-':
+					oldCount--
+				case '+':
+					newCount--
+				case '\\':
+					if len(h.lines) > 0 {
+						cur.markNoEOL(h.lines[len(h.lines)-1])
+					}
+					continue
+				default:
+					return nil, fmt.Errorf("line %d: unexpected line %q in hunk %q", i+1, hl, l)
+				}
+				h.lines = append(h.lines, hl)
+			}
+			if i+1 < len(lines) && strings.HasPrefix(lines[i+1], `\`) {
+				i++
+				cur.markNoEOL(h.lines[len(h.lines)-1])
+			}
+			cur.hunks = append(cur.hunks, h)
+		}
+	}
+	flush()
+
+	if len(patches) == 0 {
+		return nil, errors.New("no file changes found, make sure to send a unified diff with ---/+++ headers and @@ hunks")
+	}
+	for _, fp := range patches {
+		if fp.oldPath == "" && fp.newPath == "" {
+			return nil, errors.New("a file patch is missing its paths")
+		}
+	}
+	return patches, nil
+}
+
+// markNoEOL records which side the "\ No newline at end of file" marker
+// following the hunk line is about, a context line is on both.
+func (fp *filePatch) markNoEOL(line string) {
+	switch line[0] {
+	case '-':
+		fp.oldNoEOL = true
+	case '+':
+		fp.newNoEOL = true
+	default:
+		fp.oldNoEOL, fp.newNoEOL = true, true
+	}
+}
+
+func resolvePatchPaths(fp *filePatch) error {
+	var err error
+	if fp.oldPath != "" {
+		if fp.oldFull, err = resolvePath(fp.oldPath); err != nil {
+			return err
+		}
+	}
+	if fp.newPath != "" {
+		if fp.newFull, err = resolvePath(fp.newPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func stripPatchPath(p string) string {
+	// Drop the optional timestamp that follows a tab.
+	p, _, _ = strings.Cut(p, "\t")
+	p = strings.TrimSpace(p)
+	if p == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(p, "a/") || strings.HasPrefix(p, "b/") {
+		return p[2:]
+	}
+	return p
+}
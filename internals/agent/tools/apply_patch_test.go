@@ -0,0 +1,209 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func TestApplyPatch(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(configs.WorkingPath, name), []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(configs.WorkingPath, name))
+		if err != nil {
+			return "<missing>"
+		}
+		return string(data)
+	}
+	apply := func(patch string) string {
+		argsStr, _ := json.Marshal(ApplyPatchToolArgs{Patch: patch})
+		out, err := handleApplyPatch(string(argsStr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	// two lines were added on top since the diff was made, and its first context line is stale
+	write("run.sh", "#!/bin/sh\r\nset -e\r\necho one\r\necho two\r\necho three\r\necho four\r\n")
+	write("old.txt", "keep me\n")
+	write("gone.txt", "bye\n")
+	patch := `diff --git a/run.sh b/run.sh
+--- a/run.sh
++++ b/run.sh
+@@ -1,3 +1,3 @@
+ echo zero
+-echo two
++echo TWO
+ echo three
+diff --git a/new/file.txt b/new/file.txt
+new file mode 100644
+--- /dev/null
++++ b/new/file.txt
+@@ -0,0 +1,2 @@
++hello
++world
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/old.txt b/renamed.txt
+similarity index 100%
+rename from old.txt
+rename to renamed.txt
+`
+	out := apply(patch)
+	if !strings.Contains(out, "hunk #1 applied at offset +2 with fuzz 1") {
+		t.Fatalf("expected the offset and fuzz to be reported, got %q", out)
+	}
+	if got := read("run.sh"); got != "#!/bin/sh\r\nset -e\r\necho one\r\necho TWO\r\necho three\r\necho four\r\n" {
+		t.Fatalf("unexpected run.sh %q", got)
+	}
+	if info, _ := os.Stat(filepath.Join(configs.WorkingPath, "run.sh")); info.Mode().Perm() != 0o755 {
+		t.Fatalf("expected the file mode to be preserved, got %v", info.Mode())
+	}
+	if read("new/file.txt") != "hello\nworld\n" || read("gone.txt") != "<missing>" ||
+		read("old.txt") != "<missing>" || read("renamed.txt") != "keep me\n" {
+		t.Fatalf("unexpected result of the file operations: %q", out)
+	}
+
+	// one bad hunk rejects the whole patch
+	out = apply(`--- a/run.sh
++++ b/run.sh
+@@ -4,1 +4,1 @@
+-echo TWO
++echo 2
+--- a/renamed.txt
++++ b/renamed.txt
+@@ -1 +1 @@
+-not there
++whatever
+`)
+	if !strings.Contains(out, "renamed.txt: hunk #1 FAILED") || !strings.Contains(out, "1 | keep me") {
+		t.Fatalf("expected a rejection report with the file's lines, got %q", out)
+	}
+	if !strings.Contains(read("run.sh"), "echo TWO") {
+		t.Fatal("expected no file to change when a hunk is rejected")
+	}
+}
+
+func TestApplyPatchFinalNewline(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	p := filepath.Join(configs.WorkingPath, "a.txt")
+	for _, tc := range []struct{ name, before, patch, after string }{
+		{"added", "one\nfoo", "@@ -1,2 +1,2 @@\n one\n-foo\n\\ No newline at end of file\n+foo\n", "one\nfoo\n"},
+		{"removed", "one\nfoo\n", "@@ -1,2 +1,2 @@\n one\n-foo\n+foo\n\\ No newline at end of file\n", "one\nfoo"},
+		{"missing on both sides", "one\nfoo", "@@ -1,2 +1,2 @@\n-one\n+ONE\n foo\n\\ No newline at end of file\n", "ONE\nfoo"},
+		{"kept", "one\nfoo\n", "@@ -1 +1 @@\n-one\n+ONE\n", "ONE\nfoo\n"},
+	} {
+		if err := os.WriteFile(p, []byte(tc.before), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		argsJSON, _ := json.Marshal(ApplyPatchToolArgs{Patch: "--- a/a.txt\n+++ b/a.txt\n" + tc.patch})
+		out, err := handleApplyPatch(string(argsJSON))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(p); string(data) != tc.after {
+			t.Errorf("%s: got %q, want %q (%s)", tc.name, data, tc.after, out)
+		}
+	}
+}
+
+func TestApplyPatchIsAllOrNothing(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(configs.WorkingPath, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(configs.WorkingPath, name))
+		if err != nil {
+			return "<missing>"
+		}
+		return string(data)
+	}
+	apply := func(patch string) (string, error) {
+		argsStr, _ := json.Marshal(ApplyPatchToolArgs{Patch: patch})
+		return handleApplyPatch(string(argsStr))
+	}
+	write("a.txt", "one\ntwo\n")
+	write("b.txt", "bye\n")
+	write("empty.txt", "")
+
+	// Empty files are created and deleted with the git headers alone.
+	out, err := apply(`diff --git a/new.txt b/new.txt
+new file mode 100755
+diff --git a/empty.txt b/empty.txt
+deleted file mode 100644
+`)
+	if err != nil || !strings.Contains(out, "A new.txt") || !strings.Contains(out, "D empty.txt") {
+		t.Fatalf("unexpected result %q, %v", out, err)
+	}
+	info, err := os.Stat(filepath.Join(configs.WorkingPath, "new.txt"))
+	if err != nil || info.Size() != 0 || info.Mode().Perm() != 0o755 || read("empty.txt") != "<missing>" {
+		t.Fatalf("expected an empty executable new.txt and no empty.txt, got %v, %v", info, err)
+	}
+
+	// Two sections of the same file would overwrite each other.
+	out, _ = apply(`--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-one
++ONE
+--- a/a.txt
++++ b/a.txt
+@@ -2 +2 @@
+-two
++TWO
+`)
+	if !strings.Contains(out, "a.txt: the file is changed by more than one section") || read("a.txt") != "one\ntwo\n" {
+		t.Fatalf("expected the patch to be rejected, got %q", out)
+	}
+
+	// A failing write puts back the files written before it.
+	calls := 0
+	commitPatchResult = func(res patchResult, staged string) error {
+		if calls++; calls == 3 {
+			return errors.New("disk full")
+		}
+		return movePatchResult(res, staged)
+	}
+	t.Cleanup(func() { commitPatchResult = movePatchResult })
+	_, err = apply(`--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-one
++ONE
+--- /dev/null
++++ b/dir/sub/c.txt
+@@ -0,0 +1 @@
++new
+--- a/b.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+`)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("expected the write to fail, got %v", err)
+	}
+	if read("a.txt") != "one\ntwo\n" || read("b.txt") != "bye\n" {
+		t.Errorf("expected the files to be restored, got %q and %q", read("a.txt"), read("b.txt"))
+	}
+	if entries, _ := os.ReadDir(configs.WorkingPath); len(entries) != 3 {
+		t.Errorf("expected no new or temporary files to be left, got %v", entries)
+	}
+}
@@ -1,114 +1,103 @@
-//////////////////
-// Deprecated
-//////////////////
-
 package tools
 
 import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os/exec"
-	"path/filepath"
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
 )
 
-// A conservative bash tool that executes a small, safe subset
-// of commands within WorkingPath. It blocks absolute paths, path traversal,
-// pipes, redirects, backgrounding, subshells, and env expansion.
-func handleBash(argsJSON string) (string, error) {
-	var args struct {
-		Cmd string `json:"cmd"`
+const (
+	defaultCommandTimeout = 2 * time.Minute
+	maxCommandTimeout     = 10 * time.Minute
+	maxCommandOutputHead  = 8 * 1024
+	maxCommandOutputTail  = 8 * 1024
+)
+
+type BashToolArgs struct {
+	Cmd     string `json:"cmd"`
+	Timeout int    `json:"timeout"` // seconds
+}
+
+var (
+	// shell is the session's shell, started by the first command.
+	shell         *Shell
+	sandboxConfig sandbox.Config
+)
+
+// UseSandbox makes the following commands run in the given sandbox. The
+// shell is restarted, in the same directory, for the change to take effect
+// right away.
+func UseSandbox(cfg sandbox.Config) {
+	sandboxConfig = cfg
+	if shell != nil {
+		dir := shell.Dir()
+		shell.Close()
+		shell = NewShell(dir, cfg)
+	}
+}
+
+// SandboxConfig returns the sandbox the commands run in.
+func SandboxConfig() sandbox.Config {
+	return sandboxConfig
+}
+
+// CloseShell kills the session's shell along with everything running in it.
+func CloseShell() {
+	if shell != nil {
+		shell.Close()
+		shell = nil
 	}
+}
+
+func handleBash(argsJSON string) (string, error) {
+	var args BashToolArgs
 	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 		return "", err
 	}
-	cmdline := strings.TrimSpace(args.Cmd)
-	if cmdline == "" {
+	if strings.TrimSpace(args.Cmd) == "" {
 		return "", errors.New("no command provided")
 	}
-
-	// Disallow potentially dangerous shell features outright.
-	// This tool executes without a shell, but we sanitize anyway.
-	forbiddenChars := []string{";", "`", "$", ">", "<", "\n", "\r", "(", ")"}
-	for _, ch := range forbiddenChars {
-		if strings.Contains(cmdline, ch) {
-			return "", fmt.Errorf("unsupported character %q in command", ch)
-		}
-	}
-
-	// Tokenize conservatively by whitespace. Quoting is not supported.
-	tokens := strings.Fields(cmdline)
-	if len(tokens) == 0 {
-		return "", errors.New("empty command")
+	timeout := defaultCommandTimeout
+	if args.Timeout > 0 {
+		timeout = min(time.Duration(args.Timeout)*time.Second, maxCommandTimeout)
 	}
 
-	cmdName := tokens[0]
-	argsList := tokens[1:]
-
-	// Whitelist of allowed commands:
-	allowed := map[string]bool{
-		"ls": true, "find": true, "stat": true, "readlink": true, "basename": true,
-		"dirname": true, "echo": true, "mkdir": true, "touch": true, "mv": true,
-		"rm": true, "rmdir": true, "cd": true,
-		"python3": true, "go": true, "node": true, "npm": true, "pip": true, "pnpm": true, "bun": true,
+	if shell == nil {
+		shell = NewShell(configs.WorkingPath, sandboxConfig)
 	}
-	if !allowed[cmdName] {
-		return "", fmt.Errorf("command %q is not allowed", cmdName)
+	res, err := shell.Run(toolCtx, args.Cmd, timeout, liveOutput)
+	if err != nil {
+		return "", err
 	}
+	return formatCommandResult(res, timeout, shell.Dir()), nil
+}
 
-	// Additional guardrails:
-	// - Block sed -i (in-place) edits
-	if cmdName == "sed" {
-		for _, a := range argsList {
-			if strings.HasPrefix(a, "-i") {
-				return "", errors.New("sed -i is not allowed")
-			}
-		}
-	}
-	// - Block find -exec / -ok / -delete
-	if cmdName == "find" {
-		for _, a := range argsList {
-			if a == "-exec" || a == "-ok" || a == "-delete" {
-				return "", errors.New("find with -exec/-ok/-delete is not allowed")
-			}
-		}
+func formatCommandResult(res CommandResult, timeout time.Duration, dir string) string {
+	var sb strings.Builder
+	sb.WriteString("<output>\n")
+	if out := strings.TrimRight(res.Output.String(), "\n"); out != "" {
+		sb.WriteString(out + "\n")
 	}
-	// - Basic guardrails for rm to avoid unexpected long options
-	if cmdName == "rm" {
-		for _, a := range argsList {
-			if strings.HasPrefix(a, "--") {
-				return "", errors.New("rm long options are not allowed")
-			}
-		}
+	sb.WriteString("</output>\n")
+	if res.Output.Truncated() {
+		fmt.Fprintf(&sb, "The output was %d bytes in total, only its first and last %d bytes are shown.\n", res.Output.Total(), maxCommandOutputTail)
 	}
 
-	// Validate path-like arguments: no absolute paths, no .. traversal.
-	// Heuristic: treat any token containing '/' or starting with '.' as path-like.
-	// Also validate -f/-o style options that may combine with a path (basic): skip options.
-	dotdot := regexp.MustCompile(`(^|/)\.\.(?:/|$)`) // contains ../ or /../ boundaries
-	for _, t := range argsList {
-		if strings.HasPrefix(t, "-") { // flags
-			continue
-		}
-		if strings.HasPrefix(t, "/") {
-			return "", fmt.Errorf("absolute paths are not allowed: %q", t)
-		}
-		if dotdot.MatchString(filepath.ToSlash(t)) {
-			return "", fmt.Errorf("path traversal is not allowed: %q", t)
-		}
+	switch {
+	case res.TimedOut:
+		fmt.Fprintf(&sb, "The command did not finish within %s and was killed.", timeout)
+	case res.Cancelled:
+		sb.WriteString("The command was cancelled by the user and was killed.")
+	default:
+		fmt.Fprintf(&sb, "Exit code: %d", res.ExitCode)
 	}
-
-	// Resolve binary and execute without a shell for safety.
-	if _, err := exec.LookPath(cmdName); err != nil {
-		return "", fmt.Errorf("command not found: %s", cmdName)
+	if res.Restarted {
+		fmt.Fprintf(&sb, "\nThe shell was restarted in %s, its variables and functions were lost.", dir)
 	}
-	cmd := exec.Command(cmdName, argsList...)
-	cmd.Dir = configs.WorkingPath
-	out, _ := cmd.CombinedOutput()
-	// Return output even on non-zero exit (e.g., grep no match), surface error as text only.
-	return string(out), nil
+	return sb.String()
 }
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/lsp/lsptest"
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
+)
+
+func TestMain(m *testing.M) {
+	sandbox.MaybeExec()
+	lsptest.ServeIfRequested()
+	os.Exit(m.Run())
+}
+
+func runBash(t *testing.T, args BashToolArgs) string {
+	t.Helper()
+	argsJSON, _ := json.Marshal(args)
+	out, err := handleBash(string(argsJSON))
+	if err != nil {
+		t.Fatalf("bash failed: %v", err)
+	}
+	return out
+}
+
+func TestBashSessionPersists(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	if err := os.Mkdir(filepath.Join(configs.WorkingPath, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(CloseShell)
+
+	runBash(t, BashToolArgs{Cmd: "cd sub && export GREETING='hi there'"})
+	out := runBash(t, BashToolArgs{Cmd: `echo "$GREETING from $(basename "$PWD")" | tr a-z A-Z`})
+	if !strings.Contains(out, "<output>\nHI THERE FROM SUB\n</output>") || !strings.HasSuffix(out, "Exit code: 0") {
+		t.Fatalf("expected the directory and variables to persist, got:\n%s", out)
+	}
+
+	out = runBash(t, BashToolArgs{Cmd: "echo oops >&2; false"})
+	if !strings.Contains(out, "oops") || !strings.HasSuffix(out, "Exit code: 1") {
+		t.Fatalf("expected stderr and the exit code, got:\n%s", out)
+	}
+
+	out = runBash(t, BashToolArgs{Cmd: "echo 'unterminated"})
+	if strings.HasSuffix(out, "Exit code: 0") {
+		t.Fatalf("expected a syntax error, got:\n%s", out)
+	}
+	out = runBash(t, BashToolArgs{Cmd: "printf 'no newline'"})
+	if !strings.Contains(out, "<output>\nno newline\n</output>") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	out = runBash(t, BashToolArgs{Cmd: "exit 3"})
+	if !strings.Contains(out, "Exit code: 3") || !strings.Contains(out, "The shell was restarted") {
+		t.Fatalf("expected the exit to be reported, got:\n%s", out)
+	}
+	out = runBash(t, BashToolArgs{Cmd: "basename \"$PWD\"; echo \"[$GREETING]\""})
+	if !strings.Contains(out, "sub\n[]") {
+		t.Fatalf("expected the restarted shell to keep only the directory, got:\n%s", out)
+	}
+}
+
+func TestBashTimeoutAndCancel(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	t.Cleanup(CloseShell)
+
+	start := time.Now()
+	out := runBash(t, BashToolArgs{Cmd: "echo started; sleep 30 & sleep 30", Timeout: 1})
+	if time.Since(start) > 10*time.Second {
+		t.Fatal("expected the command to be killed after the timeout")
+	}
+	if !strings.Contains(out, "started") || !strings.Contains(out, "did not finish within 1s") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if out := runBash(t, BashToolArgs{Cmd: "echo alive"}); !strings.Contains(out, "alive") {
+		t.Fatalf("expected the shell to be restarted, got:\n%s", out)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var streamed []string
+	UseContext(ctx, func(output string) {
+		streamed = append(streamed, output)
+		if strings.Contains(output, "tick") {
+			cancel()
+		}
+	})
+	t.Cleanup(func() { UseContext(context.Background(), nil) })
+	out = runBash(t, BashToolArgs{Cmd: "while true; do echo tick; sleep 0.2; done"})
+	if !strings.Contains(out, "cancelled by the user") || len(streamed) < 1 {
+		t.Fatalf("expected the command to be cancelled while streaming, got:\n%s", out)
+	}
+}
+
+func TestBashSandboxed(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	outside := t.TempDir()
+	cfg := sandbox.Config{Mode: sandbox.ModeWorkspace, WritableRoots: []string{configs.WorkingPath}}
+	if err := cfg.Check(); err != nil {
+		t.Skip("the sandbox is not available:", err)
+	}
+	runBash(t, BashToolArgs{Cmd: "mkdir sub && cd sub"})
+	UseSandbox(cfg)
+	t.Cleanup(func() {
+		UseSandbox(sandbox.Config{})
+		CloseShell()
+	})
+
+	out := runBash(t, BashToolArgs{Cmd: "basename \"$PWD\" && touch ok.txt && touch " + outside + "/no.txt"})
+	if !strings.Contains(out, "sub\n") || !strings.Contains(out, "Permission denied") || strings.HasSuffix(out, "Exit code: 0") {
+		t.Fatalf("expected only the workspace to be writable, got:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(configs.WorkingPath, "sub", "ok.txt")); err != nil {
+		t.Fatal("expected the write inside the workspace to succeed")
+	}
+}
+
+func TestOutputBuffer(t *testing.T) {
+	b := newOutputBuffer(10, 10)
+	for range 100 {
+		b.Write([]byte("0123456789"))
+	}
+	b.Write([]byte("the end"))
+	if !b.Truncated() || b.Total() != 1007 {
+		t.Fatalf("unexpected buffer state: truncated=%v total=%d", b.Truncated(), b.Total())
+	}
+	if got := b.String(); got != "0123456789\n\n... [987 bytes omitted] ...\n\n789the end" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
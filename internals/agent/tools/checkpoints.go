@@ -0,0 +1,33 @@
+package tools
+
+import (
+	"fmt"
+
+	"github.com/sifatulrabbi/cli-agent/internals/db"
+)
+
+var (
+	checkpointStore *db.CheckpointStore
+	checkpointTurn  int
+)
+
+// UseCheckpoints makes the mutating tools snapshot the files they are about to
+// modify into the store. The snapshots are recorded as part of the given turn.
+func UseCheckpoints(store *db.CheckpointStore, turn int) {
+	checkpointStore = store
+	checkpointTurn = turn
+}
+
+// snapshotFiles must be called by every mutating tool before it touches the
+// given (absolute) paths. The tool must not make the change when it fails, it
+// couldn't be undone.
+func snapshotFiles(toolName string, paths ...string) error {
+	if checkpointStore != nil {
+		if err := checkpointStore.Snapshot(checkpointTurn, toolName, paths...); err != nil {
+			return fmt.Errorf("failed to checkpoint the files before changing them, nothing was changed: %w", err)
+		}
+	}
+	forgetIgnoreMatchers()
+	recordEdits(paths...)
+	return nil
+}
@@ -0,0 +1,18 @@
+package tools
+
+import "context"
+
+var (
+	toolCtx    = context.Background()
+	liveOutput = func(output string) {}
+)
+
+// UseContext makes the long running tools stop as soon as ctx is cancelled
+// and report their output, as it is so far, to onOutput while they run.
+func UseContext(ctx context.Context, onOutput func(output string)) {
+	toolCtx = ctx
+	liveOutput = onOutput
+	if liveOutput == nil {
+		liveOutput = func(string) {}
+	}
+}
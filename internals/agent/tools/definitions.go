@@ -0,0 +1,383 @@
+package tools
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// ToolDefinition describes a tool to the model. Parameters is a JSON schema
+// of the arguments the tool's handler expects.
+type ToolDefinition struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+// Definitions are the tools advertised to the model. Every definition must
+// have a matching entry in Handlers.
+var Definitions = []ToolDefinition{
+	{
+		Name: ToolListFiles,
+		Description: "List a directory of the project as a tree, with the number of entries of each directory and the size of each file. " +
+			"Ignored files (.gitignore, .agentignore) are skipped and directories with many files are summarized by file type. " +
+			"Long listings are paginated, call ls again with the returned cursor to see the rest.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "description": "The directory to list. Defaults to the project root."},
+				"maxDepth": {"type": "integer", "description": "How many levels of directories to expand. Defaults to 3, at most 10."},
+				"include": {"type": "array", "items": {"type": "string"}, "description": "Only list files matching one of these globs (e.g. *.go, cmd/**/*.ts)."},
+				"exclude": {"type": "array", "items": {"type": "string"}, "description": "Skip files and directories matching one of these globs."},
+				"limit": {"type": "integer", "description": "The maximum number of lines to return. Defaults to 200, max 1000."},
+				"cursor": {"type": "string", "description": "The cursor returned by a previous ls call, to continue its listing."}
+			}
+		}`),
+	},
+	{
+		Name: ToolReadFiles,
+		Description: "Use this to read multiple files at once, safely, and securely. This is a must use for reading files of the project! " +
+			"Lines are numbered and long reads are cut off at line boundaries with the offset to continue from. " +
+			"Binary, minified and very large files are described instead of shown.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"filePaths": {
+					"type": "array",
+					"description": "A list of read operations.",
+					"items": {
+						"type": "object",
+						"properties": {
+							"filePath": {"type": "string", "description": "The path of the file. Make sure to include the entire path from ./ till the file."},
+							"offset": {"type": "integer", "description": "The line number to start reading from (1-based). Defaults to the first line."},
+							"limit": {"type": "integer", "description": "The maximum number of lines to read. Defaults to 2000."}
+						},
+						"required": ["filePath"]
+					}
+				}
+			},
+			"required": ["filePaths"]
+		}`),
+	},
+	{
+		Name: ToolAppendFile,
+		Description: "Insert content into a text file in the project. Must provide the full path. " +
+			"(Note: the full path can be obtained by using the 'ls' tool.)",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"filePath": {"type": "string", "description": "The path of the file to insert into"},
+				"inserts": {
+					"type": "array",
+					"items": {
+						"type": "object",
+						"properties": {
+							"insertAfter": {"type": "integer", "description": "The line number after which to insert the content."},
+							"content": {"type": "string", "description": "The content to insert"}
+						},
+						"required": ["insertAfter", "content"]
+					}
+				}
+			},
+			"required": ["filePath", "inserts"]
+		}`),
+	},
+	{
+		Name: ToolPatchFile,
+		Description: "Patch a text file by replacing existing line ranges only. " +
+			"Insertion is not supported here; use 'append_file' for insertions. " +
+			"Prefer 'edit_file' whenever the line numbers might be stale.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"filePath": {"type": "string", "description": "The path of the file to patch"},
+				"patches": {
+					"type": "array",
+					"items": {
+						"type": "object",
+						"properties": {
+							"startLine": {"type": "integer", "description": "The start line of the range to replace (1-based)"},
+							"endLine": {"type": "integer", "description": "The end line of the range to replace (1-based)"},
+							"content": {"type": "string", "description": "Replacement content. Use empty string to delete the specified range."}
+						},
+						"required": ["startLine", "endLine", "content"]
+					}
+				}
+			},
+			"required": ["filePath", "patches"]
+		}`),
+	},
+	{
+		Name: ToolEditFile,
+		Description: "Edit a text file by replacing existing text. Each oldText must match the file's current content exactly once " +
+			"(include enough surrounding lines to make it unique) unless replaceAll is set. " +
+			"All edits are applied together or none at all. Returns a diff of the change.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"filePath": {"type": "string", "description": "The path of the file to edit"},
+				"edits": {
+					"type": "array",
+					"items": {
+						"type": "object",
+						"properties": {
+							"oldText": {"type": "string", "description": "The exact text to replace, including indentation."},
+							"newText": {"type": "string", "description": "The replacement text. Use empty string to delete oldText."},
+							"replaceAll": {"type": "boolean", "description": "Replace every occurrence of oldText instead of requiring a unique match."}
+						},
+						"required": ["oldText", "newText"]
+					}
+				}
+			},
+			"required": ["filePath", "edits"]
+		}`),
+	},
+	{
+		Name: ToolApplyPatch,
+		Description: "Apply a unified diff (as produced by 'git diff' or 'diff -u') to one or more files. " +
+			"Supports creating (--- /dev/null), deleting (+++ /dev/null) and renaming files. " +
+			"Hunks may be slightly off: they are searched around the stated line numbers and up to 'fuzz' outer context lines may be ignored. " +
+			"Either every hunk applies or no file is changed; rejected hunks are reported with the file's current lines.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"patch": {"type": "string", "description": "The unified diff, with ---/+++ file headers and @@ hunks."},
+				"fuzz": {"type": "integer", "description": "How many leading/trailing context lines of a hunk may be ignored when it does not match. Defaults to 2."},
+				"maxOffset": {"type": "integer", "description": "How many lines away from the stated position a hunk may be found. Defaults to 200."}
+			},
+			"required": ["patch"]
+		}`),
+	},
+	{
+		Name:        ToolWriteFile,
+		Description: "Create a file with the given content, along with its missing parent directories. Refuses to replace an existing file unless overwrite is set; prefer edit_file for changing existing files.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"filePath": {"type": "string", "description": "The path of the file to create."},
+				"content": {"type": "string", "description": "The full content of the file."},
+				"overwrite": {"type": "boolean", "description": "Replace the file if it already exists."}
+			},
+			"required": ["filePath", "content"]
+		}`),
+	},
+	{
+		Name:        ToolDeletePath,
+		Description: "Delete a file or a directory. Directories that are not empty are only deleted when recursive is set.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "description": "The path of the file or directory to delete."},
+				"recursive": {"type": "boolean", "description": "Delete a directory with everything in it."}
+			},
+			"required": ["path"]
+		}`),
+	},
+	{
+		Name:        ToolMovePath,
+		Description: "Move or rename a file or a directory. The destination is the full new path, not the directory to move into; missing parent directories are created.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"source": {"type": "string", "description": "The path of the file or directory to move."},
+				"destination": {"type": "string", "description": "The new path."},
+				"overwrite": {"type": "boolean", "description": "Replace the destination file if it already exists."}
+			},
+			"required": ["source", "destination"]
+		}`),
+	},
+	{
+		Name:        ToolCopyPath,
+		Description: "Copy a file or a directory with its content, keeping the file modes. The destination is the full path of the copy; missing parent directories are created.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"source": {"type": "string", "description": "The path of the file or directory to copy."},
+				"destination": {"type": "string", "description": "The path of the copy."},
+				"overwrite": {"type": "boolean", "description": "Replace the destination file if it already exists."}
+			},
+			"required": ["source", "destination"]
+		}`),
+	},
+	{
+		Name:        ToolSearch,
+		Description: "Search the contents of the project's files with a regular expression or a literal string. Ignored files (.gitignore, .agentignore) and binary files are skipped. Results are grouped by file with line numbers.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"pattern": {"type": "string", "description": "The RE2 regular expression (or literal text when literal is set) to search for."},
+				"literal": {"type": "boolean", "description": "Treat the pattern as plain text instead of a regular expression."},
+				"caseInsensitive": {"type": "boolean", "description": "Match without regard to letter case."},
+				"path": {"type": "string", "description": "The directory or file to search in. Defaults to the project root."},
+				"include": {"type": "array", "items": {"type": "string"}, "description": "Only search files matching one of these globs (e.g. *.go, internals/**/*.ts)."},
+				"exclude": {"type": "array", "items": {"type": "string"}, "description": "Skip files matching one of these globs."},
+				"contextLines": {"type": "integer", "description": "How many lines to show before and after each match (max 10)."},
+				"maxResults": {"type": "integer", "description": "The maximum number of matches to return. Defaults to 100."},
+				"filesOnly": {"type": "boolean", "description": "Only list the matching files with their match counts."}
+			},
+			"required": ["pattern"]
+		}`),
+	},
+	{
+		Name:        ToolCodeOutline,
+		Description: "List the declarations of a Go file (functions, methods, types, interface methods, constants and variables) with their signatures and line ranges. Use it to find the part of a file to read instead of reading the whole file.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"filePath": {"type": "string", "description": "The path of the Go file."}
+			},
+			"required": ["filePath"]
+		}`),
+	},
+	{
+		Name: ToolFindSymbol,
+		Description: "Navigate Go code with the type checker: show the definition of a symbol, list its references, the implementations of an interface (or the interfaces a type implements), or the method set of a type. " +
+			"Symbols are written as Name, pkg.Name, Type.Method or pkg.Type.Method, e.g. 'ModelProvider.Invoke'.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"symbol": {"type": "string", "description": "The symbol to look up."},
+				"action": {"type": "string", "enum": ["definition", "references", "implementations", "methods"], "description": "What to find. Defaults to definition."},
+				"path": {"type": "string", "description": "A file or directory inside the Go module to search. Defaults to the project root."},
+				"maxResults": {"type": "integer", "description": "The maximum number of references or implementations to list. Defaults to 100."}
+			},
+			"required": ["symbol"]
+		}`),
+	},
+	{
+		Name:        ToolDiagnostics,
+		Description: "Get the errors and warnings the language servers (e.g. gopls, pyright) report for files. The file editing tools already report the new problems an edit introduces; use this to check files on demand, e.g. before finishing a task or after changing files with bash. Without paths, lists the problems of every file the running servers know about.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"paths": {"type": "array", "items": {"type": "string"}, "description": "The files to check."}
+			}
+		}`),
+	},
+	{
+		Name:        ToolBash,
+		Description: "Run a command in a persistent bash shell that starts in the project root. The working directory, exported variables and functions persist between calls. Pipes, redirects and quoting work as usual, but the command gets no stdin so avoid interactive programs. Long outputs are shortened to their beginning and end. Commands may run in a sandbox where writing outside of the project or using the network fails with 'Operation not permitted'; ask the user to change the sandbox when that is needed.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"cmd": {"type": "string", "description": "The command to run (e.g., go test ./... 2>&1 | tail -n 50)."},
+				"timeout": {"type": "integer", "description": "How many seconds the command may run before it is killed. Defaults to 120, at most 600."}
+			},
+			"required": ["cmd"]
+		}`),
+	},
+	{
+		Name: ToolRunTests,
+		Description: "Run the project's tests and get a summary: the passed, failed and skipped counts, the failing tests with their output and file:line, and the packages or files that failed to build. " +
+			"Detects go test, pytest, jest and vitest from the closest go.mod, package.json or pytest configuration. Prefer this over running the tests with bash.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "description": "A package directory, test file or pytest node id (e.g. tests/test_api.py::test_get) to run the tests of. Append /... to include the Go packages below a directory. Defaults to every test of the project."},
+				"name": {"type": "string", "description": "Only run the tests matching this name: a -run regular expression for Go (TestFoo or TestFoo/case match exactly), a -k expression for pytest, a -t pattern for jest and vitest."},
+				"framework": {"type": "string", "enum": ["go", "pytest", "jest", "vitest"], "description": "The test framework, when it can't be detected."},
+				"timeout": {"type": "integer", "description": "How many seconds the tests may run before they are killed. Defaults to 300, at most 600."}
+			}
+		}`),
+	},
+	{
+		Name: ToolGit,
+		Description: "Inspect and use the project's git repository: status (staged, unstaged and untracked files), diff (of the working tree, the staged changes or between refs, optionally per file), log (filtered by author, date, message or path), show (a commit and its diff), blame (a line range of a file), branches, commit, and stash (list, push, pop, apply, drop). " +
+			"Committing and changing stashes ask the user for permission first. Prefer this over running git with bash.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"command": {"type": "string", "enum": ["status", "diff", "log", "show", "blame", "branches", "commit", "stash"]},
+				"paths": {"type": "array", "items": {"type": "string"}, "description": "Limit diff, log and show to these paths, the file to blame, or the files to stage before a commit or to stash."},
+				"ref": {"type": "string", "description": "diff: the ref to compare the working tree with, two refs or a range like main...HEAD. log: where to start. show: the commit, defaults to HEAD. blame: the revision. stash: the stash to apply or drop, e.g. stash@{1}."},
+				"staged": {"type": "boolean", "description": "diff: show the staged changes instead of the unstaged ones."},
+				"maxCount": {"type": "integer", "description": "log: how many commits to list. Defaults to 20, at most 200."},
+				"skip": {"type": "integer", "description": "log: how many commits to skip, to page through the history."},
+				"author": {"type": "string", "description": "log: only commits by authors matching this."},
+				"since": {"type": "string", "description": "log: only commits after this date, e.g. 2024-01-31 or '2 weeks ago'."},
+				"until": {"type": "string", "description": "log: only commits before this date."},
+				"grep": {"type": "string", "description": "log: only commits whose message matches this regular expression, ignoring case."},
+				"startLine": {"type": "integer", "description": "blame: the first line (1-based)."},
+				"endLine": {"type": "integer", "description": "blame: the last line (inclusive)."},
+				"remote": {"type": "boolean", "description": "branches: list the remote branches too."},
+				"message": {"type": "string", "description": "commit: the commit message. stash push: the stash message."},
+				"all": {"type": "boolean", "description": "commit: commit every change to a tracked file, not only the staged ones."},
+				"action": {"type": "string", "enum": ["list", "push", "pop", "apply", "drop"], "description": "stash: what to do. Defaults to list."},
+				"untracked": {"type": "boolean", "description": "stash push: stash the untracked files too."}
+			},
+			"required": ["command"]
+		}`),
+	},
+	{
+		Name: ToolWebFetch,
+		Description: "Fetch a web page and read it as Markdown, with the navigation, scripts and styles left out. JSON and plain text are shown as they are. " +
+			"Use it to read documentation, issues or API references the user points to. Long pages are shown in parts, the fetched pages are cached for the session.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"url": {"type": "string", "description": "The http or https URL to fetch."},
+				"offset": {"type": "integer", "description": "The line of the page to start from (1-based), to continue reading a long page."}
+			},
+			"required": ["url"]
+		}`),
+	},
+	{
+		Name: ToolWebSearch,
+		Description: "Search the web and get a ranked list of results with their title, URL and a snippet. " +
+			"Use it to find documentation, error messages or recent information, then read the relevant results with web_fetch.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"query": {"type": "string", "description": "The search query."},
+				"maxResults": {"type": "integer", "description": "How many results to return. Defaults to 8, at most 20."}
+			},
+			"required": ["query"]
+		}`),
+	},
+	{
+		Name: ToolTodoWrite,
+		Description: "Plan multi-step work as a todo list the user sees while you work. Every call replaces the whole list: pass every todo, keeping the ids of the existing ones, to add, edit, reorder or remove todos and to change their status. " +
+			"Mark a todo in_progress before starting it and completed as soon as it is done, keep only one in_progress at a time, and cancel the ones that are no longer needed. Skip the list for simple requests.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"todos": {
+					"type": "array",
+					"description": "The whole todo list, in the order the work should be done.",
+					"items": {
+						"type": "object",
+						"properties": {
+							"id": {"type": "integer", "description": "The id of an existing todo, leave it out for a new one."},
+							"content": {"type": "string", "description": "What needs to be done."},
+							"status": {"type": "string", "enum": ["pending", "in_progress", "completed", "cancelled"], "description": "Defaults to pending."}
+						},
+						"required": ["content"]
+					}
+				}
+			},
+			"required": ["todos"]
+		}`),
+	},
+	{
+		Name: ToolReadOutput,
+		Description: "Read a tool output that was too large to be shown whole and was saved with an id (e.g. out-3). " +
+			"Page through it with offset and limit, or pass a regular expression as the pattern to list its matching lines with their line numbers.",
+		Parameters: schema(`{
+			"type": "object",
+			"properties": {
+				"id": {"type": "string", "description": "The id of the saved output."},
+				"offset": {"type": "integer", "description": "The 1-based line to start reading or searching from. Defaults to 1."},
+				"limit": {"type": "integer", "description": "The maximum number of lines, or of matches with a pattern. Defaults to 200."},
+				"pattern": {"type": "string", "description": "A regular expression (Go syntax) to search for instead of reading the lines in order."}
+			},
+			"required": ["id"]
+		}`),
+	},
+}
+
+func schema(s string) map[string]any {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		log.Panicln("Invalid tool schema:", err)
+	}
+	return m
+}
@@ -0,0 +1,190 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sifatulrabbi/cli-agent/internals/lsp"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+const maxDiagnosticsPerFile = 20
+
+type DiagnosticsToolArgs struct {
+	Paths []string `json:"paths"`
+}
+
+var (
+	languageServers *lsp.Manager
+	// diagnosticsBefore are the problems the edited files had before the
+	// running tool call modified them.
+	diagnosticsBefore = map[string][]lsp.Diagnostic{}
+)
+
+// UseLanguageServers makes the file tools report the problems their edits
+// introduce, as reported by the language servers of the manager. The servers
+// of the previous manager are shut down.
+func UseLanguageServers(m *lsp.Manager) {
+	if languageServers != nil && languageServers != m {
+		languageServers.Close()
+	}
+	languageServers = m
+}
+
+// CloseLanguageServers shuts down the language servers started so far.
+func CloseLanguageServers() {
+	if languageServers != nil {
+		languageServers.Close()
+	}
+}
+
+// recordDiagnosticsBefore remembers the problems of a file about to be edited.
+func recordDiagnosticsBefore(p string) {
+	if languageServers == nil || !languageServers.Handles(p) {
+		return
+	}
+	diags, ok := languageServers.Known(p)
+	if !ok {
+		// Check the file as it is before the first edit, or all of its
+		// problems would look new.
+		before, _ := languageServers.Check(toolCtx, p)
+		diags = before[p]
+	}
+	diagnosticsBefore[p] = diags
+}
+
+// reportDiagnostics returns the problems the language servers report for the
+// edited files, leaving out those the files already had before.
+func reportDiagnostics(files []string) string {
+	before := diagnosticsBefore
+	diagnosticsBefore = map[string][]lsp.Diagnostic{}
+	if languageServers == nil {
+		return ""
+	}
+	after, _ := languageServers.Check(toolCtx, files...)
+	if len(after) == 0 {
+		return ""
+	}
+
+	fresh := map[string][]lsp.Diagnostic{}
+	existing := 0
+	for p, diags := range after {
+		seen := map[string]int{}
+		for _, d := range before[p] {
+			seen[d.Key()]++
+		}
+		for _, d := range diags {
+			if d.Severity > lsp.SeverityWarning {
+				continue
+			}
+			if seen[d.Key()] > 0 {
+				seen[d.Key()]--
+				existing++
+				continue
+			}
+			fresh[p] = append(fresh[p], d)
+		}
+	}
+
+	var sb strings.Builder
+	if len(fresh) == 0 {
+		sb.WriteString("\n\nNo new problems were reported for the edited files.")
+	} else {
+		sb.WriteString("\n\nThe language server reported new problems in the edited files:\n")
+		sb.WriteString(formatDiagnostics(fresh))
+	}
+	if existing > 0 {
+		fmt.Fprintf(&sb, "\n%s the files already had before the edit %s not shown.", utils.Plural(existing, "problem"), utils.Ternary(existing == 1, "is", "are"))
+	}
+	return sb.String()
+}
+
+// handleDiagnostics reports the current problems of the given files, or every
+// problem the running language servers know about.
+func handleDiagnostics(argsJSON string) (string, error) {
+	var args DiagnosticsToolArgs
+	if argsJSON != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", err
+		}
+	}
+	if languageServers == nil {
+		return "No language servers are configured.", nil
+	}
+
+	var (
+		diags map[string][]lsp.Diagnostic
+		notes []string
+	)
+	if len(args.Paths) > 0 {
+		var files []string
+		for _, p := range args.Paths {
+			full, err := resolvePath(p)
+			if err != nil {
+				return "", err
+			}
+			if !languageServers.Handles(full) {
+				notes = append(notes, fmt.Sprintf("No language server is configured for '%s'.", p))
+				continue
+			}
+			files = append(files, full)
+		}
+		var err error
+		if diags, err = languageServers.Check(toolCtx, files...); err != nil {
+			notes = append(notes, err.Error())
+		}
+	} else {
+		if len(languageServers.Running()) == 0 {
+			return "No language server is running yet, they start when a file of their language is edited or checked. Pass the paths of the files to check.", nil
+		}
+		diags = languageServers.All()
+	}
+
+	for p, ds := range diags {
+		if len(ds) == 0 {
+			delete(diags, p)
+		}
+	}
+	var sb strings.Builder
+	if len(diags) == 0 {
+		sb.WriteString("No problems were reported.")
+	} else {
+		sb.WriteString(formatDiagnostics(diags))
+	}
+	for _, n := range notes {
+		sb.WriteString("\n" + n)
+	}
+	return sb.String(), nil
+}
+
+func formatDiagnostics(diags map[string][]lsp.Diagnostic) string {
+	paths := make([]string, 0, len(diags))
+	for p := range diags {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var sb strings.Builder
+	sb.WriteString("<diagnostics>\n")
+	for _, p := range paths {
+		ds := append([]lsp.Diagnostic{}, diags[p]...)
+		sort.SliceStable(ds, func(i, j int) bool {
+			if ds[i].Range.Start.Line != ds[j].Range.Start.Line {
+				return ds[i].Range.Start.Line < ds[j].Range.Start.Line
+			}
+			return ds[i].Severity < ds[j].Severity
+		})
+		sb.WriteString(displayPath(p) + "\n")
+		for i, d := range ds {
+			if i == maxDiagnosticsPerFile {
+				fmt.Fprintf(&sb, "  ... %d more\n", len(ds)-i)
+				break
+			}
+			sb.WriteString("  " + strings.ReplaceAll(d.String(), "\n", " ") + "\n")
+		}
+	}
+	sb.WriteString("</diagnostics>")
+	return sb.String()
+}
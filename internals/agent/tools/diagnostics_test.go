@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/lsp"
+	"github.com/sifatulrabbi/cli-agent/internals/lsp/lsptest"
+)
+
+func TestDiagnosticsAfterEdits(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	UseLanguageServers(lsp.NewManager(configs.WorkingPath, []lsp.ServerConfig{
+		{Name: "fake", Command: lsptest.Command(), Extensions: []string{".txt"}},
+	}))
+	t.Cleanup(func() { UseLanguageServers(nil) })
+
+	if out, _ := handleDiagnostics(`{}`); !strings.Contains(out, "No language server is running yet") {
+		t.Fatalf("unexpected diagnostics before any server started:\n%s", out)
+	}
+	if err := os.WriteFile(filepath.Join(configs.WorkingPath, "a.txt"), []byte("an ERROR from before\nfine\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the problems the edit introduced are reported.
+	out := runFileTool(t, Handlers[ToolEditFile], EditFileToolArgs{FilePath: "a.txt", Edits: []FileEdit{{OldText: "fine", NewText: "WARN now"}}})
+	if !strings.Contains(out, "<diagnostics>\na.txt\n  2:1 warning: suspicious line: WARN now (fake)\n</diagnostics>") {
+		t.Fatalf("expected the new warning, got:\n%s", out)
+	}
+	if strings.Contains(out, "bad line") || !strings.Contains(out, "1 problem the files already had before the edit is not shown.") {
+		t.Fatalf("expected the existing error to be left out, got:\n%s", out)
+	}
+
+	out = runFileTool(t, Handlers[ToolWriteFile], WriteFileToolArgs{FilePath: "b.txt", Content: "all good\n"})
+	if !strings.Contains(out, "No new problems were reported for the edited files.") {
+		t.Fatalf("expected no problems, got:\n%s", out)
+	}
+	// Files no server handles get nothing appended.
+	out = runFileTool(t, Handlers[ToolWriteFile], WriteFileToolArgs{FilePath: "c.md", Content: "ERROR\n"})
+	if strings.Contains(out, "problem") {
+		t.Fatalf("expected no diagnostics for c.md, got:\n%s", out)
+	}
+
+	out, err := handleDiagnostics(`{}`)
+	if err != nil || !strings.Contains(out, "a.txt\n  1:4 error: bad line: an ERROR from before (fake)\n  2:1 warning") {
+		t.Fatalf("unexpected diagnostics of the workspace:\n%s", out)
+	}
+	out, _ = handleDiagnostics(`{"paths": ["b.txt", "c.md"]}`)
+	if !strings.Contains(out, "No problems were reported.\nNo language server is configured for 'c.md'.") {
+		t.Fatalf("unexpected diagnostics of b.txt:\n%s", out)
+	}
+}
@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+const maxEditDiffLines = 60
+
+type FileEdit struct {
+	OldText    string `json:"oldText"`
+	NewText    string `json:"newText"`
+	ReplaceAll bool   `json:"replaceAll"`
+}
+
+type EditFileToolArgs struct {
+	FilePath string     `json:"filePath"`
+	Edits    []FileEdit `json:"edits"`
+}
+
+// handleEditFile replaces text anchored by its exact content instead of line
+// numbers. Either all the edits apply or the file is left untouched.
+func handleEditFile(argsJSON string) (string, error) {
+	var args EditFileToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	if len(args.Edits) < 1 {
+		return "No edits provided. Pass at least one {oldText, newText} pair.", nil
+	}
+
+	full, err := resolvePath(args.FilePath)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return fmt.Sprintf("The '%s' file does not exists. Here is the error: %q", args.FilePath, err), nil
+	}
+	original := string(data)
+	eol := detectEOL(original)
+
+	updated := original
+	var notes, errs []string
+	for i, e := range args.Edits {
+		oldText := toEOL(e.OldText, eol)
+		newText := toEOL(e.NewText, eol)
+		if oldText == "" {
+			errs = append(errs, fmt.Sprintf("Edit %d: oldText is empty. Use 'append_file' to add content to a file.", i+1))
+			continue
+		}
+
+		switch n := strings.Count(updated, oldText); {
+		case n == 1 || (n > 1 && e.ReplaceAll):
+			updated = strings.ReplaceAll(updated, oldText, newText)
+		case n > 1:
+			errs = append(errs, fmt.Sprintf("Edit %d: oldText matches %d times. Include more surrounding lines to make it unique or set replaceAll.", i+1, n))
+		default:
+			res, note, err := replaceIgnoringWhitespace(updated, oldText, newText, eol, e.ReplaceAll)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("Edit %d: %s", i+1, err))
+				continue
+			}
+			updated = res
+			notes = append(notes, fmt.Sprintf("Edit %d: %s", i+1, note))
+		}
+	}
+	if len(errs) > 0 {
+		return "Could not apply the edits to '" + args.FilePath + "', the file was not changed:\n" + strings.Join(errs, "\n"), nil
+	}
+	if updated == original {
+		return fmt.Sprintf("The edits did not change '%s'.", args.FilePath), nil
+	}
+
+	if err := snapshotFiles(ToolEditFile, full); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(full, []byte(updated), 0o644); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Applied %d edit(s) to '%s'.\n", len(args.Edits), args.FilePath)
+	for _, n := range notes {
+		sb.WriteString(n + "\n")
+	}
+	sb.WriteString(shortDiff(args.FilePath, original, updated))
+	return sb.String(), nil
+}
+
+// replaceIgnoringWhitespace is the fallback when oldText does not match
+// exactly, usually because the model got the indentation wrong. It compares
+// whole lines with their whitespace collapsed.
+func replaceIgnoringWhitespace(content, oldText, newText, eol string, replaceAll bool) (string, string, error) {
+	lines := safeSplit(content)
+	oldLines := safeSplit(strings.Trim(oldText, eol))
+	if len(oldLines) == 0 {
+		return "", "", fmt.Errorf("oldText only contains whitespace")
+	}
+	for i := range oldLines {
+		oldLines[i] = normalizeWhitespace(oldLines[i])
+	}
+
+	matches := []int{}
+	for start := 0; start+len(oldLines) <= len(lines); start++ {
+		found := true
+		for j, ol := range oldLines {
+			if normalizeWhitespace(lines[start+j]) != ol {
+				found = false
+				break
+			}
+		}
+		if found {
+			matches = append(matches, start)
+			start += len(oldLines) - 1
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		return "", "", fmt.Errorf("oldText was not found in the file, not even when ignoring whitespace. Read the file again to get its current content")
+	case len(matches) > 1 && !replaceAll:
+		return "", "", fmt.Errorf("oldText matches %d times when ignoring whitespace. Include more surrounding lines to make it unique or set replaceAll", len(matches))
+	}
+
+	newLines := safeSplit(strings.Trim(newText, eol))
+	var ranges []string
+	// Replace from the bottom so the earlier match positions stay valid.
+	for i := len(matches) - 1; i >= 0; i-- {
+		start := matches[i]
+		lines = append(append(append([]string{}, lines[:start]...), newLines...), lines[start+len(oldLines):]...)
+		ranges = append([]string{fmt.Sprintf("%d-%d", start+1, start+len(oldLines))}, ranges...)
+	}
+	note := fmt.Sprintf("oldText did not match exactly; replaced line(s) %s that match when ignoring whitespace.", strings.Join(ranges, ", "))
+	return strings.Join(lines, eol), note, nil
+}
+
+func normalizeWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func toEOL(s, eol string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if eol == "\n" {
+		return s
+	}
+	return strings.ReplaceAll(s, "\n", eol)
+}
+
+// shortDiff renders a unified diff of the change, clipped so a large edit does
+// not flood the context.
+func shortDiff(filePath, before, after string) string {
+	diff := utils.UnifiedDiff("a/"+filePath, "b/"+filePath, before, after, 2)
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	if len(lines) > maxEditDiffLines {
+		lines = append(lines[:maxEditDiffLines], fmt.Sprintf("... %d more diff lines", len(lines)-maxEditDiffLines))
+	}
+	return "<diff>\n" + strings.Join(lines, "\n") + "\n</diff>"
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func TestEditFile(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	p := filepath.Join(configs.WorkingPath, "main.go")
+	original := "package main\r\n\r\nfunc main() {\r\n\tprintln(\"a\")\r\n\tprintln(\"a\")\r\n}\r\n"
+	if err := os.WriteFile(p, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	edit := func(edits ...FileEdit) string {
+		argsStr, _ := json.Marshal(EditFileToolArgs{FilePath: "main.go", Edits: edits})
+		out, err := handleEditFile(string(argsStr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	content := func() string {
+		data, _ := os.ReadFile(p)
+		return string(data)
+	}
+
+	// ambiguous matches leave the file untouched, even when the other edits are fine
+	out := edit(FileEdit{OldText: "package main", NewText: "package app"}, FileEdit{OldText: `println("a")`, NewText: `println("b")`})
+	if !strings.Contains(out, "matches 2 times") || content() != original {
+		t.Fatalf("expected the ambiguous edit to be rejected atomically, got %q", out)
+	}
+
+	out = edit(FileEdit{OldText: "package main", NewText: "package app"}, FileEdit{OldText: `println("a")`, NewText: `println("b")`, ReplaceAll: true})
+	if !strings.Contains(out, "-package main") || !strings.Contains(out, "+package app") {
+		t.Fatalf("expected a diff in the result, got %q", out)
+	}
+	if want := strings.ReplaceAll(strings.ReplaceAll(original, "main\r", "app\r"), `"a"`, `"b"`); content() != want {
+		t.Fatalf("unexpected content %q", content())
+	}
+
+	// wrong indentation falls back to whitespace-insensitive matching and keeps the CRLF line endings
+	out = edit(FileEdit{OldText: "func main() {\n  println(\"b\")\n  println(\"b\")\n}", NewText: "func main() {}"})
+	if !strings.Contains(out, "ignoring whitespace") || content() != "package app\r\n\r\nfunc main() {}\r\n" {
+		t.Fatalf("expected the whitespace-insensitive fallback to apply, got %q and %q", out, content())
+	}
+
+	if out = edit(FileEdit{OldText: "func other()", NewText: ""}); !strings.Contains(out, "was not found") {
+		t.Fatalf("expected a not found error, got %q", out)
+	}
+}
@@ -0,0 +1,329 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+type WriteFileToolArgs struct {
+	FilePath  string `json:"filePath"`
+	Content   string `json:"content"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+type DeletePathToolArgs struct {
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive"`
+}
+
+type MovePathToolArgs struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Overwrite   bool   `json:"overwrite"`
+}
+
+// CopyPathToolArgs has the same shape as MovePathToolArgs.
+type CopyPathToolArgs MovePathToolArgs
+
+// handleWriteFile creates a file with the given content, or replaces an
+// existing one when overwrite is set.
+func handleWriteFile(argsJSON string) (string, error) {
+	var args WriteFileToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	full, err := resolvePath(args.FilePath)
+	if err != nil {
+		return "", err
+	}
+
+	verb := "Created"
+	if info, err := os.Stat(full); err == nil {
+		if info.IsDir() {
+			return fmt.Sprintf("'%s' is a directory.", args.FilePath), nil
+		}
+		if !args.Overwrite {
+			return fmt.Sprintf("'%s' already exists. Use edit_file to change it, or set overwrite to replace its whole content.", args.FilePath), nil
+		}
+		verb = "Overwrote"
+	}
+
+	if err := snapshotFiles(ToolWriteFile, full); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(full, []byte(args.Content), 0o644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s '%s' (%d lines).", verb, args.FilePath, len(safeSplit(args.Content))), nil
+}
+
+// handleDeletePath deletes a file, or a directory with everything in it when
+// recursive is set.
+func handleDeletePath(argsJSON string) (string, error) {
+	var args DeletePathToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	full, err := resolveEntry(args.Path)
+	if err != nil {
+		return "", err
+	}
+	if isAllowedRoot(full) {
+		return fmt.Sprintf("'%s' is a root of the workspace and can't be deleted.", args.Path), nil
+	}
+	info, err := os.Lstat(full)
+	if err != nil {
+		return fmt.Sprintf("Unable to delete '%s': %s", args.Path, err), nil
+	}
+
+	if !info.IsDir() {
+		if err := snapshotFiles(ToolDeletePath, full); err != nil {
+			return "", err
+		}
+		if err := os.Remove(full); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Deleted '%s'.", args.Path), nil
+	}
+
+	files, err := filesUnder(full)
+	if err != nil {
+		return "", err
+	}
+	if len(files) > 0 && !args.Recursive {
+		return fmt.Sprintf("'%s' is a directory with %s in it. Set recursive to delete it with its content.", args.Path, utils.Plural(len(files), "file")), nil
+	}
+	if err := snapshotFiles(ToolDeletePath, files...); err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(full); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Deleted the directory '%s' with %s.", args.Path, utils.Plural(len(files), "file")), nil
+}
+
+// handleMovePath moves or renames a file or a directory.
+func handleMovePath(argsJSON string) (string, error) {
+	var args MovePathToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	src, dst, msg, err := prepareTransfer(args, ToolMovePath)
+	if msg != "" || err != nil {
+		return msg, err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		// Rename can't cross filesystems, which the extra roots may be on.
+		if !errors.Is(err, syscall.EXDEV) {
+			return "", err
+		}
+		if err := copyTree(src, dst); err != nil {
+			return "", err
+		}
+		if err := os.RemoveAll(src); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("Moved '%s' to '%s'.", args.Source, args.Destination), nil
+}
+
+// handleCopyPath copies a file or a directory, keeping the file modes.
+func handleCopyPath(argsJSON string) (string, error) {
+	var args CopyPathToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	src, dst, msg, err := prepareTransfer(MovePathToolArgs(args), ToolCopyPath)
+	if msg != "" || err != nil {
+		return msg, err
+	}
+	if err := copyTree(src, dst); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Copied '%s' to '%s'.", args.Source, args.Destination), nil
+}
+
+// prepareTransfer validates the source and the destination of a move or a
+// copy and snapshots the files both will change. A symlink is moved or copied
+// as a link. A non-empty message is the
+// reason the transfer can't be done.
+func prepareTransfer(args MovePathToolArgs, toolName string) (src, dst, msg string, err error) {
+	if src, err = resolveEntry(args.Source); err != nil {
+		return "", "", "", err
+	}
+	if dst, err = resolveEntry(args.Destination); err != nil {
+		return "", "", "", err
+	}
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return "", "", fmt.Sprintf("Unable to read '%s': %s", args.Source, err), nil
+	}
+	if toolName == ToolMovePath && isAllowedRoot(src) {
+		return "", "", fmt.Sprintf("'%s' is a root of the workspace and can't be moved.", args.Source), nil
+	}
+	if src == dst {
+		return "", "", fmt.Sprintf("'%s' and '%s' are the same path.", args.Source, args.Destination), nil
+	}
+	if srcInfo.IsDir() && withinAnyRoot(dst, []string{src}) {
+		return "", "", fmt.Sprintf("Can't put the directory '%s' inside of itself.", args.Source), nil
+	}
+	if dstInfo, err := os.Lstat(dst); err == nil {
+		switch {
+		case dstInfo.IsDir():
+			return "", "", fmt.Sprintf("'%s' already exists and is a directory. Pass the full destination path, including the new name.", args.Destination), nil
+		case srcInfo.IsDir():
+			return "", "", fmt.Sprintf("'%s' already exists and is a file, it can't be replaced by a directory.", args.Destination), nil
+		case !args.Overwrite:
+			return "", "", fmt.Sprintf("'%s' already exists. Set overwrite to replace it.", args.Destination), nil
+		}
+	}
+
+	srcFiles, err := filesUnder(src)
+	if err != nil {
+		return "", "", "", err
+	}
+	touched := []string{}
+	for _, f := range srcFiles {
+		rel, _ := filepath.Rel(src, f)
+		touched = append(touched, filepath.Join(dst, rel))
+	}
+	if toolName == ToolMovePath {
+		touched = append(touched, srcFiles...)
+	}
+	if err := snapshotFiles(toolName, touched...); err != nil {
+		return "", "", "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", "", "", err
+	}
+	return src, dst, "", nil
+}
+
+// writeFileAtomic writes the file to a temporary file next to it and renames
+// it into place, so a failed write never leaves a half-written file behind.
+// Existing files keep their mode, new ones get the given mode.
+func writeFileAtomic(path string, data []byte, mode fs.FileMode) error {
+	tmp, err := stageFile(path, data, mode)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return os.Rename(tmp, path)
+}
+
+// stageFile writes the data to a temporary file next to path, which renaming
+// it to path replaces at once. An existing file keeps its mode.
+func stageFile(path string, data []byte, mode fs.FileMode) (string, error) {
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return "", err
+	}
+	ok = true
+	return tmp.Name(), nil
+}
+
+// copyTree copies a file, symlink or directory to dst, which must not be an
+// existing directory.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		default:
+			return copyFile(p, target, info.Mode().Perm())
+		}
+	})
+}
+
+// copyFile copies the content and the mode of src to dst.
+func copyFile(src, dst string, mode fs.FileMode) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(dst, data, mode); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode)
+}
+
+// filesUnder returns the path itself for files and every file and symlink
+// inside of it for directories, which is what the checkpoints snapshot.
+func filesUnder(root string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func isAllowedRoot(p string) bool {
+	for _, root := range allowedRoots() {
+		if filepath.Clean(root) == filepath.Clean(p) {
+			return true
+		}
+	}
+	return false
+}
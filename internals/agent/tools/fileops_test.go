@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/db"
+)
+
+func runFileTool(t *testing.T, handler func(string) (string, error), args any) string {
+	t.Helper()
+	argsJSON, _ := json.Marshal(args)
+	out, err := handler(string(argsJSON))
+	if err != nil {
+		t.Fatalf("the tool failed: %v", err)
+	}
+	return out
+}
+
+func TestFileOperations(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	configs.SessionsPath = t.TempDir()
+	store, _ := db.GetCheckpointStore("session")
+	UseCheckpoints(store, 1)
+	t.Cleanup(func() { UseCheckpoints(nil, 0) })
+
+	read := func(p string) string {
+		data, err := os.ReadFile(filepath.Join(configs.WorkingPath, p))
+		if err != nil {
+			return "<missing>"
+		}
+		return string(data)
+	}
+	script := filepath.Join(configs.WorkingPath, "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runFileTool(t, handleWriteFile, WriteFileToolArgs{FilePath: "src/app/main.go", Content: "package main\n"})
+	if out != "Created 'src/app/main.go' (2 lines)." || read("src/app/main.go") != "package main\n" {
+		t.Fatalf("unexpected write: %s", out)
+	}
+	out = runFileTool(t, handleWriteFile, WriteFileToolArgs{FilePath: "run.sh", Content: "#!/bin/bash\n"})
+	if !strings.Contains(out, "already exists") || read("run.sh") != "#!/bin/sh\n" {
+		t.Fatalf("expected the existing file to be kept, got: %s", out)
+	}
+	runFileTool(t, handleWriteFile, WriteFileToolArgs{FilePath: "run.sh", Content: "#!/bin/bash\n", Overwrite: true})
+	if info, _ := os.Stat(script); read("run.sh") != "#!/bin/bash\n" || info.Mode().Perm() != 0o755 {
+		t.Fatalf("expected the overwrite to keep the mode, got %v", info.Mode())
+	}
+	if entries, _ := os.ReadDir(configs.WorkingPath); len(entries) != 2 {
+		t.Fatalf("expected no temporary files to be left behind, got %v", entries)
+	}
+
+	out = runFileTool(t, handleCopyPath, CopyPathToolArgs{Source: "run.sh", Destination: "src/app/main.go"})
+	if !strings.Contains(out, "Set overwrite") {
+		t.Fatalf("expected the copy to refuse to clobber, got: %s", out)
+	}
+	runFileTool(t, handleCopyPath, CopyPathToolArgs{Source: "src", Destination: "backup/src"})
+	runFileTool(t, handleCopyPath, CopyPathToolArgs{Source: "run.sh", Destination: "backup/run.sh"})
+	if info, err := os.Stat(filepath.Join(configs.WorkingPath, "backup/run.sh")); err != nil || info.Mode().Perm() != 0o755 || read("backup/src/app/main.go") != "package main\n" {
+		t.Fatalf("unexpected copies: %v %v", info, err)
+	}
+
+	out = runFileTool(t, handleMovePath, MovePathToolArgs{Source: "src", Destination: "src/inner"})
+	if !strings.Contains(out, "inside of itself") {
+		t.Fatalf("expected the move to be refused, got: %s", out)
+	}
+	runFileTool(t, handleMovePath, MovePathToolArgs{Source: "src/app", Destination: "cmd/app"})
+	if read("cmd/app/main.go") != "package main\n" || read("src/app/main.go") != "<missing>" {
+		t.Fatal("expected the directory to be moved")
+	}
+
+	out = runFileTool(t, handleDeletePath, DeletePathToolArgs{Path: "backup"})
+	if !strings.Contains(out, "Set recursive") || read("backup/run.sh") == "<missing>" {
+		t.Fatalf("expected the directory to be kept, got: %s", out)
+	}
+	runFileTool(t, handleDeletePath, DeletePathToolArgs{Path: "backup", Recursive: true})
+	runFileTool(t, handleDeletePath, DeletePathToolArgs{Path: "run.sh"})
+	if read("backup/run.sh") != "<missing>" || read("run.sh") != "<missing>" {
+		t.Fatal("expected the paths to be deleted")
+	}
+	if out := runFileTool(t, handleDeletePath, DeletePathToolArgs{Path: ".", Recursive: true}); !strings.Contains(out, "can't be deleted") {
+		t.Fatalf("expected the workspace to be protected, got: %s", out)
+	}
+
+	// every change went through the checkpoints
+	if _, err := store.UndoAll(); err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range map[string]string{"run.sh": "#!/bin/sh\n", "src/app/main.go": "<missing>", "cmd/app/main.go": "<missing>", "backup/run.sh": "<missing>"} {
+		if got := read(p); got != want {
+			t.Errorf("expected %s to be %q after the undo, got %q", p, want, got)
+		}
+	}
+}
+
+func TestEditsNeedACheckpoint(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	configs.SessionsPath = t.TempDir()
+	store, _ := db.GetCheckpointStore("session")
+	UseCheckpoints(store, 1)
+	t.Cleanup(func() { UseCheckpoints(nil, 0) })
+
+	// The checkpoints can't be saved under a file.
+	configs.SessionsPath = filepath.Join(configs.WorkingPath, "sessions")
+	if err := os.WriteFile(configs.SessionsPath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	argsJSON, _ := json.Marshal(WriteFileToolArgs{FilePath: "main.go", Content: "package main\n"})
+	if _, err := handleWriteFile(string(argsJSON)); err == nil || !strings.Contains(err.Error(), "nothing was changed") {
+		t.Fatalf("expected the write to fail, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(configs.WorkingPath, "main.go")); !os.IsNotExist(err) {
+		t.Error("expected the file not to be written")
+	}
+}
+
+func TestFileOperationsOnSymlinks(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	configs.SessionsPath = t.TempDir()
+	store, _ := db.GetCheckpointStore("session")
+	UseCheckpoints(store, 1)
+	t.Cleanup(func() { UseCheckpoints(nil, 0) })
+
+	path := func(p string) string { return filepath.Join(configs.WorkingPath, p) }
+	if err := os.WriteFile(path("real.txt"), []byte("real\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{"link.txt": "real.txt", "moved.txt": "real.txt", "out.txt": outside} {
+		if err := os.Symlink(target, path(link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	isLink := func(p string) bool {
+		info, err := os.Lstat(path(p))
+		return err == nil && info.Mode()&os.ModeSymlink != 0
+	}
+
+	runFileTool(t, handleDeletePath, DeletePathToolArgs{Path: "link.txt"})
+	if _, err := os.Lstat(path("link.txt")); !os.IsNotExist(err) {
+		t.Error("expected the link to be deleted")
+	}
+	if data, _ := os.ReadFile(path("real.txt")); string(data) != "real\n" {
+		t.Error("expected the target of the link to be kept")
+	}
+
+	runFileTool(t, handleMovePath, MovePathToolArgs{Source: "moved.txt", Destination: "docs/moved.txt"})
+	if _, err := os.Lstat(path("moved.txt")); !os.IsNotExist(err) || !isLink("docs/moved.txt") {
+		t.Error("expected the link to be moved")
+	}
+	if data, _ := os.ReadFile(path("real.txt")); string(data) != "real\n" {
+		t.Error("expected the target of the link to stay in place")
+	}
+
+	// A link out of the workspace is an entry of the workspace as well.
+	runFileTool(t, handleCopyPath, CopyPathToolArgs{Source: "out.txt", Destination: "out-copy.txt"})
+	runFileTool(t, handleDeletePath, DeletePathToolArgs{Path: "out.txt"})
+	if !isLink("out-copy.txt") || isLink("out.txt") {
+		t.Error("expected the link to be copied and deleted")
+	}
+	if data, _ := os.ReadFile(outside); string(data) != "secret\n" {
+		t.Error("expected the file outside of the workspace to be kept")
+	}
+
+	if _, err := store.UndoAll(); err != nil {
+		t.Fatal(err)
+	}
+	if !isLink("link.txt") || !isLink("moved.txt") || !isLink("out.txt") || isLink("out-copy.txt") {
+		t.Error("expected the undo to restore the links")
+	}
+}
+
+func TestDeleteSymlinkedRoot(t *testing.T) {
+	root := t.TempDir()
+	configs.WorkingPath = filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(root, configs.WorkingPath); err != nil {
+		t.Fatal(err)
+	}
+	if out := runFileTool(t, handleDeletePath, DeletePathToolArgs{Path: ".", Recursive: true}); !strings.Contains(out, "can't be deleted") {
+		t.Fatalf("expected the workspace to be protected, got: %s", out)
+	}
+}
@@ -7,9 +7,6 @@ import (
 	"os"
 	"slices"
 	"strings"
-
-	"github.com/sifatulrabbi/cli-agent/internals/configs"
-	"github.com/sifatulrabbi/cli-agent/internals/utils"
 )
 
 type FilePatch struct {
@@ -33,23 +30,19 @@ type AppendFileToolArgs struct {
 	Inserts  []FileInsert `json:"inserts"`
 }
 
-type ReadFile struct {
-	FilePath  string `json:"filePath"`
-	StartLine int    `json:"startLine"`
-	EndLine   int    `json:"endLine"`
-}
-
-type ReadFilesToolArgs struct {
-	Reads []ReadFile `json:"filePaths"`
-}
-
 func handleAppendFile(argsJSON string) (string, error) {
 	var args AppendFileToolArgs
 	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 		return "", err
 	}
 
-	fullPath := buildPathFromRootDir(args.FilePath)
+	fullPath, err := resolvePath(args.FilePath)
+	if err != nil {
+		return "", err
+	}
+	if err := snapshotFiles(ToolAppendFile, fullPath); err != nil {
+		return "", err
+	}
 	data, err := os.ReadFile(fullPath)
 	if err != nil {
 		pathSplit := strings.Split(fullPath, "/")
@@ -89,7 +82,7 @@ func handleAppendFile(argsJSON string) (string, error) {
 		lines = append(lines[:idx], append(newLines, lines[idx:]...)...)
 	}
 	updated := strings.Join(lines, eol)
-	if err := os.WriteFile(fullPath, []byte(updated), 0o644); err != nil {
+	if err := writeFileAtomic(fullPath, []byte(updated), 0o644); err != nil {
 		return "", err
 	}
 	return fmt.Sprintf("Inserted content into '%s'.", args.FilePath), nil
@@ -100,7 +93,10 @@ func handlePatchTextFile(argsJSON string) (string, error) {
 	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 		return "", err
 	}
-	full := buildPathFromRootDir(args.FilePath)
+	full, err := resolvePath(args.FilePath)
+	if err != nil {
+		return "", err
+	}
 	data, err := os.ReadFile(full)
 	if err != nil {
 		return fmt.Sprintf("The '%s' file does not exists. Here is the error: %q", args.FilePath, err), nil
@@ -133,100 +129,11 @@ func handlePatchTextFile(argsJSON string) (string, error) {
 	}
 
 	updated := strings.Join(lines, eol)
-	if err := os.WriteFile(full, []byte(updated), 0o644); err != nil {
-		return "", err
-	}
-	return fmt.Sprintf("Applied %d patch(es) to '%s'.", len(args.Patches), args.FilePath), nil
-}
-
-func handleReadFiles(argsJSON string) (string, error) {
-	var args ReadFilesToolArgs
-	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-		return "", err
-	}
-
-	if len(args.Reads) < 1 {
-		return "The filePaths is empty please specify filePaths to read files.", nil
-	}
-
-	out := ""
-	for _, p := range args.Reads {
-		content, err := formatFileWithLineNumbers(p.FilePath)
-		if err != nil {
-			out += fmt.Sprintf("Failed to read file: %q\nReason: %s", p, err)
-			continue
-		}
-		if out != "" {
-			out += "\n\n"
-		}
-
-		lines := safeSplit(content)
-		safeStart := p.StartLine - 1
-		safeEnd := p.EndLine
-
-		if p.StartLine > len(lines) || p.StartLine < 0 {
-			safeStart = 0
-		}
-		if p.EndLine < 1 || p.EndLine > len(lines) {
-			safeEnd = len(lines)
-		}
-
-		fileContent := fmt.Sprintf("<file_content name=%q>\n%s\n</file_content>",
-			p,
-			strings.Join(lines[safeStart:safeEnd], "\n"))
-
-		outTokens := utils.CountTokens(fileContent)
-		if outTokens > 2000 {
-			safeEnd = safeEnd / 2
-			fileContent = fmt.Sprintf("Showing from line %d to line %d since the file is too large.\n<file_content name=%q>\n%s\n</file_content>",
-				safeStart+1, safeEnd, p, strings.Join(lines[safeStart:safeEnd], "\n"))
-		}
-		out += fileContent
-	}
-
-	return out, nil
-}
-
-func handleListFiles(argsJSON string) (string, error) {
-	_ = argsJSON
-	// Refresh .gitignore-derived patterns now that WorkingPath is prepared
-	detectGitIgnores()
-	// Ensure we traverse from the working path and respect ignore rules
-	entries, err := traverseDir(configs.WorkingPath)
-	if err != nil {
+	if err := snapshotFiles(ToolPatchFile, full); err != nil {
 		return "", err
 	}
-	// Prepend project root indicator
-	lines := append([]string{"./"}, entries...)
-	// Wrap output as requested
-	var sb strings.Builder
-	sb.WriteString("<all_files_and_dirs>\n")
-	sb.WriteString(strings.Join(lines, "\n"))
-	sb.WriteString("\n</all_files_and_dirs>")
-	return sb.String(), nil
-}
-
-// formatFileWithLineNumbers reads a single file and returns it with prefixed line numbers
-func formatFileWithLineNumbers(relPath string) (string, error) {
-	full := buildPathFromRootDir(relPath)
-	data, err := os.ReadFile(full)
-	if err != nil {
+	if err := writeFileAtomic(full, []byte(updated), 0o644); err != nil {
 		return "", err
 	}
-	lines := safeSplit(string(data))
-	maxWidth := len(fmt.Sprintf("%d", len(lines)))
-	var sb strings.Builder
-	sb.WriteString("File: ")
-	sb.WriteString(relPath)
-	sb.WriteString("\n")
-	for i, line := range lines {
-		num := fmt.Sprintf("%*d", maxWidth, i+1)
-		sb.WriteString(num)
-		sb.WriteString(" | ")
-		sb.WriteString(line)
-		if i < len(lines)-1 {
-			sb.WriteString("\n")
-		}
-	}
-	return sb.String(), nil
+	return fmt.Sprintf("Applied %d patch(es) to '%s'.", len(args.Patches), args.FilePath), nil
 }
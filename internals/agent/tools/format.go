@@ -0,0 +1,214 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+const formatTimeout = 10 * time.Second
+
+// defaultFormatters are used for the globs the settings don't configure, when
+// the formatter is installed.
+var defaultFormatters = map[string][]string{
+	"*.go":  {"gofmt"},
+	"*.py":  {"black", "--quiet", "-"},
+	"*.pyi": {"black", "--quiet", "--pyi", "-"},
+	"*.rs":  {"rustfmt", "--emit", "stdout"},
+	"*.ts":  {"prettier", "--stdin-filepath", "{file}"},
+	"*.tsx": {"prettier", "--stdin-filepath", "{file}"},
+	"*.js":  {"prettier", "--stdin-filepath", "{file}"},
+	"*.jsx": {"prettier", "--stdin-filepath", "{file}"},
+}
+
+type formatter struct {
+	glob    string
+	re      *regexp.Regexp
+	command []string
+}
+
+var formatters []formatter
+
+// UseFormatters makes the file tools format the files they modify. The most
+// specific glob matching a file selects its formatter.
+func UseFormatters(s configs.FormatSettings) {
+	formatters = nil
+	if s.Disabled {
+		return
+	}
+	commands := map[string][]string{}
+	for glob, command := range defaultFormatters {
+		commands[glob] = command
+	}
+	for glob, command := range s.Formatters {
+		commands[glob] = command
+	}
+	for glob, command := range commands {
+		// An empty command is kept, it leaves the files it matches unformatted.
+		res := compileGlobs([]string{glob})
+		if len(res) == 0 {
+			continue
+		}
+		formatters = append(formatters, formatter{glob: glob, re: res[0], command: command})
+	}
+	sort.Slice(formatters, func(i, j int) bool {
+		if len(formatters[i].glob) != len(formatters[j].glob) {
+			return len(formatters[i].glob) > len(formatters[j].glob)
+		}
+		return formatters[i].glob < formatters[j].glob
+	})
+}
+
+func formatterFor(p string) *formatter {
+	rel := relSlash(workspaceRootOf(p), p)
+	for i := range formatters {
+		if formatters[i].re.MatchString(rel) {
+			return utils.Ternary(len(formatters[i].command) > 0, &formatters[i], nil)
+		}
+	}
+	return nil
+}
+
+// formatEditedFiles formats the files and returns a note for the ones whose
+// lines the formatter changed, so the model doesn't edit them based on stale
+// line numbers.
+func formatEditedFiles(files []string) string {
+	var sb strings.Builder
+	for _, p := range files {
+		f := formatterFor(p)
+		if f == nil {
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		before, err := os.ReadFile(p)
+		if err != nil || isBinary(before) {
+			continue
+		}
+		after, err := runFormatter(f.command, p, before)
+		if err != nil {
+			var execErr *exec.Error
+			if !errors.As(err, &execErr) {
+				fmt.Fprintf(&sb, "\n\nFormatting '%s' with %s failed: %s", displayPath(p), f.command[0], err)
+			}
+			continue
+		}
+		if bytes.Equal(before, after) || (len(after) == 0 && len(before) > 0) {
+			continue
+		}
+		if err := writeFileAtomic(p, after, info.Mode()); err != nil {
+			log.Println("ERROR: Failed to write the formatted file", p, err)
+			continue
+		}
+		fmt.Fprintf(&sb, "\n\n%s reformatted '%s', which changed %s. Read the file again before editing it by line numbers.",
+			f.command[0], displayPath(p), changedLines(string(before), string(after)))
+	}
+	return sb.String()
+}
+
+// runFormatter pipes the content through the formatter command, which runs
+// in the sandbox of the bash tool. Errors carry the first line the formatter
+// printed to stderr, a formatter that is not installed is an *exec.Error.
+func runFormatter(command []string, p string, content []byte) ([]byte, error) {
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = strings.ReplaceAll(arg, "{file}", p)
+	}
+	if !strings.Contains(args[0], "/") {
+		// Inside the sandbox a missing command is only an exit code.
+		if _, err := exec.LookPath(args[0]); err != nil {
+			return nil, err
+		}
+	}
+	cmd, err := sandbox.Command(sandboxConfig, args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Dir = configs.WorkingPath
+	cmd.Stdin = bytes.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(toolCtx, formatTimeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		<-done
+		return nil, fmt.Errorf("did not finish within %s", formatTimeout)
+	}
+	if err != nil {
+		if msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); msg != "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// changedLines describes which lines of the new content differ from the old,
+// e.g. "lines 3-5 and 12".
+func changedLines(before, after string) string {
+	var lines []int
+	newLine := 1
+	for _, d := range utils.DiffLines(safeSplit(before), safeSplit(after)) {
+		switch d.Kind {
+		case utils.DiffEqual:
+			newLine++
+		case utils.DiffInsert:
+			lines = append(lines, newLine)
+			newLine++
+		case utils.DiffDelete:
+			// A removed line shows as a change of the line that took its place.
+			lines = append(lines, newLine)
+		}
+	}
+
+	var ranges []string
+	count := 0
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] <= lines[j]+1 {
+			j++
+		}
+		if lines[j] == lines[i] {
+			ranges = append(ranges, fmt.Sprint(lines[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		}
+		count += lines[j] - lines[i] + 1
+		i = j + 1
+	}
+	if len(ranges) == 0 {
+		return "only the line endings"
+	}
+	if len(ranges) > 10 {
+		return fmt.Sprintf("%s across the file", utils.Plural(count, "line"))
+	}
+	what := utils.Ternary(count == 1, "line ", "lines ")
+	if len(ranges) == 1 {
+		return what + ranges[0]
+	}
+	return what + strings.Join(ranges[:len(ranges)-1], ", ") + " and " + ranges[len(ranges)-1]
+}
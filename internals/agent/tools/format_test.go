@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
+)
+
+func TestFormatAfterEdits(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	UseFormatters(configs.FormatSettings{Formatters: map[string][]string{
+		"*.txt":        {"sed", "s/^ *//"},
+		"broken/*.txt": {"sh", "-c", "echo 'syntax error at line 2' >&2; exit 1"},
+		"*.md":         {"cli-agent-no-such-formatter"},
+		"*.go":         {}, // turns gofmt off
+		"skip/*":       {},
+	}})
+	t.Cleanup(func() { UseFormatters(configs.FormatSettings{Disabled: true}) })
+	read := func(p string) string {
+		data, _ := os.ReadFile(filepath.Join(configs.WorkingPath, p))
+		return string(data)
+	}
+
+	out := runFileTool(t, Handlers[ToolWriteFile], WriteFileToolArgs{FilePath: "a.txt", Content: "one\n  two\n    three\nfour\n"})
+	if read("a.txt") != "one\ntwo\nthree\nfour\n" {
+		t.Fatalf("the file was not formatted: %q", read("a.txt"))
+	}
+	if !strings.Contains(out, "sed reformatted 'a.txt', which changed lines 2-3. Read the file again") {
+		t.Fatalf("expected a note about the formatting, got:\n%s", out)
+	}
+	out = runFileTool(t, Handlers[ToolEditFile], EditFileToolArgs{FilePath: "a.txt", Edits: []FileEdit{{OldText: "four", NewText: "four"}}})
+	if strings.Contains(out, "reformatted") {
+		t.Fatalf("an already formatted file should not be noted, got:\n%s", out)
+	}
+
+	os.Mkdir(filepath.Join(configs.WorkingPath, "broken"), 0o755)
+	out = runFileTool(t, Handlers[ToolWriteFile], WriteFileToolArgs{FilePath: "broken/b.txt", Content: "  x\n"})
+	if read("broken/b.txt") != "  x\n" || !strings.Contains(out, "Formatting 'broken/b.txt' with sh failed: syntax error at line 2") {
+		t.Fatalf("expected the more specific formatter to fail, got %q:\n%s", read("broken/b.txt"), out)
+	}
+	os.Mkdir(filepath.Join(configs.WorkingPath, "skip"), 0o755)
+	for _, p := range []string{"c.md", "d.go", "skip/e.txt"} {
+		out = runFileTool(t, Handlers[ToolWriteFile], WriteFileToolArgs{FilePath: p, Content: "  x\n"})
+		if read(p) != "  x\n" || strings.Contains(out, "format") {
+			t.Fatalf("%s should be left alone, got %q:\n%s", p, read(p), out)
+		}
+	}
+}
+
+func TestChangedLines(t *testing.T) {
+	tests := []struct{ before, after, want string }{
+		{"a\nb\nc\n", "a\nB\nc\n", "line 2"},
+		{"a\nb\nc\nd\ne\n", "A\nb\nC\nD\ne\n", "lines 1 and 3-4"},
+		{"a\nb\nc\n", "a\nc\n", "line 2"},
+		{"a\r\nb\r\n", "a\nb\n", "only the line endings"},
+	}
+	for _, tt := range tests {
+		if got := changedLines(tt.before, tt.after); got != tt.want {
+			t.Errorf("changedLines(%q, %q) = %q, want %q", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSandboxed(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	outside := t.TempDir()
+	cfg := sandbox.Config{Mode: sandbox.ModeWorkspace, WritableRoots: []string{configs.WorkingPath}}
+	if err := cfg.Check(); err != nil {
+		t.Skip("the sandbox is not available:", err)
+	}
+	t.Setenv("CLI_AGENT_TEST_TOKEN", "hunter2")
+	UseSandbox(cfg)
+	UseFormatters(configs.FormatSettings{Formatters: map[string][]string{
+		"*.txt": {"sh", "-c", `touch ` + outside + `/no.txt; printf 'token=%s\n' "$CLI_AGENT_TEST_TOKEN"; cat`},
+	}})
+	t.Cleanup(func() {
+		UseSandbox(sandbox.Config{})
+		UseFormatters(configs.FormatSettings{Disabled: true})
+	})
+
+	runFileTool(t, Handlers[ToolWriteFile], WriteFileToolArgs{FilePath: "a.txt", Content: "x\n"})
+	if data, _ := os.ReadFile(filepath.Join(configs.WorkingPath, "a.txt")); string(data) != "token=\nx\n" {
+		t.Errorf("expected the formatter to run without the secrets, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "no.txt")); !os.IsNotExist(err) {
+		t.Error("expected the formatter not to write outside of the workspace")
+	}
+}
@@ -0,0 +1,452 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/git"
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+const (
+	maxGitDiffTokens = 8000
+	defaultGitLog    = 20
+	maxGitLog        = 200
+	maxGitListed     = 200
+)
+
+type GitToolArgs struct {
+	Command string   `json:"command"` // status, diff, log, show, blame, branches, commit or stash
+	Paths   []string `json:"paths"`
+	// Ref is what diff compares against, where log starts, the commit show
+	// shows, the revision blame looks at and the stash to apply or drop.
+	Ref       string `json:"ref"`
+	Staged    bool   `json:"staged"`
+	MaxCount  int    `json:"maxCount"`
+	Skip      int    `json:"skip"`
+	Author    string `json:"author"`
+	Since     string `json:"since"`
+	Until     string `json:"until"`
+	Grep      string `json:"grep"`
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine"`
+	Remote    bool   `json:"remote"`
+	Message   string `json:"message"`
+	All       bool   `json:"all"`
+	Action    string `json:"action"` // of stash: list, push, pop, apply or drop
+	Untracked bool   `json:"untracked"`
+}
+
+// gitWrites reports whether the call changes the repository, which the
+// permission policy asks about.
+func gitWrites(args GitToolArgs) bool {
+	return args.Command == "commit" || args.Command == "stash" && args.Action != "" && args.Action != "list"
+}
+
+// gitSubject is what the permission rules of a git call are matched against,
+// e.g. "commit" or "stash pop".
+func gitSubject(args GitToolArgs) string {
+	if args.Command == "stash" {
+		return "stash " + utils.Ternary(args.Action == "", "list", args.Action)
+	}
+	return args.Command
+}
+
+func handleGit(argsJSON string) (string, error) {
+	var args GitToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	repo := git.Repo{Dir: configs.WorkingPath}
+	root, err := repo.Root(toolCtx)
+	if err != nil {
+		return fmt.Sprintf("The project is not a git repository: %v", err), nil
+	}
+	repo.Sandbox = gitSandbox(repo)
+	g := gitFormatter{repo: repo, root: root}
+
+	paths := make([]string, 0, len(args.Paths))
+	for _, p := range args.Paths {
+		full, err := resolvePath(p)
+		if err != nil {
+			return "", err
+		}
+		paths = append(paths, full)
+	}
+
+	var out string
+	switch args.Command {
+	case "status":
+		out, err = g.status()
+	case "diff":
+		var refs []string
+		if args.Ref != "" {
+			refs = strings.Fields(args.Ref)
+		}
+		out, err = g.diff(git.DiffOptions{Staged: args.Staged, Refs: refs, Paths: paths})
+	case "log":
+		out, err = g.log(git.LogOptions{
+			Ref: args.Ref, MaxCount: utils.Ternary(args.MaxCount > 0, min(args.MaxCount, maxGitLog), defaultGitLog), Skip: args.Skip,
+			Author: args.Author, Since: args.Since, Until: args.Until, Grep: args.Grep, Paths: paths,
+		})
+	case "show":
+		out, err = g.show(args.Ref, paths)
+	case "blame":
+		if len(paths) != 1 {
+			return "Pass the file to blame as the only path.", nil
+		}
+		out, err = g.blame(paths[0], args.StartLine, args.EndLine, args.Ref)
+	case "branches":
+		out, err = g.branches(args.Remote)
+	case "commit":
+		if strings.TrimSpace(args.Message) == "" {
+			return "Pass the commit message.", nil
+		}
+		out, err = g.commit(args.Message, paths, args.All)
+	case "stash":
+		out, err = g.stash(args.Action, args.Ref, args.Message, paths, args.Untracked)
+	default:
+		return fmt.Sprintf("Unknown git command '%s', use status, diff, log, show, blame, branches, commit or stash.", args.Command), nil
+	}
+	if err != nil {
+		// git's own errors, like an unknown revision, are for the model.
+		return err.Error(), nil
+	}
+	return out, nil
+}
+
+// gitSandbox is the sandbox of the bash tool with the git directories, which
+// may be outside of the workspace (e.g. of a worktree), writable. git runs in
+// it since the sandboxed commands can change the hooks and the config of the
+// repository, which run other commands.
+func gitSandbox(repo git.Repo) sandbox.Config {
+	cfg := sandboxConfig
+	if !cfg.Sandboxed() {
+		return cfg
+	}
+	if dirs, err := repo.GitDirs(toolCtx); err == nil {
+		cfg.WritableRoots = append(slices.Clone(cfg.WritableRoots), dirs...)
+	}
+	return cfg
+}
+
+type gitFormatter struct {
+	repo git.Repo
+	root string
+}
+
+// path shows a path git reports, relative to the repository root, like the
+// other tools show paths.
+func (g gitFormatter) path(p string) string {
+	return displayPath(filepath.Join(g.root, p))
+}
+
+func (g gitFormatter) status() (string, error) {
+	s, err := g.repo.Status(toolCtx)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	branch := utils.Ternary(s.Branch == "", "detached at "+s.Commit, s.Branch)
+	fmt.Fprintf(&sb, "<git_status branch=%q", branch)
+	if s.Upstream != "" {
+		fmt.Fprintf(&sb, " upstream=%q ahead=\"%d\" behind=\"%d\"", s.Upstream, s.Ahead, s.Behind)
+	}
+	sb.WriteString(">\n")
+	if s.Commit == "" {
+		sb.WriteString("No commits yet.\n")
+	}
+	writeChanges := func(title string, changes []git.FileChange) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "%s (%d):\n", title, len(changes))
+		for i, c := range changes {
+			if i == maxGitListed {
+				fmt.Fprintf(&sb, "  ... %d more\n", len(changes)-i)
+				break
+			}
+			fmt.Fprintf(&sb, "  %-12s %s", c.Status, g.path(c.Path))
+			if c.OldPath != "" {
+				fmt.Fprintf(&sb, " (from %s)", g.path(c.OldPath))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	writeChanges("Conflicts", s.Conflicted)
+	writeChanges("Staged", s.Staged)
+	writeChanges("Unstaged", s.Unstaged)
+	untracked := make([]git.FileChange, len(s.Untracked))
+	for i, p := range s.Untracked {
+		untracked[i] = git.FileChange{Path: p, Status: "untracked"}
+	}
+	writeChanges("Untracked", untracked)
+	if len(s.Conflicted)+len(s.Staged)+len(s.Unstaged)+len(s.Untracked) == 0 {
+		sb.WriteString("The working tree is clean.\n")
+	}
+	sb.WriteString("</git_status>")
+	return sb.String(), nil
+}
+
+func (g gitFormatter) diff(opts git.DiffOptions) (string, error) {
+	files, err := g.repo.Diff(toolCtx, opts)
+	if err != nil {
+		return "", err
+	}
+	what := "unstaged changes"
+	switch {
+	case len(opts.Refs) > 0:
+		what = "changes of " + strings.Join(opts.Refs, " ")
+	case opts.Staged:
+		what = "staged changes"
+	}
+	if len(files) == 0 {
+		return fmt.Sprintf("No %s.", what), nil
+	}
+	return fmt.Sprintf("<git_diff of=%q>\n%s</git_diff>", what, g.fileDiffs(files)), nil
+}
+
+// fileDiffs lists the changed files and then their patches, as many as fit in
+// the token budget.
+func (g gitFormatter) fileDiffs(files []git.FileDiff) string {
+	var sb strings.Builder
+	added, deleted := 0, 0
+	for _, f := range files {
+		added += f.Added
+		deleted += f.Deleted
+	}
+	fmt.Fprintf(&sb, "%s changed, +%d -%d\n", utils.Plural(len(files), "file"), added, deleted)
+	for _, f := range files {
+		name := g.path(f.Path)
+		if f.OldPath != "" {
+			name = g.path(f.OldPath) + " -> " + name
+		}
+		fmt.Fprintf(&sb, "  %s %s\n", name, utils.Ternary(f.Binary, "(binary)", fmt.Sprintf("(+%d -%d)", f.Added, f.Deleted)))
+	}
+
+	budget := maxGitDiffTokens
+	var omitted []string
+	for _, f := range files {
+		if f.Binary {
+			continue
+		}
+		patch := strings.TrimRight(f.Patch, "\n")
+		tokens := utils.CountTokens(patch)
+		if tokens > budget {
+			if budget < maxGitDiffTokens/4 {
+				omitted = append(omitted, g.path(f.Path))
+				continue
+			}
+			patch = clipToTokens(patch, budget)
+			tokens = budget
+		}
+		budget -= tokens
+		sb.WriteString("\n" + patch + "\n")
+	}
+	if len(omitted) > 0 {
+		fmt.Fprintf(&sb, "\nThe patches of %s were left out to stay within the token budget, pass their paths to see them: %s\n", utils.Plural(len(omitted), "file"), strings.Join(omitted, ", "))
+	}
+	return sb.String()
+}
+
+// clipToTokens keeps the lines of the patch that fit in the budget.
+func clipToTokens(patch string, budget int) string {
+	lines := strings.Split(patch, "\n")
+	used := 0
+	for i, l := range lines {
+		used += utils.CountTokens(l) + 1
+		if used > budget {
+			return strings.Join(lines[:i], "\n") + fmt.Sprintf("\n... %d more lines of this patch were left out, pass a narrower path or use read_files", len(lines)-i)
+		}
+	}
+	return patch
+}
+
+func (g gitFormatter) log(opts git.LogOptions) (string, error) {
+	commits, err := g.repo.Log(toolCtx, opts)
+	if err != nil {
+		return "", err
+	}
+	if len(commits) == 0 {
+		return "No commits match.", nil
+	}
+	var sb strings.Builder
+	sb.WriteString("<git_log>\n")
+	for _, c := range commits {
+		fmt.Fprintf(&sb, "%s %s %s: %s", c.ShortHash(), c.Date.Format("2006-01-02"), c.Author, c.Subject)
+		if c.Refs != "" {
+			fmt.Fprintf(&sb, " (%s)", c.Refs)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("</git_log>")
+	if len(commits) == opts.MaxCount {
+		fmt.Fprintf(&sb, "\nThere may be more commits, pass skip=%d to see the next ones.", opts.Skip+len(commits))
+	}
+	return sb.String(), nil
+}
+
+func (g gitFormatter) show(ref string, paths []string) (string, error) {
+	c, files, err := g.repo.Show(toolCtx, ref, paths)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<git_commit hash=%q>\n", c.Hash)
+	fmt.Fprintf(&sb, "Author: %s <%s>\nDate: %s\n", c.Author, c.Email, c.Date.Format("2006-01-02 15:04:05 -0700"))
+	if c.Refs != "" {
+		fmt.Fprintf(&sb, "Refs: %s\n", c.Refs)
+	}
+	sb.WriteString("\n" + c.Subject + "\n")
+	if c.Body != "" {
+		sb.WriteString("\n" + c.Body + "\n")
+	}
+	if len(files) > 0 {
+		sb.WriteString("\n" + g.fileDiffs(files))
+	}
+	sb.WriteString("</git_commit>")
+	return sb.String(), nil
+}
+
+func (g gitFormatter) blame(file string, start, end int, ref string) (string, error) {
+	lines, err := g.repo.Blame(toolCtx, file, start, end, ref)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<git_blame path=%q>\n", displayPath(file))
+	var commits []git.Commit
+	seen := map[string]bool{}
+	for _, l := range lines {
+		hash := l.Commit.ShortHash()
+		if strings.Trim(l.Commit.Hash, "0") == "" {
+			hash = "uncommitted"
+		}
+		fmt.Fprintf(&sb, "%-11s %4d | %s\n", hash, l.Line, l.Text)
+		if !seen[l.Commit.Hash] && hash != "uncommitted" {
+			seen[l.Commit.Hash] = true
+			commits = append(commits, l.Commit)
+		}
+	}
+	if len(commits) > 0 {
+		sb.WriteString("\nCommits:\n")
+		for _, c := range commits {
+			fmt.Fprintf(&sb, "%s %s %s: %s\n", c.ShortHash(), c.Date.Format("2006-01-02"), c.Author, c.Subject)
+		}
+	}
+	sb.WriteString("</git_blame>")
+	return sb.String(), nil
+}
+
+func (g gitFormatter) branches(remote bool) (string, error) {
+	branches, err := g.repo.Branches(toolCtx, remote)
+	if err != nil {
+		return "", err
+	}
+	if len(branches) == 0 {
+		return "No branches yet.", nil
+	}
+	var sb strings.Builder
+	sb.WriteString("<git_branches>\n")
+	for i, b := range branches {
+		if i == maxGitListed {
+			fmt.Fprintf(&sb, "... %d more\n", len(branches)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "%s %s %s %s %s", utils.Ternary(b.Current, "*", " "), b.Name, b.Commit, b.Date, b.Subject)
+		if b.Upstream != "" {
+			fmt.Fprintf(&sb, " [%s%s]", b.Upstream, utils.Ternary(b.Track == "", "", ": "+b.Track))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("</git_branches>")
+	return sb.String(), nil
+}
+
+func (g gitFormatter) commit(message string, paths []string, all bool) (string, error) {
+	c, files, err := g.repo.Commit(toolCtx, message, paths, all)
+	if err != nil {
+		if errors.Is(err, git.ErrNothingToCommit) {
+			return "Nothing to commit, stage files by passing their paths or set all to commit every change to a tracked file.", nil
+		}
+		return "", err
+	}
+	added, deleted := 0, 0
+	for _, f := range files {
+		added += f.Added
+		deleted += f.Deleted
+	}
+	return fmt.Sprintf("Committed %s: %s\n%s changed, +%d -%d", c.ShortHash(), c.Subject, utils.Plural(len(files), "file"), added, deleted), nil
+}
+
+func (g gitFormatter) stash(action, ref, message string, paths []string, untracked bool) (string, error) {
+	switch action {
+	case "", "list":
+		stashes, err := g.repo.StashList(toolCtx)
+		if err != nil {
+			return "", err
+		}
+		if len(stashes) == 0 {
+			return "No stashes.", nil
+		}
+		var sb strings.Builder
+		sb.WriteString("<git_stashes>\n")
+		for _, s := range stashes {
+			fmt.Fprintf(&sb, "%s %s %s\n", s.Ref, s.Date.Format("2006-01-02"), s.Subject)
+		}
+		sb.WriteString("</git_stashes>")
+		return sb.String(), nil
+	case "push":
+		if st, err := g.repo.Status(toolCtx); err == nil {
+			var changed []string
+			for _, c := range append(st.Staged, st.Unstaged...) {
+				changed = append(changed, filepath.Join(g.root, c.Path))
+			}
+			for _, p := range utils.Ternary(untracked, st.Untracked, nil) {
+				changed = append(changed, filepath.Join(g.root, p))
+			}
+			if err := snapshotFiles(ToolGit, changed...); err != nil {
+				return "", err
+			}
+		}
+		ok, err := g.repo.StashPush(toolCtx, message, paths, untracked)
+		if err != nil {
+			return "", err
+		}
+		return utils.Ternary(ok, "Stashed the changes as stash@{0}.", "No local changes to stash."), nil
+	case "pop", "apply":
+		if files, err := g.repo.StashFiles(toolCtx, ref); err == nil {
+			for i, f := range files {
+				files[i] = filepath.Join(g.root, f)
+			}
+			if err := snapshotFiles(ToolGit, files...); err != nil {
+				return "", err
+			}
+		}
+		out, err := g.repo.StashApply(toolCtx, ref, action == "pop")
+		if err != nil {
+			return "", err
+		}
+		s, err := g.status()
+		if err != nil {
+			return "", err
+		}
+		what := utils.Ternary(ref == "", "the latest stash", ref)
+		if action == "pop" && strings.Contains(out, "Dropped") {
+			return fmt.Sprintf("Applied and dropped %s.\n%s", what, s), nil
+		}
+		return fmt.Sprintf("Applied %s.\n%s", what, s), nil
+	case "drop":
+		if err := g.repo.StashDrop(toolCtx, ref); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Dropped %s.", utils.Ternary(ref == "", "the latest stash", ref)), nil
+	}
+	return fmt.Sprintf("Unknown stash action '%s', use list, push, pop, apply or drop.", action), nil
+}
@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
+)
+
+func runGit(t *testing.T, args GitToolArgs) string {
+	t.Helper()
+	argsJSON, _ := json.Marshal(args)
+	out, err := handleGit(string(argsJSON))
+	if err != nil {
+		t.Fatalf("git failed: %v", err)
+	}
+	return out
+}
+
+func TestGitTool(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	for _, kv := range [][2]string{{"GIT_AUTHOR_NAME", "Ann"}, {"GIT_AUTHOR_EMAIL", "ann@example.com"}, {"GIT_COMMITTER_NAME", "Ann"}, {"GIT_COMMITTER_EMAIL", "ann@example.com"}} {
+		t.Setenv(kv[0], kv[1])
+	}
+	if out := runGit(t, GitToolArgs{Command: "status"}); !strings.Contains(out, "not a git repository") {
+		t.Fatalf("expected the missing repository to be reported, got %q", out)
+	}
+	if out, err := exec.Command("git", "init", "-q", "-b", "main", configs.WorkingPath).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	write := func(p, content string) {
+		if err := os.WriteFile(filepath.Join(configs.WorkingPath, p), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("a.txt", "one\ntwo\n")
+	if out := runGit(t, GitToolArgs{Command: "commit", Message: "Nothing staged"}); !strings.HasPrefix(out, "Nothing to commit") {
+		t.Fatalf("expected nothing to commit, got %q", out)
+	}
+	out := runGit(t, GitToolArgs{Command: "commit", Message: "Add a", Paths: []string{"a.txt"}})
+	if !strings.Contains(out, ": Add a\n1 file changed, +2 -0") {
+		t.Fatalf("unexpected commit output %q", out)
+	}
+
+	write("a.txt", "one\nTWO\n")
+	write("b.txt", "new\n")
+	out = runGit(t, GitToolArgs{Command: "status"})
+	for _, want := range []string{`<git_status branch="main">`, "Unstaged (1):\n  modified     a.txt", "Untracked (1):\n  untracked    b.txt"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("status is missing %q:\n%s", want, out)
+		}
+	}
+	out = runGit(t, GitToolArgs{Command: "diff"})
+	for _, want := range []string{"1 file changed, +1 -1", "-two\n+TWO"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diff is missing %q:\n%s", want, out)
+		}
+	}
+	if out := runGit(t, GitToolArgs{Command: "diff", Staged: true}); out != "No staged changes." {
+		t.Errorf("unexpected staged diff %q", out)
+	}
+
+	runGit(t, GitToolArgs{Command: "commit", Message: "Change a", All: true})
+	out = runGit(t, GitToolArgs{Command: "log", MaxCount: 1})
+	if !strings.Contains(out, "Ann: Change a (HEAD -> main)") || !strings.Contains(out, "pass skip=1") {
+		t.Errorf("unexpected log %q", out)
+	}
+	out = runGit(t, GitToolArgs{Command: "blame", Paths: []string{"a.txt"}, StartLine: 2, EndLine: 2})
+	if !strings.Contains(out, "2 | TWO") || !strings.Contains(out, "Ann: Change a") || strings.Contains(out, "Add a") {
+		t.Errorf("unexpected blame %q", out)
+	}
+	if out := runGit(t, GitToolArgs{Command: "show", Ref: "nope"}); strings.Contains(out, "<git_commit") {
+		t.Errorf("expected an unknown revision to be reported, got %q", out)
+	}
+	outside := filepath.Join(t.TempDir(), "out")
+	if out := runGit(t, GitToolArgs{Command: "diff", Ref: "HEAD~1 --output=" + outside}); !strings.Contains(out, "invalid revision") {
+		t.Errorf("expected a ref looking like an option to be refused, got %q", out)
+	}
+	if _, err := os.Stat(outside); !os.IsNotExist(err) {
+		t.Error("expected git diff not to write the file")
+	}
+
+	write("a.txt", "stashed\n")
+	if out := runGit(t, GitToolArgs{Command: "stash", Action: "push", Message: "wip"}); out != "Stashed the changes as stash@{0}." {
+		t.Errorf("unexpected stash push %q", out)
+	}
+	if out := runGit(t, GitToolArgs{Command: "stash"}); !strings.Contains(out, "stash@{0}") || !strings.Contains(out, "wip") {
+		t.Errorf("unexpected stash list %q", out)
+	}
+	runGit(t, GitToolArgs{Command: "stash", Action: "pop"})
+	if data, _ := os.ReadFile(filepath.Join(configs.WorkingPath, "a.txt")); string(data) != "stashed\n" {
+		t.Errorf("the stash was not popped: %q", data)
+	}
+}
+
+func TestGitReadOnly(t *testing.T) {
+	calls := []struct {
+		args GitToolArgs
+		want bool
+	}{
+		{GitToolArgs{Command: "status"}, true},
+		{GitToolArgs{Command: "diff"}, true},
+		{GitToolArgs{Command: "stash"}, true},
+		{GitToolArgs{Command: "stash", Action: "list"}, true},
+		{GitToolArgs{Command: "commit"}, false},
+		{GitToolArgs{Command: "stash", Action: "push"}, false},
+		{GitToolArgs{Command: "stash", Action: "pop"}, false},
+		{GitToolArgs{Command: "stash", Action: "drop"}, false},
+		{GitToolArgs{Command: "stash", Action: "apply"}, false},
+	}
+	for _, c := range calls {
+		argsJSON, _ := json.Marshal(c.args)
+		if got := ReadOnly(ToolGit, string(argsJSON)); got != c.want {
+			t.Errorf("ReadOnly(%+v) = %v, want %v", c.args, got, c.want)
+		}
+	}
+	argsJSON, _ := json.Marshal(GitToolArgs{Command: "stash", Action: "pop"})
+	if got := PermissionSubjects(ToolGit, string(argsJSON)); len(got) != 1 || got[0] != "stash pop" {
+		t.Errorf("unexpected git subjects %q", got)
+	}
+}
+
+func TestGitSandboxed(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	gitDir, outside := t.TempDir(), t.TempDir()
+	cfg := sandbox.Config{Mode: sandbox.ModeWorkspace, WritableRoots: []string{configs.WorkingPath}}
+	if err := cfg.Check(); err != nil {
+		t.Skip("the sandbox is not available:", err)
+	}
+	for _, kv := range [][2]string{{"GIT_AUTHOR_NAME", "Ann"}, {"GIT_AUTHOR_EMAIL", "ann@example.com"}, {"GIT_COMMITTER_NAME", "Ann"}, {"GIT_COMMITTER_EMAIL", "ann@example.com"}} {
+		t.Setenv(kv[0], kv[1])
+	}
+	// The git directory is outside of the workspace, like the one of a worktree.
+	if out, err := exec.Command("git", "init", "-q", "-b", "main", "--separate-git-dir", gitDir, configs.WorkingPath).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	hook := "#!/bin/sh\ntouch " + filepath.Join(outside, "hooked") + "\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(gitDir, "hooks", "pre-commit"), []byte(hook), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configs.WorkingPath, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	UseSandbox(cfg)
+	t.Cleanup(func() { UseSandbox(sandbox.Config{}) })
+
+	if out := runGit(t, GitToolArgs{Command: "commit", Message: "Add a", Paths: []string{"a.txt"}}); !strings.Contains(out, ": Add a\n") {
+		t.Fatalf("expected the commit to be made in the sandbox, got %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "hooked")); !os.IsNotExist(err) {
+		t.Error("expected the hook to run in the sandbox")
+	}
+}
@@ -1,56 +1,282 @@
 package tools
 
 import (
-    "os"
-    "path/filepath"
-    "strings"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const agentIgnoreFile = ".agentignore"
 
-    "github.com/sifatulrabbi/cli-agent/internals/configs"
+// defaultIgnorePatterns are applied to every project before its own ignore
+// files, which may re-include any of them with a negated pattern.
+var defaultIgnorePatterns = []string{
+	".git/",
+	".venv/",
+	"env/",
+	".vscode/",
+	".idea/",
+	"node_modules/",
+	"__pycache__/",
+	"build/",
+	"dist/",
+	".cache/",
+	".tmp/",
+	"tmp/",
+	".DS_Store",
+	".env",
+	"*.env.*",
+	"*.log",
+	"*.db",
+	"*.sqlite",
+	"*.egg",
+	"*.egg-info",
+	"*.pyc",
+	"*.ignore.*",
+}
+
+type ignoreRule struct {
+	base    string // directory of the ignore file, relative to the root ("" for the root)
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// IgnoreMatcher implements the .gitignore rules of a project: the built-in
+// defaults, .git/info/exclude, and every .gitignore and .agentignore file,
+// each scoped to the directory it lives in. Later rules take precedence, so
+// nested ignore files override their parents.
+type IgnoreMatcher struct {
+	root  string
+	rules []ignoreRule
+	// stamps are the modification times of the directories and the ignore
+	// files the rules were loaded from, the zero time for missing files.
+	stamps map[string]time.Time
+}
+
+var (
+	ignoreMatchersMu sync.Mutex
+	ignoreMatchers   = map[string]*IgnoreMatcher{}
 )
 
-func detectGitIgnores() {
-    entries, _ := traverseDir(configs.WorkingPath)
-    dedup := make(map[string]struct{}, len(ignoreFiles)+len(ignoreDirs))
-    for _, v := range ignoreFiles {
-        dedup[v] = struct{}{}
-    }
-    for _, v := range ignoreDirs {
-        dedup[v] = struct{}{}
-    }
-    for _, e := range entries {
-        if filepath.Base(e) != ".gitignore" {
-            continue
-        }
-
-        fullPath := filepath.Join(configs.WorkingPath, e)
-        data, rErr := os.ReadFile(fullPath)
-        if rErr != nil {
-            continue
-        }
-
-        lines := safeSplit(string(data))
-
-        for _, raw := range lines {
-            s := strings.TrimSpace(raw)
-            if _, ok := dedup[s]; ok || s == "" || strings.HasPrefix(s, "#") || strings.HasPrefix(s, "!") {
-                continue
-            }
-
-            dedup[s] = struct{}{}
-
-            isDir := strings.HasSuffix(s, "/")
-            s = strings.TrimPrefix(s, "/")
-            s = strings.TrimSuffix(s, "/")
-            s = filepath.ToSlash(s)
-
-            if s == "" {
-                continue
-            }
-            if isDir {
-                ignoreDirs = append(ignoreDirs, s)
-            } else {
-                ignoreFiles = append(ignoreFiles, s)
-            }
-        }
-    }
+// NewIgnoreMatcher builds the matcher for the project at root by loading the
+// ignore files of every directory that is not itself ignored.
+func NewIgnoreMatcher(root string) *IgnoreMatcher {
+	m := &IgnoreMatcher{root: root, stamps: map[string]time.Time{}}
+	m.addPatterns("", defaultIgnorePatterns)
+	m.loadFile("", filepath.Join(root, ".git", "info", "exclude"))
+
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		rel := relSlash(root, p)
+		if rel != "" && m.match(rel, true) {
+			return filepath.SkipDir
+		}
+		// A new ignore file or directory changes the time of its directory.
+		m.stamp(p)
+		m.loadFile(rel, filepath.Join(p, ".gitignore"))
+		m.loadFile(rel, filepath.Join(p, agentIgnoreFile))
+		return nil
+	})
+	return m
+}
+
+// ignoreMatcher returns the matcher of the root, which is only rebuilt once
+// the ignore files or the directories change, instead of on every call.
+func ignoreMatcher(root string) *IgnoreMatcher {
+	ignoreMatchersMu.Lock()
+	defer ignoreMatchersMu.Unlock()
+	if m := ignoreMatchers[root]; m != nil && m.upToDate() {
+		return m
+	}
+	m := NewIgnoreMatcher(root)
+	ignoreMatchers[root] = m
+	return m
+}
+
+// forgetIgnoreMatchers drops the cached matchers when the tools edit files,
+// as a change may be too quick for the modification times to show.
+func forgetIgnoreMatchers() {
+	ignoreMatchersMu.Lock()
+	defer ignoreMatchersMu.Unlock()
+	clear(ignoreMatchers)
+}
+
+func (m *IgnoreMatcher) stamp(p string) {
+	if info, err := os.Stat(p); err == nil {
+		m.stamps[p] = info.ModTime()
+	} else {
+		m.stamps[p] = time.Time{}
+	}
+}
+
+func (m *IgnoreMatcher) upToDate() bool {
+	for p, t := range m.stamps {
+		info, err := os.Stat(p)
+		if err != nil && !t.IsZero() || err == nil && !info.ModTime().Equal(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// Ignored reports whether the slash separated path, relative to the root, is
+// ignored either by itself or because one of its parent directories is.
+func (m *IgnoreMatcher) Ignored(rel string, isDir bool) bool {
+	rel = strings.Trim(filepath.ToSlash(rel), "/")
+	if rel == "" || rel == "." {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.match(rel, isDir)
+}
+
+// match checks the path against the rules without looking at its parents,
+// which is enough when walking the tree top-down and skipping ignored dirs.
+func (m *IgnoreMatcher) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		sub := rel
+		if r.base != "" {
+			var ok bool
+			if sub, ok = strings.CutPrefix(rel, r.base+"/"); !ok {
+				continue
+			}
+		}
+		if r.re.MatchString(sub) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func (m *IgnoreMatcher) loadFile(base, p string) {
+	if m.stamps != nil {
+		m.stamp(p)
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return
+	}
+	m.addPatterns(base, safeSplit(string(data)))
+}
+
+func (m *IgnoreMatcher) addPatterns(base string, patterns []string) {
+	for _, raw := range patterns {
+		if r, ok := parseIgnorePattern(base, raw); ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+}
+
+func parseIgnorePattern(base, raw string) (ignoreRule, bool) {
+	s := trimIgnoreTrailingSpaces(raw)
+	if s == "" || strings.HasPrefix(s, "#") {
+		return ignoreRule{}, false
+	}
+
+	r := ignoreRule{base: base}
+	if strings.HasPrefix(s, "!") {
+		r.negate = true
+		s = s[1:]
+	} else if strings.HasPrefix(s, `\!`) || strings.HasPrefix(s, `\#`) {
+		s = s[1:]
+	}
+	if strings.HasSuffix(s, "/") {
+		r.dirOnly = true
+		s = strings.TrimRight(s, "/")
+	}
+	if s == "" {
+		return ignoreRule{}, false
+	}
+
+	// A slash anywhere but at the end anchors the pattern to the ignore
+	// file's directory, otherwise it matches a name at any depth.
+	anchored := strings.Contains(s, "/")
+	s = strings.TrimPrefix(s, "/")
+	expr := globToRegexp(s)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && (i == 0 || glob[i-1] == '/' && i+2 == len(glob)):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+			for i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+			}
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// trimIgnoreTrailingSpaces drops the trailing spaces of a pattern unless they
+// are escaped with a backslash.
+func trimIgnoreTrailingSpaces(s string) string {
+	s = strings.TrimRight(s, "\r")
+	for strings.HasSuffix(s, " ") && !strings.HasSuffix(s, `\ `) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func relSlash(root, p string) string {
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return path.Clean(filepath.ToSlash(rel))
 }
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":              "*.txt\n!keep.txt\n/only-root.md\nlogs/**/debug\ndocs/**/*.tmp.md\nsecret\\ \n",
+		".git/info/exclude":       "local.go\n",
+		".agentignore":            "fixtures/\n",
+		"sub/.gitignore":          "!*.txt\nvendor/\n",
+		"buildscript.sh":          "",
+		"build/out.bin":           "",
+		"notes.txt":               "",
+		"keep.txt":                "",
+		"only-root.md":            "",
+		"sub/only-root.md":        "",
+		"sub/notes.txt":           "",
+		"sub/vendor/lib.go":       "",
+		"vendor/lib.go":           "",
+		"logs/debug":              "",
+		"logs/a/b/debug":          "",
+		"docs/x/y/page.tmp.md":    "",
+		"docs/page.md":            "",
+		"local.go":                "",
+		"fixtures/data.json":      "",
+		"secret ":                 "",
+		"main.go":                 "",
+		"node_modules/x/index.js": "",
+	}
+	for p, content := range files {
+		full := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewIgnoreMatcher(root)
+	ignored := []string{"build/out.bin", "notes.txt", "only-root.md", "sub/vendor/lib.go", "logs/debug",
+		"logs/a/b/debug", "docs/x/y/page.tmp.md", "local.go", "fixtures/data.json", "secret ", "node_modules/x/index.js"}
+	visible := []string{"buildscript.sh", "keep.txt", "sub/only-root.md", "sub/notes.txt", "vendor/lib.go", "docs/page.md", "main.go", ".gitignore"}
+	for _, p := range ignored {
+		if !m.Ignored(p, false) {
+			t.Errorf("expected %q to be ignored", p)
+		}
+	}
+	for _, p := range visible {
+		if m.Ignored(p, false) {
+			t.Errorf("expected %q to be visible", p)
+		}
+	}
+
+	w := &lsWalker{root: root, ignores: m, maxDepth: 10}
+	entries := w.walk(root, "", 0).paths(".")
+	for _, p := range visible {
+		if !slices.Contains(entries, "./"+p) {
+			t.Errorf("expected ls to list %q, got %v", p, entries)
+		}
+	}
+	for _, p := range ignored {
+		if slices.Contains(entries, "./"+p) {
+			t.Errorf("expected ls to hide %q", p)
+		}
+	}
+	if slices.Contains(entries, "./.git/") || slices.Contains(entries, "./node_modules/") {
+		t.Errorf("expected the ignored directories to be skipped, got %v", entries)
+	}
+}
+
+// paths flattens the tree into paths starting with prefix, with a trailing
+// slash for the directories.
+func (n *lsNode) paths(prefix string) []string {
+	var out []string
+	for _, c := range n.children {
+		p := prefix + "/" + c.name
+		if c.isDir {
+			out = append(out, p+"/")
+			out = append(out, c.paths(p)...)
+		} else {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func TestIgnoreMatcherWhitelist(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("/**\n!/**/\n!*.go\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewIgnoreMatcher(root)
+	for p, want := range map[string]bool{"notes.txt": true, "a/b/notes.txt": true, "main.go": false, "a/b/main.go": false} {
+		if got := m.Ignored(p, false); got != want {
+			t.Errorf("Ignored(%q) = %v, want %v", p, got, want)
+		}
+	}
+	if m.Ignored("a/b", true) {
+		t.Error("expected the directories to be re-included")
+	}
+}
+
+func TestIgnoreMatcherIsCached(t *testing.T) {
+	root := t.TempDir()
+	t.Cleanup(forgetIgnoreMatchers)
+	m := ignoreMatcher(root)
+	if ignoreMatcher(root) != m {
+		t.Fatal("expected the matcher to be reused")
+	}
+
+	// An old modification time stands for an edit made a while ago.
+	past := time.Now().Add(-time.Hour)
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(filepath.Join(root, "sub"), past, past)
+	if ignoreMatcher(root) == m {
+		t.Fatal("expected a new directory to rebuild the matcher")
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", ".gitignore"), []byte("*.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !ignoreMatcher(root).Ignored("sub/notes.txt", false) {
+		t.Error("expected the new ignore file to be loaded")
+	}
+}
@@ -0,0 +1,278 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sifatulrabbi/cli-agent/internals/gonav"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+const (
+	defaultSymbolMaxResults = 100
+	maxSymbolDeclLines      = 80
+)
+
+type CodeOutlineToolArgs struct {
+	FilePath string `json:"filePath"`
+}
+
+type FindSymbolToolArgs struct {
+	Symbol     string `json:"symbol"`
+	Action     string `json:"action"` // definition, references, implementations or methods
+	Path       string `json:"path"`   // any path inside the Go module, defaults to the WorkingPath
+	MaxResults int    `json:"maxResults"`
+}
+
+// handleCodeOutline lists the declarations of a Go file with their line
+// ranges, so the relevant part can be read without reading the whole file.
+func handleCodeOutline(argsJSON string) (string, error) {
+	var args CodeOutlineToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	full, err := resolvePath(args.FilePath)
+	if err != nil {
+		return "", err
+	}
+	if filepath.Ext(full) != ".go" {
+		return fmt.Sprintf("'%s' is not a Go file, code_outline only supports Go.", args.FilePath), nil
+	}
+	src, err := os.ReadFile(full)
+	if err != nil {
+		return fmt.Sprintf("Failed to read file %q: %s", args.FilePath, err), nil
+	}
+	pkgName, entries, err := gonav.Outline(full, src)
+	if pkgName == "" {
+		return fmt.Sprintf("Unable to parse '%s': %s", args.FilePath, err), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<outline path=%q package=%q>\n", displayPath(full), pkgName)
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "%s%-9s %s\n", strings.Repeat("  ", e.Depth), fmt.Sprintf("%d-%d", e.Start, e.End), e.Signature)
+	}
+	sb.WriteString("</outline>")
+	if err != nil {
+		fmt.Fprintf(&sb, "\nThe file has syntax errors, the outline may be incomplete: %s", err)
+	}
+	return sb.String(), nil
+}
+
+// handleFindSymbol answers questions about a symbol of a Go module using the
+// type checker instead of text search.
+func handleFindSymbol(argsJSON string) (string, error) {
+	var args FindSymbolToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(args.Symbol) == "" {
+		return "", fmt.Errorf("no symbol provided")
+	}
+	if args.Path == "" {
+		args.Path = "."
+	}
+	if args.Action == "" {
+		args.Action = "definition"
+	}
+	if args.MaxResults < 1 {
+		args.MaxResults = defaultSymbolMaxResults
+	}
+
+	dir, err := resolvePath(args.Path)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	root, err := gonav.FindModuleRoot(dir)
+	if err != nil {
+		return fmt.Sprintf("Unable to find the Go module: %s. Pass a path inside of the module.", err), nil
+	}
+	prog, err := gonav.Load(root)
+	if err != nil {
+		return fmt.Sprintf("Unable to load the Go module at %s: %s", displayPath(root), err), nil
+	}
+
+	objs := prog.Lookup(args.Symbol)
+	if len(objs) == 0 {
+		return fmt.Sprintf("No symbol named %q was found in %s. Use Name, pkg.Name, Type.Method or pkg.Type.Method.", args.Symbol, prog.Module), nil
+	}
+
+	f := &symbolFormatter{prog: prog, lines: map[string][]string{}, maxResults: args.MaxResults}
+	parts := []string{}
+	for _, obj := range objs {
+		switch args.Action {
+		case "definition":
+			parts = append(parts, f.definition(obj))
+		case "references":
+			parts = append(parts, f.references(obj))
+		case "implementations":
+			parts = append(parts, f.implementations(obj))
+		case "methods":
+			parts = append(parts, f.methods(obj))
+		default:
+			return fmt.Sprintf("Unknown action %q, use definition, references, implementations or methods.", args.Action), nil
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+type symbolFormatter struct {
+	prog       *gonav.Program
+	lines      map[string][]string // the lines of the files read so far
+	maxResults int
+}
+
+func (f *symbolFormatter) definition(obj types.Object) string {
+	start, end, ok := f.prog.DeclRange(obj)
+	if !ok {
+		return fmt.Sprintf("%s is declared outside of the module.", describeObject(obj))
+	}
+	lines := f.fileLines(start.Filename)
+	last := min(end.Line, start.Line+maxSymbolDeclLines-1, len(lines))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n<definition path=%q lines=\"%d-%d\">\n", describeObject(obj), displayPath(start.Filename), start.Line, end.Line)
+	width := len(fmt.Sprint(last))
+	for i := start.Line; i <= last; i++ {
+		fmt.Fprintf(&sb, "%*d | %s\n", width, i, lines[i-1])
+	}
+	sb.WriteString("</definition>")
+	if last < end.Line {
+		fmt.Fprintf(&sb, "\nThe declaration continues until line %d, use read_files to see the rest.", end.Line)
+	}
+	return sb.String()
+}
+
+func (f *symbolFormatter) references(obj types.Object) string {
+	refs := f.prog.References(obj)
+	if len(refs) == 0 {
+		return fmt.Sprintf("%s is not referenced anywhere in the module.", describeObject(obj))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s is referenced %s:\n", describeObject(obj), utils.Plural(len(refs), "time"))
+	f.writePositions(&sb, refs)
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (f *symbolFormatter) implementations(obj types.Object) string {
+	tn, ok := obj.(*types.TypeName)
+	if !ok {
+		return fmt.Sprintf("%s is not a type, implementations only work on interfaces and types.", describeObject(obj))
+	}
+	found := f.prog.Implementations(tn)
+	_, isIface := tn.Type().Underlying().(*types.Interface)
+	what := utils.Ternary(isIface, "types of the module implementing it", "interfaces of the module it implements")
+	if len(found) == 0 {
+		return fmt.Sprintf("%s: there are no %s.", describeObject(obj), what)
+	}
+	positions := make([]token.Position, 0, len(found))
+	for _, impl := range found {
+		positions = append(positions, f.prog.Fset.Position(impl.Pos()))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s, the %s:\n", describeObject(obj), what)
+	f.writePositions(&sb, positions)
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (f *symbolFormatter) methods(obj types.Object) string {
+	tn, ok := obj.(*types.TypeName)
+	if !ok {
+		return fmt.Sprintf("%s is not a type, methods only work on types.", describeObject(obj))
+	}
+	methods := f.prog.MethodSet(tn)
+	if len(methods) == 0 {
+		return fmt.Sprintf("%s has no methods.", describeObject(obj))
+	}
+	qualifier := func(pkg *types.Package) string {
+		return utils.Ternary(pkg == tn.Pkg(), "", pkg.Name())
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s has %s:\n", describeObject(obj), utils.Plural(len(methods), "method"))
+	for _, sel := range methods {
+		fn := sel.Obj().(*types.Func)
+		sig := fn.Type().(*types.Signature)
+		recv := ""
+		if sig.Recv() != nil {
+			recv = "(" + types.TypeString(sig.Recv().Type(), qualifier) + ") "
+		}
+		line := "  func " + recv + fn.Name() + strings.TrimPrefix(types.TypeString(sig, qualifier), "func")
+		if len(sel.Index()) > 1 {
+			line += " (promoted)"
+		}
+		if f.prog.Declares(fn) {
+			pos := f.prog.Fset.Position(fn.Pos())
+			line += fmt.Sprintf("  %s:%d", displayPath(pos.Filename), pos.Line)
+		}
+		sb.WriteString(line + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// writePositions lists the positions grouped by file, with their lines.
+func (f *symbolFormatter) writePositions(sb *strings.Builder, positions []token.Position) {
+	file := ""
+	for i, pos := range positions {
+		if i == f.maxResults {
+			fmt.Fprintf(sb, "... %d more not shown, raise maxResults to see them.\n", len(positions)-i)
+			break
+		}
+		if pos.Filename != file {
+			file = pos.Filename
+			sb.WriteString(displayPath(file) + "\n")
+		}
+		text := ""
+		if lines := f.fileLines(file); pos.Line <= len(lines) {
+			text = strings.TrimSpace(lines[pos.Line-1])
+		}
+		fmt.Fprintf(sb, "  %d: %s\n", pos.Line, text)
+	}
+}
+
+func (f *symbolFormatter) fileLines(filename string) []string {
+	if lines, ok := f.lines[filename]; ok {
+		return lines
+	}
+	data, _ := os.ReadFile(filename)
+	lines := safeSplit(string(data))
+	f.lines[filename] = lines
+	return lines
+}
+
+// describeObject names the kind and the qualified name of obj, e.g. "method
+// (*agent.Agent).Invoke".
+func describeObject(obj types.Object) string {
+	pkg := ""
+	if obj.Pkg() != nil {
+		pkg = obj.Pkg().Name() + "."
+	}
+	switch o := obj.(type) {
+	case *types.Func:
+		if recv := o.Type().(*types.Signature).Recv(); recv != nil {
+			return fmt.Sprintf("method (%s).%s", types.TypeString(recv.Type(), (*types.Package).Name), o.Name())
+		}
+		return "func " + pkg + o.Name()
+	case *types.TypeName:
+		kind := "type"
+		if _, ok := o.Type().Underlying().(*types.Interface); ok {
+			kind = "interface"
+		}
+		return kind + " " + pkg + o.Name()
+	case *types.Var:
+		if o.IsField() {
+			return "field " + o.Name() + " " + types.TypeString(o.Type(), (*types.Package).Name)
+		}
+		return "var " + pkg + o.Name()
+	case *types.Const:
+		return "const " + pkg + o.Name()
+	}
+	return pkg + obj.Name()
+}
@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func TestGoCodeTools(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.22\n",
+		"provider/provider.go": `package provider
+
+// Provider talks to a model.
+type Provider interface {
+	Invoke(prompt string) (string, error)
+}
+
+type Echo struct{}
+
+func (Echo) Invoke(prompt string) (string, error) {
+	return prompt, nil
+}
+`,
+		"main.go": "package main\n\nimport \"example.com/app/provider\"\n\nfunc main() {\n\tvar p provider.Provider = provider.Echo{}\n\tp.Invoke(\"hi\")\n}\n",
+	}
+	for p, content := range files {
+		full := filepath.Join(configs.WorkingPath, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	find := func(args FindSymbolToolArgs) string {
+		argsJSON, _ := json.Marshal(args)
+		out, err := handleFindSymbol(string(argsJSON))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	out, err := handleCodeOutline(`{"filePath": "provider/provider.go"}`)
+	if err != nil || !strings.Contains(out, "<outline path=\"provider/provider.go\" package=\"provider\">\n4-6       type Provider interface\n  5-5       Invoke(prompt string) (string, error)\n") {
+		t.Fatalf("unexpected outline:\n%s", out)
+	}
+
+	out = find(FindSymbolToolArgs{Symbol: "Echo.Invoke"})
+	if !strings.Contains(out, "method (provider.Echo).Invoke\n<definition path=\"provider/provider.go\" lines=\"10-12\">\n10 | func (Echo) Invoke") {
+		t.Fatalf("unexpected definition:\n%s", out)
+	}
+	out = find(FindSymbolToolArgs{Symbol: "Provider.Invoke", Action: "references"})
+	if !strings.Contains(out, "is referenced 1 time:\nmain.go\n  7: p.Invoke(\"hi\")") {
+		t.Fatalf("unexpected references:\n%s", out)
+	}
+	out = find(FindSymbolToolArgs{Symbol: "provider.Provider", Action: "implementations"})
+	if !strings.Contains(out, "provider/provider.go\n  8: type Echo struct{}") {
+		t.Fatalf("unexpected implementations:\n%s", out)
+	}
+	out = find(FindSymbolToolArgs{Symbol: "Echo", Action: "methods"})
+	if !strings.Contains(out, "func (Echo) Invoke(prompt string) (string, error)  provider/provider.go:10") {
+		t.Fatalf("unexpected methods:\n%s", out)
+	}
+	if out := find(FindSymbolToolArgs{Symbol: "Missing"}); !strings.Contains(out, "No symbol named") {
+		t.Fatalf("expected the symbol to be missing, got:\n%s", out)
+	}
+}
@@ -2,12 +2,25 @@ package tools
 
 // Handlers maps tool name to an executor that returns a string result.
 var Handlers = map[string]func(argsJSON string) (string, error){
-	ToolListFiles:      handleListFiles,
-	ToolReadFiles:      handleReadFiles,
-	ToolAppendFile:     handleAppendFile,
-	ToolPatchFile:      handlePatchTextFile,
-	ToolGrep:           handleGrep,
-	ToolBash:           handleBash,
-	ToolAddTodo:        handleAddTodo,
-	ToolMarkTodoAsDone: handleMarkTodoAsDone,
+	ToolListFiles:   handleListFiles,
+	ToolReadFiles:   handleReadFiles,
+	ToolAppendFile:  afterEdits(handleAppendFile),
+	ToolPatchFile:   afterEdits(handlePatchTextFile),
+	ToolEditFile:    afterEdits(handleEditFile),
+	ToolApplyPatch:  afterEdits(handleApplyPatch),
+	ToolWriteFile:   afterEdits(handleWriteFile),
+	ToolDeletePath:  handleDeletePath,
+	ToolMovePath:    afterEdits(handleMovePath),
+	ToolCopyPath:    afterEdits(handleCopyPath),
+	ToolSearch:      handleSearch,
+	ToolCodeOutline: handleCodeOutline,
+	ToolFindSymbol:  handleFindSymbol,
+	ToolDiagnostics: handleDiagnostics,
+	ToolBash:        handleBash,
+	ToolRunTests:    handleRunTests,
+	ToolGit:         handleGit,
+	ToolWebFetch:    handleWebFetch,
+	ToolWebSearch:   handleWebSearch,
+	ToolTodoWrite:   handleTodoWrite,
+	ToolReadOutput:  handleReadOutput,
 }
@@ -0,0 +1,337 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+const (
+	defaultListDepth = 3
+	maxListDepth     = 10
+	defaultListLimit = 200
+	maxListLimit     = 1000
+	// Directories with more files than this get a summary of their files
+	// instead of the list, the subdirectories are listed either way.
+	maxListedFilesPerDir = 50
+)
+
+type ListFilesToolArgs struct {
+	Path     string   `json:"path"`
+	MaxDepth int      `json:"maxDepth"`
+	Include  []string `json:"include"`
+	Exclude  []string `json:"exclude"`
+	Limit    int      `json:"limit"`
+	Cursor   string   `json:"cursor"`
+}
+
+type lsNode struct {
+	name     string
+	isDir    bool
+	size     int64
+	dirs     int // direct subdirectories, after the ignore rules
+	files    int // direct files, after the ignore rules
+	expanded bool
+	children []*lsNode
+	summary  string // replaces the files of huge directories
+	note     string // replaces the counts of the directories not listed
+}
+
+type lsWalker struct {
+	root             string // the workspace root the ignore rules are relative to
+	ignores          *IgnoreMatcher
+	include, exclude []*regexp.Regexp
+	maxDepth         int
+	roots            []string
+	// visited maps the directories listed so far, with their symlinks
+	// resolved, to their paths in the listing, so symlinks don't loop.
+	visited map[string]string
+}
+
+// handleListFiles renders the directory at the given path as a tree, down to
+// maxDepth levels, with the child counts of the directories and the sizes of
+// the files. The output is paginated by lines with a cursor.
+func handleListFiles(argsJSON string) (string, error) {
+	var args ListFilesToolArgs
+	if argsJSON != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", err
+		}
+	}
+	if args.Path == "" {
+		args.Path = "."
+	}
+	if args.MaxDepth < 1 {
+		args.MaxDepth = defaultListDepth
+	}
+	args.MaxDepth = min(args.MaxDepth, maxListDepth)
+	if args.Limit < 1 {
+		args.Limit = defaultListLimit
+	}
+	args.Limit = min(args.Limit, maxListLimit)
+	offset := 0
+	if args.Cursor != "" {
+		n, err := strconv.Atoi(args.Cursor)
+		if err != nil || n < 0 {
+			return fmt.Sprintf("Invalid cursor %q, pass the cursor of the previous ls result as-is.", args.Cursor), nil
+		}
+		offset = n
+	}
+
+	dir, err := resolvePath(args.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Sprintf("Unable to list %q: %s", args.Path, err), nil
+	}
+	if !info.IsDir() {
+		return fmt.Sprintf("%s (%s) is a file, use read_files to read it.", args.Path, formatSize(info.Size())), nil
+	}
+
+	w := newLsWalker(dir, args)
+	tree := w.walk(dir, "", 0)
+	name := displayPath(dir)
+	if name == "." {
+		name = "./"
+	} else {
+		name += "/"
+	}
+	lines := []string{fmt.Sprintf("%s %s", name, dirCounts(tree))}
+	lines = renderLsTree(tree, 1, lines)
+
+	if offset >= len(lines) && offset > 0 {
+		return fmt.Sprintf("The cursor %q is past the end of the listing (%d lines).", args.Cursor, len(lines)), nil
+	}
+	end := min(offset+args.Limit, len(lines))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<directory_tree path=%q max_depth=\"%d\">\n", name, args.MaxDepth)
+	sb.WriteString(strings.Join(lines[offset:end], "\n"))
+	sb.WriteString("\n</directory_tree>")
+	if end < len(lines) {
+		fmt.Fprintf(&sb, "\nShowing lines %d-%d of %d. Call ls again with cursor %q for the rest, or narrow it down with path, maxDepth or include.",
+			offset+1, end, len(lines), strconv.Itoa(end))
+	}
+	return sb.String(), nil
+}
+
+func newLsWalker(dir string, args ListFilesToolArgs) *lsWalker {
+	wsRoot := workspaceRootOf(dir)
+	ignores := ignoreMatcher(wsRoot)
+	if rel := relSlash(wsRoot, dir); rel != "" && ignores.Ignored(rel, true) {
+		// Listing an ignored directory was asked for explicitly.
+		ignores = &IgnoreMatcher{root: wsRoot}
+	}
+	return &lsWalker{
+		root:     wsRoot,
+		ignores:  ignores,
+		include:  compileGlobs(args.Include),
+		exclude:  compileGlobs(args.Exclude),
+		maxDepth: args.MaxDepth,
+		roots:    allowedRoots(),
+	}
+}
+
+// walk builds the tree of abs, whose path relative to the listed directory is
+// rel, expanding the directories above the maximum depth. The symlinks to
+// directories are followed unless they lead out of the allowed roots or to a
+// directory listed already.
+func (w *lsWalker) walk(abs, rel string, depth int) *lsNode {
+	node := &lsNode{name: filepath.Base(abs), isDir: true}
+	if w.visited == nil {
+		w.visited = map[string]string{}
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		w.visited[real] = strings.TrimPrefix(rel+"/", "/")
+	}
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		node.summary = "unreadable: " + err.Error()
+		return node
+	}
+
+	var files []*lsNode
+	for _, e := range entries {
+		p := filepath.Join(abs, e.Name())
+		childRel := strings.TrimPrefix(rel+"/"+e.Name(), "/")
+		isDir, note := e.IsDir(), ""
+		if e.Type()&os.ModeSymlink != 0 {
+			if target, err := filepath.EvalSymlinks(p); err == nil {
+				if info, err := os.Lstat(target); err == nil && info.IsDir() {
+					isDir = true
+					if listed, ok := w.visited[target]; ok {
+						note = "symlink to " + utils.Ternary(listed == "", "./", listed) + ", listed already"
+					} else if !withinAnyRoot(target, w.roots) {
+						note = "symlink out of the workspace, not listed"
+					}
+				}
+			}
+		}
+		if w.ignores.match(relSlash(w.root, p), isDir) || matchesAnyGlob(childRel, w.exclude) {
+			continue
+		}
+
+		if isDir {
+			child := &lsNode{name: e.Name(), isDir: true, note: note}
+			if note != "" {
+				node.dirs++
+				node.children = append(node.children, child)
+				continue
+			}
+			if depth+1 < w.maxDepth {
+				child = w.walk(p, childRel, depth+1)
+			} else {
+				child.dirs, child.files = w.countChildren(p)
+			}
+			if len(w.include) > 0 && child.expanded && !child.hasFiles() {
+				continue
+			}
+			node.dirs++
+			node.children = append(node.children, child)
+			continue
+		}
+
+		if len(w.include) > 0 && !matchesAnyGlob(childRel, w.include) {
+			continue
+		}
+		node.files++
+		f := &lsNode{name: e.Name()}
+		if info, err := e.Info(); err == nil {
+			f.size = info.Size()
+		}
+		files = append(files, f)
+	}
+
+	node.expanded = true
+	if len(files) > maxListedFilesPerDir {
+		node.summary = summarizeFiles(files)
+	} else {
+		node.children = append(node.children, files...)
+	}
+	sort.SliceStable(node.children, func(i, j int) bool {
+		a, b := node.children[i], node.children[j]
+		if a.isDir != b.isDir {
+			return a.isDir
+		}
+		return a.name < b.name
+	})
+	return node
+}
+
+// countChildren counts the entries of a directory that is not expanded.
+func (w *lsWalker) countChildren(abs string) (dirs, files int) {
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return 0, 0
+	}
+	for _, e := range entries {
+		if w.ignores.match(relSlash(w.root, filepath.Join(abs, e.Name())), e.IsDir()) {
+			continue
+		}
+		if e.IsDir() {
+			dirs++
+		} else {
+			files++
+		}
+	}
+	return dirs, files
+}
+
+func (n *lsNode) hasFiles() bool {
+	if n.files > 0 {
+		return true
+	}
+	return slices.ContainsFunc(n.children, func(c *lsNode) bool { return c.isDir && c.hasFiles() })
+}
+
+func renderLsTree(node *lsNode, level int, lines []string) []string {
+	indent := strings.Repeat("  ", level)
+	for _, c := range node.children {
+		if c.isDir {
+			lines = append(lines, fmt.Sprintf("%s%s/ %s", indent, c.name, dirCounts(c)))
+			if c.expanded {
+				lines = renderLsTree(c, level+1, lines)
+			}
+		} else {
+			lines = append(lines, fmt.Sprintf("%s%s (%s)", indent, c.name, formatSize(c.size)))
+		}
+	}
+	if node.summary != "" {
+		lines = append(lines, fmt.Sprintf("%s[%s]", indent, node.summary))
+	}
+	return lines
+}
+
+func dirCounts(n *lsNode) string {
+	if n.note != "" {
+		return "(" + n.note + ")"
+	}
+	if n.dirs == 0 && n.files == 0 {
+		return "(empty)"
+	}
+	var parts []string
+	if n.dirs > 0 {
+		parts = append(parts, utils.Plural(n.dirs, "dir"))
+	}
+	if n.files > 0 {
+		parts = append(parts, utils.Plural(n.files, "file"))
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// summarizeFiles describes a long list of files by their extensions.
+func summarizeFiles(files []*lsNode) string {
+	counts := map[string]int{}
+	var total int64
+	for _, f := range files {
+		ext := filepath.Ext(f.name)
+		if ext == "" {
+			ext = "no extension"
+		}
+		counts[ext]++
+		total += f.size
+	}
+	exts := make([]string, 0, len(counts))
+	for ext := range counts {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if counts[exts[i]] != counts[exts[j]] {
+			return counts[exts[i]] > counts[exts[j]]
+		}
+		return exts[i] < exts[j]
+	})
+	var parts []string
+	for i, ext := range exts {
+		if i == 5 {
+			parts = append(parts, "…")
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", counts[ext], ext))
+	}
+	return fmt.Sprintf("%s not listed, %s in total: %s; use include or search to find specific ones",
+		utils.Plural(len(files), "file"), formatSize(total), strings.Join(parts, ", "))
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func runLs(t *testing.T, args ListFilesToolArgs) string {
+	t.Helper()
+	argsJSON, _ := json.Marshal(args)
+	out, err := handleListFiles(string(argsJSON))
+	if err != nil {
+		t.Fatalf("ls failed: %v", err)
+	}
+	return out
+}
+
+func TestListFiles(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	files := map[string]string{
+		".gitignore":         "dist/\n",
+		"main.go":            "package main\n",
+		"README.md":          strings.Repeat("x", 2048),
+		"pkg/a.go":           "package pkg\n",
+		"pkg/deep/er/b.go":   "package er\n",
+		"dist/bundle.js":     "",
+		"node_modules/x.js":  "",
+		"docs/guide.md":      "",
+		"docs/img/logo.png":  "",
+		"internal/x/util.go": "",
+	}
+	for i := range 60 {
+		files[fmt.Sprintf("data/%02d.json", i)] = "{}"
+	}
+	for p, content := range files {
+		full := filepath.Join(configs.WorkingPath, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := runLs(t, ListFilesToolArgs{MaxDepth: 2})
+	for _, want := range []string{
+		"./ (4 dirs, 3 files)",
+		"\n  data/ (60 files)\n    [60 files not listed, 120 B in total: 60 .json;",
+		"\n  pkg/ (1 dir, 1 file)\n    deep/ (1 dir)\n    a.go (12 B)\n",
+		"\n  README.md (2.0 KB)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+	for _, hidden := range []string{"dist", "node_modules", "er/", "b.go"} {
+		if strings.Contains(out, hidden) {
+			t.Fatalf("expected %q to be skipped:\n%s", hidden, out)
+		}
+	}
+
+	out = runLs(t, ListFilesToolArgs{Path: "pkg", MaxDepth: 5})
+	if !strings.Contains(out, `<directory_tree path="pkg/"`) || !strings.Contains(out, "\n  deep/ (1 dir)\n    er/ (1 file)\n      b.go (11 B)\n") {
+		t.Fatalf("unexpected sub-path listing:\n%s", out)
+	}
+
+	out = runLs(t, ListFilesToolArgs{MaxDepth: 5, Include: []string{"*.md"}, Exclude: []string{"docs/img"}})
+	if !strings.Contains(out, "docs/ (1 file)\n    guide.md") || !strings.Contains(out, "README.md") {
+		t.Fatalf("expected the markdown files, got:\n%s", out)
+	}
+	for _, hidden := range []string{"main.go", "pkg/", "internal/", "img/"} {
+		if strings.Contains(out, hidden) {
+			t.Fatalf("expected %q to be filtered out:\n%s", hidden, out)
+		}
+	}
+
+	out = runLs(t, ListFilesToolArgs{Path: "dist"})
+	if !strings.Contains(out, "bundle.js (0 B)") {
+		t.Fatalf("expected an explicitly listed ignored directory to be listed, got:\n%s", out)
+	}
+}
+
+func TestListFilesSymlinks(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(configs.WorkingPath, "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{"loop": ".", "pkg/up": "..", "alias": "pkg", "out": outside} {
+		if err := os.Symlink(target, filepath.Join(configs.WorkingPath, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runLs(t, ListFilesToolArgs{MaxDepth: 100})
+	for _, want := range []string{
+		`max_depth="10"`,
+		"\n  alias/ (1 dir)\n    up/ (symlink to ./, listed already)\n",
+		"\n  loop/ (symlink to ./, listed already)\n",
+		"\n  out/ (symlink out of the workspace, not listed)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "secret.txt") {
+		t.Fatalf("expected the directory outside of the workspace not to be listed:\n%s", out)
+	}
+}
+
+func TestListFilesPagination(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	for i := range 30 {
+		if err := os.WriteFile(filepath.Join(configs.WorkingPath, fmt.Sprintf("f%02d.txt", i)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var seen []string
+	cursor := ""
+	for range 10 {
+		out := runLs(t, ListFilesToolArgs{Limit: 12, Cursor: cursor})
+		body := out[strings.Index(out, ">\n")+2 : strings.Index(out, "\n</directory_tree>")]
+		seen = append(seen, strings.Split(body, "\n")...)
+		_, next, ok := strings.Cut(out, "with cursor ")
+		if !ok {
+			break
+		}
+		cursor, _, _ = strings.Cut(strings.TrimPrefix(next, `"`), `"`)
+	}
+	if len(seen) != 31 || seen[0] != "./ (30 files)" || seen[30] != "  f29.txt (0 B)" {
+		t.Fatalf("expected every line once across the pages, got %d lines: %q", len(seen), seen)
+	}
+
+	if out := runLs(t, ListFilesToolArgs{Cursor: "bogus"}); !strings.Contains(out, "Invalid cursor") {
+		t.Fatalf("expected an invalid cursor error, got:\n%s", out)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for size, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 5 << 20: "5.0 MB", 3 << 30: "3.0 GB"} {
+		if got := formatSize(size); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
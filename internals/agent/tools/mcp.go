@@ -0,0 +1,266 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sifatulrabbi/cli-agent/internals/mcp"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+const (
+	mcpToolPrefix      = "mcp__"
+	maxMCPOutputTokens = 8000
+	// The tool names the model providers accept.
+	maxToolNameLength = 64
+)
+
+var (
+	mcpServers *mcp.Manager
+	// mcpSyncMu serializes the syncs of the tools, the servers call back from
+	// their own goroutines.
+	mcpSyncMu sync.Mutex
+
+	mcpHookMu  sync.Mutex
+	mcpChanged func()
+)
+
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// UseMCP connects to the servers of the manager and offers their tools to
+// the model as mcp__<server>__<tool>, the list follows the changes of the
+// servers. The servers of the previous manager are disconnected.
+func UseMCP(m *mcp.Manager) {
+	if mcpServers != nil && mcpServers != m {
+		mcpServers.Close()
+	}
+	mcpSyncMu.Lock()
+	mcpServers = m
+	mcpSyncMu.Unlock()
+	syncMCPTools()
+	m.Start(func() {
+		syncMCPTools()
+		mcpHookMu.Lock()
+		f := mcpChanged
+		mcpHookMu.Unlock()
+		if f != nil {
+			f()
+		}
+	})
+}
+
+// OnMCPChange sets the function called whenever the status of an MCP server
+// changes, e.g. to redraw it.
+func OnMCPChange(f func()) {
+	mcpHookMu.Lock()
+	defer mcpHookMu.Unlock()
+	mcpChanged = f
+}
+
+// MCPStatus returns the status of every configured MCP server.
+func MCPStatus() []mcp.ServerStatus {
+	if mcpServers == nil {
+		return nil
+	}
+	return mcpServers.Status()
+}
+
+// MCPPrompt gets the prompt of a server filled in with the arguments and
+// returns the text of its messages, for the user to send as their input.
+func MCPPrompt(ctx context.Context, server, name string, args map[string]string) (string, error) {
+	if mcpServers == nil {
+		return "", errors.New("no MCP server is configured")
+	}
+	result, err := mcpServers.GetPrompt(ctx, server, name, args)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{}
+	for _, msg := range result.Messages {
+		parts = append(parts, formatMCPContent([]mcp.Content{msg.Content}))
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// CloseMCP disconnects from the MCP servers.
+func CloseMCP() {
+	if mcpServers != nil {
+		mcpServers.Close()
+	}
+}
+
+// MCPToolName is the name a tool of an MCP server is offered to the model
+// under.
+func MCPToolName(server, tool string) string {
+	name := mcpToolPrefix + invalidToolNameChars.ReplaceAllString(server, "_") + "__" + invalidToolNameChars.ReplaceAllString(tool, "_")
+	if len(name) > maxToolNameLength {
+		name = name[:maxToolNameLength]
+	}
+	return name
+}
+
+// syncMCPTools registers the tools of the ready servers in place of the
+// previous ones, and mcp_resources when a server has resources.
+func syncMCPTools() {
+	mcpSyncMu.Lock()
+	defer mcpSyncMu.Unlock()
+	tools := []registeredTool{}
+	if mcpServers != nil {
+		hasResources := false
+		for _, s := range mcpServers.Status() {
+			if s.State != mcp.StateReady {
+				continue
+			}
+			hasResources = hasResources || len(s.Resources) > 0
+			for _, t := range s.Tools {
+				tools = append(tools, registeredTool{
+					def: ToolDefinition{
+						Name:        MCPToolName(s.Name, t.Name),
+						Description: mcpToolDescription(s.Name, t),
+						Parameters:  objectSchema(t.InputSchema),
+					},
+					handler: mcpToolHandler(s.Name, t.Name),
+				})
+			}
+		}
+		if hasResources {
+			tools = append(tools, registeredTool{def: mcpResourcesDefinition, handler: handleMCPResources})
+		}
+	}
+	for _, name := range replaceTools([]string{mcpToolPrefix, ToolMCPResources}, tools) {
+		log.Println("ERROR: Skipping the MCP tool as its name is taken:", name)
+	}
+}
+
+func mcpToolDescription(server string, t mcp.Tool) string {
+	desc := strings.TrimSpace(t.Description)
+	if desc == "" {
+		desc = utils.Ternary(t.Title != "", t.Title, t.Name)
+	}
+	return fmt.Sprintf("%s (the %s tool of the %s MCP server)", desc, t.Name, server)
+}
+
+func mcpToolHandler(server, tool string) func(argsJSON string) (string, error) {
+	return func(argsJSON string) (string, error) {
+		if strings.TrimSpace(argsJSON) == "" {
+			argsJSON = "{}"
+		}
+		if !json.Valid([]byte(argsJSON)) {
+			return "The arguments are not valid JSON.", nil
+		}
+		result, err := mcpServers.CallTool(toolCtx, server, tool, json.RawMessage(argsJSON))
+		if err != nil {
+			return fmt.Sprintf("The %s tool of the %s MCP server failed: %v", tool, server, err), nil
+		}
+		out := formatMCPContent(result.Content)
+		if out == "" && len(result.StructuredContent) > 0 {
+			out = string(result.StructuredContent)
+		}
+		if result.IsError {
+			out = "The tool reported an error:\n" + out
+		} else if out == "" {
+			out = "The tool returned nothing."
+		}
+		return clipOutput(out, maxMCPOutputTokens), nil
+	}
+}
+
+// formatMCPContent shows the text of the content, the binary parts are
+// described.
+func formatMCPContent(content []mcp.Content) string {
+	parts := []string{}
+	for _, c := range content {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "image", "audio":
+			parts = append(parts, fmt.Sprintf("[%s %s, %d bytes base64]", c.Type, c.MimeType, len(c.Data)))
+		case "resource_link":
+			parts = append(parts, fmt.Sprintf("[resource %s: %s]", c.Name, c.URI))
+		case "resource":
+			if c.Resource != nil {
+				parts = append(parts, formatResourceContents(*c.Resource))
+			}
+		default:
+			parts = append(parts, fmt.Sprintf("[unsupported %s content]", c.Type))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func formatResourceContents(r mcp.ResourceContents) string {
+	if r.Text != "" || r.Blob == "" {
+		return fmt.Sprintf("<resource uri=%q>\n%s\n</resource>", r.URI, r.Text)
+	}
+	return fmt.Sprintf("[resource %s: %s, %d bytes base64]", r.URI, r.MimeType, len(r.Blob))
+}
+
+type MCPResourcesToolArgs struct {
+	Server string `json:"server"`
+	URI    string `json:"uri"`
+}
+
+var mcpResourcesDefinition = ToolDefinition{
+	Name: ToolMCPResources,
+	Description: "List the resources of the connected MCP servers, or read one of them. " +
+		"Call it without a uri to list the resources, with the server and the uri of a resource to read it.",
+	Parameters: schema(`{
+		"type": "object",
+		"properties": {
+			"server": {"type": "string", "description": "The MCP server to list or read the resources of. Defaults to every server when listing."},
+			"uri": {"type": "string", "description": "The URI of the resource to read."}
+		}
+	}`),
+}
+
+func handleMCPResources(argsJSON string) (string, error) {
+	var args MCPResourcesToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	if mcpServers == nil {
+		return "No MCP server is configured.", nil
+	}
+
+	if args.URI == "" {
+		var sb strings.Builder
+		for _, s := range mcpServers.Status() {
+			if args.Server != "" && s.Name != args.Server {
+				continue
+			}
+			for _, r := range s.Resources {
+				fmt.Fprintf(&sb, "%s %s", s.Name, r.URI)
+				if r.MimeType != "" {
+					fmt.Fprintf(&sb, " (%s)", r.MimeType)
+				}
+				if desc := utils.Ternary(r.Description != "", r.Description, r.Title); desc != "" {
+					fmt.Fprintf(&sb, ": %s", desc)
+				}
+				sb.WriteString("\n")
+			}
+		}
+		if sb.Len() == 0 {
+			return "There are no resources.", nil
+		}
+		return "The resources by server and URI:\n" + sb.String(), nil
+	}
+
+	if args.Server == "" {
+		return "Pass the server of the resource to read.", nil
+	}
+	contents, err := mcpServers.ReadResource(toolCtx, args.Server, args.URI)
+	if err != nil {
+		return fmt.Sprintf("Unable to read %s from the %s MCP server: %v", args.URI, args.Server, err), nil
+	}
+	parts := make([]string, 0, len(contents))
+	for _, c := range contents {
+		parts = append(parts, formatResourceContents(c))
+	}
+	return clipOutput(strings.Join(parts, "\n"), maxMCPOutputTokens), nil
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sifatulrabbi/cli-agent/internals/mcp"
+	"github.com/sifatulrabbi/cli-agent/internals/mcp/mcptest"
+)
+
+func TestMCPTools(t *testing.T) {
+	srv := httptest.NewServer(mcptest.Handler())
+	t.Cleanup(srv.Close)
+	m := mcp.NewManager(t.TempDir(), []mcp.ServerConfig{
+		{Name: "fake.server", URL: srv.URL, Timeout: 5 * time.Second},
+		{Name: "down", URL: "http://127.0.0.1:1/mcp"},
+	})
+	t.Cleanup(func() {
+		CloseMCP()
+		mcpServers = nil
+		syncMCPTools()
+	})
+	UseMCP(m)
+	if err := m.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	status := MCPStatus()
+	if len(status) != 2 || status[0].State != mcp.StateFailed || status[1].State != mcp.StateReady {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	names := []string{}
+	for _, def := range AllDefinitions() {
+		names = append(names, def.Name)
+	}
+	for _, want := range []string{"mcp__fake_server__echo", "mcp__fake_server__grow", ToolMCPResources} {
+		if !slices.Contains(names, want) {
+			t.Errorf("expected %s among the tools %v", want, names)
+		}
+	}
+	// The tools are replaced at once, a sync never leaves them out.
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(syncMCPTools)
+	}
+	for range 100 {
+		if _, ok := Handler("mcp__fake_server__echo"); !ok {
+			t.Fatal("expected the tool to stay registered while the tools are synced")
+		}
+	}
+	wg.Wait()
+	for _, def := range AllDefinitions() {
+		if def.Name == "mcp__fake_server__grow" && (def.Parameters["type"] != "object" || def.Parameters["properties"] == nil) {
+			t.Errorf("expected the schema to be completed, got %v", def.Parameters)
+		}
+	}
+
+	call := func(name, args string) string {
+		t.Helper()
+		handler, ok := Handler(name)
+		if !ok {
+			t.Fatalf("the tool %s is not registered", name)
+		}
+		out, err := handler(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	if out := call("mcp__fake_server__echo", `{"text": "hi"}`); out != "hi" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if out := call("mcp__fake_server__fail", ""); out != "The tool reported an error:\nsomething broke" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if out := call("mcp__fake_server__echo", `{"text":`); out != "The arguments are not valid JSON." {
+		t.Errorf("unexpected output %q", out)
+	}
+	if out := call(ToolMCPResources, `{}`); out != "The resources by server and URI:\nfake.server test://readme (text/markdown): The read me.\n" {
+		t.Errorf("unexpected resources %q", out)
+	}
+	if out := call(ToolMCPResources, `{"server": "fake.server", "uri": "test://readme"}`); out != "<resource uri=\"test://readme\">\n# Read me\n</resource>" {
+		t.Errorf("unexpected resource %q", out)
+	}
+	if out, err := MCPPrompt(context.Background(), "fake.server", "greet", map[string]string{"name": "Ada"}); err != nil || out != "Hello Ada" {
+		t.Errorf("unexpected prompt %q, %v", out, err)
+	}
+	if _, err := MCPPrompt(context.Background(), "down", "greet", nil); err == nil {
+		t.Error("expected an error from a server that is down")
+	}
+
+	if ReadOnly("mcp__fake_server__echo", "{}") || !ReadOnly(ToolMCPResources, "{}") {
+		t.Error("only mcp_resources should be read-only")
+	}
+	if name := MCPToolName("s", strings.Repeat("x", 100)); len(name) != maxToolNameLength {
+		t.Errorf("expected the name to be clipped, got %q", name)
+	}
+	if out := clipOutput(strings.Repeat("word ", 20000), maxMCPOutputTokens); !strings.HasSuffix(out, "tokens.") || len(out) > 50000 {
+		t.Errorf("expected the output to be clipped, got %d bytes", len(out))
+	}
+}
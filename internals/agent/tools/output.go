@@ -0,0 +1,208 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sifatulrabbi/cli-agent/internals/db"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+const (
+	// Outputs over maxToolOutputTokens are spilled to the artifact store,
+	// the limit is above the budgets of the tools that page on their own.
+	maxToolOutputTokens = 16000
+	// The preview is measured in bytes, about 2000 and 1000 tokens.
+	previewHeadBytes    = 8000
+	previewTailBytes    = 4000
+	maxPreviewLineChars = 500
+	// Longer lines are split, so a single huge line can be paged too.
+	maxOutputLineChars = 2000
+
+	maxReadOutputTokens  = 8000
+	defaultReadOutputMax = 200
+	maxReadOutputMatches = 200
+)
+
+var artifacts *db.ArtifactStore
+
+// UseArtifacts makes the large tool outputs spill into the store.
+func UseArtifacts(store *db.ArtifactStore) {
+	artifacts = store
+}
+
+// LimitOutput returns the output of a tool call as it should be given to the
+// model. An output over the token limit is stored whole and replaced by its
+// beginning and its end, with the id read_output reads the rest with.
+func LimitOutput(toolName, output string) string {
+	// A token takes a byte at least and about four on average. Counting the
+	// tokens of large outputs takes seconds, so only the ones in between are
+	// counted.
+	if artifacts == nil || len(output) <= maxToolOutputTokens {
+		return output
+	}
+	if len(output) < 4*maxToolOutputTokens && utils.CountTokens(output) <= maxToolOutputTokens {
+		return output
+	}
+	id, err := artifacts.Save(output)
+	if err != nil {
+		log.Println("ERROR: Failed to store the large output of", toolName, err)
+		return clipOutput(output, maxToolOutputTokens)
+	}
+
+	lines := outputLines(output)
+	head, used := []string{}, 0
+	for _, line := range lines {
+		line = clipLine(line)
+		if used += len(line) + 1; used > previewHeadBytes && len(head) > 0 {
+			break
+		}
+		head = append(head, line)
+	}
+	tail, used := []string{}, 0
+	for i := len(lines) - 1; i >= len(head); i-- {
+		line := clipLine(lines[i])
+		if used += len(line) + 1; used > previewTailBytes {
+			break
+		}
+		tail = append([]string{line}, tail...)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<output id=%q tool=%q lines=\"%d\" bytes=\"%d\">\n", id, toolName, len(lines), len(output))
+	sb.WriteString(strings.Join(head, "\n") + "\n")
+	if skipped := len(lines) - len(head) - len(tail); skipped > 0 {
+		fmt.Fprintf(&sb, "... %d lines not shown ...\n", skipped)
+	}
+	if len(tail) > 0 {
+		sb.WriteString(strings.Join(tail, "\n") + "\n")
+	}
+	sb.WriteString("</output>\n")
+	fmt.Fprintf(&sb, "The output was too large and was saved as %s. Call read_output with its id and an offset to read lines %d-%d, or with a pattern to search it.",
+		id, len(head)+1, len(lines)-len(tail))
+	return sb.String()
+}
+
+// clipOutput keeps the beginning of an output of more than maxTokens.
+func clipOutput(out string, maxTokens int) string {
+	tokens := utils.CountTokens(out)
+	if tokens <= maxTokens {
+		return out
+	}
+	keep := len(out) * maxTokens / tokens
+	for keep > 0 && !utf8.RuneStart(out[keep]) {
+		keep--
+	}
+	return out[:keep] + fmt.Sprintf("\n... the output was cut off, it had about %d tokens.", tokens)
+}
+
+// outputLines splits the output into the lines read_output numbers, the
+// lines longer than maxOutputLineChars are split into several.
+func outputLines(output string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		for len(line) > maxOutputLineChars {
+			cut := maxOutputLineChars
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			lines = append(lines, line[:cut])
+			line = line[cut:]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func clipLine(line string) string {
+	if len(line) <= maxPreviewLineChars {
+		return line
+	}
+	return strings.ToValidUTF8(line[:maxPreviewLineChars], "") + fmt.Sprintf("… (%d more characters)", len(line)-maxPreviewLineChars)
+}
+
+type ReadOutputToolArgs struct {
+	ID      string `json:"id"`
+	Offset  int    `json:"offset"`  // the 1-based line to start from
+	Limit   int    `json:"limit"`   // the maximum number of lines
+	Pattern string `json:"pattern"` // a regular expression to search for
+}
+
+func handleReadOutput(argsJSON string) (string, error) {
+	var args ReadOutputToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	if artifacts == nil {
+		return "There are no stored outputs in this session.", nil
+	}
+	output, err := artifacts.Read(strings.TrimSpace(args.ID))
+	if err != nil {
+		return err.Error(), nil
+	}
+	lines := outputLines(output)
+	start := max(args.Offset, 1)
+	if start > len(lines) {
+		return fmt.Sprintf("%s has only %d lines, the offset %d is past its end.", args.ID, len(lines), args.Offset), nil
+	}
+	limit := args.Limit
+	if limit <= 0 {
+		limit = defaultReadOutputMax
+	}
+
+	if args.Pattern != "" {
+		re, err := regexp.Compile(args.Pattern)
+		if err != nil {
+			return fmt.Sprintf("Invalid pattern: %v", err), nil
+		}
+		return searchOutput(args.ID, lines, re, start, min(limit, maxReadOutputMatches)), nil
+	}
+
+	var body strings.Builder
+	used, last := 0, start-1
+	for i := start - 1; i < len(lines) && i < start-1+limit; i++ {
+		line := fmt.Sprintf("%d | %s", i+1, lines[i])
+		tokens := utils.CountTokens(line) + 1
+		// Always show at least one line so the reads make progress.
+		if used+tokens > maxReadOutputTokens && i > start-1 {
+			break
+		}
+		used += tokens
+		body.WriteString(line + "\n")
+		last = i + 1
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<output id=%q lines=\"%d-%d\" total_lines=\"%d\">\n%s</output>", args.ID, start, last, len(lines), body.String())
+	if last < len(lines) {
+		fmt.Fprintf(&sb, "\nCall read_output with offset %d to continue.", last+1)
+	}
+	return sb.String(), nil
+}
+
+// searchOutput lists the lines matching re from the start line on, at most
+// limit of them.
+func searchOutput(id string, lines []string, re *regexp.Regexp, start, limit int) string {
+	var body strings.Builder
+	matches, used := 0, 0
+	for i := start - 1; i < len(lines); i++ {
+		if !re.MatchString(lines[i]) {
+			continue
+		}
+		line := fmt.Sprintf("%d | %s", i+1, lines[i])
+		used += utils.CountTokens(line) + 1
+		if matches == limit || used > maxReadOutputTokens {
+			return fmt.Sprintf("<matches id=%q pattern=%q>\n%s</matches>\nThere are more matches, call read_output with offset %d to continue the search.",
+				id, re.String(), body.String(), i+1)
+		}
+		body.WriteString(line + "\n")
+		matches++
+	}
+	if matches == 0 {
+		return fmt.Sprintf("No line of %s from line %d on matches %q.", id, start, re.String())
+	}
+	return fmt.Sprintf("<matches id=%q pattern=%q>\n%s</matches>", id, re.String(), body.String())
+}
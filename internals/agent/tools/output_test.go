@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/db"
+)
+
+func runReadOutput(t *testing.T, args ReadOutputToolArgs) string {
+	t.Helper()
+	argsJSON, _ := json.Marshal(args)
+	out, err := handleReadOutput(string(argsJSON))
+	if err != nil {
+		t.Fatalf("read_output failed: %v", err)
+	}
+	return out
+}
+
+func TestLimitOutput(t *testing.T) {
+	configs.SessionsPath = t.TempDir()
+	UseArtifacts(db.NewArtifactStore("session"))
+	t.Cleanup(func() { UseArtifacts(nil) })
+
+	if out := LimitOutput(ToolBash, "small"); out != "small" {
+		t.Errorf("expected the small output as it is, got %q", out)
+	}
+
+	var lines []string
+	for i := 1; i <= 20000; i++ {
+		lines = append(lines, fmt.Sprintf("line %d of the output", i))
+	}
+	lines[12344] = "panic: something broke"
+	big := strings.Join(lines, "\n")
+	out := LimitOutput(ToolBash, big)
+	if !strings.HasPrefix(out, `<output id="out-1" tool="bash" lines="20000"`) ||
+		!strings.Contains(out, "\nline 1 of the output\n") || !strings.Contains(out, "\nline 20000 of the output\n</output>") ||
+		!strings.Contains(out, "lines not shown") || strings.Contains(out, "line 10000 of") {
+		t.Fatalf("unexpected preview:\n%s", out)
+	}
+	if len(out) > len(big)/10 {
+		t.Errorf("the preview is too long: %d bytes", len(out))
+	}
+
+	page := runReadOutput(t, ReadOutputToolArgs{ID: "out-1", Offset: 10000, Limit: 2})
+	want := "<output id=\"out-1\" lines=\"10000-10001\" total_lines=\"20000\">\n10000 | line 10000 of the output\n10001 | line 10001 of the output\n</output>\nCall read_output with offset 10002 to continue."
+	if page != want {
+		t.Errorf("unexpected page:\n%s\nwant:\n%s", page, want)
+	}
+	if found := runReadOutput(t, ReadOutputToolArgs{ID: "out-1", Pattern: "^panic"}); found != "<matches id=\"out-1\" pattern=\"^panic\">\n12345 | panic: something broke\n</matches>" {
+		t.Errorf("unexpected matches %q", found)
+	}
+	if found := runReadOutput(t, ReadOutputToolArgs{ID: "out-1", Pattern: "of the", Limit: 2}); !strings.HasSuffix(found, "call read_output with offset 3 to continue the search.") {
+		t.Errorf("expected the matches to be limited, got %q", found)
+	}
+	if found := runReadOutput(t, ReadOutputToolArgs{ID: "out-1", Pattern: "("}); !strings.HasPrefix(found, "Invalid pattern") {
+		t.Errorf("expected the pattern to be refused, got %q", found)
+	}
+	if page := runReadOutput(t, ReadOutputToolArgs{ID: "out-7"}); page != "there is no output out-7 in this session" {
+		t.Errorf("unexpected answer %q", page)
+	}
+
+	// A single huge line is split so it can be paged.
+	out = LimitOutput(ToolBash, strings.Repeat("ab", 50000))
+	if !strings.Contains(out, `lines="50"`) {
+		t.Fatalf("expected the line to be split, got:\n%.300s", out)
+	}
+	if page := runReadOutput(t, ReadOutputToolArgs{ID: "out-2", Offset: 2, Limit: 1}); !strings.Contains(page, "2 | "+strings.Repeat("ab", 1000)+"\n") {
+		t.Errorf("unexpected page %.200q", page)
+	}
+}
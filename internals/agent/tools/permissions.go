@@ -0,0 +1,466 @@
+package tools
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+// readOnlyTools can neither change the workspace nor run commands, so the
+// permission policy allows them unless a rule says otherwise.
+var readOnlyTools = []string{ToolListFiles, ToolReadFiles, ToolSearch, ToolCodeOutline, ToolFindSymbol, ToolDiagnostics, ToolTodoWrite, ToolReadOutput, ToolMCPResources}
+
+// ReadOnly reports whether the tool call can neither change the workspace nor
+// run commands. Only the git commands that change the repository count as
+// writes.
+func ReadOnly(toolName, argsJSON string) bool {
+	if toolName == ToolGit {
+		var args GitToolArgs
+		return json.Unmarshal([]byte(argsJSON), &args) == nil && !gitWrites(args)
+	}
+	return slices.Contains(readOnlyTools, toolName) || readOnlyPlugin(toolName)
+}
+
+// PermissionSubjects returns what the permission rules of a tool call are
+// matched against: every command a bash call runs, the host web_fetch
+// fetches from, or the paths a file tool touches relative to the WorkingPath.
+func PermissionSubjects(toolName, argsJSON string) []string {
+	paths := []string{}
+	switch toolName {
+	case ToolBash:
+		var args BashToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			return splitCommands(args.Cmd)
+		}
+	case ToolGit:
+		var args GitToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			return []string{gitSubject(args)}
+		}
+	case ToolWebFetch:
+		var args WebFetchToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			if u, err := url.Parse(strings.TrimSpace(args.URL)); err == nil && u.Hostname() != "" {
+				return []string{strings.ToLower(u.Hostname())}
+			}
+		}
+	case ToolReadFiles:
+		var args ReadFilesToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			for _, r := range args.Reads {
+				paths = append(paths, r.FilePath)
+			}
+		}
+	case ToolAppendFile, ToolPatchFile, ToolEditFile, ToolWriteFile:
+		var args struct {
+			FilePath string `json:"filePath"`
+		}
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			paths = append(paths, args.FilePath)
+		}
+	case ToolDeletePath:
+		var args DeletePathToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			paths = append(paths, args.Path)
+		}
+	case ToolMovePath, ToolCopyPath:
+		var args MovePathToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			paths = append(paths, args.Source, args.Destination)
+		}
+	case ToolApplyPatch:
+		var args ApplyPatchToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			if patches, err := parseUnifiedDiff(args.Patch); err == nil {
+				for _, fp := range patches {
+					paths = append(paths, fp.oldPath, fp.newPath)
+				}
+			}
+		}
+	case ToolListFiles:
+		var args ListFilesToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			paths = append(paths, utils.Ternary(args.Path == "", ".", args.Path))
+		}
+	case ToolCodeOutline:
+		var args CodeOutlineToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			paths = append(paths, args.FilePath)
+		}
+	case ToolFindSymbol:
+		var args FindSymbolToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			paths = append(paths, utils.Ternary(args.Path == "", ".", args.Path))
+		}
+	case ToolRunTests:
+		var args RunTestsToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			p, _, _ := strings.Cut(strings.TrimSuffix(args.Path, "/..."), "::")
+			paths = append(paths, utils.Ternary(p == "", ".", p))
+		}
+	case ToolDiagnostics:
+		var args DiagnosticsToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			paths = append(paths, args.Paths...)
+		}
+	case ToolSearch:
+		var args SearchToolArgs
+		if json.Unmarshal([]byte(argsJSON), &args) == nil {
+			paths = append(paths, utils.Ternary(args.Path == "", ".", args.Path))
+		}
+	}
+
+	subjects := []string{}
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if resolved, err := resolvePath(p); err == nil {
+			p = displayPath(resolved)
+		}
+		if !slices.Contains(subjects, p) {
+			subjects = append(subjects, p)
+		}
+	}
+	return subjects
+}
+
+// AlwaysAsk reports whether the user is asked about the tool call even when
+// the rules allow it: the bash commands running a script given as a string
+// (sh -c, eval) do what the rules can only partly see.
+func AlwaysAsk(toolName, argsJSON string) bool {
+	if toolName != ToolBash {
+		return false
+	}
+	var args BashToolArgs
+	if json.Unmarshal([]byte(argsJSON), &args) != nil {
+		return false
+	}
+	return slices.ContainsFunc(splitCommands(args.Cmd), func(s string) bool {
+		_, ok := shellScript(strings.Fields(s))
+		return ok
+	})
+}
+
+// destructiveCommands get rules for the exact command they were allowed
+// with, "rm -rf build" doesn't allow every rm.
+var destructiveCommands = []string{"rm", "rmdir", "shred", "dd", "truncate", "mkfs", "chmod", "chown", "chgrp", "kill", "pkill", "killall", "git push", "git reset", "git clean"}
+
+// SuggestRules returns the rules the "always allow" answer to a tool call
+// records: the command prefixes (e.g. "bash(go test *)") for bash, except for
+// the destructive commands, the shell scripts and the files the output is
+// redirected to, and the exact paths for the file tools.
+func SuggestRules(toolName string, subjects []string) []string {
+	if len(subjects) == 0 {
+		return []string{toolName}
+	}
+	rules := []string{}
+	for _, s := range subjects {
+		rule := toolName + "(" + s + ")"
+		if toolName == ToolBash && !strings.HasPrefix(s, ">") && !exactRule(s) {
+			words := strings.Fields(s)
+			prefix := words[0]
+			if len(words) > 1 && !strings.HasPrefix(words[1], "-") {
+				prefix += " " + words[1]
+			}
+			rule = toolName + "(" + prefix + " *)"
+		}
+		if !slices.Contains(rules, rule) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// exactRule reports whether the command is only allowed as it is.
+func exactRule(command string) bool {
+	words := strings.Fields(command)
+	if _, ok := shellScript(words); ok {
+		return true
+	}
+	return slices.ContainsFunc(destructiveCommands, func(c string) bool {
+		return command == c || strings.HasPrefix(command, c+" ")
+	})
+}
+
+// splitCommands splits a command line on its control operators (&&, ||, ;,
+// |, &, newlines and subshell parentheses) and pulls out the commands of
+// $(...), `...` and <(...) substitutions, so a rule allowing one command can't
+// be used to smuggle another one in. Every command is normalized by
+// commandSubject, and the files its output is redirected to become subjects
+// of their own ("> out.log"), which a rule allowing the command doesn't cover.
+func splitCommands(cmdline string) []string {
+	var (
+		commands  []string
+		redirects []string
+		words     []string
+		cur       strings.Builder
+		inWord    bool
+		quote     byte
+		redirect  string // the redirection waiting for its target
+	)
+	endWord := func() {
+		if !inWord {
+			return
+		}
+		if redirect != "" {
+			if s := redirectSubject(redirect, cur.String()); s != "" {
+				redirects = append(redirects, s)
+			}
+			redirect = ""
+		} else {
+			words = append(words, cur.String())
+		}
+		cur.Reset()
+		inWord = false
+	}
+	flush := func() {
+		endWord()
+		if words := unwrapCommand(words); len(words) > 0 {
+			commands = append(commands, commandSubject(words))
+			if script, ok := shellScript(words); ok {
+				commands = append(commands, splitCommands(script)...)
+			}
+		}
+		commands = append(commands, redirects...)
+		words, redirects, redirect = nil, nil, ""
+	}
+	substitution := func(i, start, end int) {
+		commands = append(commands, splitCommands(cmdline[start:min(end, len(cmdline))])...)
+		cur.WriteString(cmdline[i:min(end+1, len(cmdline))])
+		inWord = true
+	}
+
+	for i := 0; i < len(cmdline); i++ {
+		c := cmdline[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+			cur.WriteByte(c)
+		case c == '\\' && i+1 < len(cmdline) && cmdline[i+1] == '\n':
+			// A line continuation.
+			endWord()
+			i++
+		case c == '\\' && i+1 < len(cmdline):
+			cur.WriteString(cmdline[i : i+2])
+			inWord = true
+			i++
+		case c == '$' && i+1 < len(cmdline) && cmdline[i+1] == '(':
+			end := closingParen(cmdline, i+1)
+			substitution(i, i+2, end)
+			i = end
+		case c == '`':
+			end := strings.IndexByte(cmdline[i+1:], '`')
+			if end < 0 {
+				end = len(cmdline) - i - 1
+			}
+			substitution(i, i+1, i+1+end)
+			i += end + 1
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+			cur.WriteByte(c)
+		case c == '\'' || c == '"':
+			quote = c
+			cur.WriteByte(c)
+			inWord = true
+		case (c == '<' || c == '>') && i+1 < len(cmdline) && cmdline[i+1] == '(':
+			end := closingParen(cmdline, i+1)
+			substitution(i, i+2, end)
+			i = end
+		case c == '<' || c == '>' || c == '&' && i+1 < len(cmdline) && cmdline[i+1] == '>':
+			// A number right before the operator is the redirected descriptor.
+			if inWord && strings.Trim(cur.String(), "0123456789") == "" {
+				cur.Reset()
+				inWord = false
+			}
+			endWord()
+			op := redirectOperator(cmdline[i:])
+			redirect = op
+			i += len(op) - 1
+		case c == ' ' || c == '\t':
+			endWord()
+		case c == ';' || c == '|' || c == '&' || c == '\n' || c == '(' || c == ')':
+			flush()
+		default:
+			cur.WriteByte(c)
+			inWord = true
+		}
+	}
+	flush()
+	return commands
+}
+
+type wrapper struct {
+	opts []string // the options taking a value
+	args int      // the arguments before the command
+}
+
+// wrapperCommands run the command given to them, so they are left out of the
+// subjects along with their options and arguments.
+var wrapperCommands = map[string]wrapper{
+	"builtin": {},
+	"command": {},
+	"exec":    {opts: []string{"-a"}},
+	"env":     {opts: []string{"-u", "--unset", "-C", "--chdir"}},
+	"nohup":   {},
+	"nice":    {opts: []string{"-n", "--adjustment"}},
+	"timeout": {opts: []string{"-s", "--signal", "-k", "--kill-after"}, args: 1},
+	"xargs":   {opts: []string{"-a", "--arg-file", "-d", "--delimiter", "-E", "-I", "-L", "-n", "--max-args", "-P", "--max-procs", "-s", "--max-chars"}},
+}
+
+// shellKeywords may precede a command without changing what it runs.
+var shellKeywords = []string{"!", "{", "}", "if", "then", "else", "elif", "fi", "while", "until", "do", "done", "time"}
+
+// shells run the script given with their -c option.
+var shells = []string{"sh", "bash", "zsh", "dash", "ksh"}
+
+var assignmentPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*\+?=`)
+
+// unwrapCommand leaves out the variable assignments, shell keywords and
+// wrappers like env and timeout in front of a command, so
+// "FOO=1 timeout 5 git push" becomes "git push".
+func unwrapCommand(words []string) []string {
+	for len(words) > 0 {
+		w := words[0]
+		wr, isWrapper := wrapperCommands[path.Base(unquote(w))]
+		if !isWrapper && !assignmentPattern.MatchString(w) && !slices.Contains(shellKeywords, w) {
+			break
+		}
+		words = words[1:]
+		if !isWrapper {
+			continue
+		}
+		for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+			opt := words[0]
+			words = words[1:]
+			if opt == "--" {
+				break
+			}
+			if slices.Contains(wr.opts, opt) && len(words) > 0 {
+				words = words[1:]
+			}
+		}
+		words = words[min(wr.args, len(words)):]
+	}
+	return words
+}
+
+// commandSubject joins the words of a command with single spaces, unquoting
+// its name, so "\git  push" becomes "git push".
+func commandSubject(words []string) string {
+	return strings.Join(append([]string{unquote(words[0])}, words[1:]...), " ")
+}
+
+// shellScript returns the script a shell runs with -c, or eval runs from its
+// arguments.
+func shellScript(words []string) (string, bool) {
+	if len(words) == 0 {
+		return "", false
+	}
+	if words[0] == "eval" {
+		args := []string{}
+		for _, w := range words[1:] {
+			args = append(args, unquote(w))
+		}
+		return strings.Join(args, " "), true
+	}
+	if !slices.Contains(shells, path.Base(words[0])) {
+		return "", false
+	}
+	for i := 1; i < len(words); i++ {
+		w := words[i]
+		switch {
+		case w == "-o" || w == "+o" || w == "-O" || w == "+O":
+			i++
+		case w == "--" || !strings.HasPrefix(w, "-") && !strings.HasPrefix(w, "+"):
+			return "", false
+		case !strings.HasPrefix(w, "--") && strings.Contains(w, "c"):
+			if i+1 < len(words) {
+				return unquote(words[i+1]), true
+			}
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// unquote removes the quotes and the backslashes of a shell word.
+func unquote(word string) string {
+	var sb strings.Builder
+	var quote byte
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				sb.WriteByte(c)
+			}
+		case c == '\\' && i+1 < len(word) && (quote == 0 || strings.IndexByte("$`\"\\\n", word[i+1]) >= 0):
+			i++
+			sb.WriteByte(word[i])
+		case c == '"' && quote == '"':
+			quote = 0
+		case (c == '"' || c == '\'') && quote == 0:
+			quote = c
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// redirectOperator returns the redirection operator s starts with.
+func redirectOperator(s string) string {
+	for _, op := range []string{"&>>", "&>", ">>", ">|", ">&", "<<<", "<<-", "<<", "<>", "<&", ">", "<"} {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return s[:1]
+}
+
+// redirectSubject returns the subject of a redirection writing to a file:
+// "> target", or ">> target" when it appends. Reading files, duplicating
+// descriptors and discarding the output need no permission of their own.
+func redirectSubject(op, target string) string {
+	switch {
+	case strings.HasPrefix(op, "<") && op != "<>":
+		return ""
+	case op == ">&" && strings.Trim(target, "0123456789-") == "":
+		return ""
+	case target == "/dev/null":
+		return ""
+	case strings.HasSuffix(op, ">>"):
+		return ">> " + target
+	}
+	return "> " + target
+}
+
+// closingParen returns the index of the parenthesis closing the one at open,
+// or the end of s when it is never closed.
+func closingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
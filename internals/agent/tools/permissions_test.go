@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/agent/permissions"
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func TestPermissionSubjects(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+
+	cmds := map[string][]string{
+		"go test ./... 2>&1 | tail -n 20":         {"go test ./...", "tail -n 20"},
+		"cd sub && make; echo 'a && b' &":         {"cd sub", "make", "echo 'a && b'"},
+		`echo "$(rm -rf /tmp/x)" ` + "`whoami`":   {"rm -rf /tmp/x", "whoami", `echo "$(rm -rf /tmp/x)" ` + "`whoami`"},
+		"npm run build &> out.log || echo failed": {"npm run build", "> out.log", "echo failed"},
+		"printf 'x\\n' \\; echo done\nls":         {"printf 'x\\n' \\; echo done", "ls"},
+
+		// What runs the command is left out, so the rules see the command.
+		"FOO=1 BAR='a b' git push":                    {"git push"},
+		"git  push\torigin \\\n  main":                {"git push origin main"},
+		`\git push`:                                   {"git push"},
+		`'git' push`:                                  {"git push"},
+		"command git push":                            {"git push"},
+		"env -i -u HOME FOO=1 git push":               {"git push"},
+		"exec -a name git push":                       {"git push"},
+		"(git push)":                                  {"git push"},
+		"(cd sub; git push) && { ls; }":               {"cd sub", "git push", "ls"},
+		"if git diff --quiet; then ls; fi":            {"git diff --quiet", "ls"},
+		"FOO=1":                                       {},
+		"timeout -s KILL 5s nohup nice -n 5 git push": {"git push"},
+		"/usr/bin/timeout 5 git push":                 {"git push"},
+		"git ls-files | xargs -n 1 git push":          {"git ls-files", "git push"},
+
+		// The scripts run by a shell or eval are split as well.
+		`sh -c "git push"`:                    {`sh -c "git push"`, "git push"},
+		`bash -o pipefail -ec 'ls; git push'`: {`bash -o pipefail -ec 'ls; git push'`, "ls", "git push"},
+		`eval "git push"`:                     {`eval "git push"`, "git push"},
+		`eval git 'push'`:                     {`eval git 'push'`, "git push"},
+
+		// The files written by a redirection are subjects of their own.
+		"go test > ~/.bashrc":              {"go test", "> ~/.bashrc"},
+		"go test>>log.txt 2>/dev/null":     {"go test", ">> log.txt"},
+		"sort < in.txt >| 'out file' 2>&1": {"sort", "> 'out file'"},
+		"diff <(git show HEAD:a) a >&2":    {"diff <(git show HEAD:a) a", "git show HEAD:a"},
+	}
+	for cmdline, want := range cmds {
+		argsJSON, _ := json.Marshal(BashToolArgs{Cmd: cmdline})
+		got := PermissionSubjects(ToolBash, string(argsJSON))
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("PermissionSubjects(%q) = %q, want %q", cmdline, got, want)
+		}
+	}
+
+	argsJSON, _ := json.Marshal(ApplyPatchToolArgs{Patch: "--- a/old.go\n+++ b/pkg/new.go\n@@ -1 +1 @@\n-a\n+b\n"})
+	if got := PermissionSubjects(ToolApplyPatch, string(argsJSON)); !slices.Equal(got, []string{"old.go", "pkg/new.go"}) {
+		t.Errorf("unexpected apply_patch subjects %q", got)
+	}
+	argsJSON, _ = json.Marshal(EditFileToolArgs{FilePath: "/" + filepath.Base(configs.WorkingPath) + "/pkg/../main.go"})
+	if got := PermissionSubjects(ToolEditFile, string(argsJSON)); !slices.Equal(got, []string{"main.go"}) {
+		t.Errorf("unexpected edit_file subjects %q", got)
+	}
+
+	if got := SuggestRules(ToolBash, []string{"go test ./...", "rm -rf x", "go test -run X", "> out.log", "git push origin main", "sh -c 'ls'"}); !slices.Equal(got, []string{"bash(go test *)", "bash(rm -rf x)", "bash(> out.log)", "bash(git push origin main)", "bash(sh -c 'ls')"}) {
+		t.Errorf("unexpected suggestions %q", got)
+	}
+}
+
+func TestWrappedCommandsFollowTheRules(t *testing.T) {
+	policy, err := permissions.NewPolicy(configs.PermissionSettings{Allow: []string{"bash(*)"}, Deny: []string{"bash(git push *)"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cmdline := range []string{"timeout 5 git push", "nohup git push", "nice git push", "xargs git push", `sh -c "git push"`, `bash -c 'ls && git push'`, `eval "git push"`} {
+		argsJSON, _ := json.Marshal(BashToolArgs{Cmd: cmdline + " origin"})
+		if decision, _ := policy.Evaluate(ToolBash, PermissionSubjects(ToolBash, string(argsJSON)), false); decision != permissions.Deny {
+			t.Errorf("expected %q to be denied, got %v", cmdline, decision)
+		}
+	}
+
+	for cmdline, want := range map[string]bool{"ls": false, "timeout 5 ls": false, "sh -c ls": true, "echo $(bash -lc 'ls')": true, "eval ls": true, "sh script.sh": false} {
+		argsJSON, _ := json.Marshal(BashToolArgs{Cmd: cmdline})
+		if got := AlwaysAsk(ToolBash, string(argsJSON)); got != want {
+			t.Errorf("AlwaysAsk(%q) = %v, want %v", cmdline, got, want)
+		}
+	}
+}
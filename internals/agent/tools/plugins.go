@@ -0,0 +1,193 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
+)
+
+const (
+	defaultPluginTimeout = 60 * time.Second
+	maxPluginOutputHead  = 512 * 1024
+	maxPluginOutputTail  = 16 * 1024
+	maxPluginStderr      = 4 * 1024
+)
+
+var (
+	pluginsMu sync.RWMutex
+	// plugins are the registered plugin tools by name.
+	plugins = map[string]configs.PluginSettings{}
+)
+
+var pluginNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// UsePlugins offers the plugin tools of the settings to the model in place of
+// the previous ones. The invalid plugins are skipped and reported together.
+func UsePlugins(settings map[string]configs.PluginSettings) error {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	for name := range plugins {
+		unregisterTool(name)
+	}
+	plugins = map[string]configs.PluginSettings{}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := []error{}
+	for _, name := range names {
+		p := settings[name]
+		if p.Disabled {
+			continue
+		}
+		if err := checkPlugin(name, p); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		desc := strings.TrimSpace(p.Description)
+		if desc == "" {
+			desc = fmt.Sprintf("Runs %s.", p.Command[0])
+		}
+		if !Register(ToolDefinition{Name: name, Description: desc, Parameters: objectSchema(p.Parameters)}, pluginHandler(name, p)) {
+			errs = append(errs, fmt.Errorf("the plugin %s has the name of a built-in tool", name))
+			continue
+		}
+		plugins[name] = p
+	}
+	return errors.Join(errs...)
+}
+
+func checkPlugin(name string, p configs.PluginSettings) error {
+	switch {
+	case !pluginNamePattern.MatchString(name):
+		return fmt.Errorf("invalid plugin name %q, use up to %d letters, digits, _ and -", name, maxToolNameLength)
+	case strings.HasPrefix(name, "mcp_"):
+		return fmt.Errorf("the plugin %s can't use the mcp_ prefix of the MCP tools", name)
+	case len(p.Command) == 0 || p.Command[0] == "":
+		return fmt.Errorf("the plugin %s has no command", name)
+	case p.Timeout < 0:
+		return fmt.Errorf("the plugin %s has a negative timeout", name)
+	}
+	return nil
+}
+
+// readOnlyPlugin reports whether the settings declare the plugin read-only.
+func readOnlyPlugin(name string) bool {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	p, ok := plugins[name]
+	return ok && p.ReadOnly
+}
+
+func pluginHandler(name string, p configs.PluginSettings) func(argsJSON string) (string, error) {
+	timeout := defaultPluginTimeout
+	if p.Timeout > 0 {
+		timeout = time.Duration(p.Timeout) * time.Second
+	}
+	return func(argsJSON string) (string, error) {
+		if strings.TrimSpace(argsJSON) == "" {
+			argsJSON = "{}"
+		}
+		if !json.Valid([]byte(argsJSON)) {
+			return "The arguments are not valid JSON.", nil
+		}
+		return runPlugin(name, p.Command, argsJSON, timeout), nil
+	}
+}
+
+// runPlugin runs the plugin's command in the sandbox of the bash tool with
+// the arguments on stdin, and returns what the model is told.
+func runPlugin(name string, command []string, argsJSON string, timeout time.Duration) string {
+	cmd, err := sandbox.Command(sandboxConfig, command[0], command[1:]...)
+	if err != nil {
+		return fmt.Sprintf("Failed to run the %s plugin: %v", name, err)
+	}
+	cmd.Dir = configs.WorkingPath
+	cmd.Env = append(cmd.Env, "CLI_AGENT_TOOL="+name, "CLI_AGENT_WORKSPACE="+configs.WorkingPath)
+	cmd.Stdin = strings.NewReader(argsJSON)
+	stdout := newOutputBuffer(maxPluginOutputHead, maxPluginOutputTail)
+	stderr := newOutputBuffer(0, maxPluginStderr)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Sprintf("Failed to run the %s plugin: %v", name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(toolCtx, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Kill whatever the plugin spawned too, it would keep the output open.
+		killProcessGroup(cmd)
+		<-done
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Sprintf("The %s plugin did not finish within %s and was stopped.", name, timeout)
+		}
+		return fmt.Sprintf("The %s plugin was stopped.", name)
+	}
+
+	out := strings.TrimSpace(stdout.String())
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return fmt.Sprintf("Failed to run the %s plugin: %v", name, err)
+		}
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "The %s plugin failed with exit code %d.", name, exitErr.ExitCode())
+		if out != "" {
+			sb.WriteString("\n" + out)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			sb.WriteString("\nstderr:\n" + msg)
+		}
+		return sb.String()
+	}
+	if out == "" {
+		return fmt.Sprintf("The %s plugin finished without output.", name)
+	}
+	return pluginResult(out)
+}
+
+// pluginResult reads the {"output": ..., "error": "..."} object a plugin may
+// write, any other output is passed on as it is.
+func pluginResult(out string) string {
+	var result struct {
+		Output json.RawMessage `json:"output"`
+		Error  string          `json:"error"`
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(out)))
+	dec.DisallowUnknownFields()
+	if !strings.HasPrefix(out, "{") || dec.Decode(&result) != nil || dec.More() || (result.Output == nil && result.Error == "") {
+		return out
+	}
+	output := string(result.Output)
+	var text string
+	if json.Unmarshal(result.Output, &text) == nil {
+		output = text
+	} else if output == "null" {
+		output = ""
+	}
+	if result.Error == "" {
+		return output
+	}
+	if output != "" {
+		return "The tool reported an error:\n" + result.Error + "\n" + output
+	}
+	return "The tool reported an error:\n" + result.Error
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func TestPlugins(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	script := func(name, body string) []string {
+		p := filepath.Join(configs.WorkingPath, name)
+		if err := os.WriteFile(p, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return []string{"./" + name}
+	}
+	t.Cleanup(func() { UsePlugins(nil) })
+
+	err := UsePlugins(map[string]configs.PluginSettings{
+		"echo_args": {Description: "Echoes.", Command: script("echo.sh", `cat; echo; echo "$CLI_AGENT_TOOL"`), ReadOnly: true},
+		"result":    {Command: script("result.sh", `echo '{"output": {"n": 1}}'`)},
+		"broken":    {Command: script("broken.sh", `echo '{"error": "no such issue"}'`)},
+		"fails":     {Command: script("fails.sh", `echo partial; echo oops >&2; exit 3`)},
+		"sleeps":    {Command: script("sleeps.sh", `sleep 5`), Timeout: 1},
+		"off":       {Command: []string{"true"}, Disabled: true},
+		"bash":      {Command: []string{"true"}},
+		"bad name":  {Command: []string{"true"}},
+		"mcp_x":     {Command: []string{"true"}},
+		"empty":     {},
+	})
+	for _, want := range []string{"bash has the name of a built-in tool", `invalid plugin name "bad name"`, "mcp_x can't use", "empty has no command"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to mention %q, got %v", want, err)
+		}
+	}
+	if _, ok := Handler("off"); ok {
+		t.Error("the disabled plugin should not be registered")
+	}
+	for _, def := range AllDefinitions() {
+		if def.Name == "result" && (def.Description != "Runs ./result.sh." || def.Parameters["type"] != "object") {
+			t.Errorf("unexpected definition %+v", def)
+		}
+	}
+
+	call := func(name, args string) string {
+		t.Helper()
+		handler, ok := Handler(name)
+		if !ok {
+			t.Fatalf("the plugin %s is not registered", name)
+		}
+		out, err := handler(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	if out := call("echo_args", `{"key": "A-1"}`); out != "{\"key\": \"A-1\"}\necho_args" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if out := call("echo_args", `{"key":`); out != "The arguments are not valid JSON." {
+		t.Errorf("unexpected output %q", out)
+	}
+	if out := call("result", `{}`); out != `{"n": 1}` {
+		t.Errorf("unexpected output %q", out)
+	}
+	if out := call("broken", `{}`); out != "The tool reported an error:\nno such issue" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if out := call("fails", `{}`); out != "The fails plugin failed with exit code 3.\npartial\nstderr:\noops" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if out := call("sleeps", `{}`); out != "The sleeps plugin did not finish within 1s and was stopped." {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	if !ReadOnly("echo_args", "{}") || ReadOnly("result", "{}") {
+		t.Error("only the plugin marked read-only should be")
+	}
+	UsePlugins(nil)
+	if _, ok := Handler("echo_args"); ok {
+		t.Error("the plugins should be replaced")
+	}
+}
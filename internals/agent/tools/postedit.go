@@ -0,0 +1,42 @@
+package tools
+
+import "slices"
+
+var (
+	// editedFiles are the files the running tool call modifies, recorded by
+	// snapshotFiles. They are only tracked for the tools wrapped by afterEdits.
+	editedFiles   []string
+	trackingEdits bool
+)
+
+func recordEdits(paths ...string) {
+	if !trackingEdits {
+		return
+	}
+	for _, p := range paths {
+		if slices.Contains(editedFiles, p) {
+			continue
+		}
+		editedFiles = append(editedFiles, p)
+		recordDiagnosticsBefore(p)
+	}
+}
+
+// afterEdits runs the configured formatters on the files the tool modified
+// and then appends the problems the language servers report for them to the
+// tool's result.
+func afterEdits(handler func(string) (string, error)) func(string) (string, error) {
+	return func(argsJSON string) (string, error) {
+		editedFiles, trackingEdits = nil, true
+		clear(diagnosticsBefore)
+		out, err := handler(argsJSON)
+		files := editedFiles
+		editedFiles, trackingEdits = nil, false
+		if err != nil || len(files) == 0 {
+			return out, err
+		}
+		out += formatEditedFiles(files)
+		out += reportDiagnostics(files)
+		return out, nil
+	}
+}
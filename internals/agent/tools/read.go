@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+const (
+	defaultReadLimit = 2000
+	// maxReadTokens is shared by all the files of a read_files call.
+	maxReadTokens = 12000
+	// Files are not started with less of the budget left than this.
+	minReadTokens     = 500
+	maxReadFileSize   = 5 << 20
+	maxReadLineLength = 2000
+	// Files whose lines are longer than this on average are considered
+	// minified or generated.
+	minifiedAvgLineLength = 500
+	minifiedMinSize       = 8 << 10
+)
+
+type ReadFile struct {
+	FilePath string `json:"filePath"`
+	Offset   int    `json:"offset"` // the 1-based line to start from
+	Limit    int    `json:"limit"`  // the maximum number of lines
+}
+
+type ReadFilesToolArgs struct {
+	Reads []ReadFile `json:"filePaths"`
+}
+
+// handleReadFiles reads the requested line ranges of the files, with line
+// numbers, until the token budget of the call runs out. Whatever is cut off
+// is pointed to with the offset to continue from.
+func handleReadFiles(argsJSON string) (string, error) {
+	var args ReadFilesToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	if len(args.Reads) < 1 {
+		return "The filePaths is empty please specify filePaths to read files.", nil
+	}
+
+	budget := maxReadTokens
+	parts := make([]string, 0, len(args.Reads))
+	for i, r := range args.Reads {
+		if budget < minReadTokens {
+			var skipped []string
+			for _, s := range args.Reads[i:] {
+				skipped = append(skipped, s.FilePath)
+			}
+			parts = append(parts, fmt.Sprintf("The token budget of this call ran out before reading %s, read them in another call.",
+				strings.Join(skipped, ", ")))
+			break
+		}
+		out, used := readFileRange(r, budget)
+		budget -= used
+		parts = append(parts, out)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// readFileRange renders one read of handleReadFiles and returns the number of
+// tokens its content took.
+func readFileRange(r ReadFile, budget int) (string, int) {
+	full, err := resolvePath(r.FilePath)
+	if err != nil {
+		return fmt.Sprintf("Failed to read file %q: %s", r.FilePath, err), 0
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return fmt.Sprintf("Failed to read file %q: %s", r.FilePath, err), 0
+	}
+	if info.IsDir() {
+		return fmt.Sprintf("%q is a directory, use ls to list it.", r.FilePath), 0
+	}
+	if info.Size() > maxReadFileSize {
+		return fmt.Sprintf("%q is too large to read (%s, the limit is %s). Use search to find the relevant parts.",
+			r.FilePath, formatSize(info.Size()), formatSize(maxReadFileSize)), 0
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return fmt.Sprintf("Failed to read file %q: %s", r.FilePath, err), 0
+	}
+	if isBinary(data) {
+		return fmt.Sprintf("%q is a binary file (%s, %s), its content is not shown.",
+			r.FilePath, http.DetectContentType(data), formatSize(info.Size())), 0
+	}
+
+	if len(data) == 0 {
+		return fmt.Sprintf("<file_content path=%q total_lines=\"0\">\n(empty file)\n</file_content>", r.FilePath), 0
+	}
+	lines := safeSplit(string(data))
+	if longest, avg := lineLengths(lines); avg > minifiedAvgLineLength && len(data) > minifiedMinSize {
+		return fmt.Sprintf("%q looks minified or generated (%s, %d lines, %d characters per line on average, the longest has %d), its content is not shown. Use search to find the relevant parts.",
+			r.FilePath, formatSize(info.Size()), len(lines), avg, longest), 0
+	}
+
+	start := max(r.Offset, 1)
+	if start > len(lines) {
+		return fmt.Sprintf("%q has only %d lines, the offset %d is past its end.", r.FilePath, len(lines), r.Offset), 0
+	}
+	limit := r.Limit
+	if limit < 1 {
+		limit = defaultReadLimit
+	}
+	end := min(start-1+limit, len(lines))
+
+	width := len(fmt.Sprint(end))
+	var body strings.Builder
+	used := 0
+	last := start - 1
+	for i := start - 1; i < end; i++ {
+		line := lines[i]
+		if len(line) > maxReadLineLength {
+			line = fmt.Sprintf("%s... [%d more characters]", strings.ToValidUTF8(line[:maxReadLineLength], ""), len(line)-maxReadLineLength)
+		}
+		line = fmt.Sprintf("%*d | %s\n", width, i+1, line)
+		tokens := utils.CountTokens(line)
+		// Always show at least one line so the reads make progress.
+		if used+tokens > budget && i > start-1 {
+			break
+		}
+		used += tokens
+		body.WriteString(line)
+		last = i + 1
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<file_content path=%q lines=\"%d-%d\" total_lines=\"%d\">\n", r.FilePath, start, last, len(lines))
+	sb.WriteString(body.String())
+	sb.WriteString("</file_content>")
+	if last < len(lines) {
+		reason := utils.Ternary(last < end, "the token budget of this call ran out", "the line limit was reached")
+		fmt.Fprintf(&sb, "\nThe file continues, %s. Call read_files with offset %d to continue from line %d.", reason, last+1, last+1)
+	}
+	return sb.String(), used
+}
+
+// lineLengths returns the length of the longest line and the average length
+// of the lines.
+func lineLengths(lines []string) (longest, avg int) {
+	total := 0
+	for _, l := range lines {
+		total += len(l)
+		longest = max(longest, len(l))
+	}
+	return longest, total / len(lines)
+}
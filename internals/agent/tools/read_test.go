@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func runReadFiles(t *testing.T, reads ...ReadFile) string {
+	t.Helper()
+	argsJSON, _ := json.Marshal(ReadFilesToolArgs{Reads: reads})
+	out, err := handleReadFiles(string(argsJSON))
+	if err != nil {
+		t.Fatalf("read_files failed: %v", err)
+	}
+	return out
+}
+
+func TestReadFiles(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	var numbered []string
+	for i := 1; i <= 12; i++ {
+		numbered = append(numbered, fmt.Sprintf("line %d", i))
+	}
+	files := map[string]string{
+		"numbered.txt": strings.Join(numbered, "\n") + "\n",
+		"empty.txt":    "",
+		"image.png":    "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"app.min.js":   strings.Repeat("var a=1;", 2000),
+	}
+	for p, content := range files {
+		if err := os.WriteFile(filepath.Join(configs.WorkingPath, p), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := runReadFiles(t, ReadFile{FilePath: "numbered.txt", Offset: 3, Limit: 2})
+	want := "<file_content path=\"numbered.txt\" lines=\"3-4\" total_lines=\"13\">\n3 | line 3\n4 | line 4\n</file_content>\n" +
+		"The file continues, the line limit was reached. Call read_files with offset 5 to continue from line 5."
+	if out != want {
+		t.Fatalf("unexpected read:\n%s", out)
+	}
+	out = runReadFiles(t, ReadFile{FilePath: "numbered.txt", Offset: 10})
+	if !strings.Contains(out, "lines=\"10-13\"") || !strings.Contains(out, "12 | line 12\n13 | \n</file_content>") || strings.Contains(out, "continues") {
+		t.Fatalf("expected the rest of the file, got:\n%s", out)
+	}
+	if out := runReadFiles(t, ReadFile{FilePath: "numbered.txt", Offset: 20}); !strings.Contains(out, "has only 13 lines") {
+		t.Fatalf("expected an offset error, got:\n%s", out)
+	}
+
+	out = runReadFiles(t, ReadFile{FilePath: "empty.txt"}, ReadFile{FilePath: "image.png"}, ReadFile{FilePath: "app.min.js"}, ReadFile{FilePath: "missing.txt"})
+	for _, want := range []string{"(empty file)", `"image.png" is a binary file (image/png`, `"app.min.js" looks minified`, `Failed to read file "missing.txt"`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestReadFilesTokenBudget(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	var lines []string
+	for i := range 3000 {
+		lines = append(lines, fmt.Sprintf("func generated%d() string { return \"some moderately long line of code %d\" }", i, i))
+	}
+	for _, name := range []string{"big.go", "other.go"} {
+		if err := os.WriteFile(filepath.Join(configs.WorkingPath, name), []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := runReadFiles(t, ReadFile{FilePath: "big.go"}, ReadFile{FilePath: "other.go"})
+	_, rest, ok := strings.Cut(out, "the token budget of this call ran out. Call read_files with offset ")
+	if !ok {
+		t.Fatalf("expected the read to be cut off by the budget, got:\n%s", out[len(out)-300:])
+	}
+	var next int
+	fmt.Sscanf(rest, "%d", &next)
+	if next < 10 || next > 2000 || !strings.Contains(out, fmt.Sprintf("%d | func generated%d()", next-1, next-2)) || strings.Contains(out, fmt.Sprintf("| func generated%d()", next-1)) {
+		t.Fatalf("expected the read to stop at a line boundary right before line %d", next)
+	}
+	if !strings.HasSuffix(out, "ran out before reading other.go, read them in another call.") {
+		t.Fatalf("expected the second file to be skipped, got:\n%s", out[len(out)-300:])
+	}
+}
@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The tools registered at runtime, next to the built-in Definitions and
+// Handlers, e.g. the tools of the MCP servers.
+var (
+	registryMu sync.RWMutex
+	registered = map[string]registeredTool{}
+)
+
+type registeredTool struct {
+	def     ToolDefinition
+	handler func(argsJSON string) (string, error)
+}
+
+// Register offers a tool to the model until it is unregistered. A tool of
+// the same name is replaced, the built-in tools can't be.
+func Register(def ToolDefinition, handler func(argsJSON string) (string, error)) bool {
+	if _, ok := Handlers[def.Name]; ok {
+		return false
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registered[def.Name] = registeredTool{def: def, handler: handler}
+	return true
+}
+
+// Unregister removes the registered tools whose names start with prefix.
+func Unregister(prefix string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for name := range registered {
+		if strings.HasPrefix(name, prefix) {
+			delete(registered, name)
+		}
+	}
+}
+
+// replaceTools removes the registered tools whose names start with one of
+// the prefixes and registers the tools in their place at once, so the model
+// never sees the list half done. The tools named like a built-in or another
+// registered tool are skipped, their names are returned.
+func replaceTools(prefixes []string, tools []registeredTool) []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for name := range registered {
+		if slices.ContainsFunc(prefixes, func(prefix string) bool { return strings.HasPrefix(name, prefix) }) {
+			delete(registered, name)
+		}
+	}
+	skipped := []string{}
+	for _, t := range tools {
+		_, builtIn := Handlers[t.def.Name]
+		if _, taken := registered[t.def.Name]; builtIn || taken {
+			skipped = append(skipped, t.def.Name)
+			continue
+		}
+		registered[t.def.Name] = t
+	}
+	return skipped
+}
+
+// unregisterTool removes the registered tool of the name.
+func unregisterTool(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registered, name)
+}
+
+// AllDefinitions returns the built-in tools followed by the registered ones,
+// sorted by name.
+func AllDefinitions() []ToolDefinition {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	extra := make([]ToolDefinition, 0, len(registered))
+	for _, t := range registered {
+		extra = append(extra, t.def)
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i].Name < extra[j].Name })
+	return append(append([]ToolDefinition{}, Definitions...), extra...)
+}
+
+// Handler returns the handler of a built-in or registered tool.
+func Handler(name string) (func(argsJSON string) (string, error), bool) {
+	if h, ok := Handlers[name]; ok {
+		return h, true
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	t, ok := registered[name]
+	return t.handler, ok
+}
+
+// objectSchema makes sure the schema of a registered tool describes an
+// object, as the model providers require.
+func objectSchema(s map[string]any) map[string]any {
+	schema := map[string]any{}
+	for k, v := range s {
+		schema[k] = v
+	}
+	schema["type"] = "object"
+	if _, ok := schema["properties"].(map[string]any); !ok {
+		schema["properties"] = map[string]any{}
+	}
+	return schema
+}
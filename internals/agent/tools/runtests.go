@@ -0,0 +1,203 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/testrun"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+const (
+	defaultTestTimeout    = 5 * time.Minute
+	maxTestFailures       = 20
+	maxTestFailureLines   = 30
+	maxTestUnparsedLength = 4000
+)
+
+type RunTestsToolArgs struct {
+	Path      string `json:"path"`
+	Name      string `json:"name"`
+	Framework string `json:"framework"`
+	Timeout   int    `json:"timeout"` // seconds
+}
+
+// handleRunTests runs the tests of the project in the session's shell, so
+// they run in the same sandbox as the bash tool, and summarizes the report of
+// the test framework.
+func handleRunTests(argsJSON string) (string, error) {
+	var args RunTestsToolArgs
+	if argsJSON != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", err
+		}
+	}
+	timeout := defaultTestTimeout
+	if args.Timeout > 0 {
+		timeout = min(time.Duration(args.Timeout)*time.Second, maxCommandTimeout)
+	}
+
+	// The path may select tests inside a file, e.g. tests/test_api.py::test_get.
+	p, selector, _ := strings.Cut(args.Path, "::")
+	target, err := resolvePath(utils.Ternary(p == "", ".", strings.TrimSuffix(p, "/...")))
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return fmt.Sprintf("The path '%s' does not exist.", args.Path), nil
+	}
+	dir := utils.Ternary(info.IsDir(), target, filepath.Dir(target))
+
+	var (
+		fw   = testrun.Framework(args.Framework)
+		root string
+	)
+	if fw == "" {
+		if fw, root, err = testrun.Detect(dir); err != nil {
+			return fmt.Sprintf("Unable to detect the test framework: %v. Pass the framework or run the tests with bash.", err), nil
+		}
+	} else {
+		if !slices.Contains(testrun.Frameworks, fw) {
+			return fmt.Sprintf("Unsupported test framework '%s', use one of %v.", fw, testrun.Frameworks), nil
+		}
+		root = testrun.Root(fw, dir)
+	}
+
+	report, err := os.CreateTemp("", "cli-agent-tests-*")
+	if err != nil {
+		return "", err
+	}
+	report.Close()
+	os.Remove(report.Name()) // the frameworks that don't run leave no report behind
+	defer os.Remove(report.Name())
+
+	opts := testrun.Options{Dir: root, Name: args.Name, ReportFile: report.Name()}
+	if rel := relSlash(root, target); args.Path != "" && rel != "" {
+		opts.Target = rel
+		if strings.HasSuffix(p, "/...") {
+			opts.Target += "/..."
+		}
+		if selector != "" {
+			opts.Target += "::" + selector
+		}
+	}
+	cmdline, err := testrun.Command(fw, opts)
+	if err != nil {
+		return "", err
+	}
+
+	if shell == nil {
+		shell = NewShell(configs.WorkingPath, sandboxConfig)
+	}
+	started := time.Now()
+	res, err := shell.Run(toolCtx, cmdline, timeout, liveOutput)
+	if err != nil {
+		return "", err
+	}
+	summary, err := testrun.Parse(fw, opts, res.Output.String())
+	if err != nil {
+		return "", err
+	}
+	summary.Duration = time.Since(started)
+	return formatTestReport(fw, opts, summary, res, timeout), nil
+}
+
+func formatTestReport(fw testrun.Framework, opts testrun.Options, r *testrun.Report, res CommandResult, timeout time.Duration) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<test_results framework=%q dir=%q", fw, displayPath(opts.Dir))
+	if opts.Target != "" {
+		fmt.Fprintf(&sb, " target=%q", opts.Target)
+	}
+	if opts.Name != "" {
+		fmt.Fprintf(&sb, " name=%q", opts.Name)
+	}
+	sb.WriteString(">\n")
+
+	fmt.Fprintf(&sb, "%d passed, %d failed, %d skipped", r.Passed, r.Failed, r.Skipped)
+	if len(r.Errors) > 0 {
+		fmt.Fprintf(&sb, ", %s failed to run", utils.Plural(len(r.Errors), utils.Ternary(fw == testrun.Go, "package", "file")))
+	}
+	fmt.Fprintf(&sb, " in %s\n", r.Duration.Round(100*time.Millisecond))
+
+	for i, f := range r.Failures {
+		if i == maxTestFailures {
+			fmt.Fprintf(&sb, "\n... %d more failing tests\n", len(r.Failures)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "\nFAIL %s", f.Name)
+		if f.Location != "" {
+			fmt.Fprintf(&sb, " at %s", testPath(f.Location, opts.Dir))
+		} else if f.Suite != "" {
+			fmt.Fprintf(&sb, " in %s", testPath(f.Suite, ""))
+		}
+		sb.WriteString("\n")
+		if f.Output != "" {
+			sb.WriteString(indentLines(clipLines(f.Output, maxTestFailureLines), "    ") + "\n")
+		}
+	}
+	for _, e := range r.Errors {
+		fmt.Fprintf(&sb, "\nERROR %s\n", testPath(e.Suite, ""))
+		if e.Output != "" {
+			sb.WriteString(indentLines(clipLines(e.Output, maxTestFailureLines), "    ") + "\n")
+		}
+	}
+	if len(r.Running) > 0 {
+		sb.WriteString("\nStill running when the tests stopped:\n")
+		for _, name := range r.Running {
+			sb.WriteString("  " + name + "\n")
+		}
+	}
+	if out := strings.TrimSpace(r.Unparsed); out != "" {
+		if len(out) > maxTestUnparsedLength {
+			out = "..." + out[len(out)-maxTestUnparsedLength:]
+		}
+		sb.WriteString("\n<output>\n" + out + "\n</output>\n")
+	}
+	sb.WriteString("</test_results>\n")
+
+	switch {
+	case res.TimedOut:
+		fmt.Fprintf(&sb, "The tests did not finish within %s and were killed.", timeout)
+	case res.Cancelled:
+		sb.WriteString("The tests were cancelled by the user and were killed.")
+	case r.Empty():
+		fmt.Fprintf(&sb, "No tests ran (exit code %d).", res.ExitCode)
+	case r.Failed == 0 && len(r.Errors) == 0:
+		sb.WriteString("All tests passed.")
+	default:
+		fmt.Fprintf(&sb, "Exit code: %d", res.ExitCode)
+	}
+	return sb.String()
+}
+
+// testPath shows the paths the frameworks report, absolute or relative to
+// root, relative to the WorkingPath like the other tools. Package names are
+// left alone when root is empty.
+func testPath(p, root string) string {
+	switch {
+	case filepath.IsAbs(p):
+		return displayPath(p)
+	case root != "":
+		return displayPath(filepath.Join(root, p))
+	}
+	return p
+}
+
+func clipLines(s string, limit int) string {
+	lines := strings.Split(s, "\n")
+	if len(lines) <= limit {
+		return s
+	}
+	return strings.Join(lines[:limit], "\n") + fmt.Sprintf("\n... %d more lines", len(lines)-limit)
+}
+
+func indentLines(s, indent string) string {
+	return indent + strings.ReplaceAll(s, "\n", "\n"+indent)
+}
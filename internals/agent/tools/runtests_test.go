@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func runTests(t *testing.T, args RunTestsToolArgs) string {
+	t.Helper()
+	argsJSON, _ := json.Marshal(args)
+	out, err := handleRunTests(string(argsJSON))
+	if err != nil {
+		t.Fatalf("run_tests failed: %v", err)
+	}
+	return out
+}
+
+func TestRunGoTests(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	t.Cleanup(CloseShell)
+	files := map[string]string{
+		"go.mod":            "module example.com/app\n\ngo 1.22\n",
+		"calc/calc.go":      "package calc\n\nfunc Add(a, b int) int { return a - b }\n",
+		"calc/calc_test.go": "package calc\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif got := Add(1, 2); got != 3 {\n\t\tt.Errorf(\"Add(1, 2) = %d\", got)\n\t}\n}\n\nfunc TestAddZero(t *testing.T) {\n\tif Add(0, 0) != 0 {\n\t\tt.Fail()\n\t}\n}\n",
+		"broken/broken.go":  "package broken\n\nvar X int = \"x\"\n",
+		"broken/x_test.go":  "package broken\n",
+		"slow/slow_test.go": "package slow\n\nimport (\n\t\"testing\"\n\t\"time\"\n)\n\nfunc TestSlow(t *testing.T) { time.Sleep(time.Minute) }\n",
+	}
+	for p, content := range files {
+		full := filepath.Join(configs.WorkingPath, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := runTests(t, RunTestsToolArgs{Path: "calc"})
+	if !strings.Contains(out, `<test_results framework="go" dir="." target="calc">`+"\n1 passed, 1 failed, 0 skipped in ") ||
+		!strings.Contains(out, "FAIL TestAdd at calc/calc_test.go:7\n    calc_test.go:7: Add(1, 2) = -1\n") {
+		t.Fatalf("unexpected report:\n%s", out)
+	}
+
+	out = runTests(t, RunTestsToolArgs{Path: "broken", Name: "TestAdd"})
+	if !strings.Contains(out, "0 passed, 0 failed, 0 skipped, 1 package failed to run") || !strings.Contains(out, "ERROR example.com/app/broken\n    broken/broken.go:3:13: cannot use \"x\"") {
+		t.Fatalf("expected a build error, got:\n%s", out)
+	}
+
+	out = runTests(t, RunTestsToolArgs{Path: "slow", Timeout: 5})
+	if !strings.Contains(out, "Still running when the tests stopped:\n  example.com/app/slow TestSlow") || !strings.Contains(out, "The tests did not finish within 5s and were killed.") {
+		t.Fatalf("expected the slow test to time out, got:\n%s", out)
+	}
+
+	if out := runTests(t, RunTestsToolArgs{Path: "missing"}); !strings.Contains(out, "does not exist") {
+		t.Fatalf("expected a missing path, got:\n%s", out)
+	}
+}
@@ -0,0 +1,324 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+const (
+	defaultSearchMaxResults = 100
+	maxSearchContextLines   = 10
+	maxSearchOutputTokens   = 4000
+	maxSearchLineLength     = 300
+	maxSearchFileSize       = 5 << 20
+)
+
+type SearchToolArgs struct {
+	Pattern         string   `json:"pattern"`
+	Literal         bool     `json:"literal"`
+	CaseInsensitive bool     `json:"caseInsensitive"`
+	Path            string   `json:"path"`
+	Include         []string `json:"include"`
+	Exclude         []string `json:"exclude"`
+	ContextLines    int      `json:"contextLines"`
+	MaxResults      int      `json:"maxResults"`
+	FilesOnly       bool     `json:"filesOnly"`
+}
+
+type searchMatch struct {
+	line    int // 1-based
+	text    string
+	isMatch bool // false for context lines
+}
+
+type fileMatches struct {
+	path    string // relative to the search root's workspace root
+	count   int
+	matches []searchMatch
+}
+
+// handleSearch searches the files of the workspace in Go, respecting the
+// project's ignore rules, and returns the matches grouped by file.
+func handleSearch(argsJSON string) (string, error) {
+	var args SearchToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	if args.Pattern == "" {
+		return "", fmt.Errorf("no pattern provided")
+	}
+	if args.Path == "" {
+		args.Path = "."
+	}
+	if args.MaxResults < 1 {
+		args.MaxResults = defaultSearchMaxResults
+	}
+	args.ContextLines = min(max(args.ContextLines, 0), maxSearchContextLines)
+
+	expr := args.Pattern
+	if args.Literal {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if args.CaseInsensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Sprintf("Invalid regular expression %q: %s. Set literal to search for the text as-is.", args.Pattern, err), nil
+	}
+
+	searchRoot, err := resolvePath(args.Path)
+	if err != nil {
+		return "", err
+	}
+	files, err := collectSearchFiles(searchRoot, compileGlobs(args.Include), compileGlobs(args.Exclude))
+	if err != nil {
+		return "", err
+	}
+
+	results := searchFiles(files, re, args)
+	return formatSearchResults(results, args), nil
+}
+
+// collectSearchFiles returns the absolute paths of the files under searchRoot
+// that are not ignored and pass the include/exclude globs.
+func collectSearchFiles(searchRoot string, include, exclude []*regexp.Regexp) ([]string, error) {
+	info, err := os.Stat(searchRoot)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{searchRoot}, nil
+	}
+
+	wsRoot := workspaceRootOf(searchRoot)
+	ignores := ignoreMatcher(wsRoot)
+	if rel := relSlash(wsRoot, searchRoot); rel != "" && ignores.Ignored(rel, true) {
+		// Searching inside an ignored directory was asked for explicitly.
+		ignores = &IgnoreMatcher{root: wsRoot}
+	}
+
+	files := []string{}
+	err = filepath.WalkDir(searchRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == searchRoot {
+			return nil
+		}
+		if ignores.match(relSlash(wsRoot, p), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		rel := relSlash(searchRoot, p)
+		if len(include) > 0 && !matchesAnyGlob(rel, include) {
+			return nil
+		}
+		if matchesAnyGlob(rel, exclude) {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	})
+	return files, err
+}
+
+// searchFiles scans the files concurrently, each worker handling whole files.
+func searchFiles(files []string, re *regexp.Regexp, args SearchToolArgs) []fileMatches {
+	jobs := make(chan string)
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results []fileMatches
+	)
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				if fm, ok := searchFile(p, re, args); ok {
+					mu.Lock()
+					results = append(results, fm)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, f := range files {
+		jobs <- f
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].path < results[j].path })
+	return results
+}
+
+func searchFile(p string, re *regexp.Regexp, args SearchToolArgs) (fileMatches, bool) {
+	fm := fileMatches{path: displayPath(p)}
+	info, err := os.Stat(p)
+	if err != nil || info.Size() > maxSearchFileSize {
+		return fm, false
+	}
+	data, err := os.ReadFile(p)
+	if err != nil || isBinary(data) {
+		return fm, false
+	}
+
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), maxSearchFileSize)
+	for sc.Scan() {
+		lines = append(lines, strings.TrimSuffix(sc.Text(), "\r"))
+	}
+
+	var hits []int
+	for i, l := range lines {
+		if re.MatchString(l) {
+			hits = append(hits, i)
+		}
+	}
+	fm.count = len(hits)
+	if args.FilesOnly {
+		return fm, fm.count > 0
+	}
+
+	// Context lines shared by neighbouring matches are only emitted once.
+	next := 0
+	for _, i := range hits[:min(len(hits), args.MaxResults)] {
+		for j := max(next, i-args.ContextLines); j <= min(len(lines)-1, i+args.ContextLines); j++ {
+			fm.matches = append(fm.matches, searchMatch{line: j + 1, text: lines[j], isMatch: re.MatchString(lines[j])})
+			next = j + 1
+		}
+	}
+	return fm, fm.count > 0
+}
+
+func formatSearchResults(results []fileMatches, args SearchToolArgs) string {
+	if len(results) == 0 {
+		return fmt.Sprintf("No matches found for %q.", args.Pattern)
+	}
+
+	total := 0
+	for _, fm := range results {
+		total += fm.count
+	}
+
+	var sb strings.Builder
+	shown, truncated := 0, false
+	tokens := 0
+	for _, fm := range results {
+		var group strings.Builder
+		if args.FilesOnly {
+			fmt.Fprintf(&group, "%s (%d matches)\n", fm.path, fm.count)
+		} else {
+			if shown >= args.MaxResults {
+				truncated = true
+				break
+			}
+			group.WriteString(fm.path + "\n")
+			prev := 0
+			for _, m := range fm.matches {
+				if m.isMatch {
+					if shown >= args.MaxResults {
+						truncated = true
+						break
+					}
+					shown++
+				}
+				if prev != 0 && m.line > prev+1 {
+					group.WriteString("  --\n")
+				}
+				sep := utils.Ternary(m.isMatch, ":", "-")
+				text := m.text
+				if len(text) > maxSearchLineLength {
+					text = text[:maxSearchLineLength] + "…"
+				}
+				fmt.Fprintf(&group, "  %d%s %s\n", m.line, sep, text)
+				prev = m.line
+			}
+		}
+
+		groupTokens := utils.CountTokens(group.String())
+		if tokens+groupTokens > maxSearchOutputTokens {
+			truncated = true
+			break
+		}
+		tokens += groupTokens
+		sb.WriteString(group.String())
+		if !args.FilesOnly {
+			sb.WriteString("\n")
+		}
+	}
+
+	if !args.FilesOnly && shown < total {
+		truncated = true
+	}
+
+	header := fmt.Sprintf("Found %d matches in %d files.\n", total, len(results))
+	out := header + "<search_results>\n" + strings.TrimRight(sb.String(), "\n") + "\n</search_results>"
+	if truncated {
+		out += "\nThe results were truncated. Narrow the search with a more specific pattern, path or include globs."
+	}
+	return out
+}
+
+// workspaceRootOf returns the allowed root the (resolved) path belongs to.
+func workspaceRootOf(p string) string {
+	for _, root := range allowedRoots() {
+		if withinAnyRoot(p, []string{root}) {
+			return root
+		}
+	}
+	return configs.WorkingPath
+}
+
+// displayPath shows paths relative to the WorkingPath, which is how the model
+// refers to them in the other tools.
+func displayPath(p string) string {
+	if rel, err := filepath.Rel(allowedRoots()[0], p); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return p
+}
+
+// compileGlobs compiles globs with the .gitignore semantics: a glob without a
+// slash matches a name at any depth, otherwise the path from the search root.
+func compileGlobs(globs []string) []*regexp.Regexp {
+	res := []*regexp.Regexp{}
+	for _, g := range globs {
+		if r, ok := parseIgnorePattern("", g); ok {
+			res = append(res, r.re)
+		}
+	}
+	return res
+}
+
+func matchesAnyGlob(rel string, globs []*regexp.Regexp) bool {
+	for _, re := range globs {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBinary uses the same heuristic as git: a NUL byte in the first 8000 bytes.
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
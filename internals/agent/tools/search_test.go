@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func runSearch(t *testing.T, args SearchToolArgs) string {
+	t.Helper()
+	argsJSON, _ := json.Marshal(args)
+	out, err := handleSearch(string(argsJSON))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	return out
+}
+
+func TestSearch(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	files := map[string]string{
+		".gitignore":          "generated/\n",
+		"main.go":             "package main\n\nfunc main() {\n\tHello()\n}\n",
+		"pkg/hello.go":        "package pkg\n\n// Hello greets.\nfunc Hello() {}\n",
+		"pkg/hello_test.go":   "package pkg\n\nfunc TestHello() { Hello() }\n",
+		"docs/notes.md":       "hello (world)\n",
+		"generated/hello.go":  "func Hello() {}\n",
+		"node_modules/x.js":   "Hello()\n",
+		"assets/logo.bin":     "Hello\x00\x01",
+		"sub/dir/deep.go.txt": "HELLO\n",
+	}
+	for p, content := range files {
+		full := filepath.Join(configs.WorkingPath, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := runSearch(t, SearchToolArgs{Pattern: `Hello\(\)`})
+	if !strings.Contains(out, "Found 3 matches in 3 files.") {
+		t.Fatalf("unexpected summary:\n%s", out)
+	}
+	for _, want := range []string{"main.go\n  4: \tHello()", "pkg/hello.go\n  4: func Hello() {}", "pkg/hello_test.go\n  3: func TestHello() { Hello() }"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in:\n%s", want, out)
+		}
+	}
+	for _, hidden := range []string{"generated/", "node_modules/", "logo.bin"} {
+		if strings.Contains(out, hidden) {
+			t.Fatalf("expected %q to be skipped:\n%s", hidden, out)
+		}
+	}
+
+	out = runSearch(t, SearchToolArgs{Pattern: "(world)", Literal: true})
+	if !strings.Contains(out, "docs/notes.md\n  1: hello (world)") {
+		t.Fatalf("literal search failed:\n%s", out)
+	}
+
+	out = runSearch(t, SearchToolArgs{Pattern: "hello", CaseInsensitive: true, Include: []string{"*.go"}, Exclude: []string{"*_test.go"}, FilesOnly: true})
+	if out != "Found 3 matches in 2 files.\n<search_results>\nmain.go (1 matches)\npkg/hello.go (2 matches)\n</search_results>" {
+		t.Fatalf("unexpected files-only output:\n%s", out)
+	}
+
+	out = runSearch(t, SearchToolArgs{Pattern: "Hello", Path: "pkg/hello.go", ContextLines: 1})
+	if !strings.Contains(out, "pkg/hello.go\n  2- \n  3: // Hello greets.\n  4: func Hello() {}\n") {
+		t.Fatalf("unexpected context output:\n%s", out)
+	}
+
+	out = runSearch(t, SearchToolArgs{Pattern: "Hello", Path: "generated"})
+	if !strings.Contains(out, "generated/hello.go") {
+		t.Fatalf("expected an explicitly searched ignored dir to be searched:\n%s", out)
+	}
+
+	out = runSearch(t, SearchToolArgs{Pattern: "(unclosed"})
+	if !strings.HasPrefix(out, "Invalid regular expression") {
+		t.Fatalf("expected the regexp error to be reported, got:\n%s", out)
+	}
+
+	argsJSON, _ := json.Marshal(SearchToolArgs{Pattern: "x", Path: "../outside"})
+	if _, err := handleSearch(string(argsJSON)); err == nil {
+		t.Fatal("expected paths outside of the workspace to be rejected")
+	}
+}
+
+func TestSearchMaxResults(t *testing.T) {
+	configs.WorkingPath = t.TempDir()
+	var sb strings.Builder
+	for i := range 50 {
+		fmt.Fprintf(&sb, "match %d\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(configs.WorkingPath, "many.txt"), []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runSearch(t, SearchToolArgs{Pattern: "match", MaxResults: 5})
+	if !strings.Contains(out, "Found 50 matches in 1 files.") || !strings.Contains(out, "  5: match 4\n") || strings.Contains(out, "match 5\n") {
+		t.Fatalf("expected the results to be capped:\n%s", out)
+	}
+	if !strings.Contains(out, "The results were truncated") {
+		t.Fatalf("expected a truncation note:\n%s", out)
+	}
+}
@@ -0,0 +1,283 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
+)
+
+const liveOutputInterval = 100 * time.Millisecond
+
+// Shell is a long lived shell process the commands of a session run in, so
+// the working directory, the exported variables and the functions defined by
+// one command are still there for the next one.
+type Shell struct {
+	mu      sync.Mutex
+	dir     string
+	sandbox sandbox.Config
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	chunks  chan []byte
+	done    chan struct{}
+	exited  chan struct{}
+}
+
+type CommandResult struct {
+	Output    *outputBuffer
+	ExitCode  int
+	TimedOut  bool
+	Cancelled bool
+	// Restarted is set when the shell had to be killed or exited on its own,
+	// losing everything but its working directory.
+	Restarted bool
+}
+
+func NewShell(dir string, sb sandbox.Config) *Shell {
+	return &Shell{dir: dir, sandbox: sb}
+}
+
+// Dir returns the working directory the shell was in after the last command.
+func (s *Shell) Dir() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dir
+}
+
+// Run runs the command in the shell and waits for it to finish, for the
+// timeout to pass or for ctx to be cancelled, whichever comes first. In the
+// last two cases the whole process group is killed and the shell is started
+// again, in the same directory, by the next command. The combined output is
+// reported to onOutput while the command runs.
+func (s *Shell) Run(ctx context.Context, command string, timeout time.Duration, onOutput func(output string)) (CommandResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := CommandResult{Output: newOutputBuffer(maxCommandOutputHead, maxCommandOutputTail)}
+	if s.cmd == nil {
+		if err := s.start(); err != nil {
+			return res, err
+		}
+	}
+
+	// The command is eval'ed so that syntax errors can't swallow the marker
+	// that tells where its output ends, and gets no stdin so it can't read
+	// the following commands.
+	marker := "__CLI_AGENT_DONE_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	script := fmt.Sprintf("{ eval %s\n} < /dev/null\nprintf '\\n%s %%d %%s\\n' \"$?\" \"$PWD\"\n", shellQuote(command), marker)
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		return res, fmt.Errorf("failed to write to the shell: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var (
+		markerBytes = []byte(marker)
+		pending     []byte
+		lastLive    time.Time
+	)
+	for {
+		select {
+		case chunk, ok := <-s.chunks:
+			if !ok {
+				// The command exited the shell itself.
+				res.Output.Write(pending)
+				res.ExitCode = s.exitCode()
+				res.Restarted = true
+				s.kill()
+				return res, nil
+			}
+			pending = append(pending, chunk...)
+			if i := bytes.Index(pending, markerBytes); i >= 0 {
+				end := bytes.IndexByte(pending[i:], '\n')
+				if end < 0 {
+					continue
+				}
+				res.Output.Write(bytes.TrimSuffix(pending[:i], []byte("\n")))
+				status := strings.TrimSpace(string(pending[i+len(marker) : i+end]))
+				code, dir, _ := strings.Cut(status, " ")
+				res.ExitCode, _ = strconv.Atoi(code)
+				if dir != "" {
+					s.dir = dir
+				}
+				return res, nil
+			}
+			// Hold back what could be the start of the marker line.
+			if keep := len(marker) + 1; len(pending) > keep {
+				res.Output.Write(pending[:len(pending)-keep])
+				pending = pending[len(pending)-keep:]
+			}
+			if onOutput != nil && time.Since(lastLive) >= liveOutputInterval {
+				onOutput(res.Output.String())
+				lastLive = time.Now()
+			}
+
+		case <-timer.C:
+			res.Output.Write(pending)
+			res.TimedOut, res.Restarted, res.ExitCode = true, true, -1
+			s.kill()
+			return res, nil
+
+		case <-ctx.Done():
+			res.Output.Write(pending)
+			res.Cancelled, res.Restarted, res.ExitCode = true, true, -1
+			s.kill()
+			return res, nil
+		}
+	}
+}
+
+// Close kills the shell and everything running in it.
+func (s *Shell) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kill()
+}
+
+func (s *Shell) start() error {
+	name, args := "/bin/sh", []string{}
+	if p, err := exec.LookPath("bash"); err == nil {
+		name, args = p, []string{"--noprofile", "--norc"}
+	}
+	if _, err := os.Stat(s.dir); err != nil {
+		return fmt.Errorf("the shell's working directory is gone: %w", err)
+	}
+
+	cmd, err := sandbox.Command(s.sandbox, name, args...)
+	if err != nil {
+		return err
+	}
+	cmd.Dir = s.dir
+	// Nothing can answer a prompt or drive a pager, so keep tools from
+	// waiting for either.
+	cmd.Env = append(cmd.Env, "PAGER=cat", "GIT_PAGER=cat", "GIT_TERMINAL_PROMPT=0", "TERM=dumb")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stdout, cmd.Stderr = w, w
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return fmt.Errorf("failed to start the shell: %w", err)
+	}
+	w.Close()
+
+	chunks, done := make(chan []byte, 64), make(chan struct{})
+	go func() {
+		defer close(chunks)
+		defer r.Close()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				select {
+				case chunks <- bytes.Clone(buf[:n]):
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	s.cmd, s.stdin, s.chunks, s.done, s.exited = cmd, stdin, chunks, done, exited
+	return nil
+}
+
+func (s *Shell) kill() {
+	if s.cmd == nil {
+		return
+	}
+	killProcessGroup(s.cmd)
+	s.stdin.Close()
+	close(s.done)
+	s.cmd, s.stdin, s.chunks, s.done, s.exited = nil, nil, nil, nil, nil
+}
+
+func (s *Shell) exitCode() int {
+	select {
+	case <-s.exited:
+		return s.cmd.ProcessState.ExitCode()
+	case <-time.After(time.Second):
+		return -1
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// outputBuffer keeps the beginning and the end of a command's output, where
+// the useful parts (what ran, the errors and the summaries) usually are.
+type outputBuffer struct {
+	head      []byte
+	tail      []byte
+	headLimit int
+	tailLimit int
+	total     int
+}
+
+func newOutputBuffer(headLimit, tailLimit int) *outputBuffer {
+	return &outputBuffer{headLimit: headLimit, tailLimit: tailLimit}
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.total += n
+	if room := b.headLimit - len(b.head); room > 0 {
+		k := min(room, len(p))
+		b.head = append(b.head, p[:k]...)
+		p = p[k:]
+	}
+	b.tail = append(b.tail, p...)
+	if len(b.tail) > 2*b.tailLimit {
+		b.tail = append([]byte(nil), b.tail[len(b.tail)-b.tailLimit:]...)
+	}
+	return n, nil
+}
+
+// Total returns the size of the whole output in bytes.
+func (b *outputBuffer) Total() int {
+	return b.total
+}
+
+// Truncated reports whether a part of the output in the middle was dropped.
+func (b *outputBuffer) Truncated() bool {
+	return b.total > len(b.head)+min(len(b.tail), b.tailLimit)
+}
+
+func (b *outputBuffer) String() string {
+	tail := b.tail
+	if len(tail) > b.tailLimit {
+		tail = tail[len(tail)-b.tailLimit:]
+	}
+	if !b.Truncated() {
+		return strings.ToValidUTF8(string(b.head)+string(tail), "")
+	}
+	omitted := b.total - len(b.head) - len(tail)
+	return strings.ToValidUTF8(fmt.Sprintf("%s\n\n... [%d bytes omitted] ...\n\n%s", b.head, omitted, tail), "")
+}
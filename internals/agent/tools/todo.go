@@ -2,87 +2,57 @@ package tools
 
 import (
 	"encoding/json"
-	"log"
-	"os"
+	"fmt"
 	"strings"
 
-	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/db"
 )
 
-type TodoItem struct {
-	Id   int    `json:"id"`
-	Task string `json:"task"`
-	Done bool   `json:"done"`
+var todos = &db.TodoList{}
+
+// UseTodos makes todo_write change the todo list of the session.
+func UseTodos(list *db.TodoList) {
+	todos = list
 }
 
-func getExistingTodoContent() []TodoItem {
-	if c, err := os.ReadFile(configs.TodosFile); err != nil {
-		return []TodoItem{}
-	} else {
-		todoList := []TodoItem{}
-		if err = json.Unmarshal(c, &todoList); err != nil {
-			return todoList
-		}
-		return todoList
-	}
+// CurrentTodos is the todo list todo_write changes.
+func CurrentTodos() *db.TodoList {
+	return todos
 }
 
-func saveTodoList(todoList []TodoItem) {
-	if c, err := json.Marshal(todoList); err != nil {
-		log.Println("Error Marshaling the todo list:", err)
-	} else {
-		if err = os.WriteFile(configs.TodosFile, c, 0o644); err != nil {
-			log.Println("Error updating the todo list file:", err)
-		}
-	}
+type TodoWriteToolArgs struct {
+	Todos []db.Todo `json:"todos"`
 }
 
-func handleAddTodo(argsJSON string) (string, error) {
-	var args struct {
-		Todos []string
-	}
+func handleTodoWrite(argsJSON string) (string, error) {
+	var args TodoWriteToolArgs
 	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
 		return "", err
 	}
-	if len(args.Todos) < 1 {
-		return "Successfully updated the todo list.", nil
+	items, err := todos.Replace(args.Todos)
+	if err != nil {
+		return fmt.Sprintf("The todo list was not changed: %v", err), nil
 	}
-	todoList := getExistingTodoContent()
-	for _, task := range args.Todos {
-		existingTodo := false
-		for _, t := range todoList {
-			if strings.TrimSpace(t.Task) == strings.TrimSpace(task) {
-				existingTodo = true
-				break
-			}
-		}
-		if existingTodo {
-			continue
-		}
-		todoList = append(todoList, TodoItem{Id: len(todoList) + 1, Task: task, Done: false})
+	if len(items) == 0 {
+		return "The todo list is now empty.", nil
 	}
-	saveTodoList(todoList)
-	return "Successfully updated the todo list.", nil
-}
 
-func handleMarkTodoAsDone(argsJSON string) (string, error) {
-	var args struct {
-		Ids []int
-	}
-	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-		return "", err
-	}
-	if len(args.Ids) < 1 {
-		return "Successfully updated the todo list.", nil
-	}
-	todoList := getExistingTodoContent()
-	for _, id := range args.Ids {
-		for i, todo := range todoList {
-			if todo.Id == id {
-				todoList[i].Done = true
-			}
+	done, inProgress := 0, 0
+	for _, t := range items {
+		switch t.Status {
+		case db.TodoCompleted, db.TodoCancelled:
+			done++
+		case db.TodoInProgress:
+			inProgress++
 		}
 	}
-	saveTodoList(todoList)
-	return "Successfully updated the todo list.", nil
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<todo_list done=\"%d\" total=\"%d\">\n%s</todo_list>", done, len(items), todos.String())
+	switch {
+	case done == len(items):
+		sb.WriteString("\nEvery todo is done.")
+	case inProgress > 1:
+		sb.WriteString("\nWork on one todo at a time, keep only the current one in_progress.")
+	}
+	return sb.String(), nil
 }
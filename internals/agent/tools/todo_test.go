@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/db"
+)
+
+func runTodoWrite(t *testing.T, todos []db.Todo) string {
+	t.Helper()
+	argsJSON, _ := json.Marshal(TodoWriteToolArgs{Todos: todos})
+	out, err := handleTodoWrite(string(argsJSON))
+	if err != nil {
+		t.Fatalf("todo_write failed: %v", err)
+	}
+	return out
+}
+
+func TestTodoWrite(t *testing.T) {
+	list := &db.TodoList{}
+	UseTodos(list)
+	t.Cleanup(func() { UseTodos(&db.TodoList{}) })
+
+	out := runTodoWrite(t, []db.Todo{{Content: "Add the flag", Status: db.TodoInProgress}, {Content: "Test the flag", Status: db.TodoPending}})
+	want := "<todo_list done=\"0\" total=\"2\">\n[~] 1. Add the flag\n[ ] 2. Test the flag\n</todo_list>"
+	if out != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", out, want)
+	}
+
+	out = runTodoWrite(t, []db.Todo{{ID: 1, Content: "Add the flag", Status: db.TodoInProgress}, {ID: 2, Content: "Test the flag"}})
+	if !strings.Contains(out, "[ ] 2. Test the flag") {
+		t.Errorf("expected a todo without a status to be pending, got %s", out)
+	}
+	out = runTodoWrite(t, []db.Todo{{ID: 2, Content: "Test the flag", Status: db.TodoInProgress}, {ID: 1, Content: "Add the flag", Status: db.TodoInProgress}})
+	if !strings.Contains(out, "[~] 2. Test the flag\n[~] 1. Add the flag") || !strings.Contains(out, "one todo at a time") {
+		t.Errorf("unexpected output %s", out)
+	}
+	out = runTodoWrite(t, []db.Todo{{ID: 2, Content: "Test the flag", Status: db.TodoCompleted}, {ID: 1, Content: "Add the flag", Status: db.TodoCancelled}})
+	if !strings.Contains(out, `done="2" total="2"`) || !strings.HasSuffix(out, "Every todo is done.") {
+		t.Errorf("unexpected output %s", out)
+	}
+
+	if out := runTodoWrite(t, []db.Todo{{Content: ""}}); !strings.HasPrefix(out, "The todo list was not changed") {
+		t.Errorf("expected the empty todo to be refused, got %s", out)
+	}
+	if len(list.All()) != 2 {
+		t.Errorf("the refused list changed the todos")
+	}
+	if out := runTodoWrite(t, nil); out != "The todo list is now empty." || len(list.All()) != 0 {
+		t.Errorf("unexpected output %s", out)
+	}
+}
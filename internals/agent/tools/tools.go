@@ -1,12 +1,26 @@
 package tools
 
 const (
-	ToolListFiles      = "ls"
-	ToolReadFiles      = "read_files"
-	ToolAppendFile     = "append_file"
-	ToolPatchFile      = "patch_file"
-	ToolGrep           = "grep"
-	ToolBash           = "bash"
-	ToolAddTodo        = "add_todo"
-	ToolMarkTodoAsDone = "mark_todo_as_done"
+	ToolListFiles    = "ls"
+	ToolReadFiles    = "read_files"
+	ToolAppendFile   = "append_file"
+	ToolPatchFile    = "patch_file"
+	ToolEditFile     = "edit_file"
+	ToolApplyPatch   = "apply_patch"
+	ToolWriteFile    = "write_file"
+	ToolDeletePath   = "delete_path"
+	ToolMovePath     = "move_path"
+	ToolCopyPath     = "copy_path"
+	ToolSearch       = "search"
+	ToolCodeOutline  = "code_outline"
+	ToolFindSymbol   = "find_symbol"
+	ToolDiagnostics  = "diagnostics"
+	ToolBash         = "bash"
+	ToolRunTests     = "run_tests"
+	ToolGit          = "git"
+	ToolWebFetch     = "web_fetch"
+	ToolWebSearch    = "web_search"
+	ToolTodoWrite    = "todo_write"
+	ToolReadOutput   = "read_output"
+	ToolMCPResources = "mcp_resources"
 )
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+	"github.com/sifatulrabbi/cli-agent/internals/webfetch"
+	"github.com/sifatulrabbi/cli-agent/internals/websearch"
+)
+
+const maxWebFetchTokens = 8000
+
+var webFetcher = webfetch.New(webfetch.Options{})
+
+// UseWeb limits the pages web_fetch can fetch to the allowed domains, starts
+// a new cache of the fetched pages and selects the backend of web_search.
+func UseWeb(s configs.WebSettings) {
+	webFetcher = webfetch.New(webfetch.Options{Allow: s.AllowedDomains, Deny: s.BlockedDomains, MaxBytes: s.MaxBytes})
+	backend, err := websearch.FromSettings(s.Search)
+	UseSearchBackend(backend)
+	if err != nil {
+		log.Println("ERROR: Invalid web search settings, the search is off.", err)
+		searchUnavailable = fmt.Sprintf("Web search is not available, its settings are invalid: %v", err)
+	}
+}
+
+type WebFetchToolArgs struct {
+	URL    string `json:"url"`
+	Offset int    `json:"offset"` // the line to start from, 1-based
+}
+
+func handleWebFetch(argsJSON string) (string, error) {
+	var args WebFetchToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(args.URL) == "" {
+		return "Pass the URL to fetch.", nil
+	}
+	page, err := webFetcher.Fetch(toolCtx, args.URL)
+	if err != nil {
+		return fmt.Sprintf("Unable to fetch %s: %v", args.URL, err), nil
+	}
+	return formatWebPage(page, args.Offset), nil
+}
+
+// formatWebPage shows the lines of the page from offset that fit in the
+// token budget, the rest can be read with the following calls since the page
+// is cached.
+func formatWebPage(page *webfetch.Page, offset int) string {
+	lines := strings.Split(page.Content, "\n")
+	start := max(offset, 1)
+	if start > len(lines) {
+		return fmt.Sprintf("%s has only %d lines, the offset %d is past its end.", page.URL, len(lines), offset)
+	}
+
+	var body strings.Builder
+	used, last := 0, start-1
+	for i := start - 1; i < len(lines); i++ {
+		tokens := utils.CountTokens(lines[i]) + 1
+		// Always show at least one line so the reads make progress.
+		if used+tokens > maxWebFetchTokens && i > start-1 {
+			break
+		}
+		used += tokens
+		body.WriteString(lines[i] + "\n")
+		last = i + 1
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<web_page url=%q", page.URL)
+	if page.Title != "" {
+		fmt.Fprintf(&sb, " title=%q", page.Title)
+	}
+	fmt.Fprintf(&sb, " lines=\"%d-%d\" total_lines=\"%d\">\n", start, last, len(lines))
+	if len(page.Redirects) > 0 {
+		fmt.Fprintf(&sb, "Redirected from %s\n\n", strings.Join(page.Redirects, " -> "))
+	}
+	if page.Status >= 400 {
+		fmt.Fprintf(&sb, "The server answered %d %s.\n\n", page.Status, page.StatusText)
+	}
+	sb.WriteString(body.String())
+	sb.WriteString("</web_page>")
+	if last < len(lines) {
+		fmt.Fprintf(&sb, "\nThe page continues, the token budget of this call ran out. Call web_fetch with offset %d to continue from line %d.", last+1, last+1)
+	}
+	if page.Truncated {
+		sb.WriteString("\nThe page was too large, only its beginning was downloaded.")
+	}
+	return sb.String()
+}
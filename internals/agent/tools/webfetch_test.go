@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func runWebFetch(t *testing.T, args WebFetchToolArgs) string {
+	t.Helper()
+	argsJSON, _ := json.Marshal(args)
+	out, err := handleWebFetch(string(argsJSON))
+	if err != nil {
+		t.Fatalf("web_fetch failed: %v", err)
+	}
+	return out
+}
+
+func TestWebFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Docs</title></head><body><nav>Menu</nav><h2>Usage</h2><p>Run <code>tool --help</code>.</p></body></html>`)
+	})
+	mux.HandleFunc("/long.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		for i := 1; i <= 3000; i++ {
+			fmt.Fprintf(w, "line %d of a long page\n", i)
+		}
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such page", http.StatusNotFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	UseWeb(configs.WebSettings{BlockedDomains: []string{"blocked.test"}})
+
+	out := runWebFetch(t, WebFetchToolArgs{URL: srv.URL + "/docs"})
+	want := fmt.Sprintf("<web_page url=%q title=\"Docs\" lines=\"1-3\" total_lines=\"3\">\n## Usage\n\nRun `tool --help`.\n</web_page>", srv.URL+"/docs")
+	if out != want {
+		t.Errorf("unexpected page:\n%s\nwant:\n%s", out, want)
+	}
+
+	out = runWebFetch(t, WebFetchToolArgs{URL: srv.URL + "/long.txt"})
+	if !strings.Contains(out, "line 1 of") || strings.Contains(out, "line 3000 of") || !strings.Contains(out, "Call web_fetch with offset") {
+		t.Errorf("expected the long page to be clipped, got %s", out[len(out)-200:])
+	}
+	out = runWebFetch(t, WebFetchToolArgs{URL: srv.URL + "/long.txt", Offset: 2990})
+	if !strings.Contains(out, `lines="2990-3001"`) || !strings.Contains(out, "line 3000 of") || strings.Contains(out, "continues") {
+		t.Errorf("unexpected continuation %s", out)
+	}
+
+	if out := runWebFetch(t, WebFetchToolArgs{URL: srv.URL + "/missing"}); !strings.Contains(out, "The server answered 404 Not Found.\n\nno such page") {
+		t.Errorf("expected the error status to be reported, got %s", out)
+	}
+	if out := runWebFetch(t, WebFetchToolArgs{URL: "https://www.blocked.test/"}); !strings.HasPrefix(out, "Unable to fetch") || !strings.Contains(out, "blocked") {
+		t.Errorf("expected the domain to be blocked, got %s", out)
+	}
+
+	argsJSON, _ := json.Marshal(WebFetchToolArgs{URL: "https://Pkg.Go.dev/net/http"})
+	if got := PermissionSubjects(ToolWebFetch, string(argsJSON)); !slices.Equal(got, []string{"pkg.go.dev"}) {
+		t.Errorf("unexpected web_fetch subjects %q", got)
+	}
+}
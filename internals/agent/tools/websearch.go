@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+	"github.com/sifatulrabbi/cli-agent/internals/websearch"
+)
+
+const (
+	defaultSearchResults = 8
+	maxSearchResults     = 20
+	maxSnippetLength     = 300
+)
+
+const searchNotConfigured = "Web search is not configured, set web.search in the settings."
+
+var (
+	searchBackend websearch.Backend
+	// searchUnavailable explains why there is no backend.
+	searchUnavailable = searchNotConfigured
+)
+
+// UseSearchBackend makes web_search query b, nil turns the search off.
+func UseSearchBackend(b websearch.Backend) {
+	searchBackend = b
+	searchUnavailable = searchNotConfigured
+}
+
+type WebSearchToolArgs struct {
+	Query      string `json:"query"`
+	MaxResults int    `json:"maxResults"`
+}
+
+func handleWebSearch(argsJSON string) (string, error) {
+	var args WebSearchToolArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(args.Query) == "" {
+		return "Pass the search query.", nil
+	}
+	if searchBackend == nil {
+		return searchUnavailable, nil
+	}
+	limit := defaultSearchResults
+	if args.MaxResults > 0 {
+		limit = min(args.MaxResults, maxSearchResults)
+	}
+	results, err := searchBackend.Search(toolCtx, args.Query, limit)
+	if err != nil {
+		return fmt.Sprintf("The search failed: %v", err), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<web_search query=%q>\n", args.Query)
+	n, blocked := 0, 0
+	for _, r := range results {
+		// The results web_fetch couldn't read aren't worth showing.
+		if u, err := url.Parse(r.URL); err != nil || webFetcher.Check(u) != nil {
+			blocked++
+			continue
+		}
+		n++
+		fmt.Fprintf(&sb, "%d. %s\n   %s\n", n, strings.Join(strings.Fields(r.Title), " "), r.URL)
+		if snippet := strings.Join(strings.Fields(r.Snippet), " "); snippet != "" {
+			if len(snippet) > maxSnippetLength {
+				snippet = strings.ToValidUTF8(snippet[:maxSnippetLength], "") + "..."
+			}
+			sb.WriteString("   " + snippet + "\n")
+		}
+	}
+	if n == 0 {
+		sb.WriteString("No results.\n")
+	}
+	sb.WriteString("</web_search>")
+	if blocked > 0 {
+		fmt.Fprintf(&sb, "\n%s from blocked domains left out.", utils.Plural(blocked, "result"))
+	}
+	if n > 0 {
+		sb.WriteString("\nCall web_fetch with the URLs of the relevant results to read them.")
+	}
+	return sb.String(), nil
+}
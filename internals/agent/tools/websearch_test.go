@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/websearch"
+)
+
+func runWebSearch(t *testing.T, args WebSearchToolArgs) string {
+	t.Helper()
+	argsJSON, _ := json.Marshal(args)
+	out, err := handleWebSearch(string(argsJSON))
+	if err != nil {
+		t.Fatalf("web_search failed: %v", err)
+	}
+	return out
+}
+
+func TestWebSearch(t *testing.T) {
+	t.Cleanup(func() { UseSearchBackend(nil) })
+	UseWeb(configs.WebSettings{BlockedDomains: []string{"spam.test"}})
+	if out := runWebSearch(t, WebSearchToolArgs{Query: "x"}); out != searchNotConfigured {
+		t.Errorf("expected the search to be off, got %q", out)
+	}
+	UseWeb(configs.WebSettings{Search: configs.WebSearchSettings{Backend: "brave"}})
+	if out := runWebSearch(t, WebSearchToolArgs{Query: "x"}); !strings.Contains(out, "needs an API key") {
+		t.Errorf("expected the invalid settings to be reported, got %q", out)
+	}
+
+	UseWeb(configs.WebSettings{BlockedDomains: []string{"spam.test"}})
+	var gotLimit int
+	UseSearchBackend(websearch.BackendFunc(func(ctx context.Context, query string, limit int) ([]websearch.Result, error) {
+		gotLimit = limit
+		if query == "fail" {
+			return nil, errors.New("quota exceeded")
+		}
+		return []websearch.Result{
+			{Title: "Go  generics\ntutorial", URL: "https://go.dev/doc/tutorial/generics", Snippet: "This tutorial introduces the basics of generics in Go."},
+			{Title: "Cheap pills", URL: "https://www.spam.test/", Snippet: "Buy now"},
+			{Title: "Spec", URL: "https://go.dev/ref/spec", Snippet: strings.Repeat("word ", 100)},
+		}, nil
+	}))
+
+	out := runWebSearch(t, WebSearchToolArgs{Query: "go generics", MaxResults: 50})
+	want := "<web_search query=\"go generics\">\n" +
+		"1. Go generics tutorial\n   https://go.dev/doc/tutorial/generics\n   This tutorial introduces the basics of generics in Go.\n" +
+		"2. Spec\n   https://go.dev/ref/spec\n   " + strings.Repeat("word ", 60) + "...\n" +
+		"</web_search>\n1 result from blocked domains left out.\nCall web_fetch with the URLs of the relevant results to read them."
+	if out != want {
+		t.Errorf("unexpected results:\n%s\nwant:\n%s", out, want)
+	}
+	if gotLimit != maxSearchResults {
+		t.Errorf("expected the limit to be capped at %d, got %d", maxSearchResults, gotLimit)
+	}
+	if out := runWebSearch(t, WebSearchToolArgs{Query: "fail"}); out != "The search failed: quota exceeded" {
+		t.Errorf("unexpected failure %q", out)
+	}
+}
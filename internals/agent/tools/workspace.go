@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+// resolvePath is the single place where paths coming from the model are
+// turned into absolute paths. Relative paths (and the "/<project-name>/..."
+// form models like to use) are resolved against the WorkingPath, the other
+// absolute paths must point into an allowed root. The result
+// is canonicalized with its symlinks resolved and must stay inside the
+// WorkingPath or one of the extra roots (see configs.ExtraRoots).
+func resolvePath(entryPath string) (string, error) {
+	p, err := absolutePath(entryPath)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := evalSymlinksPartial(p)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve the path %q: %w", entryPath, err)
+	}
+	if roots := allowedRoots(); !withinAnyRoot(resolved, roots) {
+		return "", fmt.Errorf("the path %q resolves to %q which is outside of the workspace; only paths inside %s are allowed",
+			entryPath, resolved, strings.Join(roots, ", "))
+	}
+	return resolved, nil
+}
+
+// resolveEntry is resolvePath for the tools that change the directory entry
+// itself: only the parent directories are resolved, so deleting or moving a
+// symlink changes the link and not the file it points to.
+func resolveEntry(entryPath string) (string, error) {
+	p, err := absolutePath(entryPath)
+	if err != nil {
+		return "", err
+	}
+	// The roots are kept whole even when they are symlinks themselves.
+	if resolved, err := evalSymlinksPartial(p); err == nil && isAllowedRoot(resolved) {
+		return resolved, nil
+	}
+	parent, err := resolvePath(filepath.Dir(p))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(parent, filepath.Base(p)), nil
+}
+
+// absolutePath turns a path of the model into an absolute one without
+// resolving its symlinks.
+func absolutePath(entryPath string) (string, error) {
+	if strings.TrimSpace(entryPath) == "" {
+		return "", fmt.Errorf("no path provided")
+	}
+	root := filepath.Clean(configs.WorkingPath)
+	roots := allowedRoots()
+
+	p := filepath.Clean(filepath.FromSlash(entryPath))
+	rootName := filepath.Base(root)
+	rel := filepath.ToSlash(p)
+	if filepath.IsAbs(p) && !withinAnyRoot(p, append([]string{root}, configs.ExtraRoots...)) {
+		// Any other absolute path is outside of the workspace, it is not
+		// taken for a relative one.
+		if rel != "/"+rootName && !strings.HasPrefix(rel, "/"+rootName+"/") {
+			return "", fmt.Errorf("the path %q is outside of the workspace; only paths inside %s are allowed, use paths relative to %s",
+				entryPath, strings.Join(roots, ", "), root)
+		}
+		p = filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(rel, "/"+rootName)))
+	} else if !filepath.IsAbs(p) {
+		rel, _ = strings.CutPrefix(rel, rootName+"/")
+		p = filepath.Join(root, filepath.FromSlash(rel))
+	}
+	return p, nil
+}
+
+// allowedRoots returns the canonical WorkingPath followed by the canonical
+// extra roots.
+func allowedRoots() []string {
+	roots := []string{}
+	for _, r := range append([]string{configs.WorkingPath}, configs.ExtraRoots...) {
+		if r == "" {
+			continue
+		}
+		if resolved, err := evalSymlinksPartial(filepath.Clean(r)); err == nil {
+			r = resolved
+		}
+		roots = append(roots, r)
+	}
+	return roots
+}
+
+func withinAnyRoot(p string, roots []string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, p)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// evalSymlinksPartial resolves the symlinks of the longest existing prefix of
+// p, so paths of files that are yet to be created can be checked as well.
+func evalSymlinksPartial(p string) (string, error) {
+	missing := []string{}
+	cur := p
+	for {
+		resolved, err := filepath.EvalSymlinks(cur)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		// A dangling symlink still decides where a new file would be written.
+		if target, lErr := os.Readlink(cur); lErr == nil {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(cur), target)
+			}
+			for i := len(missing) - 1; i >= 0; i-- {
+				target = filepath.Join(target, missing[i])
+			}
+			return evalSymlinksPartial(target)
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return p, nil
+		}
+		missing = append(missing, filepath.Base(cur))
+		cur = parent
+	}
+}
@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func TestResolvePath(t *testing.T) {
+	tmp := t.TempDir()
+	configs.WorkingPath = filepath.Join(tmp, "project")
+	shared := filepath.Join(tmp, "shared-lib")
+	for _, dir := range []string{configs.WorkingPath, shared, filepath.Join(tmp, "secrets")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(tmp, "secrets"), filepath.Join(configs.WorkingPath, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(tmp, "secrets", "new.txt"), filepath.Join(configs.WorkingPath, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	configs.ExtraRoots = []string{}
+	t.Cleanup(func() { configs.ExtraRoots = []string{} })
+
+	allowed := map[string]string{
+		"main.go":                  filepath.Join(configs.WorkingPath, "main.go"),
+		"./pkg/../main.go":         filepath.Join(configs.WorkingPath, "main.go"),
+		"/project/cmd/root.go":     filepath.Join(configs.WorkingPath, "cmd", "root.go"),
+		configs.WorkingPath + "/x": filepath.Join(configs.WorkingPath, "x"),
+	}
+	for in, want := range allowed {
+		got, err := resolvePath(in)
+		if err != nil {
+			t.Fatalf("resolvePath(%q) failed: %v", in, err)
+		}
+		if want, _ = evalSymlinksPartial(want); got != want {
+			t.Fatalf("resolvePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"../../etc/passwd", "../shared-lib/lib.go", "escape/key.pem", "dangling", "/etc/passwd", "/tmp/foo", filepath.Join(shared, "lib.go")} {
+		if _, err := resolvePath(in); err == nil || !strings.Contains(err.Error(), "outside of the workspace") {
+			t.Fatalf("expected %q to be rejected, got %v", in, err)
+		}
+	}
+
+	if err := configs.AddExtraRoots(shared); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolvePath("../shared-lib/lib.go"); err != nil {
+		t.Fatalf("expected the extra root to be allowed, got %v", err)
+	}
+	if _, err := resolvePath(filepath.Join(shared, "lib.go")); err != nil {
+		t.Fatalf("expected absolute paths inside the extra root to be allowed, got %v", err)
+	}
+}
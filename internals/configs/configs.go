@@ -5,7 +5,6 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -17,8 +16,13 @@ var (
 	OpenRouterAPIKey  string = ""
 	OpenRouterBaseURL string = "https://openrouter.ai/api/v1"
 	LogFilePath       string = ""
-	TodosFile         string = "/tmp/cli-agent/todos"
+	SessionsPath      string = ""
+	ConfigPath        string = ""
 	DevMode           bool   = true
+
+	// ExtraRoots are directories outside of the WorkingPath the file tools
+	// are allowed to access (e.g. `--add-dir ../shared-lib`).
+	ExtraRoots []string = []string{}
 )
 
 func Prepare() {
@@ -48,21 +52,46 @@ func Prepare() {
 	OpenRouterAPIKey = os.Getenv("OPENROUTER_API_KEY")
 	LogFilePath = "/tmp/cli-agent/debug.log"
 
-	// Ensure the todo list file exists
-	if _, err = os.ReadDir(TodosFile); os.IsNotExist(err) {
-		if err = os.MkdirAll(TodosFile, 0o755); err != nil {
-			log.Fatalln("ERROR: Unable to prepare the directory '/tmp/cli-agent/todos' for dev setup.")
+	// Sessions are kept in the user's home so they survive reboots and can be resumed or forked later.
+	homeDir, err := os.UserHomeDir()
+	if err != nil || homeDir == "" {
+		homeDir = "/tmp"
+	}
+	ConfigPath = filepath.Join(homeDir, ".cli-agent")
+	SessionsPath = filepath.Join(ConfigPath, "sessions")
+	if _, err = os.ReadDir(SessionsPath); os.IsNotExist(err) {
+		if err = os.MkdirAll(SessionsPath, 0o755); err != nil {
+			log.Fatalln("ERROR: Unable to prepare the sessions directory:", err)
 		}
 	}
-	TodosFile = filepath.Join(TodosFile, strings.ReplaceAll(strings.ReplaceAll(WorkingPath, "/", "-"), ".", "-")+".json")
-	err = os.WriteFile(TodosFile, []byte("[]"), 0o644)
-	if err != nil {
-		log.Fatalln("Unable to create the todo's file:", err)
+
+	if AppSettings, err = LoadSettings(); err != nil {
+		log.Fatalln("ERROR: Unable to load the settings:", err)
 	}
 
 	if DevMode {
-		fmt.Printf("Starting CLI-Agent from '%s' | logs file '%s' | todo file '%s'",
-			WorkingPath, LogFilePath, TodosFile)
+		// Not on stdout, which `mcp serve` speaks the protocol on.
+		fmt.Fprintf(os.Stderr, "Starting CLI-Agent from '%s' | logs file '%s'\n", WorkingPath, LogFilePath)
 		time.Sleep(1 * time.Second)
 	}
 }
+
+// AddExtraRoots allows the file tools to access the given directories as well
+// as the WorkingPath. Relative paths are resolved against the current directory.
+func AddExtraRoots(dirs ...string) error {
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return fmt.Errorf("unable to add the directory %q: %w", dir, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%q is not a directory", dir)
+		}
+		ExtraRoots = append(ExtraRoots, abs)
+	}
+	return nil
+}
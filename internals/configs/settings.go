@@ -0,0 +1,311 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const settingsFileName = "settings.json"
+
+// Settings are read from the user's ~/.cli-agent/settings.json and then from
+// the project's .cli-agent/settings.json, which adds to or overrides them.
+// Unless the user trusts the project, its settings may only tighten the
+// user's ones (see restricted).
+type Settings struct {
+	// TrustedProjects are the directories whose project settings apply in
+	// full. It is only read from the user's settings.
+	TrustedProjects []string `json:"trustedProjects"`
+
+	Permissions PermissionSettings `json:"permissions"`
+	Sandbox     SandboxSettings    `json:"sandbox"`
+	LSP         LSPSettings        `json:"lsp"`
+	Format      FormatSettings     `json:"format"`
+	Web         WebSettings        `json:"web"`
+	MCP         MCPSettings        `json:"mcp"`
+	// Plugins are the tools run by external commands, by tool name.
+	Plugins map[string]PluginSettings `json:"plugins"`
+}
+
+// PermissionSettings are the rules tool calls are checked against, written
+// as "tool" or "tool(pattern)", e.g. "bash(go test *)" or "edit_file(**/*.sql)".
+type PermissionSettings struct {
+	Allow []string `json:"allow"`
+	Ask   []string `json:"ask"`
+	Deny  []string `json:"deny"`
+}
+
+// SandboxSettings select how the commands of the bash tool are sandboxed.
+type SandboxSettings struct {
+	Mode          string   `json:"mode"` // "workspace" or "off"
+	Network       *bool    `json:"network"`
+	WritablePaths []string `json:"writablePaths"` // besides the workspace, the temp and the cache dirs
+}
+
+// LSPSettings configure the language servers whose diagnostics are reported
+// after the agent edits a file. Servers replace the built-in ones of the same
+// name (gopls, pyright, typescript). They run outside of the sandbox.
+type LSPSettings struct {
+	Disabled bool                         `json:"disabled"`
+	Servers  map[string]LSPServerSettings `json:"servers"`
+}
+
+type LSPServerSettings struct {
+	Command    []string `json:"command"`
+	Extensions []string `json:"extensions"` // e.g. [".go"]
+	Disabled   bool     `json:"disabled"`
+}
+
+// FormatSettings map globs to the formatter commands run on the files the
+// agent edits, e.g. {"*.ts": ["prettier", "--stdin-filepath", "{file}"]}. The
+// command reads the file on stdin and writes the formatted file to stdout,
+// {file} is replaced with its path. It runs in the sandbox of the bash tool.
+// An empty command turns a built-in formatter off.
+type FormatSettings struct {
+	Disabled   bool                `json:"disabled"`
+	Formatters map[string][]string `json:"formatters"`
+}
+
+// WebSettings limit the pages the web tools fetch. A domain matches its
+// subdomains too, the blocked domains win over the allowed ones and an empty
+// allow list allows every domain.
+type WebSettings struct {
+	AllowedDomains []string          `json:"allowedDomains"`
+	BlockedDomains []string          `json:"blockedDomains"`
+	MaxBytes       int64             `json:"maxBytes"` // of a response, 5 MB by default
+	Search         WebSearchSettings `json:"search"`
+}
+
+// WebSearchSettings select the backend of the web_search tool: "searxng"
+// with the URL of the instance, or "brave" and "tavily" with an API key.
+// APIKeyEnv names an environment variable holding the key, so it needn't be
+// written in the settings file.
+type WebSearchSettings struct {
+	Backend   string `json:"backend"`
+	URL       string `json:"url"`
+	APIKey    string `json:"apiKey"`
+	APIKeyEnv string `json:"apiKeyEnv"`
+}
+
+// MCPSettings configure the Model Context Protocol servers, by name, whose
+// tools the agent can use. A server runs a command, outside of the sandbox,
+// and talks over its stdin and stdout, or is reached at a streamable HTTP URL.
+type MCPSettings struct {
+	Servers map[string]MCPServerSettings `json:"servers"`
+}
+
+type MCPServerSettings struct {
+	Command  []string          `json:"command"`
+	Env      map[string]string `json:"env"`
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers"` // e.g. {"Authorization": "Bearer ..."}
+	Timeout  int               `json:"timeout"` // of a tool call in seconds, 60 by default
+	Disabled bool              `json:"disabled"`
+}
+
+// PluginSettings declare a tool run by an external command. The command
+// reads the arguments of a call as JSON on stdin and writes the result to
+// stdout, as text or as {"output": "...", "error": "..."}. It runs in the
+// workspace and in the sandbox of the bash tool.
+type PluginSettings struct {
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"` // the JSON schema of the arguments
+	Command     []string       `json:"command"`
+	Timeout     int            `json:"timeout"`  // of a call in seconds, 60 by default
+	ReadOnly    bool           `json:"readOnly"` // allowed without asking, like read_files
+	Disabled    bool           `json:"disabled"`
+}
+
+var AppSettings Settings
+
+// IgnoredProjectSettings are the settings of the untrusted project in the
+// WorkingPath that LoadSettings left out, e.g. "permissions.allow".
+var IgnoredProjectSettings []string
+
+// UserSettingsPath is the settings file shared by every project.
+func UserSettingsPath() string {
+	return filepath.Join(ConfigPath, settingsFileName)
+}
+
+// ProjectSettingsPath is the settings file of the project in the WorkingPath.
+func ProjectSettingsPath() string {
+	return filepath.Join(WorkingPath, ".cli-agent", settingsFileName)
+}
+
+// LoadSettings reads the user and the project settings files. Missing files
+// are fine, malformed ones are reported. The project settings that would
+// loosen the user's ones are left out unless the user trusts the project.
+func LoadSettings() (Settings, error) {
+	IgnoredProjectSettings = nil
+	s, err := readSettings(UserSettingsPath())
+	if err != nil {
+		return s, err
+	}
+	project, err := readSettings(ProjectSettingsPath())
+	if err != nil {
+		return s, err
+	}
+	if !s.trusts(WorkingPath) {
+		project, IgnoredProjectSettings = project.restricted()
+	}
+	project.TrustedProjects = nil
+	s.merge(project)
+	return s, nil
+}
+
+func readSettings(p string) (Settings, error) {
+	var s Settings
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("invalid settings file %s: %w", p, err)
+	}
+	return s, nil
+}
+
+// trusts reports whether dir is one of the TrustedProjects.
+func (s Settings) trusts(dir string) bool {
+	for _, p := range s.TrustedProjects {
+		if strings.HasPrefix(p, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				p = filepath.Join(home, p[2:])
+			}
+		}
+		if filepath.Clean(p) == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// restricted returns the settings of an untrusted project without what would
+// loosen the user's ones or start a process, along with the names of what was
+// left out. Kept are the ask and deny rules, a stricter sandbox, the blocked
+// domains, the size limit of the web tools and turning off the language
+// servers, formatters, MCP servers and plugins.
+func (s Settings) restricted() (Settings, []string) {
+	r := Settings{}
+	ignored := []string{}
+	ignore := func(name string, set bool) {
+		if set {
+			ignored = append(ignored, name)
+		}
+	}
+
+	r.Permissions.Ask, r.Permissions.Deny = s.Permissions.Ask, s.Permissions.Deny
+	ignore("permissions.allow", len(s.Permissions.Allow) > 0)
+	if s.Sandbox.Mode == "workspace" {
+		r.Sandbox.Mode = s.Sandbox.Mode
+	}
+	ignore("sandbox.mode", r.Sandbox.Mode != s.Sandbox.Mode)
+	if s.Sandbox.Network != nil && !*s.Sandbox.Network {
+		r.Sandbox.Network = s.Sandbox.Network
+	}
+	ignore("sandbox.network", r.Sandbox.Network != s.Sandbox.Network)
+	ignore("sandbox.writablePaths", len(s.Sandbox.WritablePaths) > 0)
+
+	r.LSP.Disabled = s.LSP.Disabled
+	for _, name := range slices.Sorted(maps.Keys(s.LSP.Servers)) {
+		if s.LSP.Servers[name].Disabled {
+			r.LSP.Servers = setKey(r.LSP.Servers, name, LSPServerSettings{Disabled: true})
+		} else {
+			ignore("lsp.servers."+name, true)
+		}
+	}
+	r.Format.Disabled = s.Format.Disabled
+	for _, glob := range slices.Sorted(maps.Keys(s.Format.Formatters)) {
+		if len(s.Format.Formatters[glob]) == 0 {
+			r.Format.Formatters = setKey(r.Format.Formatters, glob, []string{})
+		} else {
+			ignore("format.formatters."+glob, true)
+		}
+	}
+
+	r.Web.BlockedDomains, r.Web.MaxBytes = s.Web.BlockedDomains, s.Web.MaxBytes
+	ignore("web.allowedDomains", len(s.Web.AllowedDomains) > 0)
+	// The search backend is sent the queries, and maybe a key read from the
+	// environment.
+	ignore("web.search", s.Web.Search != WebSearchSettings{})
+
+	for _, name := range slices.Sorted(maps.Keys(s.MCP.Servers)) {
+		if s.MCP.Servers[name].Disabled {
+			r.MCP.Servers = setKey(r.MCP.Servers, name, MCPServerSettings{Disabled: true})
+		} else {
+			ignore("mcp.servers."+name, true)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.Plugins)) {
+		if s.Plugins[name].Disabled {
+			r.Plugins = setKey(r.Plugins, name, PluginSettings{Disabled: true})
+		} else {
+			ignore("plugins."+name, true)
+		}
+	}
+	ignore("trustedProjects", len(s.TrustedProjects) > 0)
+	return r, ignored
+}
+
+func setKey[V any](m map[string]V, key string, v V) map[string]V {
+	if m == nil {
+		m = map[string]V{}
+	}
+	m[key] = v
+	return m
+}
+
+// merge adds the lists of o to the ones of s and lets the values set in o
+// override the ones of s.
+func (s *Settings) merge(o Settings) {
+	s.Permissions.Allow = append(s.Permissions.Allow, o.Permissions.Allow...)
+	s.Permissions.Ask = append(s.Permissions.Ask, o.Permissions.Ask...)
+	s.Permissions.Deny = append(s.Permissions.Deny, o.Permissions.Deny...)
+	if o.Sandbox.Mode != "" {
+		s.Sandbox.Mode = o.Sandbox.Mode
+	}
+	if o.Sandbox.Network != nil {
+		s.Sandbox.Network = o.Sandbox.Network
+	}
+	s.Sandbox.WritablePaths = append(s.Sandbox.WritablePaths, o.Sandbox.WritablePaths...)
+	s.LSP.Disabled = s.LSP.Disabled || o.LSP.Disabled
+	for name, server := range o.LSP.Servers {
+		if s.LSP.Servers == nil {
+			s.LSP.Servers = map[string]LSPServerSettings{}
+		}
+		s.LSP.Servers[name] = server
+	}
+	s.Format.Disabled = s.Format.Disabled || o.Format.Disabled
+	for glob, command := range o.Format.Formatters {
+		if s.Format.Formatters == nil {
+			s.Format.Formatters = map[string][]string{}
+		}
+		s.Format.Formatters[glob] = command
+	}
+	s.Web.AllowedDomains = append(s.Web.AllowedDomains, o.Web.AllowedDomains...)
+	s.Web.BlockedDomains = append(s.Web.BlockedDomains, o.Web.BlockedDomains...)
+	if o.Web.MaxBytes > 0 {
+		s.Web.MaxBytes = o.Web.MaxBytes
+	}
+	if o.Web.Search.Backend != "" {
+		s.Web.Search = o.Web.Search
+	}
+	for name, server := range o.MCP.Servers {
+		if s.MCP.Servers == nil {
+			s.MCP.Servers = map[string]MCPServerSettings{}
+		}
+		s.MCP.Servers[name] = server
+	}
+	for name, plugin := range o.Plugins {
+		if s.Plugins == nil {
+			s.Plugins = map[string]PluginSettings{}
+		}
+		s.Plugins[name] = plugin
+	}
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+// ArtifactStore keeps the tool outputs too large for the conversation, the
+// model reads them back in pages. They are saved with the session so the
+// artifact ids in its history stay valid after a restart.
+type ArtifactStore struct {
+	SessionID string
+
+	mu     sync.Mutex
+	nextID int
+}
+
+var artifactIDPattern = regexp.MustCompile(`^out-[0-9]+$`)
+
+func NewArtifactStore(sessionID string) *ArtifactStore {
+	return &ArtifactStore{SessionID: sessionID}
+}
+
+// Save stores the output and returns its id, e.g. "out-3".
+func (as *ArtifactStore) Save(content string) (string, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	dir := artifactsDirPath(as.SessionID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if as.nextID == 0 {
+		// Continue after the artifacts saved before a restart.
+		as.nextID = 1
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(e.Name(), "out-"), ".txt")); err == nil {
+				as.nextID = max(as.nextID, n+1)
+			}
+		}
+	}
+	id := fmt.Sprintf("out-%d", as.nextID)
+	if err := os.WriteFile(filepath.Join(dir, id+".txt"), []byte(content), 0o644); err != nil {
+		return "", err
+	}
+	as.nextID++
+	return id, nil
+}
+
+// Read returns the output stored under the id.
+func (as *ArtifactStore) Read(id string) (string, error) {
+	if !artifactIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid output id %q, the ids look like out-1", id)
+	}
+	data, err := os.ReadFile(filepath.Join(artifactsDirPath(as.SessionID), id+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("there is no output %s in this session", id)
+	}
+	return string(data), err
+}
+
+// CopyTo copies the stored outputs to another session, e.g. a fork that
+// keeps the messages referring to them.
+func (as *ArtifactStore) CopyTo(sessionID string) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	src := artifactsDirPath(as.SessionID)
+	entries, err := os.ReadDir(src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	dst := artifactsDirPath(sessionID)
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(src, e.Name()))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dst, e.Name()), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func artifactsDirPath(sessionID string) string {
+	return filepath.Join(configs.SessionsPath, sessionID, "outputs")
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func TestArtifactStore(t *testing.T) {
+	configs.SessionsPath = t.TempDir()
+	store := NewArtifactStore("session")
+	if id, err := store.Save("first"); err != nil || id != "out-1" {
+		t.Fatalf("unexpected id %q, %v", id, err)
+	}
+
+	// The ids continue after a restart.
+	store = NewArtifactStore("session")
+	id, err := store.Save("second")
+	if err != nil || id != "out-2" {
+		t.Fatalf("unexpected id %q, %v", id, err)
+	}
+	if content, err := store.Read(id); err != nil || content != "second" {
+		t.Fatalf("unexpected content %q, %v", content, err)
+	}
+	if _, err := store.Read("out-9"); err == nil || !strings.Contains(err.Error(), "there is no output out-9") {
+		t.Errorf("expected a missing output, got %v", err)
+	}
+	if _, err := store.Read("../../etc/passwd"); err == nil || !strings.Contains(err.Error(), "invalid output id") {
+		t.Errorf("expected the id to be refused, got %v", err)
+	}
+
+	if err := store.CopyTo("fork"); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := NewArtifactStore("fork").Read("out-1"); err != nil || content != "first" {
+		t.Fatalf("expected the fork to have the outputs, got %q, %v", content, err)
+	}
+	if err := NewArtifactStore("empty").CopyTo("other"); err != nil {
+		t.Errorf("copying no outputs should work, got %v", err)
+	}
+}
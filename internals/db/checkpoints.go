@@ -0,0 +1,275 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+// FileSnapshot is the state of a file right before a tool modified it.
+type FileSnapshot struct {
+	Path    string      `json:"path"` // absolute path
+	Existed bool        `json:"existed"`
+	Content []byte      `json:"content"`
+	Mode    fs.FileMode `json:"mode"`
+	Link    string      `json:"link,omitempty"` // the target when the file was a symlink
+}
+
+type Checkpoint struct {
+	Turn      int            `json:"turn"` // index of the user message that started the turn
+	Tool      string         `json:"tool"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Files     []FileSnapshot `json:"files"`
+}
+
+// FileChange is the cumulative change of a single file over a session.
+type FileChange struct {
+	Path     string
+	Original FileSnapshot
+	Current  FileSnapshot
+}
+
+// CheckpointStore keeps the snapshots of every file the agent modified in a
+// session so the edits can be reverted regardless of the project using git.
+type CheckpointStore struct {
+	SessionID   string       `json:"sessionId"`
+	Checkpoints []Checkpoint `json:"checkpoints"`
+
+	mu sync.Mutex
+}
+
+// GetCheckpointStore loads the checkpoints of the session, returning an empty
+// store when the session has not modified any files yet.
+func GetCheckpointStore(sessionID string) (*CheckpointStore, error) {
+	store := &CheckpointStore{SessionID: sessionID, Checkpoints: []Checkpoint{}}
+	data, err := os.ReadFile(checkpointsFilePath(sessionID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, store); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Snapshot records the current state of the given files as a new checkpoint
+// of the turn. It must be called before the files are modified.
+func (cs *CheckpointStore) Snapshot(turn int, tool string, paths ...string) error {
+	cp := Checkpoint{Turn: turn, Tool: tool, CreatedAt: time.Now()}
+	for _, p := range paths {
+		snap, err := snapshotFile(p)
+		if err != nil {
+			return err
+		}
+		cp.Files = append(cp.Files, snap)
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.Checkpoints = append(cs.Checkpoints, cp)
+	return cs.save()
+}
+
+// HasChangesSince reports whether any file was modified on or after the turn.
+func (cs *CheckpointStore) HasChangesSince(turn int) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for _, cp := range cs.Checkpoints {
+		if cp.Turn >= turn {
+			return true
+		}
+	}
+	return false
+}
+
+// UndoLastTurn reverts the edits of the most recent turn that modified files.
+func (cs *CheckpointStore) UndoLastTurn() ([]string, error) {
+	cs.mu.Lock()
+	lastTurn := -1
+	for _, cp := range cs.Checkpoints {
+		lastTurn = max(lastTurn, cp.Turn)
+	}
+	cs.mu.Unlock()
+	if lastTurn < 0 {
+		return nil, nil
+	}
+	return cs.UndoSince(lastTurn)
+}
+
+// UndoAll reverts every edit made during the session.
+func (cs *CheckpointStore) UndoAll() ([]string, error) {
+	return cs.UndoSince(0)
+}
+
+// UndoSince restores the files modified on or after the given turn to their
+// state at the beginning of that turn and drops the reverted checkpoints. It
+// returns the restored paths.
+func (cs *CheckpointStore) UndoSince(turn int) ([]string, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	restored := map[string]struct{}{}
+	kept := []Checkpoint{}
+	var errs []error
+	// Walking backwards leaves every file in the state of its oldest snapshot.
+	for i := len(cs.Checkpoints) - 1; i >= 0; i-- {
+		cp := cs.Checkpoints[i]
+		if cp.Turn < turn {
+			kept = append([]Checkpoint{cp}, kept...)
+			continue
+		}
+		for j := len(cp.Files) - 1; j >= 0; j-- {
+			if err := restoreFile(cp.Files[j]); err != nil {
+				errs = append(errs, err)
+			}
+			restored[cp.Files[j].Path] = struct{}{}
+		}
+	}
+	cs.Checkpoints = kept
+	if err := cs.save(); err != nil {
+		errs = append(errs, err)
+	}
+
+	paths := make([]string, 0, len(restored))
+	for p := range restored {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, errors.Join(errs...)
+}
+
+// DropSince forgets the checkpoints of the turns on or after the given one
+// without touching the files, e.g. once they are copied to the session forked
+// off a rewind, so undoing the next turns doesn't revert them.
+func (cs *CheckpointStore) DropSince(turn int) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	kept := []Checkpoint{}
+	for _, cp := range cs.Checkpoints {
+		if cp.Turn < turn {
+			kept = append(kept, cp)
+		}
+	}
+	cs.Checkpoints = kept
+	return cs.save()
+}
+
+// Changes returns the files changed during the session comparing their state
+// before the first modification with their current state on disk.
+func (cs *CheckpointStore) Changes() ([]FileChange, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	originals := map[string]FileSnapshot{}
+	for _, cp := range cs.Checkpoints {
+		for _, snap := range cp.Files {
+			if _, ok := originals[snap.Path]; !ok {
+				originals[snap.Path] = snap
+			}
+		}
+	}
+
+	changes := []FileChange{}
+	for p, original := range originals {
+		current, err := snapshotFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if original.Existed == current.Existed && original.Link == current.Link && string(original.Content) == string(current.Content) {
+			continue
+		}
+		changes = append(changes, FileChange{Path: p, Original: original, Current: current})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// CopyTo saves a copy of the checkpoints under another session.
+func (cs *CheckpointStore) CopyTo(sessionID string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cp := &CheckpointStore{SessionID: sessionID, Checkpoints: cs.Checkpoints}
+	return cp.save()
+}
+
+func (cs *CheckpointStore) save() error {
+	p := checkpointsFilePath(cs.SessionID)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cs)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0o644)
+}
+
+func snapshotFile(p string) (FileSnapshot, error) {
+	snap := FileSnapshot{Path: p}
+	// The symlinks are kept as links, not as the file they point to.
+	info, err := os.Lstat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return snap, nil
+	} else if err != nil {
+		return snap, err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if snap.Link, err = os.Readlink(p); err != nil {
+			return snap, err
+		}
+		snap.Existed = true
+		return snap, nil
+	}
+	if info.IsDir() {
+		return snap, errors.New("cannot snapshot a directory: " + p)
+	}
+	if snap.Content, err = os.ReadFile(p); err != nil {
+		return snap, err
+	}
+	snap.Existed = true
+	snap.Mode = info.Mode().Perm()
+	return snap, nil
+}
+
+func restoreFile(snap FileSnapshot) error {
+	if !snap.Existed {
+		if err := os.Remove(snap.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(snap.Path), 0o755); err != nil {
+		return err
+	}
+	if snap.Link != "" || isSymlink(snap.Path) {
+		// Writing would go through the link, so it is replaced.
+		if err := os.Remove(snap.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if snap.Link != "" {
+			return os.Symlink(snap.Link, snap.Path)
+		}
+	}
+	if err := os.WriteFile(snap.Path, snap.Content, snap.Mode); err != nil {
+		return err
+	}
+	return os.Chmod(snap.Path, snap.Mode)
+}
+
+func isSymlink(p string) bool {
+	info, err := os.Lstat(p)
+	return err == nil && info.Mode()&fs.ModeSymlink != 0
+}
+
+func checkpointsFilePath(sessionID string) string {
+	return filepath.Join(configs.SessionsPath, sessionID, "checkpoints.json")
+}
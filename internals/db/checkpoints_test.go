@@ -0,0 +1,124 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func TestCheckpointUndo(t *testing.T) {
+	configs.SessionsPath = t.TempDir()
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "main.go")
+	created := filepath.Join(dir, "new.go")
+	if err := os.WriteFile(existing, []byte("v0"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, _ := GetCheckpointStore("session")
+	edit := func(turn int, p, content string) {
+		if err := store.Snapshot(turn, "patch_file", p); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	edit(1, existing, "v1")
+	edit(3, existing, "v2")
+	edit(3, created, "new")
+	edit(3, existing, "v3")
+
+	changes, err := store.Changes()
+	if err != nil || len(changes) != 2 || string(changes[0].Original.Content) != "v0" || changes[1].Original.Existed {
+		t.Fatalf("unexpected changes: %+v, %v", changes, err)
+	}
+
+	// the store is persisted so undoing works after a restart
+	store, _ = GetCheckpointStore("session")
+	if restored, err := store.UndoLastTurn(); err != nil || len(restored) != 2 {
+		t.Fatalf("unexpected undo result: %v, %v", restored, err)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "v1" {
+		t.Fatalf("expected the last turn to be reverted, got %q", data)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Fatal("expected the created file to be removed")
+	}
+
+	if _, err := store.UndoAll(); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(existing)
+	if data, _ := os.ReadFile(existing); string(data) != "v0" || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected the original file back, got %q with mode %v", data, info.Mode())
+	}
+	if store.HasChangesSince(0) {
+		t.Fatal("expected no checkpoints left")
+	}
+}
+
+func TestCheckpointSymlinks(t *testing.T) {
+	configs.SessionsPath = t.TempDir()
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	link := filepath.Join(dir, "link.txt")
+	if err := os.WriteFile(target, []byte("target"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Fatal(err)
+	}
+
+	store, _ := GetCheckpointStore("session")
+	if err := store.Snapshot(1, "delete_path", link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(link, []byte("a regular file now"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if changes, _ := store.Changes(); len(changes) != 1 || changes[0].Original.Link != "target.txt" {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+	if _, err := store.UndoAll(); err != nil {
+		t.Fatal(err)
+	}
+	if dest, err := os.Readlink(link); err != nil || dest != "target.txt" {
+		t.Fatalf("expected the symlink back, got %q, %v", dest, err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "target" {
+		t.Errorf("the target changed to %q", data)
+	}
+}
+
+func TestCheckpointDropSince(t *testing.T) {
+	configs.SessionsPath = t.TempDir()
+	p := filepath.Join(t.TempDir(), "main.go")
+	store, _ := GetCheckpointStore("session")
+	for _, turn := range []int{1, 3} {
+		if err := store.Snapshot(turn, "write_file", p); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(fmt.Sprintf("v%d", turn)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.DropSince(3); err != nil {
+		t.Fatal(err)
+	}
+	if store.HasChangesSince(3) || !store.HasChangesSince(1) {
+		t.Fatal("expected only the checkpoints of turn 3 to be dropped")
+	}
+	if data, _ := os.ReadFile(p); string(data) != "v3" {
+		t.Errorf("dropping the checkpoints changed the file to %q", data)
+	}
+	if reloaded, _ := GetCheckpointStore("session"); len(reloaded.Checkpoints) != 1 {
+		t.Error("expected the dropped checkpoints to stay dropped after a restart")
+	}
+}
@@ -1,7 +1,18 @@
 package db
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
 )
 
 const (
@@ -48,6 +59,42 @@ type AgentHistory struct {
 	CreatedAt   time.Time        `json:"createdAt"`
 	UpdatedAt   time.Time        `json:"updatedAt"`
 	Messages    []HistoryMessage `json:"messages"`
+	Todos       *TodoList        `json:"todos"`
+
+	// Set when this session was forked off another one (e.g. by a rewind).
+	ParentSessionID string `json:"parentSessionId,omitempty"`
+	ForkedAt        int    `json:"forkedAt,omitempty"` // index of the message the parent was rewound to
+}
+
+func NewHistory(workingPath, modelName string) *AgentHistory {
+	now := time.Now()
+	return &AgentHistory{
+		SessionID:   uuid.NewString(),
+		WorkingPath: workingPath,
+		ModelName:   modelName,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Messages:    []HistoryMessage{},
+		Todos:       &TodoList{},
+	}
+}
+
+// Fork returns a copy of the history under a new session id that points back
+// to this session as its parent.
+func (ah AgentHistory) Fork(forkedAt int) *AgentHistory {
+	fork := NewHistory(ah.WorkingPath, ah.ModelName)
+	fork.CreatedAt = ah.CreatedAt
+	fork.ParentSessionID = ah.SessionID
+	fork.ForkedAt = forkedAt
+	fork.Messages = make([]HistoryMessage, len(ah.Messages))
+	for i, msg := range ah.Messages {
+		msg.ToolCalls = append([]ToolCall(nil), msg.ToolCalls...)
+		fork.Messages[i] = msg
+	}
+	if ah.Todos != nil {
+		fork.Todos = ah.Todos.Clone()
+	}
+	return fork
 }
 
 func (ah AgentHistory) GetSessionUsage() Usage {
@@ -64,10 +111,72 @@ func (ah AgentHistory) GetSessionUsage() Usage {
 	return Usage{Input: totalIn, Output: totalOut, Total: total}
 }
 
+// GetHistory returns the most recently updated session of the working path.
 func GetHistory(workingPath string) (*AgentHistory, error) {
-	return nil, nil
+	sessions, err := ListSessions(workingPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) < 1 {
+		return nil, fmt.Errorf("no sessions found for %q", workingPath)
+	}
+	return sessions[0], nil
+}
+
+func GetSession(sessionID string) (*AgentHistory, error) {
+	data, err := os.ReadFile(sessionFilePath(sessionID))
+	if err != nil {
+		return nil, err
+	}
+	history := &AgentHistory{}
+	if err = json.Unmarshal(data, history); err != nil {
+		return nil, err
+	}
+	if history.Todos == nil {
+		// The sessions saved before the todos were kept in them.
+		history.Todos = &TodoList{}
+	}
+	return history, nil
+}
+
+// ListSessions returns the sessions of the working path, most recently updated first.
+// An empty workingPath lists every session.
+func ListSessions(workingPath string) ([]*AgentHistory, error) {
+	entries, err := os.ReadDir(configs.SessionsPath)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []*AgentHistory{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		history, err := GetSession(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		if workingPath != "" && history.WorkingPath != workingPath {
+			continue
+		}
+		sessions = append(sessions, history)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt) })
+	return sessions, nil
 }
 
 func SaveHistory(history *AgentHistory) error {
-	return nil
+	if history == nil || history.SessionID == "" {
+		return errors.New("cannot save a history without a session id")
+	}
+	history.UpdatedAt = time.Now()
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sessionFilePath(history.SessionID), data, 0o644)
+}
+
+func sessionFilePath(sessionID string) string {
+	return filepath.Join(configs.SessionsPath, sessionID+".json")
 }
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func TestSessions(t *testing.T) {
+	configs.SessionsPath = t.TempDir()
+	if err := SaveHistory(&AgentHistory{}); err == nil {
+		t.Error("expected a history without a session id to be refused")
+	}
+
+	older := NewHistory("/project", "gpt-5/low")
+	older.Messages = append(older.Messages, HistoryMessage{Role: MsgRoleUser, Text: "hi"})
+	other := NewHistory("/elsewhere", "")
+	newer := NewHistory("/project", "")
+	for _, h := range []*AgentHistory{older, other, newer} {
+		if err := SaveHistory(h); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sessions, err := ListSessions("/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].SessionID != newer.SessionID || sessions[1].SessionID != older.SessionID {
+		t.Fatalf("expected the sessions of the project, most recent first, got %+v", sessions)
+	}
+	if all, _ := ListSessions(""); len(all) != 3 {
+		t.Errorf("expected every session, got %d", len(all))
+	}
+	if latest, err := GetHistory("/project"); err != nil || latest.SessionID != newer.SessionID {
+		t.Errorf("unexpected latest session %+v, %v", latest, err)
+	}
+	loaded, err := GetSession(older.SessionID)
+	if err != nil || loaded.ModelName != "gpt-5/low" || len(loaded.Messages) != 1 || loaded.Messages[0].Text != "hi" {
+		t.Errorf("unexpected loaded session %+v, %v", loaded, err)
+	}
+}
+
+func TestFork(t *testing.T) {
+	history := NewHistory("/project", "gpt-5")
+	history.Messages = []HistoryMessage{
+		{Role: MsgRoleUser, Text: "list the files"},
+		{Role: MsgRoleAI, ToolCalls: []ToolCall{{Name: "ls", CallID: "1", Args: "{}"}}},
+	}
+
+	fork := history.Fork(1)
+	if fork.SessionID == history.SessionID || fork.ParentSessionID != history.SessionID || fork.ForkedAt != 1 {
+		t.Fatalf("unexpected fork %+v", fork)
+	}
+	if fork.WorkingPath != "/project" || fork.ModelName != "gpt-5" || !fork.CreatedAt.Equal(history.CreatedAt) {
+		t.Errorf("the fork lost the session's settings: %+v", fork)
+	}
+
+	// Changing the fork leaves the original as it was.
+	fork.Messages[0].Text = "changed"
+	fork.Messages[1].ToolCalls[0].Args = `{"depth": 2}`
+	fork.Messages = append(fork.Messages, HistoryMessage{Role: MsgRoleTool, Text: "main.go"})
+	if history.Messages[0].Text != "list the files" || history.Messages[1].ToolCalls[0].Args != "{}" || len(history.Messages) != 2 {
+		t.Errorf("the fork shares the messages of its parent: %+v", history.Messages)
+	}
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type TodoStatus string
+
+const (
+	TodoPending    TodoStatus = "pending"
+	TodoInProgress TodoStatus = "in_progress"
+	TodoCompleted  TodoStatus = "completed"
+	TodoCancelled  TodoStatus = "cancelled"
+)
+
+var TodoStatuses = []TodoStatus{TodoPending, TodoInProgress, TodoCompleted, TodoCancelled}
+
+type Todo struct {
+	ID      int        `json:"id"`
+	Content string     `json:"content"`
+	Status  TodoStatus `json:"status"`
+}
+
+// TodoList is the plan the agent keeps for the session, it is saved with the
+// session's history. It is safe for concurrent use, the TUI reads it while
+// the agent's tools change it.
+type TodoList struct {
+	Items  []Todo `json:"items"`
+	NextID int    `json:"nextId"`
+
+	mu sync.Mutex
+}
+
+// All returns a copy of the todos in their order.
+func (tl *TodoList) All() []Todo {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	return slices.Clone(tl.Items)
+}
+
+// Replace makes todos the whole list. The todos keep their ids when they
+// have one, the others get new ids.
+func (tl *TodoList) Replace(todos []Todo) ([]Todo, error) {
+	seen := map[int]bool{}
+	for i := range todos {
+		todos[i].Content = strings.TrimSpace(todos[i].Content)
+		if todos[i].Status == "" {
+			todos[i].Status = TodoPending
+		}
+		if err := checkTodo(todos[i]); err != nil {
+			return nil, fmt.Errorf("todo %d of the list: %w", i+1, err)
+		}
+		if id := todos[i].ID; id != 0 {
+			if seen[id] {
+				return nil, fmt.Errorf("the id %d is used twice", id)
+			}
+			seen[id] = true
+		}
+	}
+
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.NextID = max(tl.NextID, 1)
+	for id := range seen {
+		tl.NextID = max(tl.NextID, id+1)
+	}
+	items := make([]Todo, len(todos))
+	for i, t := range todos {
+		if t.ID == 0 {
+			t.ID = tl.NextID
+			tl.NextID++
+		}
+		items[i] = t
+	}
+	tl.Items = items
+	return slices.Clone(items), nil
+}
+
+// MarshalJSON saves the list while the tools may change it.
+func (tl *TodoList) MarshalJSON() ([]byte, error) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	return json.Marshal(struct {
+		Items  []Todo `json:"items"`
+		NextID int    `json:"nextId"`
+	}{tl.Items, tl.NextID})
+}
+
+// Clone returns a copy of the list, e.g. for a forked session.
+func (tl *TodoList) Clone() *TodoList {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	return &TodoList{Items: slices.Clone(tl.Items), NextID: tl.NextID}
+}
+
+// String formats the list as a checklist, e.g. "[x] 1. Add the flag".
+func (tl *TodoList) String() string {
+	var sb strings.Builder
+	for _, t := range tl.All() {
+		fmt.Fprintf(&sb, "%s %d. %s\n", t.Status.Checkbox(), t.ID, t.Content)
+	}
+	return sb.String()
+}
+
+// Checkbox is how the status is shown in a checklist.
+func (s TodoStatus) Checkbox() string {
+	switch s {
+	case TodoInProgress:
+		return "[~]"
+	case TodoCompleted:
+		return "[x]"
+	case TodoCancelled:
+		return "[-]"
+	}
+	return "[ ]"
+}
+
+func checkTodo(t Todo) error {
+	if t.Content == "" {
+		return fmt.Errorf("the content is empty")
+	}
+	if !slices.Contains(TodoStatuses, t.Status) {
+		return fmt.Errorf("invalid status %q, use one of %v", t.Status, TodoStatuses)
+	}
+	return nil
+}
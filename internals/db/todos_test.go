@@ -0,0 +1,79 @@
+package db
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func TestTodoList(t *testing.T) {
+	tl := &TodoList{}
+	items, err := tl.Replace([]Todo{{Content: " Write the parser "}, {Content: "Test it", Status: TodoInProgress}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Todo{{ID: 1, Content: "Write the parser", Status: TodoPending}, {ID: 2, Content: "Test it", Status: TodoInProgress}}
+	if !slices.Equal(items, want) {
+		t.Fatalf("unexpected todos %+v", items)
+	}
+
+	// The ids are kept and new todos never reuse one.
+	items, _ = tl.Replace([]Todo{{ID: 2, Content: "Test it", Status: TodoCompleted}, {Content: "Document it"}})
+	if items[0].ID != 2 || items[1].ID != 3 {
+		t.Fatalf("unexpected ids %+v", items)
+	}
+	if _, err := tl.Replace([]Todo{{Content: "x", Status: "done"}}); err == nil {
+		t.Error("expected an invalid status to be refused")
+	}
+	if _, err := tl.Replace([]Todo{{ID: 4, Content: "a"}, {ID: 4, Content: "b"}}); err == nil {
+		t.Error("expected a duplicated id to be refused")
+	}
+	if len(tl.All()) != 2 {
+		t.Fatalf("a refused list changed the todos: %+v", tl.All())
+	}
+
+	// Editing, reordering and removing todos is replacing the list.
+	items, _ = tl.Replace([]Todo{{Content: "Release it"}, {ID: 3, Content: "Document the flags", Status: TodoInProgress}})
+	if items[0].ID != 4 {
+		t.Errorf("unexpected id %d", items[0].ID)
+	}
+	if got := tl.String(); got != "[ ] 4. Release it\n[~] 3. Document the flags\n" {
+		t.Errorf("unexpected list %q", got)
+	}
+}
+
+func TestTodosAreSavedWithTheSession(t *testing.T) {
+	configs.SessionsPath = t.TempDir()
+	history := NewHistory("/project", "")
+	history.Todos.Replace([]Todo{{Content: "First"}})
+	if err := SaveHistory(history); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := GetSession(history.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Todos.String(); got != "[ ] 1. First\n" {
+		t.Errorf("unexpected todos %q", got)
+	}
+	if items, _ := loaded.Todos.Replace(append(loaded.Todos.All(), Todo{Content: "Second"})); items[1].ID != 2 {
+		t.Errorf("the next id was not saved, got %d", items[1].ID)
+	}
+
+	fork := loaded.Fork(0)
+	fork.Todos.Replace(append(fork.Todos.All(), Todo{Content: "Only in the fork"}))
+	if len(loaded.Todos.All()) != 2 || len(fork.Todos.All()) != 3 {
+		t.Errorf("the fork shares the todos of its parent")
+	}
+
+	// The sessions saved before the todos were kept in them.
+	old := `{"sessionId": "old", "workingPath": "/project", "messages": []}`
+	if err := os.WriteFile(sessionFilePath("old"), []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := GetSession("old"); err != nil || loaded.Todos == nil {
+		t.Errorf("expected an empty todo list, got %+v, %v", loaded, err)
+	}
+}
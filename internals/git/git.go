@@ -0,0 +1,649 @@
+// Package git runs git commands in a repository and parses their machine
+// readable output.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+// Repo is the repository (or worktree) containing Dir.
+type Repo struct {
+	Dir string
+	// Sandbox is where git runs, the hooks and the config of the repository
+	// may run other commands.
+	Sandbox sandbox.Config
+}
+
+// Run runs git with the arguments and returns its stdout. The error carries
+// what git printed to stderr.
+func (r Repo) Run(ctx context.Context, args ...string) (string, error) {
+	return r.run(ctx, nil, args)
+}
+
+// readOnly runs a command that only reads the repository without the filter
+// drivers of its config. With the diffs made with --no-ext-diff and
+// --no-textconv, reading an untrusted checkout runs none of its programs.
+func (r Repo) readOnly(ctx context.Context, args ...string) (string, error) {
+	config := []string{}
+	out, _ := r.run(ctx, nil, []string{"config", "--name-only", "--get-regexp", `^filter\..*\.(clean|smudge|process)$`})
+	for _, name := range strings.Fields(out) {
+		config = append(config, "-c", name+"=")
+	}
+	return r.run(ctx, config, args)
+}
+
+// run runs git with the config options in front of the arguments. The
+// fsmonitor and the pager of the repository are never run.
+func (r Repo) run(ctx context.Context, config, args []string) (string, error) {
+	config = append([]string{"-c", "core.quotepath=off", "-c", "color.ui=false", "-c", "core.fsmonitor=false", "-c", "core.pager=cat"}, config...)
+	cmd, err := sandbox.CommandContext(ctx, r.Sandbox, "git", append(config, args...)...)
+	if err != nil {
+		return "", err
+	}
+	cmd.Dir = r.Dir
+	// Never wait for an editor, a pager or credentials.
+	cmd.Env = append(cmd.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_EDITOR=true", "GIT_PAGER=cat", "LC_ALL=C")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err = cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				msg = strings.TrimSpace(stdout.String())
+			}
+			return stdout.String(), fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// checkRefs refuses the refs git would take for options, like
+// "--output=file", since they are put in front of the "--".
+func checkRefs(refs ...string) error {
+	for _, ref := range refs {
+		if strings.HasPrefix(ref, "-") {
+			return fmt.Errorf("invalid revision %q: it can't start with '-'", ref)
+		}
+	}
+	return nil
+}
+
+// Root returns the top level directory of the repository.
+func (r Repo) Root(ctx context.Context) (string, error) {
+	out, err := r.Run(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// GitDirs returns the absolute paths of the git directory of the worktree
+// and of the one it shares with the other worktrees, the same one for most
+// repositories.
+func (r Repo) GitDirs(ctx context.Context) ([]string, error) {
+	out, err := r.Run(ctx, "rev-parse", "--path-format=absolute", "--git-dir", "--git-common-dir")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(out), "\n"), nil
+}
+
+type FileChange struct {
+	Path    string
+	OldPath string // of renames and copies
+	Status  string // modified, added, deleted, renamed, copied, type changed, conflicted
+}
+
+type Status struct {
+	Branch     string // empty when detached
+	Commit     string // the abbreviated HEAD, empty before the first commit
+	Upstream   string
+	Ahead      int
+	Behind     int
+	Staged     []FileChange
+	Unstaged   []FileChange
+	Untracked  []string
+	Conflicted []FileChange
+}
+
+var statusNames = map[byte]string{
+	'M': "modified", 'T': "type changed", 'A': "added", 'D': "deleted",
+	'R': "renamed", 'C': "copied", 'U': "conflicted",
+}
+
+// Status parses git status --porcelain=v2.
+func (r Repo) Status(ctx context.Context) (*Status, error) {
+	out, err := r.readOnly(ctx, "status", "--porcelain=v2", "--branch", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	return parseStatus(out), nil
+}
+
+func parseStatus(out string) *Status {
+	s := &Status{}
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); i++ {
+		line := fields[i]
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case '#':
+			key, value, _ := strings.Cut(strings.TrimPrefix(line, "# "), " ")
+			switch key {
+			case "branch.oid":
+				if value != "(initial)" {
+					s.Commit = value[:min(len(value), 7)]
+				}
+			case "branch.head":
+				if value != "(detached)" {
+					s.Branch = value
+				}
+			case "branch.upstream":
+				s.Upstream = value
+			case "branch.ab":
+				fmt.Sscanf(value, "+%d -%d", &s.Ahead, &s.Behind)
+			}
+		case '1', '2':
+			// 1 XY sub mH mI mW hH hI path
+			// 2 XY sub mH mI mW hH hI Xscore path, followed by the original path
+			parts := strings.SplitN(line, " ", utils.Ternary(line[0] == '2', 10, 9))
+			if len(parts) < 9 {
+				continue
+			}
+			xy, p := parts[1], parts[len(parts)-1]
+			var old string
+			if line[0] == '2' && i+1 < len(fields) {
+				i++
+				old = fields[i]
+			}
+			if xy[0] != '.' {
+				s.Staged = append(s.Staged, FileChange{Path: p, OldPath: old, Status: statusNames[xy[0]]})
+			}
+			if xy[1] != '.' {
+				s.Unstaged = append(s.Unstaged, FileChange{Path: p, Status: statusNames[xy[1]]})
+			}
+		case 'u':
+			parts := strings.SplitN(line, " ", 11)
+			if len(parts) == 11 {
+				s.Conflicted = append(s.Conflicted, FileChange{Path: parts[10], Status: conflictKind(parts[1])})
+			}
+		case '?':
+			s.Untracked = append(s.Untracked, strings.TrimPrefix(line, "? "))
+		}
+	}
+	return s
+}
+
+func conflictKind(xy string) string {
+	switch xy {
+	case "DD":
+		return "both deleted"
+	case "AU":
+		return "added by us"
+	case "UD":
+		return "deleted by them"
+	case "UA":
+		return "added by them"
+	case "DU":
+		return "deleted by us"
+	case "AA":
+		return "both added"
+	}
+	return "both modified"
+}
+
+// FileDiff is the diff of a single file.
+type FileDiff struct {
+	Path    string
+	OldPath string
+	Added   int
+	Deleted int
+	Binary  bool
+	Patch   string
+}
+
+// DiffOptions select what is compared. By default the working tree is
+// compared with the index.
+type DiffOptions struct {
+	Staged bool
+	// Refs are compared with the working tree (one) or with each other (two),
+	// a single "a..b" or "a...b" range works too.
+	Refs  []string
+	Paths []string
+}
+
+func (r Repo) Diff(ctx context.Context, opts DiffOptions) ([]FileDiff, error) {
+	if err := checkRefs(opts.Refs...); err != nil {
+		return nil, err
+	}
+	args := []string{"diff", "--no-ext-diff", "--no-textconv", "--find-renames"}
+	if opts.Staged {
+		args = append(args, "--cached")
+	}
+	args = append(args, opts.Refs...)
+	args = append(append(args, "--"), opts.Paths...)
+	return r.diff(ctx, args)
+}
+
+// diff runs a command printing a patch and adds the numbers of added and
+// deleted lines to the files.
+func (r Repo) diff(ctx context.Context, args []string) ([]FileDiff, error) {
+	patch, err := r.readOnly(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	files := splitPatch(patch)
+	// The numstat of the same diff, in the same order.
+	numstatArgs := append([]string{args[0], "--numstat", "-z"}, args[1:]...)
+	if out, err := r.readOnly(ctx, numstatArgs...); err == nil {
+		stats := parseNumstat(out)
+		for i := range files {
+			if i < len(stats) {
+				files[i].Added, files[i].Deleted, files[i].Binary = stats[i].Added, stats[i].Deleted, stats[i].Binary
+			}
+		}
+	}
+	return files, nil
+}
+
+func splitPatch(patch string) []FileDiff {
+	var files []FileDiff
+	for _, chunk := range strings.SplitAfter(patch, "\n") {
+		if strings.HasPrefix(chunk, "diff --git ") || strings.HasPrefix(chunk, "diff --cc ") {
+			files = append(files, FileDiff{})
+		}
+		if len(files) == 0 {
+			continue
+		}
+		f := &files[len(files)-1]
+		f.Patch += chunk
+		switch {
+		case strings.HasPrefix(chunk, "--- a/"):
+			f.OldPath = strings.TrimSuffix(strings.TrimPrefix(chunk, "--- a/"), "\n")
+		case strings.HasPrefix(chunk, "+++ b/"):
+			f.Path = strings.TrimSuffix(strings.TrimPrefix(chunk, "+++ b/"), "\n")
+		case strings.HasPrefix(chunk, "rename from "):
+			f.OldPath = strings.TrimSuffix(strings.TrimPrefix(chunk, "rename from "), "\n")
+		case strings.HasPrefix(chunk, "rename to "):
+			f.Path = strings.TrimSuffix(strings.TrimPrefix(chunk, "rename to "), "\n")
+		case strings.HasPrefix(chunk, "diff --git ") && f.Path == "":
+			// Binary files and mode changes have no ---/+++ lines.
+			header := strings.TrimSuffix(chunk, "\n")
+			if i := strings.LastIndex(header, " b/"); i >= 0 {
+				f.Path = header[i+3:]
+			}
+		}
+	}
+	for i := range files {
+		if files[i].OldPath == files[i].Path {
+			files[i].OldPath = ""
+		}
+	}
+	return files
+}
+
+type numstat struct {
+	Added, Deleted int
+	Binary         bool
+}
+
+func parseNumstat(out string) []numstat {
+	var stats []numstat
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); i++ {
+		parts := strings.SplitN(fields[i], "\t", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		var s numstat
+		s.Binary = parts[0] == "-"
+		s.Added, _ = strconv.Atoi(parts[0])
+		s.Deleted, _ = strconv.Atoi(parts[1])
+		if parts[2] == "" {
+			// A rename, the old and the new path follow.
+			i += 2
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+type Commit struct {
+	Hash    string
+	Author  string
+	Email   string
+	Date    time.Time
+	Subject string
+	Body    string
+	Refs    string // the branches and tags pointing at it
+}
+
+// ShortHash is the hash abbreviated to 7 characters.
+func (c Commit) ShortHash() string {
+	return c.Hash[:min(len(c.Hash), 7)]
+}
+
+// LogOptions filter the commits of Log.
+type LogOptions struct {
+	Ref      string // defaults to HEAD
+	MaxCount int
+	Skip     int
+	Author   string
+	Since    string // anything git understands, e.g. "2 weeks ago" or "2024-01-31"
+	Until    string
+	Grep     string // in the commit message
+	Paths    []string
+}
+
+// commitFormat separates the fields with US and the commits with RS.
+const commitFormat = "%H%x1f%an%x1f%ae%x1f%aI%x1f%D%x1f%s%x1f%b%x1e"
+
+func (r Repo) Log(ctx context.Context, opts LogOptions) ([]Commit, error) {
+	args := []string{"log", "--no-ext-diff", "--no-textconv", "--format=" + commitFormat}
+	if opts.MaxCount > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(opts.MaxCount))
+	}
+	if opts.Skip > 0 {
+		args = append(args, "--skip="+strconv.Itoa(opts.Skip))
+	}
+	if opts.Author != "" {
+		args = append(args, "--author="+opts.Author)
+	}
+	if opts.Since != "" {
+		args = append(args, "--since="+opts.Since)
+	}
+	if opts.Until != "" {
+		args = append(args, "--until="+opts.Until)
+	}
+	if opts.Grep != "" {
+		args = append(args, "--regexp-ignore-case", "--grep="+opts.Grep)
+	}
+	if opts.Ref != "" {
+		if err := checkRefs(opts.Ref); err != nil {
+			return nil, err
+		}
+		args = append(args, opts.Ref)
+	}
+	args = append(append(args, "--"), opts.Paths...)
+	out, err := r.readOnly(ctx, args...)
+	if err != nil {
+		if strings.Contains(err.Error(), "does not have any commits yet") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseCommits(out), nil
+}
+
+func parseCommits(out string) []Commit {
+	var commits []Commit
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(strings.TrimLeft(record, "\n"), "\x1f")
+		if len(fields) < 7 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[3])
+		commits = append(commits, Commit{
+			Hash: fields[0], Author: fields[1], Email: fields[2], Date: date,
+			Refs: fields[4], Subject: fields[5], Body: strings.TrimSpace(fields[6]),
+		})
+	}
+	return commits
+}
+
+// Show returns the commit along with the diff it introduced to the paths.
+func (r Repo) Show(ctx context.Context, ref string, paths []string) (*Commit, []FileDiff, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if err := checkRefs(ref); err != nil {
+		return nil, nil, err
+	}
+	out, err := r.readOnly(ctx, "show", "--no-patch", "--format="+commitFormat, ref, "--")
+	if err != nil {
+		return nil, nil, err
+	}
+	commits := parseCommits(out)
+	if len(commits) == 0 {
+		return nil, nil, fmt.Errorf("%s is not a commit", ref)
+	}
+	args := append([]string{"show", "--format=", "--no-ext-diff", "--no-textconv", "--find-renames", ref, "--"}, paths...)
+	files, err := r.diff(ctx, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &commits[0], files, nil
+}
+
+type BlameLine struct {
+	Line   int
+	Text   string
+	Commit Commit // only the hash, author, date and subject are set
+}
+
+// Blame returns who last changed the lines start to end (1-based, inclusive)
+// of the file.
+func (r Repo) Blame(ctx context.Context, file string, start, end int, ref string) ([]BlameLine, error) {
+	if err := checkRefs(ref); err != nil {
+		return nil, err
+	}
+	args := []string{"blame", "--porcelain", "--no-textconv"}
+	if start > 0 {
+		args = append(args, "-L", fmt.Sprintf("%d,%s", start, utils.Ternary(end > 0, strconv.Itoa(end), "")))
+	}
+	if ref != "" {
+		args = append(args, ref)
+	}
+	out, err := r.readOnly(ctx, append(args, "--", file)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(out), nil
+}
+
+func parseBlame(out string) []BlameLine {
+	var (
+		lines   []BlameLine
+		commits = map[string]*Commit{}
+		current *Commit
+		lineNo  int
+	)
+	for _, l := range strings.Split(out, "\n") {
+		if strings.HasPrefix(l, "\t") {
+			lines = append(lines, BlameLine{Line: lineNo, Text: l[1:], Commit: *current})
+			continue
+		}
+		key, value, _ := strings.Cut(l, " ")
+		switch key {
+		case "author":
+			current.Author = value
+		case "author-mail":
+			current.Email = strings.Trim(value, "<>")
+		case "author-time":
+			if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				current.Date = time.Unix(sec, 0)
+			}
+		case "summary":
+			current.Subject = value
+		default:
+			// <hash> <original line> <final line> [<lines in group>]
+			parts := strings.Fields(l)
+			if len(key) == 40 && len(parts) >= 3 {
+				if commits[key] == nil {
+					commits[key] = &Commit{Hash: key}
+				}
+				current = commits[key]
+				lineNo, _ = strconv.Atoi(parts[2])
+			}
+		}
+	}
+	return lines
+}
+
+type Branch struct {
+	Name     string
+	Commit   string
+	Upstream string
+	Track    string // e.g. "ahead 1, behind 2" or "gone"
+	Current  bool
+	Remote   bool
+	Date     string
+	Subject  string
+}
+
+// Branches lists the local branches, and the remote ones too when remote is
+// set, the most recently committed to first.
+func (r Repo) Branches(ctx context.Context, remote bool) ([]Branch, error) {
+	format := "%(refname)%1f%(objectname:short)%1f%(upstream:short)%1f%(upstream:track,nobracket)%1f%(HEAD)%1f%(committerdate:short)%1f%(contents:subject)"
+	args := []string{"for-each-ref", "--sort=-committerdate", "--format=" + format, "refs/heads"}
+	if remote {
+		args = append(args, "refs/remotes")
+	}
+	out, err := r.readOnly(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	var branches []Branch
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		f := strings.Split(line, "\x1f")
+		if len(f) < 7 || strings.HasSuffix(f[0], "/HEAD") {
+			continue
+		}
+		b := Branch{Commit: f[1], Upstream: f[2], Track: f[3], Current: f[4] == "*", Date: f[5], Subject: f[6]}
+		if name, ok := strings.CutPrefix(f[0], "refs/remotes/"); ok {
+			b.Name, b.Remote = name, true
+		} else {
+			b.Name = strings.TrimPrefix(f[0], "refs/heads/")
+		}
+		branches = append(branches, b)
+	}
+	return branches, nil
+}
+
+// ErrNothingToCommit is returned by Commit when no change is staged.
+var ErrNothingToCommit = errors.New("nothing to commit")
+
+// Commit records the staged changes, staging the paths first, or every
+// change to a tracked file when all is set. It returns the new commit.
+func (r Repo) Commit(ctx context.Context, message string, paths []string, all bool) (*Commit, []FileDiff, error) {
+	if len(paths) > 0 {
+		if _, err := r.Run(ctx, append([]string{"add", "--"}, paths...)...); err != nil {
+			return nil, nil, err
+		}
+	}
+	args := []string{"commit", "--message", message}
+	if all {
+		args = append(args, "--all")
+	}
+	if _, err := r.Run(ctx, args...); err != nil {
+		msg := err.Error()
+		if strings.Contains(msg, "nothing to commit") || strings.Contains(msg, "nothing added to commit") || strings.Contains(msg, "no changes added to commit") {
+			return nil, nil, ErrNothingToCommit
+		}
+		return nil, nil, err
+	}
+	return r.Show(ctx, "HEAD", nil)
+}
+
+type Stash struct {
+	Ref     string // stash@{n}
+	Subject string
+	Date    time.Time
+}
+
+func (r Repo) StashList(ctx context.Context) ([]Stash, error) {
+	out, err := r.readOnly(ctx, "stash", "list", "--format=%gd%x1f%aI%x1f%gs")
+	if err != nil {
+		return nil, err
+	}
+	var stashes []Stash
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		f := strings.SplitN(line, "\x1f", 3)
+		if len(f) < 3 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, f[1])
+		stashes = append(stashes, Stash{Ref: f[0], Date: date, Subject: f[2]})
+	}
+	return stashes, nil
+}
+
+// StashPush stashes the changes, of the paths only when given, untracked
+// files included when untracked is set. It reports false when there was
+// nothing to stash.
+func (r Repo) StashPush(ctx context.Context, message string, paths []string, untracked bool) (bool, error) {
+	args := []string{"stash", "push"}
+	if message != "" {
+		args = append(args, "--message", message)
+	}
+	if untracked {
+		args = append(args, "--include-untracked")
+	}
+	out, err := r.Run(ctx, append(append(args, "--"), paths...)...)
+	if err != nil {
+		return false, err
+	}
+	return !strings.Contains(out, "No local changes to save"), nil
+}
+
+// StashFiles lists the files the stash (the latest when ref is empty)
+// changes, relative to the repository root.
+func (r Repo) StashFiles(ctx context.Context, ref string) ([]string, error) {
+	if err := checkRefs(ref); err != nil {
+		return nil, err
+	}
+	args := []string{"stash", "show", "--name-only", "--include-untracked", "-z"}
+	if ref != "" {
+		args = append(args, ref)
+	}
+	out, err := r.readOnly(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range strings.Split(out, "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// StashApply applies the stash (the latest when ref is empty), dropping it
+// afterwards when pop is set.
+func (r Repo) StashApply(ctx context.Context, ref string, pop bool) (string, error) {
+	if err := checkRefs(ref); err != nil {
+		return "", err
+	}
+	args := []string{"stash", utils.Ternary(pop, "pop", "apply")}
+	if ref != "" {
+		args = append(args, ref)
+	}
+	return r.Run(ctx, args...)
+}
+
+func (r Repo) StashDrop(ctx context.Context, ref string) error {
+	if err := checkRefs(ref); err != nil {
+		return err
+	}
+	args := []string{"stash", "drop"}
+	if ref != "" {
+		args = append(args, ref)
+	}
+	_, err := r.Run(ctx, args...)
+	return err
+}
@@ -0,0 +1,216 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRepo(t *testing.T) (Repo, func(name string, args ...string)) {
+	t.Helper()
+	dir := t.TempDir()
+	run := func(name string, args ...string) {
+		t.Helper()
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Ann", "GIT_AUTHOR_EMAIL=ann@example.com", "GIT_COMMITTER_NAME=Ann",
+			"GIT_COMMITTER_EMAIL=ann@example.com", "GIT_AUTHOR_DATE=2024-03-01T10:00:00Z", "GIT_COMMITTER_DATE=2024-03-01T10:00:00Z")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s %v: %v\n%s", name, args, err, out)
+		}
+	}
+	run("git", "init", "-q", "-b", "main")
+	return Repo{Dir: dir}, run
+}
+
+func write(t *testing.T, repo Repo, p, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(repo.Dir, p), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStatusAndDiff(t *testing.T) {
+	ctx := context.Background()
+	repo, run := newRepo(t)
+	s, err := repo.Status(ctx)
+	if err != nil || s.Branch != "main" || s.Commit != "" {
+		t.Fatalf("unexpected status of an empty repository %+v, %v", s, err)
+	}
+
+	write(t, repo, "a.txt", "one\ntwo\n")
+	write(t, repo, "old name.txt", "moved\n")
+	run("git", "add", ".")
+	run("git", "commit", "-q", "-m", "first")
+	write(t, repo, "a.txt", "one\n2\nthree\n")
+	run("git", "mv", "old name.txt", "new name.txt")
+	write(t, repo, "untracked.txt", "")
+
+	s, err = repo.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Staged) != 1 || s.Staged[0] != (FileChange{Path: "new name.txt", OldPath: "old name.txt", Status: "renamed"}) {
+		t.Fatalf("unexpected staged changes %+v", s.Staged)
+	}
+	if len(s.Unstaged) != 1 || s.Unstaged[0] != (FileChange{Path: "a.txt", Status: "modified"}) {
+		t.Fatalf("unexpected unstaged changes %+v", s.Unstaged)
+	}
+	if len(s.Untracked) != 1 || s.Untracked[0] != "untracked.txt" {
+		t.Fatalf("unexpected untracked files %+v", s.Untracked)
+	}
+
+	files, err := repo.Diff(ctx, DiffOptions{})
+	if err != nil || len(files) != 1 || files[0].Path != "a.txt" || files[0].Added != 2 || files[0].Deleted != 1 {
+		t.Fatalf("unexpected diff %+v, %v", files, err)
+	}
+	files, err = repo.Diff(ctx, DiffOptions{Staged: true})
+	if err != nil || len(files) != 1 || files[0].Path != "new name.txt" || files[0].OldPath != "old name.txt" {
+		t.Fatalf("unexpected staged diff %+v, %v", files, err)
+	}
+}
+
+func TestLogShowAndBlame(t *testing.T) {
+	ctx := context.Background()
+	repo, run := newRepo(t)
+	write(t, repo, "a.txt", "one\ntwo\n")
+	run("git", "add", ".")
+	run("git", "commit", "-q", "-m", "Add a", "-m", "With a body.")
+	write(t, repo, "a.txt", "one\nTWO\nthree\n")
+	run("git", "commit", "-q", "-am", "Change a")
+
+	commits, err := repo.Log(ctx, LogOptions{Grep: "add"})
+	if err != nil || len(commits) != 1 || commits[0].Subject != "Add a" || commits[0].Body != "With a body." || commits[0].Author != "Ann" {
+		t.Fatalf("unexpected log %+v, %v", commits, err)
+	}
+	c, files, err := repo.Show(ctx, "HEAD", nil)
+	if err != nil || c.Subject != "Change a" || c.Refs != "HEAD -> main" || len(files) != 1 || files[0].Added != 2 || files[0].Deleted != 1 {
+		t.Fatalf("unexpected show %+v %+v, %v", c, files, err)
+	}
+
+	lines, err := repo.Blame(ctx, "a.txt", 1, 2, "")
+	if err != nil || len(lines) != 2 {
+		t.Fatalf("unexpected blame %+v, %v", lines, err)
+	}
+	if lines[0].Commit.Subject != "Add a" || lines[1].Commit.Subject != "Change a" || lines[1].Line != 2 || lines[1].Text != "TWO" {
+		t.Fatalf("unexpected blame %+v", lines)
+	}
+
+	branches, err := repo.Branches(ctx, false)
+	if err != nil || len(branches) != 1 || !branches[0].Current || branches[0].Subject != "Change a" {
+		t.Fatalf("unexpected branches %+v, %v", branches, err)
+	}
+}
+
+func TestOptionRefs(t *testing.T) {
+	ctx := context.Background()
+	repo, run := newRepo(t)
+	write(t, repo, "a.txt", "one\n")
+	run("git", "add", ".")
+	run("git", "commit", "-q", "-m", "Add a")
+	write(t, repo, "a.txt", "two\n")
+	run("git", "stash", "-q")
+
+	out := filepath.Join(t.TempDir(), "out")
+	ref := "--output=" + out
+	calls := map[string]func() error{
+		"diff":  func() error { _, err := repo.Diff(ctx, DiffOptions{Refs: []string{"HEAD", ref}}); return err },
+		"log":   func() error { _, err := repo.Log(ctx, LogOptions{Ref: ref}); return err },
+		"show":  func() error { _, _, err := repo.Show(ctx, ref, nil); return err },
+		"blame": func() error { _, err := repo.Blame(ctx, "a.txt", 0, 0, ref); return err },
+		"stash": func() error { _, err := repo.StashFiles(ctx, ref); return err },
+		"apply": func() error { _, err := repo.StashApply(ctx, "--index", false); return err },
+		"drop":  func() error { return repo.StashDrop(ctx, "-q") },
+	}
+	for name, call := range calls {
+		if err := call(); err == nil || !strings.Contains(err.Error(), "invalid revision") {
+			t.Errorf("%s: expected the ref to be refused, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Error("expected git not to write the output file")
+	}
+	if stashes, _ := repo.StashList(ctx); len(stashes) != 1 {
+		t.Errorf("expected the stash to be kept, got %+v", stashes)
+	}
+}
+
+func TestReadingRunsNoConfiguredPrograms(t *testing.T) {
+	ctx := context.Background()
+	repo, run := newRepo(t)
+	write(t, repo, ".gitattributes", "*.txt filter=evil diff=evil\n")
+	write(t, repo, "a.txt", "one\n")
+	run("git", "add", ".")
+	run("git", "commit", "-q", "-m", "first")
+	marker := filepath.Join(t.TempDir(), "ran")
+	for key, program := range map[string]string{
+		"filter.evil.clean":  "touch " + marker + "-clean; cat",
+		"diff.evil.textconv": "touch " + marker + "-textconv; cat",
+		"diff.external":      "touch " + marker + "-external; true",
+		"core.fsmonitor":     "touch " + marker + "-fsmonitor; false",
+		"core.pager":         "touch " + marker + "-pager; cat",
+	} {
+		run("git", "config", key, program)
+	}
+	write(t, repo, "a.txt", "two\n")
+	// An old mtime makes git compare the content through the filter.
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(repo.Dir, "a.txt"), past, past)
+
+	if s, err := repo.Status(ctx); err != nil || len(s.Unstaged) != 1 {
+		t.Fatalf("unexpected status %+v, %v", s, err)
+	}
+	if files, err := repo.Diff(ctx, DiffOptions{}); err != nil || len(files) != 1 {
+		t.Fatalf("unexpected diff %+v, %v", files, err)
+	}
+	if _, _, err := repo.Show(ctx, "HEAD", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Log(ctx, LogOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Blame(ctx, "a.txt", 0, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if ran, _ := filepath.Glob(marker + "-*"); len(ran) > 0 {
+		t.Errorf("expected no configured program to run, ran %q", ran)
+	}
+}
+
+func TestCommitAndStash(t *testing.T) {
+	ctx := context.Background()
+	repo, run := newRepo(t)
+	run("git", "config", "user.name", "Ann")
+	run("git", "config", "user.email", "ann@example.com")
+	write(t, repo, "a.txt", "one\n")
+	c, files, err := repo.Commit(ctx, "Add a", []string{"a.txt"}, false)
+	if err != nil || c.Subject != "Add a" || len(files) != 1 {
+		t.Fatalf("unexpected commit %+v %+v, %v", c, files, err)
+	}
+	if _, _, err := repo.Commit(ctx, "Nothing", nil, true); !errors.Is(err, ErrNothingToCommit) {
+		t.Fatalf("expected ErrNothingToCommit, got %v", err)
+	}
+
+	write(t, repo, "a.txt", "changed\n")
+	if ok, err := repo.StashPush(ctx, "wip", nil, false); err != nil || !ok {
+		t.Fatalf("unexpected stash push %v, %v", ok, err)
+	}
+	stashes, err := repo.StashList(ctx)
+	if err != nil || len(stashes) != 1 || stashes[0].Ref != "stash@{0}" || stashes[0].Subject != "On main: wip" {
+		t.Fatalf("unexpected stashes %+v, %v", stashes, err)
+	}
+	if files, err := repo.StashFiles(ctx, ""); err != nil || len(files) != 1 || files[0] != "a.txt" {
+		t.Fatalf("unexpected stash files %v, %v", files, err)
+	}
+	if _, err := repo.StashApply(ctx, "", true); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo.Dir, "a.txt")); string(data) != "changed\n" {
+		t.Fatalf("the stash was not applied: %q", data)
+	}
+}
@@ -0,0 +1,171 @@
+package gonav
+
+import (
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testModule = map[string]string{
+	"go.mod": "module example.com/shop\n\ngo 1.22\n",
+	"store/store.go": `package store
+
+import "io"
+
+// Store keeps the items.
+type Store interface {
+	Get(id string) (string, error)
+	io.Closer
+}
+
+type base struct{}
+
+func (base) Close() error { return nil }
+
+type Memory struct {
+	base
+	items map[string]string
+}
+
+func (m *Memory) Get(id string) (string, error) { return m.items[id], nil }
+
+const (
+	A = 1
+	B = 2
+)
+`,
+	"store/store_linux.go": "package store\n\nfunc platform() string { return \"linux\" }\n",
+	"store/store_other.go": "//go:build !linux\n\npackage store\n\nfunc platform() string { return \"other\" }\n",
+	"main.go": `package main
+
+import "example.com/shop/store"
+
+func main() {
+	var s store.Store = &store.Memory{}
+	s.Get("a")
+	defer s.Close()
+}
+`,
+	"store/store_test.go": "package store_test\n\nimport \"example.com/shop/store\"\n\nvar _ store.Store = (*store.Memory)(nil)\n",
+	"testdata/broken.go":  "package broken\n\nfunc {",
+}
+
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for p, content := range files {
+		full := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestProgram(t *testing.T) {
+	root := writeModule(t, testModule)
+	if got, err := FindModuleRoot(filepath.Join(root, "store")); err != nil || got != root {
+		t.Fatalf("unexpected module root %q, %v", got, err)
+	}
+	prog, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, pkg := range prog.Packages {
+		paths = append(paths, pkg.Path)
+	}
+	if !slices.Equal(paths, []string{"example.com/shop", "example.com/shop/store", "example.com/shop/store_test"}) {
+		t.Fatalf("unexpected packages %v", paths)
+	}
+
+	objs := prog.Lookup("Store.Get")
+	if len(objs) != 1 {
+		t.Fatalf("expected the interface method, got %v", objs)
+	}
+	refs := prog.References(objs[0])
+	if len(refs) != 1 || filepath.Base(refs[0].Filename) != "main.go" || refs[0].Line != 7 {
+		t.Fatalf("unexpected references %v", refs)
+	}
+
+	memory := prog.Lookup("store.Memory")[0].(*types.TypeName)
+	start, end, ok := prog.DeclRange(prog.Lookup("Memory.Get")[0])
+	if !ok || start.Line != 20 || end.Line != 20 {
+		t.Fatalf("unexpected range of the method: %v-%v", start, end)
+	}
+	if start, end, _ := prog.DeclRange(prog.Lookup("Store")[0]); start.Line != 5 || end.Line != 9 {
+		t.Fatalf("expected the doc comment and the whole type, got %v-%v", start, end)
+	}
+	if start, end, _ := prog.DeclRange(prog.Lookup("B")[0]); start.Line != 24 || end.Line != 24 {
+		t.Fatalf("expected only the spec of a grouped const, got %v-%v", start, end)
+	}
+
+	impls := prog.Implementations(prog.Lookup("Store")[0].(*types.TypeName))
+	if len(impls) != 1 || impls[0] != memory {
+		t.Fatalf("expected Memory to implement Store, got %v", impls)
+	}
+	if ifaces := prog.Implementations(memory); len(ifaces) != 1 || ifaces[0].Name() != "Store" {
+		t.Fatalf("expected Store to be implemented by Memory, got %v", ifaces)
+	}
+
+	var methods []string
+	for _, sel := range prog.MethodSet(memory) {
+		methods = append(methods, sel.Obj().Name())
+	}
+	if !slices.Equal(methods, []string{"Close", "Get"}) || !prog.Declares(memory) {
+		t.Fatalf("unexpected method set %v", methods)
+	}
+	if len(prog.Lookup("platform")) != 1 {
+		t.Fatal("expected only the file of this platform to be loaded")
+	}
+
+	if again, _ := Load(root); again != prog {
+		t.Fatal("expected the program to be reused while the files are unchanged")
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(root, "main.go"), future, future); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Load(root); again == prog {
+		t.Fatal("expected the program to be reloaded after a change")
+	}
+}
+
+func TestOutline(t *testing.T) {
+	pkg, entries, err := Outline("store.go", []byte(testModule["store/store.go"]))
+	if err != nil || pkg != "store" {
+		t.Fatalf("unexpected result %q, %v", pkg, err)
+	}
+	var lines []string
+	for _, e := range entries {
+		lines = append(lines, strings.Repeat("  ", e.Depth)+e.Signature)
+	}
+	want := []string{
+		"type Store interface",
+		"  Get(id string) (string, error)",
+		"  io.Closer",
+		"type base struct",
+		"func (base) Close() error",
+		"type Memory struct",
+		"func (m *Memory) Get(id string) (string, error)",
+		"const A",
+		"const B",
+	}
+	if !slices.Equal(lines, want) {
+		t.Fatalf("unexpected outline:\n%s", strings.Join(lines, "\n"))
+	}
+	if entries[0].Start != 6 || entries[0].End != 9 || entries[1].Start != 7 {
+		t.Fatalf("unexpected line ranges %+v", entries[:2])
+	}
+
+	if _, entries, err := Outline("broken.go", []byte("package x\n\nfunc A() {}\n\nfunc {")); err == nil || len(entries) < 1 || entries[0].Signature != "func A()" {
+		t.Fatalf("expected a partial outline with the error, got %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,227 @@
+package gonav
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Program is every Go package of a module, parsed and type-checked from
+// source. The packages outside of the module are imported from their export
+// data, which is good enough to resolve the types the module uses.
+type Program struct {
+	Root     string // the directory of go.mod
+	Module   string // the module path
+	Fset     *token.FileSet
+	Packages []*Package // sorted by import path
+}
+
+type Package struct {
+	Path  string // the import path, with a "_test" suffix for external tests
+	Dir   string
+	Name  string
+	Files []*ast.File
+	Types *types.Package
+	Info  *types.Info
+}
+
+var (
+	cacheMu sync.Mutex
+	cached  *Program
+	// cachedStamp identifies the state of the files cached was loaded from.
+	cachedStamp string
+	// stdImporter is kept around since it caches the packages it imported.
+	stdImporter = importer.Default()
+)
+
+// FindModuleRoot returns the closest directory at or above dir with a go.mod.
+func FindModuleRoot(dir string) (string, error) {
+	for cur := dir; ; {
+		if _, err := os.Stat(filepath.Join(cur, "go.mod")); err == nil {
+			return cur, nil
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return "", fmt.Errorf("no go.mod found at or above %s", dir)
+		}
+		cur = parent
+	}
+}
+
+// Load parses and type-checks the module rooted at root. The result is reused
+// until a Go file of the module is added, removed or modified. Type errors
+// don't fail the load, the packages are checked as far as possible.
+func Load(root string) (*Program, error) {
+	files, stamp, err := moduleFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if cached != nil && cached.Root == root && cachedStamp == stamp {
+		return cached, nil
+	}
+
+	prog := &Program{Root: root, Module: modulePath(root), Fset: token.NewFileSet()}
+	byPath := map[string]*Package{}
+	for _, f := range files {
+		file, err := parser.ParseFile(prog.Fset, f, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if file == nil {
+			return nil, err
+		}
+		dir := filepath.Dir(f)
+		importPath := prog.importPathOf(dir)
+		if strings.HasSuffix(file.Name.Name, "_test") && strings.HasSuffix(f, "_test.go") {
+			importPath += "_test"
+		}
+		pkg := byPath[importPath]
+		if pkg == nil {
+			pkg = &Package{Path: importPath, Dir: dir, Name: file.Name.Name}
+			byPath[importPath] = pkg
+			prog.Packages = append(prog.Packages, pkg)
+		}
+		pkg.Files = append(pkg.Files, file)
+	}
+	sort.Slice(prog.Packages, func(i, j int) bool { return prog.Packages[i].Path < prog.Packages[j].Path })
+
+	c := &checker{prog: prog, byPath: byPath, checking: map[string]bool{}}
+	for _, pkg := range prog.Packages {
+		c.check(pkg)
+	}
+	cached, cachedStamp = prog, stamp
+	return prog, nil
+}
+
+// Package returns the package whose files are in dir, preferring the package
+// over its external tests.
+func (p *Program) Package(dir string) *Package {
+	var found *Package
+	for _, pkg := range p.Packages {
+		if pkg.Dir == dir && (found == nil || !strings.HasSuffix(pkg.Path, "_test")) {
+			found = pkg
+		}
+	}
+	return found
+}
+
+func (p *Program) importPathOf(dir string) string {
+	rel, err := filepath.Rel(p.Root, dir)
+	if err != nil || rel == "." {
+		return p.Module
+	}
+	return path.Join(p.Module, filepath.ToSlash(rel))
+}
+
+type checker struct {
+	prog     *Program
+	byPath   map[string]*Package
+	checking map[string]bool
+}
+
+func (c *checker) Import(importPath string) (*types.Package, error) {
+	if pkg, ok := c.byPath[importPath]; ok {
+		if c.checking[importPath] {
+			return nil, fmt.Errorf("import cycle through %s", importPath)
+		}
+		return c.check(pkg), nil
+	}
+	return stdImporter.Import(importPath)
+}
+
+func (c *checker) check(pkg *Package) *types.Package {
+	if pkg.Types != nil {
+		return pkg.Types
+	}
+	c.checking[pkg.Path] = true
+	defer delete(c.checking, pkg.Path)
+
+	pkg.Info = &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	conf := types.Config{
+		Importer: c,
+		// Keep going past the errors, the code is often mid-edit.
+		Error:                    func(error) {},
+		DisableUnusedImportCheck: true,
+	}
+	pkg.Types, _ = conf.Check(pkg.Path, c.prog.Fset, pkg.Files, pkg.Info)
+	return pkg.Types
+}
+
+// moduleFiles lists the Go files of the module that are built on this
+// platform, skipping nested modules and the directories the go command
+// ignores. The stamp changes whenever one of the files does.
+func moduleFiles(root string) ([]string, string, error) {
+	var files []string
+	var stamp strings.Builder
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if p == root {
+				return nil
+			}
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" || name == "node_modules" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") {
+			return nil
+		}
+		if ok, err := build.Default.MatchFile(filepath.Dir(p), name); err != nil || !ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, p)
+		fmt.Fprintf(&stamp, "%s:%d:%d\n", p, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(files) == 0 {
+		return nil, "", errors.New("no Go files found in " + root)
+	}
+	return files, stamp.String(), nil
+}
+
+func modulePath(root string) string {
+	f, err := os.Open(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return filepath.Base(root)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return filepath.Base(root)
+}
@@ -0,0 +1,360 @@
+package gonav
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// OutlineEntry is a declaration of a file. Depth is 1 for the methods of
+// interfaces, 0 otherwise.
+type OutlineEntry struct {
+	Kind      string // func, method, type, const or var
+	Signature string
+	Start     int
+	End       int
+	Depth     int
+}
+
+// Outline lists the declarations of a Go file with their line ranges. It only
+// parses the file, so it works on files that don't type-check or aren't part
+// of a module.
+func Outline(filename string, src []byte) (pkgName string, entries []OutlineEntry, err error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if file == nil {
+		return "", nil, err
+	}
+	line := func(p token.Pos) int { return fset.Position(p).Line }
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			kind := "func"
+			if d.Recv != nil {
+				kind = "method"
+			}
+			sig := *d
+			sig.Body, sig.Doc = nil, nil
+			entries = append(entries, OutlineEntry{Kind: kind, Signature: nodeString(fset, &sig), Start: line(d.Pos()), End: line(d.End())})
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				start, end := line(spec.Pos()), line(spec.End())
+				if len(d.Specs) == 1 {
+					start, end = line(d.Pos()), line(d.End())
+				}
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					entries = append(entries, OutlineEntry{Kind: "type", Signature: "type " + typeSpecString(fset, s), Start: start, End: end})
+					if iface, ok := s.Type.(*ast.InterfaceType); ok {
+						for _, m := range iface.Methods.List {
+							entries = append(entries, OutlineEntry{Kind: "method", Signature: fieldString(fset, m), Start: line(m.Pos()), End: line(m.End()), Depth: 1})
+						}
+					}
+				case *ast.ValueSpec:
+					var names []string
+					for _, n := range s.Names {
+						names = append(names, n.Name)
+					}
+					sig := d.Tok.String() + " " + strings.Join(names, ", ")
+					if s.Type != nil {
+						sig += " " + nodeString(fset, s.Type)
+					}
+					entries = append(entries, OutlineEntry{Kind: d.Tok.String(), Signature: sig, Start: start, End: end})
+				}
+			}
+		}
+	}
+	return file.Name.Name, entries, err
+}
+
+// Lookup finds the objects a query like "Name", "pkg.Name", "Type.Member" or
+// "pkg.Type.Member" refers to. Plain names fall back to the methods and
+// fields of that name when no package-level object has it.
+func (p *Program) Lookup(query string) []types.Object {
+	parts := strings.Split(strings.TrimSpace(query), ".")
+	var found []types.Object
+	add := func(obj types.Object) {
+		if obj == nil {
+			return
+		}
+		for _, o := range found {
+			if o == obj {
+				return
+			}
+		}
+		found = append(found, obj)
+	}
+	member := func(pkg *Package, typeName, name string) {
+		if tn, ok := pkg.Types.Scope().Lookup(typeName).(*types.TypeName); ok {
+			obj, _, _ := types.LookupFieldOrMethod(tn.Type(), true, pkg.Types, name)
+			add(obj)
+		}
+	}
+
+	for _, pkg := range p.Packages {
+		if pkg.Types == nil {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		switch len(parts) {
+		case 1:
+			add(scope.Lookup(parts[0]))
+		case 2:
+			if pkg.Name == parts[0] {
+				add(scope.Lookup(parts[1]))
+			}
+			member(pkg, parts[0], parts[1])
+		case 3:
+			if pkg.Name == parts[0] {
+				member(pkg, parts[1], parts[2])
+			}
+		}
+	}
+
+	if len(found) == 0 && len(parts) == 1 {
+		for _, tn := range p.namedTypes() {
+			obj, _, _ := types.LookupFieldOrMethod(tn.Type(), true, tn.Pkg(), parts[0])
+			if obj != nil && obj.Pkg() == tn.Pkg() {
+				add(obj)
+			}
+		}
+	}
+	return found
+}
+
+// DeclRange returns the range of the declaration of obj: the whole function,
+// type or value declaration with its doc comment, or the field or interface
+// method.
+func (p *Program) DeclRange(obj types.Object) (start, end token.Position, ok bool) {
+	if !p.Declares(obj) {
+		return start, end, false
+	}
+	file := p.fileOf(obj.Pos())
+	if file == nil {
+		return start, end, false
+	}
+	from, to := obj.Pos(), obj.Pos()
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil || n.Pos() > obj.Pos() || n.End() <= obj.Pos() {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			from, to = n.Pos(), n.End()
+			if n.Doc != nil {
+				from = n.Doc.Pos()
+			}
+			return false
+		case *ast.GenDecl:
+			from, to = n.Pos(), n.End()
+			if n.Doc != nil {
+				from = n.Doc.Pos()
+			}
+		case *ast.TypeSpec, *ast.ValueSpec:
+			if gd := enclosingGenDecl(file, n.Pos()); gd != nil && len(gd.Specs) > 1 {
+				from, to = n.Pos(), n.End()
+			}
+		case *ast.Field:
+			from, to = n.Pos(), n.End()
+			if n.Doc != nil {
+				from = n.Doc.Pos()
+			}
+		}
+		return true
+	})
+	return p.Fset.Position(from), p.Fset.Position(to), true
+}
+
+// References returns the positions everything in the module uses obj at.
+func (p *Program) References(obj types.Object) []token.Position {
+	var refs []token.Position
+	for _, pkg := range p.Packages {
+		if pkg.Info == nil {
+			continue
+		}
+		for id, used := range pkg.Info.Uses {
+			if used == obj || originOf(used) == obj {
+				refs = append(refs, p.Fset.Position(id.Pos()))
+			}
+		}
+	}
+	sortPositions(refs)
+	return refs
+}
+
+// Implementations returns the named types of the module that implement the
+// interface tn, or the interfaces of the module the type tn implements.
+func (p *Program) Implementations(tn *types.TypeName) []*types.TypeName {
+	var found []*types.TypeName
+	target := tn.Type()
+	iface, isIface := target.Underlying().(*types.Interface)
+	for _, other := range p.namedTypes() {
+		// Implements is unspecified for generic types that aren't instantiated.
+		if other == tn || isGeneric(other) || isGeneric(tn) {
+			continue
+		}
+		otherIface, otherIsIface := other.Type().Underlying().(*types.Interface)
+		switch {
+		case isIface && !otherIsIface:
+			if iface.NumMethods() > 0 && implements(other.Type(), iface) {
+				found = append(found, other)
+			}
+		case !isIface && otherIsIface:
+			if otherIface.NumMethods() > 0 && implements(target, otherIface) {
+				found = append(found, other)
+			}
+		}
+	}
+	return found
+}
+
+// MethodSet returns the methods that can be called on a value of the type,
+// including the promoted ones and those with pointer receivers.
+func (p *Program) MethodSet(tn *types.TypeName) []*types.Selection {
+	t := tn.Type()
+	if _, ok := t.Underlying().(*types.Interface); !ok {
+		t = types.NewPointer(t)
+	}
+	mset := types.NewMethodSet(t)
+	methods := make([]*types.Selection, 0, mset.Len())
+	for i := range mset.Len() {
+		methods = append(methods, mset.At(i))
+	}
+	return methods
+}
+
+// Declares reports whether obj is declared in the module, as opposed to one of
+// its dependencies, whose positions don't belong to the program's FileSet.
+func (p *Program) Declares(obj types.Object) bool {
+	if obj.Pkg() == nil {
+		return false
+	}
+	for _, pkg := range p.Packages {
+		if pkg.Types == obj.Pkg() {
+			return true
+		}
+	}
+	return false
+}
+
+// namedTypes returns the package-level type declarations of the module.
+func (p *Program) namedTypes() []*types.TypeName {
+	var named []*types.TypeName
+	for _, pkg := range p.Packages {
+		if pkg.Types == nil {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			if tn, ok := scope.Lookup(name).(*types.TypeName); ok && !tn.IsAlias() {
+				named = append(named, tn)
+			}
+		}
+	}
+	return named
+}
+
+func (p *Program) fileOf(pos token.Pos) *ast.File {
+	for _, pkg := range p.Packages {
+		for _, f := range pkg.Files {
+			if f.FileStart <= pos && pos <= f.FileEnd {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+func isGeneric(tn *types.TypeName) bool {
+	named, ok := tn.Type().(*types.Named)
+	return ok && named.TypeParams().Len() > 0
+}
+
+func implements(t types.Type, iface *types.Interface) bool {
+	return types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface)
+}
+
+// originOf maps the methods and fields of instantiated generic types back to
+// their declaration.
+func originOf(obj types.Object) types.Object {
+	switch o := obj.(type) {
+	case *types.Func:
+		return o.Origin()
+	case *types.Var:
+		return o.Origin()
+	}
+	return obj
+}
+
+func enclosingGenDecl(file *ast.File, pos token.Pos) *ast.GenDecl {
+	for _, decl := range file.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Pos() <= pos && pos < gd.End() {
+			return gd
+		}
+	}
+	return nil
+}
+
+func sortPositions(positions []token.Position) {
+	sort.Slice(positions, func(i, j int) bool {
+		a, b := positions[i], positions[j]
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+}
+
+func typeSpecString(fset *token.FileSet, s *ast.TypeSpec) string {
+	name := s.Name.Name
+	if s.TypeParams != nil {
+		name += "[" + fieldListString(fset, s.TypeParams) + "]"
+	}
+	if s.Assign.IsValid() {
+		name += " ="
+	}
+	switch s.Type.(type) {
+	case *ast.StructType:
+		return name + " struct"
+	case *ast.InterfaceType:
+		return name + " interface"
+	}
+	return name + " " + nodeString(fset, s.Type)
+}
+
+func fieldString(fset *token.FileSet, f *ast.Field) string {
+	if len(f.Names) == 0 {
+		return nodeString(fset, f.Type)
+	}
+	// Interface methods are fields whose type is the signature.
+	if ft, ok := f.Type.(*ast.FuncType); ok {
+		return f.Names[0].Name + strings.TrimPrefix(nodeString(fset, ft), "func")
+	}
+	names := make([]string, len(f.Names))
+	for i, n := range f.Names {
+		names[i] = n.Name
+	}
+	return strings.Join(names, ", ") + " " + nodeString(fset, f.Type)
+}
+
+func fieldListString(fset *token.FileSet, fl *ast.FieldList) string {
+	var parts []string
+	for _, f := range fl.List {
+		parts = append(parts, fieldString(fset, f))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func nodeString(fset *token.FileSet, n any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, n); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
@@ -0,0 +1,373 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Client talks JSON-RPC to a language server over its stdin and stdout. It
+// keeps the diagnostics the server publishes for every document.
+type Client struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	nextID    int
+	pending   map[string]chan message
+	versions  map[string]int // the open documents by URI
+	diags     map[string][]Diagnostic
+	published map[string]int // how many times the diagnostics of a URI were published
+	// changed is closed and replaced whenever diagnostics are published.
+	changed chan struct{}
+	done    chan struct{}
+	err     error
+}
+
+// Start launches the server and runs the initialize handshake for the
+// workspace at root.
+func Start(ctx context.Context, name string, command []string, root string) (*Client, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no command configured for the %s language server", name)
+	}
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path, command[1:]...)
+	cmd.Dir = root
+	cmd.Stderr = io.Discard
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		name:      name,
+		cmd:       cmd,
+		stdin:     stdin,
+		pending:   map[string]chan message{},
+		versions:  map[string]int{},
+		diags:     map[string][]Diagnostic{},
+		published: map[string]int{},
+		changed:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go c.readLoop(bufio.NewReader(stdout))
+
+	rootURI := PathToURI(root)
+	params := map[string]any{
+		"processId":        os.Getpid(),
+		"clientInfo":       map[string]any{"name": "cli-agent"},
+		"rootUri":          rootURI,
+		"workspaceFolders": []map[string]any{{"uri": rootURI, "name": filepath.Base(root)}},
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"dynamicRegistration": false},
+				"publishDiagnostics": map[string]any{"relatedInformation": false, "versionSupport": true},
+			},
+			"workspace": map[string]any{"configuration": true, "workspaceFolders": true},
+		},
+	}
+	if err := c.Call(ctx, "initialize", params, nil); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("unable to initialize the %s language server: %w", name, err)
+	}
+	if err := c.Notify("initialized", map[string]any{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Call sends a request and waits for its response, which is decoded into
+// result unless result is nil.
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	ch := make(chan message, 1)
+	c.pending[string(id)] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+	}()
+	if err := c.write(message{ID: &id, Method: method, Params: mustMarshal(params)}); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil && len(resp.Result) > 0 {
+			return json.Unmarshal(resp.Result, result)
+		}
+		return nil
+	case <-c.done:
+		return c.exitErr()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification, which has no response.
+func (c *Client) Notify(method string, params any) error {
+	return c.write(message{Method: method, Params: mustMarshal(params)})
+}
+
+// Sync sends the content of the file to the server, opening the document the
+// first time. It returns how many times the diagnostics of the file had been
+// published before, for WaitForDiagnostics.
+func (c *Client) Sync(path, languageID, text string) (int, error) {
+	uri := PathToURI(path)
+	c.mu.Lock()
+	version, open := c.versions[uri]
+	c.versions[uri] = version + 1
+	seen := c.published[uri]
+	c.mu.Unlock()
+
+	if !open {
+		return seen, c.Notify("textDocument/didOpen", map[string]any{
+			"textDocument": textDocumentItem{URI: uri, LanguageID: languageID, Version: 1, Text: text},
+		})
+	}
+	return seen, c.Notify("textDocument/didChange", map[string]any{
+		"textDocument":   versionedTextDocumentIdentifier{URI: uri, Version: version + 1},
+		"contentChanges": []map[string]any{{"text": text}},
+	})
+}
+
+// CloseDocument tells the server the file is gone or no longer of interest.
+func (c *Client) CloseDocument(path string) error {
+	uri := PathToURI(path)
+	c.mu.Lock()
+	_, open := c.versions[uri]
+	delete(c.versions, uri)
+	delete(c.diags, uri)
+	c.mu.Unlock()
+	if !open {
+		return nil
+	}
+	return c.Notify("textDocument/didClose", map[string]any{"textDocument": textDocumentIdentifier{URI: uri}})
+}
+
+// WaitForDiagnostics waits until the diagnostics of the file were published
+// more than seen times and then for the server to go quiet for a moment, as
+// servers often publish the syntax errors before the type errors. It gives up
+// at the timeout and returns whatever is known by then.
+func (c *Client) WaitForDiagnostics(path string, seen int, timeout, quiet time.Duration) []Diagnostic {
+	uri := PathToURI(path)
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		c.mu.Lock()
+		count, changed := c.published[uri], c.changed
+		c.mu.Unlock()
+
+		wait := deadline.C
+		var settle <-chan time.Time
+		if count > seen {
+			settle = time.After(quiet)
+		}
+		select {
+		case <-changed:
+			continue
+		case <-settle:
+		case <-wait:
+		case <-c.done:
+		}
+		return c.Diagnostics(path)
+	}
+}
+
+// IsOpen reports whether the file was sent to the server.
+func (c *Client) IsOpen(path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, open := c.versions[PathToURI(path)]
+	return open
+}
+
+// Diagnostics returns the last diagnostics published for the file.
+func (c *Client) Diagnostics(path string) []Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.diags[PathToURI(path)]
+}
+
+// AllDiagnostics returns the last diagnostics of every file, by path.
+func (c *Client) AllDiagnostics() map[string][]Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	all := map[string][]Diagnostic{}
+	for uri, diags := range c.diags {
+		if len(diags) > 0 {
+			all[URIToPath(uri)] = diags
+		}
+	}
+	return all
+}
+
+// Close asks the server to shut down and kills it if it doesn't in time.
+func (c *Client) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if c.Call(ctx, "shutdown", nil, nil) == nil {
+		c.Notify("exit", nil)
+	}
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+}
+
+func (c *Client) write(msg message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.stdin, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.stdin.Write(body)
+	return err
+}
+
+func (c *Client) readLoop(r *bufio.Reader) {
+	tp := textproto.NewReader(r)
+	var err error
+	for {
+		var msg message
+		if msg, err = readMessage(tp, r); err != nil {
+			break
+		}
+		switch {
+		case msg.Method == "" && msg.ID != nil:
+			c.mu.Lock()
+			ch := c.pending[string(*msg.ID)]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		case msg.Method != "" && msg.ID != nil:
+			c.answer(msg)
+		case msg.Method == "textDocument/publishDiagnostics":
+			var params publishDiagnosticsParams
+			if json.Unmarshal(msg.Params, &params) == nil {
+				c.mu.Lock()
+				c.diags[params.URI] = params.Diagnostics
+				c.published[params.URI]++
+				close(c.changed)
+				c.changed = make(chan struct{})
+				c.mu.Unlock()
+			}
+		}
+	}
+
+	waitErr := c.cmd.Wait()
+	c.mu.Lock()
+	c.err = errors.Join(fmt.Errorf("the %s language server exited", c.name), waitErr)
+	c.mu.Unlock()
+	close(c.done)
+	if !errors.Is(err, io.EOF) {
+		log.Println("ERROR: Failed to read from the language server", c.name, err)
+	}
+}
+
+// answer responds to the requests servers send to the client. Only the ones
+// servers need answered to keep working are supported.
+func (c *Client) answer(req message) {
+	resp := message{ID: req.ID}
+	switch req.Method {
+	case "workspace/configuration":
+		var params struct {
+			Items []any `json:"items"`
+		}
+		json.Unmarshal(req.Params, &params)
+		resp.Result = mustMarshal(make([]any, len(params.Items)))
+	case "client/registerCapability", "client/unregisterCapability", "window/workDoneProgress/create":
+		resp.Result = json.RawMessage("null")
+	case "workspace/workspaceFolders":
+		resp.Result = json.RawMessage("null")
+	default:
+		resp.Error = &responseError{Code: -32601, Message: "method not supported: " + req.Method}
+	}
+	if err := c.write(resp); err != nil {
+		log.Println("ERROR: Failed to answer the language server", c.name, err)
+	}
+}
+
+func (c *Client) exitErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func readMessage(tp *textproto.Reader, r io.Reader) (message, error) {
+	var msg message
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return msg, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return msg, fmt.Errorf("invalid Content-Length header: %w", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return msg, err
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		// Skip the message rather than losing the connection.
+		log.Println("ERROR: Invalid message from the language server:", err)
+		return message{}, nil
+	}
+	return msg, nil
+}
+
+func mustMarshal(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -0,0 +1,100 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/lsp/lsptest"
+)
+
+func TestMain(m *testing.M) {
+	lsptest.ServeIfRequested()
+	os.Exit(m.Run())
+}
+
+func TestManagerCheck(t *testing.T) {
+	root := t.TempDir()
+	m := NewManager(root, []ServerConfig{{Name: "fake", Command: lsptest.Command(), Extensions: []string{".txt"}}})
+	t.Cleanup(m.Close)
+
+	file := filepath.Join(root, "a.txt")
+	if err := os.WriteFile(file, []byte("fine\nan ERROR here\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Known(file); ok {
+		t.Fatal("nothing should be known before the first check")
+	}
+	diags, err := m.Check(context.Background(), file, filepath.Join(root, "b.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || len(diags[file]) != 1 {
+		t.Fatalf("expected one diagnostic for a.txt, got %v", diags)
+	}
+	if got := diags[file][0].String(); got != "2:4 error: bad line: an ERROR here (fake)" {
+		t.Fatalf("unexpected diagnostic %q", got)
+	}
+
+	// Changes are synced to the open document.
+	os.WriteFile(file, []byte("WARN\nfine\n"), 0o644)
+	diags, _ = m.Check(context.Background(), file)
+	if len(diags[file]) != 1 || diags[file][0].Severity != SeverityWarning || diags[file][0].Range.Start.Line != 0 {
+		t.Fatalf("unexpected diagnostics after the change: %v", diags[file])
+	}
+	if known, ok := m.Known(file); !ok || len(known) != 1 {
+		t.Fatalf("expected the last diagnostics to be known, got %v", known)
+	}
+	if all := m.All(); len(all) != 1 || len(all[file]) != 1 {
+		t.Fatalf("unexpected diagnostics of the workspace: %v", all)
+	}
+	if running := m.Running(); len(running) != 1 || running[0] != "fake" {
+		t.Fatalf("unexpected running servers %v", running)
+	}
+
+	// Deleted files are closed and forgotten.
+	os.Remove(file)
+	if diags, _ := m.Check(context.Background(), file); len(diags) != 0 {
+		t.Fatalf("expected no diagnostics for a deleted file, got %v", diags)
+	}
+	if len(m.All()) != 0 {
+		t.Fatal("expected the deleted file to be forgotten")
+	}
+}
+
+func TestManagerMissingServer(t *testing.T) {
+	root := t.TempDir()
+	m := NewManager(root, []ServerConfig{{Name: "missing", Command: []string{"cli-agent-no-such-server"}, Extensions: []string{".txt"}}})
+	t.Cleanup(m.Close)
+	file := filepath.Join(root, "a.txt")
+	os.WriteFile(file, []byte("ERROR"), 0o644)
+	if _, err := m.Check(context.Background(), file); err == nil {
+		t.Fatal("expected an error for a server that can't be started")
+	}
+	if len(m.Running()) != 0 {
+		t.Fatal("the missing server should not be running")
+	}
+}
+
+func TestServersFromSettings(t *testing.T) {
+	servers := ServersFromSettings(configs.LSPSettings{Servers: map[string]configs.LSPServerSettings{
+		"pyright": {Disabled: true},
+		"gopls":   {Command: []string{"gopls", "-remote=auto"}, Extensions: []string{".go"}},
+		"rust":    {Command: []string{"rust-analyzer"}, Extensions: []string{".rs"}},
+	}})
+	names := []string{}
+	for _, s := range servers {
+		names = append(names, s.Name)
+	}
+	if len(names) != 3 || names[0] != "typescript" || names[1] != "gopls" || names[2] != "rust" {
+		t.Fatalf("unexpected servers %v", names)
+	}
+	if len(servers[1].Command) != 2 {
+		t.Fatalf("the configured gopls command should replace the default, got %v", servers[1].Command)
+	}
+	if ServersFromSettings(configs.LSPSettings{Disabled: true}) != nil {
+		t.Fatal("expected no servers when disabled")
+	}
+}
@@ -0,0 +1,116 @@
+// Package lsptest provides a minimal language server for tests, which reports
+// an error for every line containing ERROR and a warning for every line
+// containing WARN.
+package lsptest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EnvVar makes a test binary run the server instead of its tests, see
+// ServeIfRequested.
+const EnvVar = "CLI_AGENT_FAKE_LSP"
+
+// ServeIfRequested serves on stdin and stdout and exits when the test binary
+// was started as the fake server. Call it from TestMain.
+func ServeIfRequested() {
+	if os.Getenv(EnvVar) == "" {
+		return
+	}
+	Serve(os.Stdin, os.Stdout)
+	os.Exit(0)
+}
+
+// Command returns the command that starts the running test binary as the
+// fake server, it needs ServeIfRequested in TestMain.
+func Command() []string {
+	return []string{"env", EnvVar + "=1", os.Args[0]}
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+}
+
+// Serve answers the requests read from r until the exit notification.
+func Serve(r io.Reader, w io.Writer) {
+	br := bufio.NewReader(r)
+	tp := textproto.NewReader(br)
+	send := func(msg message) {
+		msg.JSONRPC = "2.0"
+		body, _ := json.Marshal(msg)
+		fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	for {
+		header, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(br, body); err != nil {
+			return
+		}
+		var msg message
+		if json.Unmarshal(body, &msg) != nil {
+			continue
+		}
+
+		switch msg.Method {
+		case "initialize":
+			send(message{ID: msg.ID, Result: map[string]any{"capabilities": map[string]any{"textDocumentSync": 1}}})
+		case "shutdown":
+			send(message{ID: msg.ID, Result: json.RawMessage("null")})
+		case "exit":
+			return
+		case "textDocument/didOpen", "textDocument/didChange":
+			var params struct {
+				TextDocument struct {
+					URI  string `json:"uri"`
+					Text string `json:"text"`
+				} `json:"textDocument"`
+				ContentChanges []struct {
+					Text string `json:"text"`
+				} `json:"contentChanges"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			text := params.TextDocument.Text
+			if n := len(params.ContentChanges); n > 0 {
+				text = params.ContentChanges[n-1].Text
+			}
+			send(message{Method: "textDocument/publishDiagnostics", Params: diagnose(params.TextDocument.URI, text)})
+		}
+	}
+}
+
+func diagnose(uri, text string) json.RawMessage {
+	diags := []map[string]any{}
+	for i, line := range strings.Split(text, "\n") {
+		severity, what, col := 1, "bad line", strings.Index(line, "ERROR")
+		if col < 0 {
+			severity, what, col = 2, "suspicious line", strings.Index(line, "WARN")
+		}
+		if col < 0 {
+			continue
+		}
+		diags = append(diags, map[string]any{
+			"range":    map[string]any{"start": map[string]int{"line": i, "character": col}, "end": map[string]int{"line": i, "character": len(line)}},
+			"severity": severity,
+			"source":   "fake",
+			"message":  what + ": " + strings.TrimSpace(line),
+		})
+	}
+	data, _ := json.Marshal(map[string]any{"uri": uri, "diagnostics": diags})
+	return data
+}
@@ -0,0 +1,262 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+const (
+	startTimeout = 30 * time.Second
+	// The first diagnostics of a server take longer, it is still loading the
+	// workspace.
+	firstDiagnosticsTimeout = 15 * time.Second
+	diagnosticsTimeout      = 5 * time.Second
+	diagnosticsQuietPeriod  = 300 * time.Millisecond
+)
+
+type ServerConfig struct {
+	Name       string
+	Command    []string
+	Extensions []string
+}
+
+// DefaultServers are used for the languages the settings don't configure.
+var DefaultServers = []ServerConfig{
+	{Name: "gopls", Command: []string{"gopls"}, Extensions: []string{".go"}},
+	{Name: "pyright", Command: []string{"pyright-langserver", "--stdio"}, Extensions: []string{".py", ".pyi"}},
+	{Name: "typescript", Command: []string{"typescript-language-server", "--stdio"}, Extensions: []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs"}},
+}
+
+var languageIDs = map[string]string{
+	".go": "go", ".py": "python", ".pyi": "python",
+	".ts": "typescript", ".tsx": "typescriptreact", ".js": "javascript", ".jsx": "javascriptreact",
+	".mjs": "javascript", ".cjs": "javascript", ".rs": "rust", ".c": "c", ".cpp": "cpp", ".h": "c",
+}
+
+// Manager starts the language servers of the workspace the first time a file
+// of their languages is checked, and keeps them running until Close.
+type Manager struct {
+	root    string
+	servers []ServerConfig
+
+	mu      sync.Mutex
+	clients map[string]*Client
+	failed  map[string]error
+	checked map[string]bool // the servers that published diagnostics before
+}
+
+// ServersFromSettings merges the configured servers into the defaults. A
+// configured server replaces the default of the same name, and servers can be
+// disabled by name.
+func ServersFromSettings(s configs.LSPSettings) []ServerConfig {
+	if s.Disabled {
+		return nil
+	}
+	servers := []ServerConfig{}
+	for _, def := range DefaultServers {
+		if _, ok := s.Servers[def.Name]; !ok {
+			servers = append(servers, def)
+		}
+	}
+	names := make([]string, 0, len(s.Servers))
+	for name := range s.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cfg := s.Servers[name]
+		if cfg.Disabled {
+			continue
+		}
+		servers = append(servers, ServerConfig{Name: name, Command: cfg.Command, Extensions: cfg.Extensions})
+	}
+	return servers
+}
+
+func NewManager(root string, servers []ServerConfig) *Manager {
+	return &Manager{
+		root:    root,
+		servers: servers,
+		clients: map[string]*Client{},
+		failed:  map[string]error{},
+		checked: map[string]bool{},
+	}
+}
+
+// Handles reports whether a server is configured for the file.
+func (m *Manager) Handles(path string) bool {
+	return m.serverFor(path) != nil
+}
+
+// Known returns the diagnostics last published for the file, without syncing
+// it or starting any server. It reports false when the file was never sent to
+// a running server, so nothing is known about it.
+func (m *Manager) Known(path string) ([]Diagnostic, bool) {
+	server := m.serverFor(path)
+	if server == nil {
+		return nil, false
+	}
+	m.mu.Lock()
+	c := m.clients[server.Name]
+	m.mu.Unlock()
+	if c == nil || !c.IsOpen(path) {
+		return nil, false
+	}
+	return c.Diagnostics(path), true
+}
+
+// Check sends the current content of the files to their servers and waits
+// for the diagnostics. Files no server handles are left out of the result,
+// the servers that could not be started are reported in the error.
+func (m *Manager) Check(ctx context.Context, paths ...string) (map[string][]Diagnostic, error) {
+	type pending struct {
+		client *Client
+		path   string
+		seen   int
+	}
+	var (
+		waits []pending
+		errs  []string
+	)
+	result := map[string][]Diagnostic{}
+	for _, p := range paths {
+		server := m.serverFor(p)
+		if server == nil {
+			continue
+		}
+		c, err := m.client(ctx, server)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			// The file was deleted or moved away.
+			c.CloseDocument(p)
+			continue
+		}
+		seen, err := c.Sync(p, languageID(p), string(data))
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		waits = append(waits, pending{client: c, path: p, seen: seen})
+	}
+
+	for _, w := range waits {
+		timeout := diagnosticsTimeout
+		m.mu.Lock()
+		if !m.checked[w.client.name] {
+			timeout = firstDiagnosticsTimeout
+		}
+		m.mu.Unlock()
+		result[w.path] = w.client.WaitForDiagnostics(w.path, w.seen, timeout, diagnosticsQuietPeriod)
+		m.mu.Lock()
+		m.checked[w.client.name] = true
+		m.mu.Unlock()
+	}
+	if len(errs) > 0 {
+		return result, fmt.Errorf("%s", strings.Join(slices.Compact(errs), "; "))
+	}
+	return result, nil
+}
+
+// All returns the diagnostics the running servers published for any file.
+func (m *Manager) All() map[string][]Diagnostic {
+	m.mu.Lock()
+	clients := make([]*Client, 0, len(m.clients))
+	for _, c := range m.clients {
+		clients = append(clients, c)
+	}
+	m.mu.Unlock()
+
+	all := map[string][]Diagnostic{}
+	for _, c := range clients {
+		for p, diags := range c.AllDiagnostics() {
+			all[p] = append(all[p], diags...)
+		}
+	}
+	return all
+}
+
+// Running returns the names of the servers that were started.
+func (m *Manager) Running() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.clients))
+	for name := range m.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close shuts every server down.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	clients := m.clients
+	m.clients = map[string]*Client{}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+func (m *Manager) serverFor(path string) *ServerConfig {
+	ext := strings.ToLower(filepath.Ext(path))
+	for i := range m.servers {
+		if slices.Contains(m.servers[i].Extensions, ext) {
+			return &m.servers[i]
+		}
+	}
+	return nil
+}
+
+// client returns the running client of the server, starting it if needed.
+// A server that failed to start is not retried.
+func (m *Manager) client(ctx context.Context, server *ServerConfig) (*Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c := m.clients[server.Name]; c != nil && c.exitErr() == nil {
+		return c, nil
+	}
+	if err := m.failed[server.Name]; err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+	c, err := Start(ctx, server.Name, server.Command, m.root)
+	if err != nil {
+		err = fmt.Errorf("the %s language server is not available: %w", server.Name, err)
+		m.failed[server.Name] = err
+		return nil, err
+	}
+	m.clients[server.Name] = c
+	delete(m.checked, server.Name)
+	return c, nil
+}
+
+func languageID(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if id, ok := languageIDs[ext]; ok {
+		return id
+	}
+	return strings.TrimPrefix(ext, ".")
+}
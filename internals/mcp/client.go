@@ -0,0 +1,284 @@
+// Package mcp is a client of the Model Context Protocol. It connects to the
+// configured servers over stdio or streamable HTTP and discovers their tools,
+// resources and prompts, which the agent's tools package offers to the model.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+)
+
+// Client is a connection to one MCP server.
+type Client struct {
+	name      string
+	transport transport
+	info      initializeResult
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]chan message
+	done    chan struct{}
+	err     error
+	// toolsChanged is called when the server says its list of tools changed.
+	toolsChanged func()
+}
+
+// Connect starts or reaches the server and runs the initialize handshake.
+// Stdio servers run in dir.
+func Connect(ctx context.Context, cfg ServerConfig, dir string) (*Client, error) {
+	c := &Client{
+		name:    cfg.Name,
+		pending: map[string]chan message{},
+		done:    make(chan struct{}),
+	}
+	switch {
+	case cfg.URL != "":
+		c.transport = newHTTPTransport(cfg, c.receive)
+	case len(cfg.Command) > 0:
+		t, err := startStdio(cfg, dir, c.receive, c.closed)
+		if err != nil {
+			return nil, err
+		}
+		c.transport = t
+	default:
+		return nil, fmt.Errorf("the %s server has neither a command nor a URL", cfg.Name)
+	}
+
+	params := map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "cli-agent", "version": "0.1.0"},
+	}
+	if err := c.Call(ctx, "initialize", params, &c.info); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("unable to initialize: %w", err)
+	}
+	if err := c.Notify(ctx, "notifications/initialized", nil); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Instructions are what the server says about using its tools, if anything.
+func (c *Client) Instructions() string {
+	return c.info.Instructions
+}
+
+// Call sends a request and waits for its response, which is decoded into
+// result unless result is nil.
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	ch := make(chan message, 1)
+	c.pending[string(id)] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+	}()
+	if params == nil {
+		params = map[string]any{}
+	}
+	if err := c.transport.send(ctx, message{JSONRPC: "2.0", ID: &id, Method: method, Params: mustMarshal(params)}); err != nil {
+		if ctx.Err() != nil {
+			c.cancel(ctx, id)
+			return ctx.Err()
+		}
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil && len(resp.Result) > 0 {
+			return json.Unmarshal(resp.Result, result)
+		}
+		return nil
+	case <-c.done:
+		return c.exitErr()
+	case <-ctx.Done():
+		c.cancel(ctx, id)
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification, which has no response.
+func (c *Client) Notify(ctx context.Context, method string, params any) error {
+	return c.transport.send(ctx, message{JSONRPC: "2.0", Method: method, Params: mustMarshal(params)})
+}
+
+// cancel tells the server to stop working on a request that timed out.
+func (c *Client) cancel(ctx context.Context, id json.RawMessage) {
+	reason := "timed out"
+	if errors.Is(ctx.Err(), context.Canceled) {
+		reason = "cancelled by the user"
+	}
+	err := c.Notify(context.Background(), "notifications/cancelled", map[string]any{"requestId": id, "reason": reason})
+	if err != nil {
+		log.Println("ERROR: Failed to cancel the request to the MCP server", c.name, err)
+	}
+}
+
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	if c.info.Capabilities.Tools == nil {
+		return nil, nil
+	}
+	return listAll[Tool](ctx, c, "tools/list", "tools")
+}
+
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	if c.info.Capabilities.Resources == nil {
+		return nil, nil
+	}
+	return listAll[Resource](ctx, c, "resources/list", "resources")
+}
+
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	if c.info.Capabilities.Prompts == nil {
+		return nil, nil
+	}
+	return listAll[Prompt](ctx, c, "prompts/list", "prompts")
+}
+
+// CallTool runs a tool of the server. The errors of the tool itself are
+// reported in the result with IsError set, the error is for the failures of
+// the connection and of the protocol.
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, error) {
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	var result CallToolResult
+	if err := c.Call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	var result struct {
+		Contents []ResourceContents `json:"contents"`
+	}
+	if err := c.Call(ctx, "resources/read", map[string]any{"uri": uri}, &result); err != nil {
+		return nil, err
+	}
+	return result.Contents, nil
+}
+
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	var result GetPromptResult
+	if err := c.Call(ctx, "prompts/get", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// OnToolsChanged sets the function called when the server's tools change.
+func (c *Client) OnToolsChanged(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.toolsChanged = f
+}
+
+// Done is closed when the connection to the server is lost.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) Close() {
+	c.transport.close()
+	c.closed(fmt.Errorf("the connection to the %s server was closed", c.name))
+}
+
+func (c *Client) receive(msg message) {
+	switch {
+	case msg.Method == "" && msg.ID != nil:
+		c.mu.Lock()
+		ch := c.pending[string(*msg.ID)]
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- msg
+		}
+	case msg.Method != "" && msg.ID != nil:
+		go c.answer(msg)
+	case msg.Method == "notifications/tools/list_changed":
+		c.mu.Lock()
+		f := c.toolsChanged
+		c.mu.Unlock()
+		if f != nil {
+			go f()
+		}
+	}
+}
+
+// answer responds to the requests servers send to the client. Only ping is
+// supported, the client declares no capabilities for the others.
+func (c *Client) answer(req message) {
+	resp := message{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &responseError{Code: -32601, Message: "method not supported: " + req.Method}
+	}
+	if err := c.transport.send(context.Background(), resp); err != nil {
+		log.Println("ERROR: Failed to answer the MCP server", c.name, err)
+	}
+}
+
+func (c *Client) closed(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+}
+
+func (c *Client) exitErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// listAll follows the cursors of a paginated list until its last page.
+func listAll[T any](ctx context.Context, c *Client, method, key string) ([]T, error) {
+	all := []T{}
+	params := map[string]any{}
+	for range 100 {
+		var page map[string]json.RawMessage
+		if err := c.Call(ctx, method, params, &page); err != nil {
+			return nil, err
+		}
+		var items []T
+		if data, ok := page[key]; ok {
+			if err := json.Unmarshal(data, &items); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", method, err)
+			}
+		}
+		all = append(all, items...)
+		var next string
+		if data, ok := page["nextCursor"]; ok {
+			json.Unmarshal(data, &next)
+		}
+		if next == "" {
+			return all, nil
+		}
+		params = map[string]any{"cursor": next}
+	}
+	return all, fmt.Errorf("%s returned more than 100 pages", method)
+}
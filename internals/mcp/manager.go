@@ -0,0 +1,297 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+const (
+	connectTimeout     = 30 * time.Second
+	defaultCallTimeout = 60 * time.Second
+)
+
+type ServerConfig struct {
+	Name    string
+	Command []string
+	Env     map[string]string
+	URL     string
+	Headers map[string]string
+	// Timeout limits every call to the server's tools.
+	Timeout time.Duration
+}
+
+// ServersFromSettings returns the enabled servers of the settings, by name.
+func ServersFromSettings(s configs.MCPSettings) []ServerConfig {
+	names := make([]string, 0, len(s.Servers))
+	for name := range s.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	servers := []ServerConfig{}
+	for _, name := range names {
+		cfg := s.Servers[name]
+		if cfg.Disabled {
+			continue
+		}
+		timeout := defaultCallTimeout
+		if cfg.Timeout > 0 {
+			timeout = time.Duration(cfg.Timeout) * time.Second
+		}
+		servers = append(servers, ServerConfig{
+			Name:    name,
+			Command: cfg.Command,
+			Env:     cfg.Env,
+			URL:     cfg.URL,
+			Headers: cfg.Headers,
+			Timeout: timeout,
+		})
+	}
+	return servers
+}
+
+type State string
+
+const (
+	StateConnecting State = "connecting"
+	StateReady      State = "ready"
+	StateFailed     State = "failed"
+)
+
+// ServerStatus is what is known of a server: whether it is connected and
+// what it offers.
+type ServerStatus struct {
+	Name         string
+	State        State
+	Err          error
+	Instructions string
+	Tools        []Tool
+	Resources    []Resource
+	Prompts      []Prompt
+}
+
+// Manager connects to the configured servers and keeps the connections until
+// Close.
+type Manager struct {
+	root    string
+	servers []ServerConfig
+
+	mu       sync.Mutex
+	clients  map[string]*Client
+	status   map[string]*ServerStatus
+	onChange func()
+	closed   bool
+}
+
+func NewManager(root string, servers []ServerConfig) *Manager {
+	m := &Manager{
+		root:    root,
+		servers: servers,
+		clients: map[string]*Client{},
+		status:  map[string]*ServerStatus{},
+	}
+	for _, s := range servers {
+		m.status[s.Name] = &ServerStatus{Name: s.Name, State: StateConnecting}
+	}
+	return m
+}
+
+// Start connects to the servers in the background. onChange is called
+// whenever the status of a server changes, e.g. when its tools are known.
+func (m *Manager) Start(onChange func()) {
+	m.mu.Lock()
+	m.onChange = onChange
+	m.mu.Unlock()
+	for _, s := range m.servers {
+		go m.connect(s)
+	}
+}
+
+// Wait blocks until every server is connected or failed, or the context is
+// done.
+func (m *Manager) Wait(ctx context.Context) error {
+	for {
+		connecting := false
+		for _, s := range m.Status() {
+			connecting = connecting || s.State == StateConnecting
+		}
+		if !connecting {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+// Status returns the status of every server, sorted by name.
+func (m *Manager) Status() []ServerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	all := make([]ServerStatus, 0, len(m.status))
+	for _, s := range m.status {
+		all = append(all, *s)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// CallTool runs a tool of a server within the server's timeout.
+func (m *Manager) CallTool(ctx context.Context, server, tool string, args json.RawMessage) (*CallToolResult, error) {
+	c, cfg, err := m.client(server)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	result, err := c.CallTool(ctx, tool, args)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("the tool did not finish within %s", cfg.Timeout)
+	}
+	return result, err
+}
+
+func (m *Manager) ReadResource(ctx context.Context, server, uri string) ([]ResourceContents, error) {
+	c, cfg, err := m.client(server)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	return c.ReadResource(ctx, uri)
+}
+
+func (m *Manager) GetPrompt(ctx context.Context, server, name string, args map[string]string) (*GetPromptResult, error) {
+	c, cfg, err := m.client(server)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	return c.GetPrompt(ctx, name, args)
+}
+
+// Close disconnects from every server.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	clients := m.clients
+	m.clients = map[string]*Client{}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+func (m *Manager) client(server string) (*Client, ServerConfig, error) {
+	var cfg ServerConfig
+	for _, s := range m.servers {
+		if s.Name == server {
+			cfg = s
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.clients[server]
+	if c == nil {
+		status := m.status[server]
+		switch {
+		case status == nil:
+			return nil, cfg, fmt.Errorf("there is no MCP server named %q", server)
+		case status.State == StateConnecting:
+			return nil, cfg, fmt.Errorf("the %s MCP server is still starting, try again in a moment", server)
+		default:
+			return nil, cfg, fmt.Errorf("the %s MCP server is not available: %v", server, status.Err)
+		}
+	}
+	return c, cfg, nil
+}
+
+func (m *Manager) connect(cfg ServerConfig) {
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	c, err := Connect(ctx, cfg, m.root)
+	if err != nil {
+		log.Println("ERROR: Failed to connect to the MCP server", cfg.Name, err)
+		m.update(cfg.Name, func(s *ServerStatus) { s.State, s.Err = StateFailed, err })
+		return
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		c.Close()
+		return
+	}
+	m.clients[cfg.Name] = c
+	m.mu.Unlock()
+
+	c.OnToolsChanged(func() { m.refresh(cfg.Name, c) })
+	m.refresh(cfg.Name, c)
+
+	<-c.Done()
+	m.mu.Lock()
+	closing := m.closed
+	if m.clients[cfg.Name] == c {
+		delete(m.clients, cfg.Name)
+	}
+	m.mu.Unlock()
+	if !closing {
+		log.Println("ERROR: Lost the connection to the MCP server", cfg.Name, c.exitErr())
+		m.update(cfg.Name, func(s *ServerStatus) {
+			s.State, s.Err = StateFailed, c.exitErr()
+			s.Tools, s.Resources, s.Prompts = nil, nil, nil
+		})
+	}
+}
+
+// refresh lists what the server offers.
+func (m *Manager) refresh(name string, c *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	tools, err := c.ListTools(ctx)
+	var resources []Resource
+	var prompts []Prompt
+	if err == nil {
+		resources, err = c.ListResources(ctx)
+	}
+	if err == nil {
+		prompts, err = c.ListPrompts(ctx)
+	}
+	if err != nil {
+		log.Println("ERROR: Failed to list the offers of the MCP server", name, err)
+		m.update(name, func(s *ServerStatus) { s.State, s.Err = StateFailed, err })
+		return
+	}
+	m.update(name, func(s *ServerStatus) {
+		s.State, s.Err = StateReady, nil
+		s.Instructions = c.Instructions()
+		s.Tools, s.Resources, s.Prompts = tools, resources, prompts
+	})
+}
+
+func (m *Manager) update(name string, change func(*ServerStatus)) {
+	m.mu.Lock()
+	change(m.status[name])
+	onChange := m.onChange
+	m.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
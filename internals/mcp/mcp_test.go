@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/mcp/mcptest"
+)
+
+func TestMain(m *testing.M) {
+	mcptest.ServeIfRequested()
+	os.Exit(m.Run())
+}
+
+func TestManager(t *testing.T) {
+	srv := httptest.NewServer(mcptest.Handler())
+	t.Cleanup(srv.Close)
+
+	for _, cfg := range []ServerConfig{
+		{Name: "stdio", Command: mcptest.Command(), Timeout: 500 * time.Millisecond},
+		{Name: "http", URL: srv.URL, Timeout: 500 * time.Millisecond},
+	} {
+		t.Run(cfg.Name, func(t *testing.T) {
+			m := NewManager(t.TempDir(), []ServerConfig{cfg})
+			t.Cleanup(m.Close)
+			changes := make(chan struct{}, 10)
+			m.Start(func() { changes <- struct{}{} })
+			ctx := context.Background()
+			if err := m.Wait(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			status := m.Status()[0]
+			if status.State != StateReady || status.Instructions != "Use echo to test." {
+				t.Fatalf("unexpected status %+v", status)
+			}
+			if len(status.Tools) != 5 || status.Tools[0].Name != "echo" || status.Tools[4].Name != "grow" {
+				t.Fatalf("expected both pages of tools, got %+v", status.Tools)
+			}
+			if len(status.Resources) != 1 || len(status.Prompts) != 1 || status.Prompts[0].Arguments[0].Name != "name" {
+				t.Fatalf("unexpected resources %+v and prompts %+v", status.Resources, status.Prompts)
+			}
+
+			result, err := m.CallTool(ctx, cfg.Name, "add", json.RawMessage(`{"a": 2, "b": 3}`))
+			if err != nil || result.IsError || result.Content[0].Text != "5" || string(result.StructuredContent) != `{"sum":5}` {
+				t.Fatalf("unexpected result %+v, %v", result, err)
+			}
+			if result, err := m.CallTool(ctx, cfg.Name, "fail", nil); err != nil || !result.IsError {
+				t.Fatalf("expected a tool error, got %+v, %v", result, err)
+			}
+			if _, err := m.CallTool(ctx, cfg.Name, "slow", json.RawMessage(`{"ms": 1000}`)); err == nil || !strings.Contains(err.Error(), "did not finish within 500ms") {
+				t.Fatalf("expected the call to time out, got %v", err)
+			}
+			if _, err := m.CallTool(ctx, "missing", "echo", nil); err == nil {
+				t.Fatal("expected an error for an unknown server")
+			}
+
+			contents, err := m.ReadResource(ctx, cfg.Name, "test://readme")
+			if err != nil || len(contents) != 1 || contents[0].Text != "# Read me" {
+				t.Fatalf("unexpected resource %+v, %v", contents, err)
+			}
+			if _, err := m.ReadResource(ctx, cfg.Name, "test://missing"); err == nil || !strings.Contains(err.Error(), "resource not found") {
+				t.Fatalf("expected the server's error, got %v", err)
+			}
+			prompt, err := m.GetPrompt(ctx, cfg.Name, "greet", map[string]string{"name": "Ada"})
+			if err != nil || prompt.Messages[0].Content.Text != "Hello Ada" {
+				t.Fatalf("unexpected prompt %+v, %v", prompt, err)
+			}
+
+			// The tools are listed again when the server says they changed.
+			for len(changes) > 0 {
+				<-changes
+			}
+			if _, err := m.CallTool(ctx, cfg.Name, "grow", nil); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-changes:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the tools were not refreshed")
+			}
+			if tools := m.Status()[0].Tools; len(tools) != 6 || tools[5].Name != "extra" {
+				t.Fatalf("expected the extra tool, got %+v", tools)
+			}
+		})
+	}
+}
+
+func TestManagerFailedServer(t *testing.T) {
+	m := NewManager(t.TempDir(), []ServerConfig{{Name: "broken", Command: []string{"cli-agent-no-such-command"}}})
+	t.Cleanup(m.Close)
+	m.Start(nil)
+	m.Wait(context.Background())
+	status := m.Status()[0]
+	if status.State != StateFailed || status.Err == nil {
+		t.Fatalf("expected the server to fail, got %+v", status)
+	}
+	if _, err := m.CallTool(context.Background(), "broken", "echo", nil); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestServersFromSettings(t *testing.T) {
+	servers := ServersFromSettings(configs.MCPSettings{Servers: map[string]configs.MCPServerSettings{
+		"b":   {URL: "http://localhost:1/mcp", Timeout: 5},
+		"a":   {Command: []string{"server"}},
+		"off": {Command: []string{"server"}, Disabled: true},
+	}})
+	if len(servers) != 2 || servers[0].Name != "a" || servers[1].Name != "b" {
+		t.Fatalf("unexpected servers %+v", servers)
+	}
+	if servers[0].Timeout != defaultCallTimeout || servers[1].Timeout != 5*time.Second {
+		t.Fatalf("unexpected timeouts %v, %v", servers[0].Timeout, servers[1].Timeout)
+	}
+}
@@ -0,0 +1,244 @@
+// Package mcptest provides a minimal MCP server for tests, over stdio and
+// over streamable HTTP. Its tools are echo, add, fail (which reports a tool
+// error), slow (which sleeps for ms milliseconds) and grow (which adds the
+// extra tool and announces the change). It has the readme resource and the
+// greet prompt.
+package mcptest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// EnvVar makes a test binary run the server instead of its tests, see
+// ServeIfRequested.
+const EnvVar = "CLI_AGENT_FAKE_MCP"
+
+// ServeIfRequested serves on stdin and stdout and exits when the test binary
+// was started as the fake server. Call it from TestMain.
+func ServeIfRequested() {
+	if os.Getenv(EnvVar) == "" {
+		return
+	}
+	Serve(os.Stdin, os.Stdout)
+	os.Exit(0)
+}
+
+// Command returns the command that starts the running test binary as the
+// fake server, it needs ServeIfRequested in TestMain.
+func Command() []string {
+	return []string{"env", EnvVar + "=1", os.Args[0]}
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   any             `json:"error,omitempty"`
+}
+
+type server struct {
+	mu    sync.Mutex
+	grown bool
+}
+
+// Serve answers the newline delimited messages read from r until r ends.
+func Serve(r io.Reader, w io.Writer) {
+	s := &server{}
+	var writeMu sync.Mutex
+	send := func(msg message) {
+		msg.JSONRPC = "2.0"
+		body, _ := json.Marshal(msg)
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		w.Write(append(body, '\n'))
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var msg message
+		if json.Unmarshal(scanner.Bytes(), &msg) != nil || msg.Method == "" {
+			continue
+		}
+		// Answer concurrently like real servers, so a slow tool doesn't hold
+		// back the other requests.
+		go func() {
+			if resp := s.handle(msg, send); resp != nil {
+				send(*resp)
+			}
+		}()
+	}
+}
+
+// Handler serves the streamable HTTP transport. The tool calls are answered
+// with an event stream, the other requests with JSON.
+func Handler() http.Handler {
+	s := &server{}
+	const sessionID = "test-session"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusOK)
+			return
+		case http.MethodPost:
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var msg message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if msg.Method != "initialize" && r.Header.Get("Mcp-Session-Id") != sessionID {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Mcp-Session-Id", sessionID)
+		if msg.Method == "" || msg.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		if msg.Method != "tools/call" {
+			resp := s.handle(msg, func(message) {})
+			resp.JSONRPC = "2.0"
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		var mu sync.Mutex
+		send := func(msg message) {
+			msg.JSONRPC = "2.0"
+			body, _ := json.Marshal(msg)
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", body)
+			w.(http.Flusher).Flush()
+		}
+		if resp := s.handle(msg, send); resp != nil {
+			send(*resp)
+		}
+	})
+}
+
+// handle returns the response to a request, or nil for notifications.
+// Notifications of the server are sent with notify.
+func (s *server) handle(msg message, notify func(message)) *message {
+	if msg.ID == nil {
+		return nil
+	}
+	resp := &message{ID: msg.ID}
+	switch msg.Method {
+	case "initialize":
+		resp.Result = map[string]any{
+			"protocolVersion": "2025-06-18",
+			"capabilities":    map[string]any{"tools": map[string]any{"listChanged": true}, "resources": map[string]any{}, "prompts": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "fake", "version": "1.0.0"},
+			"instructions":    "Use echo to test.",
+		}
+	case "ping":
+		resp.Result = map[string]any{}
+	case "tools/list":
+		var params struct {
+			Cursor string `json:"cursor"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		// Two pages, to exercise the pagination.
+		if params.Cursor == "" {
+			resp.Result = map[string]any{"tools": s.tools()[:2], "nextCursor": "page2"}
+		} else {
+			resp.Result = map[string]any{"tools": s.tools()[2:]}
+		}
+	case "tools/call":
+		var params struct {
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		resp.Result = s.call(params.Name, params.Arguments, notify)
+	case "resources/list":
+		resp.Result = map[string]any{"resources": []map[string]any{
+			{"uri": "test://readme", "name": "readme", "description": "The read me.", "mimeType": "text/markdown"},
+		}}
+	case "resources/read":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		if params.URI != "test://readme" {
+			resp.Error = map[string]any{"code": -32002, "message": "resource not found"}
+			break
+		}
+		resp.Result = map[string]any{"contents": []map[string]any{{"uri": params.URI, "mimeType": "text/markdown", "text": "# Read me"}}}
+	case "prompts/list":
+		resp.Result = map[string]any{"prompts": []map[string]any{
+			{"name": "greet", "description": "Greets someone.", "arguments": []map[string]any{{"name": "name", "required": true}}},
+		}}
+	case "prompts/get":
+		var params struct {
+			Arguments map[string]string `json:"arguments"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		resp.Result = map[string]any{"messages": []map[string]any{
+			{"role": "user", "content": map[string]any{"type": "text", "text": "Hello " + params.Arguments["name"]}},
+		}}
+	default:
+		resp.Error = map[string]any{"code": -32601, "message": "method not found"}
+	}
+	return resp
+}
+
+func (s *server) tools() []map[string]any {
+	object := func(props map[string]any) map[string]any {
+		return map[string]any{"type": "object", "properties": props}
+	}
+	tools := []map[string]any{
+		{"name": "echo", "description": "Echoes the text.", "inputSchema": object(map[string]any{"text": map[string]any{"type": "string"}})},
+		{"name": "add", "description": "Adds two numbers.", "inputSchema": object(map[string]any{"a": map[string]any{"type": "number"}, "b": map[string]any{"type": "number"}})},
+		{"name": "fail", "description": "Always fails.", "inputSchema": object(map[string]any{})},
+		{"name": "slow", "description": "Sleeps.", "inputSchema": object(map[string]any{"ms": map[string]any{"type": "integer"}})},
+		{"name": "grow", "description": "Adds the extra tool.", "inputSchema": map[string]any{}},
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.grown {
+		tools = append(tools, map[string]any{"name": "extra", "inputSchema": object(map[string]any{})})
+	}
+	return tools
+}
+
+func (s *server) call(name string, args map[string]any, notify func(message)) map[string]any {
+	text := func(t string) []map[string]any { return []map[string]any{{"type": "text", "text": t}} }
+	switch name {
+	case "echo":
+		return map[string]any{"content": text(fmt.Sprint(args["text"]))}
+	case "add":
+		a, _ := args["a"].(float64)
+		b, _ := args["b"].(float64)
+		return map[string]any{"content": text(fmt.Sprint(a + b)), "structuredContent": map[string]any{"sum": a + b}}
+	case "fail":
+		return map[string]any{"content": text("something broke"), "isError": true}
+	case "slow":
+		ms, _ := args["ms"].(float64)
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+		return map[string]any{"content": text("done")}
+	case "grow":
+		s.mu.Lock()
+		s.grown = true
+		s.mu.Unlock()
+		notify(message{Method: "notifications/tools/list_changed"})
+		return map[string]any{"content": text("grown")}
+	case "extra":
+		return map[string]any{"content": text("extra")}
+	}
+	return map[string]any{"content": text("unknown tool " + name), "isError": true}
+}
@@ -0,0 +1,296 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// supportedVersions are the protocol versions the server speaks, the newest
+// first.
+var supportedVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// Server serves tools to an MCP client over newline delimited JSON, e.g. on
+// stdin and stdout. The tool calls run one at a time, in the order they were
+// received.
+type Server struct {
+	Name         string
+	Version      string
+	Instructions string
+	// Tools lists the tools the client may call.
+	Tools func() []Tool
+	// CallTool runs a tool. The failures of the tool belong in the result,
+	// with IsError set, the error is reported as a protocol error, e.g. for
+	// an unknown tool.
+	CallTool func(ctx context.Context, session *ServerSession, name string, args json.RawMessage) (*CallToolResult, error)
+}
+
+// ServerSession is the connection to a client, the tools use it to ask the
+// client's user for input.
+type ServerSession struct {
+	server *Server
+	w      io.Writer
+	// done is closed once the client stops sending.
+	done chan struct{}
+
+	writeMu sync.Mutex
+
+	mu           sync.Mutex
+	nextID       int
+	pending      map[string]chan message
+	capabilities map[string]json.RawMessage
+	running      string // the id of the running tool call
+	cancel       context.CancelFunc
+}
+
+// ElicitResult is the answer of the user to an elicitation: the action is
+// "accept", "decline" or "cancel", the content is what was entered when
+// accepted.
+type ElicitResult struct {
+	Action  string         `json:"action"`
+	Content map[string]any `json:"content,omitempty"`
+}
+
+// Serve answers the requests read from r until r ends or ctx is done. r is
+// closed when ctx is done if it is an io.Closer, so its reader stops too.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	ss := &ServerSession{server: s, w: w, done: make(chan struct{}), pending: map[string]chan message{}}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	calls := make(chan message)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for call := range calls {
+			ss.runTool(ctx, call)
+		}
+	}()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 32<<20)
+		for scanner.Scan() {
+			select {
+			case lines <- append([]byte(nil), scanner.Bytes()...):
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	// The calls wait in queue while one runs, so the responses to its
+	// elicitations are still read.
+	var (
+		queue []message
+		err   error
+	)
+loop:
+	for {
+		var (
+			next  chan message
+			first message
+		)
+		if len(queue) > 0 {
+			next, first = calls, queue[0]
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			queue = nil
+			if c, ok := r.(io.Closer); ok {
+				c.Close()
+			}
+			break loop
+		case err = <-readErr:
+			// The calls received so far still run, the client may have
+			// closed its end right after sending them.
+			break loop
+		case next <- first:
+			queue = queue[1:]
+		case line := <-lines:
+			var msg message
+			if json.Unmarshal(line, &msg) != nil {
+				continue
+			}
+			if msg.Method == "tools/call" && msg.ID != nil {
+				queue = append(queue, msg)
+				continue
+			}
+			ss.handle(msg)
+		}
+	}
+	close(ss.done)
+	for _, call := range queue {
+		calls <- call
+	}
+	close(calls)
+	wg.Wait()
+	return err
+}
+
+// CanElicit reports whether the client can ask its user for input.
+func (ss *ServerSession) CanElicit() bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	_, ok := ss.capabilities["elicitation"]
+	return ok
+}
+
+// Elicit asks the client's user for the input the schema describes, a flat
+// object of strings, numbers, booleans and enums.
+func (ss *ServerSession) Elicit(ctx context.Context, text string, schema map[string]any) (*ElicitResult, error) {
+	ss.mu.Lock()
+	ss.nextID++
+	id := json.RawMessage(strconv.Quote("elicit-" + strconv.Itoa(ss.nextID)))
+	ch := make(chan message, 1)
+	ss.pending[string(id)] = ch
+	ss.mu.Unlock()
+	defer func() {
+		ss.mu.Lock()
+		delete(ss.pending, string(id))
+		ss.mu.Unlock()
+	}()
+
+	params := map[string]any{"message": text, "requestedSchema": schema}
+	if err := ss.write(message{ID: &id, Method: "elicitation/create", Params: mustMarshal(params)}); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		var result ElicitResult
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			return nil, fmt.Errorf("invalid elicitation result: %w", err)
+		}
+		return &result, nil
+	case <-ss.done:
+		return nil, errors.New("the client disconnected")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (ss *ServerSession) handle(msg message) {
+	switch {
+	case msg.Method == "" && msg.ID != nil:
+		ss.mu.Lock()
+		ch := ss.pending[string(*msg.ID)]
+		ss.mu.Unlock()
+		if ch != nil {
+			// A second response to the request is dropped.
+			select {
+			case ch <- msg:
+			default:
+			}
+		}
+	case msg.ID == nil:
+		if msg.Method == "notifications/cancelled" {
+			var params struct {
+				RequestID json.RawMessage `json:"requestId"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			ss.mu.Lock()
+			if ss.running != "" && ss.running == string(params.RequestID) {
+				ss.cancel()
+			}
+			ss.mu.Unlock()
+		}
+	default:
+		resp := message{ID: msg.ID}
+		switch msg.Method {
+		case "initialize":
+			resp.Result = ss.initialize(msg.Params)
+		case "ping":
+			resp.Result = json.RawMessage("{}")
+		case "tools/list":
+			resp.Result = mustMarshal(map[string]any{"tools": ss.server.Tools()})
+		default:
+			resp.Error = &responseError{Code: -32601, Message: "method not found: " + msg.Method}
+		}
+		ss.write(resp)
+	}
+}
+
+func (ss *ServerSession) initialize(params json.RawMessage) json.RawMessage {
+	var req struct {
+		ProtocolVersion string                     `json:"protocolVersion"`
+		Capabilities    map[string]json.RawMessage `json:"capabilities"`
+	}
+	json.Unmarshal(params, &req)
+	ss.mu.Lock()
+	ss.capabilities = req.Capabilities
+	ss.mu.Unlock()
+
+	version := ProtocolVersion
+	if slices.Contains(supportedVersions, req.ProtocolVersion) {
+		version = req.ProtocolVersion
+	}
+	result := map[string]any{
+		"protocolVersion": version,
+		"capabilities":    map[string]any{"tools": map[string]any{}},
+		"serverInfo":      map[string]any{"name": ss.server.Name, "version": ss.server.Version},
+	}
+	if ss.server.Instructions != "" {
+		result["instructions"] = ss.server.Instructions
+	}
+	return mustMarshal(result)
+}
+
+func (ss *ServerSession) runTool(ctx context.Context, req message) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	resp := message{ID: req.ID}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.Name == "" {
+		resp.Error = &responseError{Code: -32602, Message: "invalid params: the tool name is missing"}
+		ss.write(resp)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ss.mu.Lock()
+	ss.running, ss.cancel = string(*req.ID), cancel
+	ss.mu.Unlock()
+	result, err := ss.server.CallTool(ctx, ss, params.Name, params.Arguments)
+	ss.mu.Lock()
+	ss.running, ss.cancel = "", nil
+	ss.mu.Unlock()
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// The client gave up on the call, it expects no response.
+		return
+	}
+	if err != nil {
+		resp.Error = &responseError{Code: -32602, Message: err.Error()}
+	} else {
+		resp.Result = mustMarshal(result)
+	}
+	ss.write(resp)
+}
+
+func (ss *ServerSession) write(msg message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ss.writeMu.Lock()
+	defer ss.writeMu.Unlock()
+	_, err = ss.w.Write(append(body, '\n'))
+	return err
+}
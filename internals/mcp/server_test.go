@@ -0,0 +1,186 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveForTest runs the server on pipes and returns a function sending a
+// line to it and one reading the next message it writes.
+func serveForTest(t *testing.T, s *Server) (func(string), func() map[string]any) {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(context.Background(), inR, outW)
+		outW.Close()
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("the server did not stop")
+		}
+	})
+
+	lines := bufio.NewScanner(outR)
+	send := func(line string) {
+		t.Helper()
+		if _, err := io.WriteString(inW, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func() map[string]any {
+		t.Helper()
+		if !lines.Scan() {
+			t.Fatal("the server closed its output")
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(lines.Bytes(), &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	return send, read
+}
+
+func TestServer(t *testing.T) {
+	s := &Server{
+		Name:    "test",
+		Version: "1.0.0",
+		Tools: func() []Tool {
+			return []Tool{{Name: "shout", InputSchema: map[string]any{"type": "object"}}}
+		},
+		CallTool: func(ctx context.Context, session *ServerSession, name string, args json.RawMessage) (*CallToolResult, error) {
+			if name != "shout" {
+				return nil, fmt.Errorf("unknown tool %q", name)
+			}
+			var params struct {
+				Text string `json:"text"`
+			}
+			json.Unmarshal(args, &params)
+			if params.Text == "wait" {
+				<-ctx.Done()
+				return &CallToolResult{}, nil
+			}
+			if session.CanElicit() && params.Text != "quiet" {
+				answer, err := session.Elicit(ctx, "Shout "+params.Text+"?", map[string]any{"type": "object"})
+				if err != nil || answer.Action != "accept" {
+					return &CallToolResult{Content: []Content{{Type: "text", Text: "refused"}}, IsError: true}, nil
+				}
+			}
+			return &CallToolResult{Content: []Content{{Type: "text", Text: strings.ToUpper(params.Text)}}}, nil
+		},
+	}
+
+	t.Run("basic", func(t *testing.T) {
+		send, read := serveForTest(t, s)
+		send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{}}}`)
+		if msg := read(); msg["result"].(map[string]any)["protocolVersion"] != "2024-11-05" {
+			t.Fatalf("expected the client's version, got %v", msg)
+		}
+		send(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+		send(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+		if msg := read(); len(msg["result"].(map[string]any)["tools"].([]any)) != 1 {
+			t.Fatalf("unexpected tools %v", msg)
+		}
+		send(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"shout","arguments":{"text":"hi"}}}`)
+		if msg := read(); msg["id"] != 3.0 || !strings.Contains(fmt.Sprint(msg["result"]), "HI") {
+			t.Fatalf("unexpected result %v", msg)
+		}
+		send(`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"whisper"}}`)
+		if msg := read(); !strings.Contains(fmt.Sprint(msg["error"]), `unknown tool "whisper"`) {
+			t.Fatalf("expected an error, got %v", msg)
+		}
+		send(`{"jsonrpc":"2.0","id":5,"method":"resources/list"}`)
+		if msg := read(); msg["error"].(map[string]any)["code"] != -32601.0 {
+			t.Fatalf("expected the method to be unknown, got %v", msg)
+		}
+
+		// A cancelled call gets no response, the following ones do.
+		send(`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"shout","arguments":{"text":"wait"}}}`)
+		send(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":6}}`)
+		send(`{"jsonrpc":"2.0","id":7,"method":"ping"}`)
+		if msg := read(); msg["id"] != 7.0 {
+			t.Fatalf("expected the ping's response, got %v", msg)
+		}
+		send(`{"jsonrpc":"2.0","id":8,"method":"tools/call","params":{"name":"shout","arguments":{"text":"after"}}}`)
+		if msg := read(); msg["id"] != 8.0 {
+			t.Fatalf("expected the next call's response, got %v", msg)
+		}
+	})
+
+	t.Run("elicitation", func(t *testing.T) {
+		send, read := serveForTest(t, s)
+		send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{"elicitation":{}}}}`)
+		read()
+		for _, action := range []string{"accept", "decline"} {
+			send(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"shout","arguments":{"text":"hi"}}}`)
+			req := read()
+			if req["method"] != "elicitation/create" || req["params"].(map[string]any)["message"] != "Shout hi?" {
+				t.Fatalf("expected an elicitation, got %v", req)
+			}
+			id, _ := json.Marshal(req["id"])
+			send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"action":%q}}`, id, action))
+			want := map[string]string{"accept": "HI", "decline": "refused"}[action]
+			if msg := read(); !strings.Contains(fmt.Sprint(msg["result"]), want) {
+				t.Fatalf("expected %s after %s, got %v", want, action, msg)
+			}
+		}
+
+		// The calls queued behind an elicitation don't keep its answer from
+		// being read.
+		send(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"shout","arguments":{"text":"hi"}}}`)
+		req := read()
+		sent := make(chan struct{})
+		go func() {
+			defer close(sent)
+			for i := range 100 {
+				send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"shout","arguments":{"text":"quiet"}}}`, 100+i))
+			}
+			id, _ := json.Marshal(req["id"])
+			send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"action":"accept"}}`, id))
+		}()
+		select {
+		case <-sent:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the server stopped reading while the calls waited")
+		}
+		if msg := read(); msg["id"] != 3.0 || !strings.Contains(fmt.Sprint(msg["result"]), "HI") {
+			t.Fatalf("expected the elicited call's result, got %v", msg)
+		}
+		for i := range 100 {
+			if msg := read(); msg["id"] != float64(100+i) {
+				t.Fatalf("expected the queued calls' results in order, got %v", msg)
+			}
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		inR, inW := io.Pipe()
+		done := make(chan error, 1)
+		go func() { done <- s.Serve(ctx, inR, io.Discard) }()
+		cancel()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("unexpected error %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the server did not stop")
+		}
+		if _, err := inW.Write([]byte("{}\n")); !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("expected the input to be closed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transport carries the JSON-RPC messages of a client. The messages the
+// server sends are passed to receive, and closed is called once when the
+// connection is lost.
+type transport interface {
+	send(ctx context.Context, msg message) error
+	close() error
+}
+
+// stdioTransport runs the server as a child process and exchanges newline
+// delimited JSON messages over its stdin and stdout.
+type stdioTransport struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	done  chan struct{}
+
+	writeMu sync.Mutex
+}
+
+func startStdio(cfg ServerConfig, dir string, receive func(message), closed func(error)) (*stdioTransport, error) {
+	if len(cfg.Command) == 0 {
+		return nil, fmt.Errorf("no command configured")
+	}
+	path, err := exec.LookPath(cfg.Command[0])
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path, cfg.Command[1:]...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	// Servers log to stderr, which would garble the TUI.
+	cmd.Stderr = io.Discard
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	t := &stdioTransport{cmd: cmd, stdin: stdin, done: make(chan struct{})}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 32<<20)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var msg message
+			if json.Unmarshal(line, &msg) != nil {
+				// Skip the garbage rather than losing the connection.
+				continue
+			}
+			receive(msg)
+		}
+		err := errors.Join(errors.New("the server exited"), scanner.Err(), cmd.Wait())
+		close(t.done)
+		closed(err)
+	}()
+	return t, nil
+}
+
+func (t *stdioTransport) send(_ context.Context, msg message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(body, '\n'))
+	return err
+}
+
+// close ends the server's input, which is how stdio servers are told to
+// stop, and kills the ones that don't in time.
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(2 * time.Second):
+		t.cmd.Process.Kill()
+		<-t.done
+	}
+	return nil
+}
+
+// httpTransport talks to a server over the streamable HTTP transport: every
+// message is POSTed, and the server answers with JSON or with a stream of
+// server-sent events ending with the response. The optional GET stream for
+// the messages the server sends on its own is not opened.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	receive func(message)
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+func newHTTPTransport(cfg ServerConfig, receive func(message)) *httpTransport {
+	return &httpTransport{url: cfg.URL, headers: cfg.Headers, client: &http.Client{}, receive: receive}
+}
+
+func (t *httpTransport) send(ctx context.Context, msg message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.setHeaders(req)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		return nil
+	}
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("the server answered %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	switch strings.TrimSpace(mediaType) {
+	case "text/event-stream":
+		return readEvents(resp.Body, t.receive)
+	case "application/json":
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return decodeMessages(data, t.receive)
+	}
+	if msg.ID != nil && msg.Method != "" {
+		return fmt.Errorf("unexpected content type %q of the response", mediaType)
+	}
+	return nil
+}
+
+// close ends the session, servers may refuse that and expire it later.
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	id := t.sessionID
+	t.mu.Unlock()
+	if id == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	t.setHeaders(req)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (t *httpTransport) setHeaders(req *http.Request) {
+	req.Header.Set("MCP-Protocol-Version", ProtocolVersion)
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+}
+
+// readEvents passes the messages in the data of the server-sent events to
+// receive until the stream ends.
+func readEvents(r io.Reader, receive func(message)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 32<<20)
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 {
+			return nil
+		}
+		payload := strings.Join(data, "\n")
+		data = data[:0]
+		return decodeMessages([]byte(payload), receive)
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := dispatch(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return dispatch()
+}
+
+// decodeMessages decodes a message or a batch of them.
+func decodeMessages(data []byte, receive func(message)) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+	if data[0] == '[' {
+		var batch []message
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return fmt.Errorf("invalid message from the server: %w", err)
+		}
+		for _, msg := range batch {
+			receive(msg)
+		}
+		return nil
+	}
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("invalid message from the server: %w", err)
+	}
+	receive(msg)
+	return nil
+}
@@ -0,0 +1,187 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
+)
+
+type Mode string
+
+const (
+	// ModeOff runs the commands like any other program of the user.
+	ModeOff Mode = "off"
+	// ModeWorkspace lets the commands read the whole filesystem but only
+	// write to the workspace and the temporary and cache directories.
+	ModeWorkspace Mode = "workspace"
+)
+
+// helperArg marks the re-executions of the program that set the sandbox up
+// and then exec the actual command, see MaybeExec.
+const helperArg = "__cli_agent_sandbox"
+
+type Config struct {
+	Mode          Mode     `json:"mode"`
+	Network       bool     `json:"network"`
+	WritableRoots []string `json:"writableRoots"`
+}
+
+// FromSettings builds the sandbox of the session. Unless the settings say
+// otherwise the commands are sandboxed without network access on Linux.
+func FromSettings(s configs.SandboxSettings) Config {
+	cfg := Config{Mode: Mode(s.Mode), Network: s.Network != nil && *s.Network}
+	if cfg.Mode == "" {
+		cfg.Mode = DefaultMode()
+	}
+	cfg.WritableRoots = append(cfg.WritableRoots, configs.WorkingPath)
+	cfg.WritableRoots = append(cfg.WritableRoots, configs.ExtraRoots...)
+	// Compilers and package managers need somewhere to put their caches.
+	cfg.WritableRoots = append(cfg.WritableRoots, os.TempDir())
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		cfg.WritableRoots = append(cfg.WritableRoots, cacheDir)
+	}
+	for _, p := range s.WritablePaths {
+		if abs, err := filepath.Abs(expandHome(p)); err == nil {
+			cfg.WritableRoots = append(cfg.WritableRoots, abs)
+		}
+	}
+	return cfg
+}
+
+func DefaultMode() Mode {
+	if runtime.GOOS == "linux" {
+		return ModeWorkspace
+	}
+	return ModeOff
+}
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.TrimSpace(s)); m {
+	case ModeOff, ModeWorkspace:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown sandbox mode %q, expected %q or %q", s, ModeWorkspace, ModeOff)
+}
+
+// Sandboxed reports whether the commands are restricted at all.
+func (c Config) Sandboxed() bool {
+	return c.Mode != "" && c.Mode != ModeOff
+}
+
+// String describes the sandbox in a few words for the status bar.
+func (c Config) String() string {
+	if !c.Sandboxed() {
+		return "sandbox off"
+	}
+	return fmt.Sprintf("sandbox: %s, %s", c.Mode, utils.Ternary(c.Network, "network on", "no network"))
+}
+
+// Check reports why the sandbox can't be set up on this machine, if it can't.
+func (c Config) Check() error {
+	if !c.Sandboxed() {
+		return nil
+	}
+	return checkSupport(c)
+}
+
+// Command returns the command that runs name inside the sandbox, with the
+// secrets stripped from its environment. Outside of the sandbox it is a plain
+// exec.Command.
+func Command(cfg Config, name string, args ...string) (*exec.Cmd, error) {
+	return CommandContext(context.Background(), cfg, name, args...)
+}
+
+// CommandContext is Command with the process killed when ctx is done, like
+// exec.CommandContext.
+func CommandContext(ctx context.Context, cfg Config, name string, args ...string) (*exec.Cmd, error) {
+	if !cfg.Sandboxed() {
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Env = os.Environ()
+		return cmd, nil
+	}
+	if err := cfg.Check(); err != nil {
+		return nil, err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("unable to locate the cli-agent executable for the sandbox: %w", err)
+	}
+	encoded, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, self, append([]string{helperArg, string(encoded), name}, args...)...)
+	cmd.Env = CleanEnv(os.Environ())
+	return cmd, nil
+}
+
+// MaybeExec must be called first thing by main (and by TestMain of the
+// packages that run sandboxed commands). When the process was started by
+// Command it restricts itself and execs the command, never returning.
+func MaybeExec() {
+	if len(os.Args) < 4 || os.Args[1] != helperArg {
+		return
+	}
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "cli-agent sandbox:", err)
+		os.Exit(126)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal([]byte(os.Args[2]), &cfg); err != nil {
+		fail(err)
+	}
+	path, err := exec.LookPath(os.Args[3])
+	if err != nil {
+		fail(err)
+	}
+	// The restrictions apply to the calling thread, which is the one that
+	// becomes the command on exec.
+	runtime.LockOSThread()
+	if err := restrictSelf(cfg); err != nil {
+		fail(err)
+	}
+	fail(syscall.Exec(path, os.Args[3:], os.Environ()))
+}
+
+var secretEnvPattern = regexp.MustCompile(`(?i)(KEY|TOKEN|SECRET|PASSWORD|PASSWD|CREDENTIAL|AUTH|COOKIE|SESSION|PRIVATE)`)
+
+// secretEnvNames are secrets whose names the pattern does not catch.
+var secretEnvNames = []string{"DATABASE_URL", "SSH_AUTH_SOCK", "GPG_AGENT_INFO", "NETRC", "PGPASS"}
+
+// publicEnvNames match the pattern but hold no secrets.
+var publicEnvNames = []string{"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_AUTHOR_DATE"}
+
+// CleanEnv drops the variables that look like they hold credentials, such as
+// the API keys of the model providers, so commands can't leak them.
+func CleanEnv(env []string) []string {
+	clean := []string{}
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if (secretEnvPattern.MatchString(name) && !slices.Contains(publicEnvNames, name)) || slices.Contains(secretEnvNames, name) {
+			continue
+		}
+		clean = append(clean, kv)
+	}
+	return clean
+}
+
+func expandHome(p string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return p
+}
@@ -0,0 +1,166 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	landlockReadAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+
+	landlockWriteAccess = unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE | unix.LANDLOCK_ACCESS_FS_MAKE_CHAR | unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG | unix.LANDLOCK_ACCESS_FS_MAKE_SOCK | unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK | unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+
+	seccompRetAllow = 0x7fff0000
+	seccompRetErrno = 0x00050000
+)
+
+// seccompArchs are the architectures the network filter knows the syscall
+// numbers of.
+var seccompArchs = map[string]uint32{
+	"amd64": unix.AUDIT_ARCH_X86_64,
+	"arm64": unix.AUDIT_ARCH_AARCH64,
+}
+
+func checkSupport(cfg Config) error {
+	if landlockABI() < 1 {
+		return errors.New("the sandbox needs Landlock, which this kernel does not support or has disabled")
+	}
+	if _, ok := seccompArchs[runtime.GOARCH]; !ok && !cfg.Network {
+		return fmt.Errorf("disabling the network is not supported on %s", runtime.GOARCH)
+	}
+	return nil
+}
+
+func restrictSelf(cfg Config) error {
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+	if err := restrictFilesystem(cfg.WritableRoots); err != nil {
+		return err
+	}
+	if !cfg.Network {
+		return restrictNetwork()
+	}
+	return nil
+}
+
+func landlockABI() int {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0
+	}
+	return int(abi)
+}
+
+// restrictFilesystem uses Landlock to make everything but the writable roots
+// (and the devices, for /dev/null and the like) read-only.
+func restrictFilesystem(writableRoots []string) error {
+	abi := landlockABI()
+	handled := uint64(landlockReadAccess | landlockWriteAccess)
+	if abi >= 2 {
+		handled |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		handled |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create the Landlock ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer unix.Close(ruleset)
+
+	allow := func(path string, access uint64) error {
+		dirFd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			if errors.Is(err, unix.ENOENT) {
+				return nil
+			}
+			return fmt.Errorf("failed to open %s for the sandbox: %w", path, err)
+		}
+		defer unix.Close(dirFd)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			// Only the file rights make sense for a single file.
+			access &= unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE |
+				unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+		}
+		rule := unix.LandlockPathBeneathAttr{Allowed_access: access & handled, Parent_fd: int32(dirFd)}
+		_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH,
+			uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+		if errno != 0 {
+			return fmt.Errorf("failed to allow %s in the sandbox: %w", path, errno)
+		}
+		return nil
+	}
+
+	if err := allow("/", landlockReadAccess); err != nil {
+		return err
+	}
+	if err := allow("/dev", landlockReadAccess|unix.LANDLOCK_ACCESS_FS_WRITE_FILE); err != nil {
+		return err
+	}
+	for _, root := range writableRoots {
+		if err := allow(root, handled); err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("failed to enforce the Landlock ruleset: %w", errno)
+	}
+	return nil
+}
+
+// restrictNetwork installs a seccomp filter that fails the creation of every
+// socket but the Unix domain ones with EPERM. io_uring, which can open
+// sockets without the socket syscall, fails with ENOSYS, as if the kernel
+// did not have it, so programs fall back to the plain syscalls.
+func restrictNetwork() error {
+	arch, ok := seccompArchs[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("disabling the network is not supported on %s", runtime.GOARCH)
+	}
+	const (
+		ldAbs  = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+		jeq    = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+		jge    = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+		ret    = unix.BPF_RET | unix.BPF_K
+		deny   = seccompRetErrno | uint32(unix.EPERM)
+		enosys = seccompRetErrno | uint32(unix.ENOSYS)
+	)
+	// Offsets into struct seccomp_data: nr, arch and the low half of args[0].
+	// The jumps skip the given number of instructions.
+	filter := []unix.SockFilter{
+		{Code: ldAbs, K: 4},
+		{Code: jeq, Jt: 1, Jf: 0, K: arch},
+		{Code: ret, K: deny},
+		{Code: ldAbs, K: 0},
+		{Code: jge, Jt: 7, Jf: 0, K: 0x40000000}, // the x32 ABI
+		{Code: jeq, Jt: 7, Jf: 0, K: unix.SYS_IO_URING_SETUP},
+		{Code: jeq, Jt: 6, Jf: 0, K: unix.SYS_IO_URING_ENTER},
+		{Code: jeq, Jt: 5, Jf: 0, K: unix.SYS_IO_URING_REGISTER},
+		{Code: jeq, Jt: 0, Jf: 2, K: unix.SYS_SOCKET},
+		{Code: ldAbs, K: 16},
+		{Code: jeq, Jt: 0, Jf: 1, K: unix.AF_UNIX},
+		{Code: ret, K: seccompRetAllow},
+		{Code: ret, K: deny},
+		{Code: ret, K: enosys},
+	}
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0); err != nil {
+		return fmt.Errorf("failed to install the network filter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// TestIOURingProbe is run inside the sandbox by TestIOURingBlocked, it prints
+// how io_uring_setup failed.
+func TestIOURingProbe(t *testing.T) {
+	if os.Getenv("CLI_AGENT_IO_URING_PROBE") == "" {
+		t.Skip("only run by TestIOURingBlocked")
+	}
+	var params [120]byte // struct io_uring_params
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, 1, uintptr(unsafe.Pointer(&params)), 0)
+	if errno == 0 {
+		unix.Close(int(fd))
+	}
+	fmt.Printf("io_uring_setup: %v\n", errno)
+}
+
+func TestIOURingBlocked(t *testing.T) {
+	cfg := Config{Mode: ModeWorkspace, WritableRoots: []string{t.TempDir()}}
+	if err := cfg.Check(); err != nil {
+		t.Skip("the sandbox is not available:", err)
+	}
+	cmd, err := Command(cfg, os.Args[0], "-test.run=^TestIOURingProbe$", "-test.v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd.Env = append(cmd.Env, "CLI_AGENT_IO_URING_PROBE=1")
+	out, _ := cmd.CombinedOutput()
+	if !strings.Contains(string(out), "io_uring_setup: function not implemented") {
+		t.Fatalf("expected io_uring to be refused by the filter:\n%s", out)
+	}
+
+	cfg.Network = true
+	cmd, _ = Command(cfg, os.Args[0], "-test.run=^TestIOURingProbe$", "-test.v")
+	cmd.Env = append(cmd.Env, "CLI_AGENT_IO_URING_PROBE=1")
+	if out, _ := cmd.CombinedOutput(); strings.Contains(string(out), "function not implemented") {
+		t.Fatalf("expected io_uring to be left alone with the network allowed:\n%s", out)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func checkSupport(cfg Config) error {
+	return fmt.Errorf("the sandbox is not supported on %s, run with --sandbox=off", runtime.GOOS)
+}
+
+func restrictSelf(cfg Config) error {
+	return checkSupport(cfg)
+}
@@ -0,0 +1,59 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	MaybeExec()
+	os.Exit(m.Run())
+}
+
+func TestSandboxedCommand(t *testing.T) {
+	work, outside := t.TempDir(), t.TempDir()
+	cfg := Config{Mode: ModeWorkspace, WritableRoots: []string{work}}
+	if err := cfg.Check(); err != nil {
+		t.Skip("the sandbox is not available:", err)
+	}
+
+	script := `echo ok > inside.txt && echo inside-ok
+echo no > "$OUTSIDE/outside.txt" || echo outside-blocked
+echo > /dev/tcp/127.0.0.1/9 || echo network-blocked
+echo "key=[$OPENAI_API_KEY]"`
+	t.Setenv("OUTSIDE", outside)
+	t.Setenv("OPENAI_API_KEY", "sk-secret")
+	cmd, err := Command(cfg, "bash", "-c", script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd.Dir = work
+	out, _ := cmd.CombinedOutput()
+	for _, want := range []string{"inside-ok", "outside-blocked", "network-blocked", "key=[]"} {
+		if !strings.Contains(string(out), want) {
+			t.Fatalf("expected %q in the output:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(string(out), "Operation not permitted") {
+		t.Fatalf("expected the socket to be refused by the filter, not the kernel:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "outside.txt")); err == nil {
+		t.Fatal("expected the write outside of the workspace to fail")
+	}
+
+	cfg.Network = true
+	cmd, _ = Command(cfg, "bash", "-c", "echo > /dev/tcp/127.0.0.1/9 || true")
+	if out, _ := cmd.CombinedOutput(); strings.Contains(string(out), "Operation not permitted") {
+		t.Fatalf("expected the network to be allowed:\n%s", out)
+	}
+}
+
+func TestCleanEnv(t *testing.T) {
+	env := []string{"PATH=/bin", "OPENAI_API_KEY=x", "GITHUB_TOKEN=y", "AWS_SECRET_ACCESS_KEY=z", "SSH_AUTH_SOCK=/s", "HOME=/h", "GOPATH=/g", "GIT_AUTHOR_NAME=Ann"}
+	if got := CleanEnv(env); !slices.Equal(got, []string{"PATH=/bin", "HOME=/h", "GOPATH=/g", "GIT_AUTHOR_NAME=Ann"}) {
+		t.Fatalf("unexpected environment %q", got)
+	}
+}
@@ -0,0 +1,217 @@
+package testrun
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// goTestEvent is a line of the go test -json output, see go doc test2json.
+type goTestEvent struct {
+	Action     string
+	Package    string
+	ImportPath string // of the build-output and build-fail events
+	Test       string
+	// FailedBuild is the package whose build failure failed the package.
+	FailedBuild string
+	Output      string
+}
+
+type goTest struct {
+	pkg, name string
+	status    string // empty while it runs
+	output    []string
+	children  bool
+}
+
+var goLocation = regexp.MustCompile(`^\s+(\w[\w.-]*\.go):(\d+):`)
+
+// ParseGoTestJSON parses the output of go test -json. The lines that are not
+// JSON, like the build errors of older Go versions, end up in Unparsed unless
+// they belong to a package that failed to build.
+func ParseGoTestJSON(output string) *Report {
+	var (
+		tests      = map[string]*goTest{}
+		order      []string
+		pkgOutput  = map[string][]string{}
+		pkgFailed  = map[string]bool{}
+		failedBy   = map[string]string{}
+		pkgOrder   []string
+		buildOut   = map[string][]string{}
+		buildFails []string
+		unparsed   []string
+	)
+	addPkg := func(pkg string) {
+		if _, ok := pkgOutput[pkg]; !ok {
+			pkgOutput[pkg] = nil
+			pkgOrder = append(pkgOrder, pkg)
+		}
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		var ev goTestEvent
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &ev) != nil {
+			if strings.TrimSpace(line) != "" {
+				unparsed = append(unparsed, line)
+			}
+			continue
+		}
+		switch ev.Action {
+		case "build-output":
+			buildOut[ev.ImportPath] = append(buildOut[ev.ImportPath], strings.TrimSuffix(ev.Output, "\n"))
+			continue
+		case "build-fail":
+			buildFails = append(buildFails, ev.ImportPath)
+			continue
+		}
+		addPkg(ev.Package)
+
+		if ev.Test == "" {
+			switch ev.Action {
+			case "output":
+				pkgOutput[ev.Package] = append(pkgOutput[ev.Package], strings.TrimSuffix(ev.Output, "\n"))
+			case "fail":
+				pkgFailed[ev.Package] = true
+				failedBy[ev.Package] = ev.FailedBuild
+			}
+			continue
+		}
+		key := ev.Package + " " + ev.Test
+		t := tests[key]
+		if t == nil {
+			t = &goTest{pkg: ev.Package, name: ev.Test}
+			tests[key] = t
+			order = append(order, key)
+			if i := strings.LastIndex(ev.Test, "/"); i >= 0 {
+				if parent := tests[ev.Package+" "+ev.Test[:i]]; parent != nil {
+					parent.children = true
+				}
+			}
+		}
+		switch ev.Action {
+		case "output":
+			t.output = append(t.output, strings.TrimSuffix(ev.Output, "\n"))
+		case "pass", "fail", "skip":
+			t.status = ev.Action
+		}
+	}
+
+	r := &Report{}
+	failedTests := map[string]bool{}
+	for _, key := range order {
+		t := tests[key]
+		switch t.status {
+		case "":
+			if !t.children {
+				r.Running = append(r.Running, t.pkg+" "+t.name)
+			}
+			failedTests[t.pkg] = true
+		case "skip":
+			if !t.children {
+				r.Skipped++
+			}
+		case "pass":
+			if !t.children {
+				r.Passed++
+			}
+		case "fail":
+			failedTests[t.pkg] = true
+			if t.children && childFailed(tests, t) {
+				continue
+			}
+			r.Failed++
+			output := goTestOutput(t.output)
+			f := Failure{Name: t.name, Suite: t.pkg, Output: output}
+			for _, l := range t.output {
+				if m := goLocation.FindStringSubmatch(l); m != nil {
+					f.Location = m[1] + ":" + m[2]
+					break
+				}
+			}
+			r.Failures = append(r.Failures, f)
+		}
+	}
+
+	// The packages that failed without a failing test didn't build, panicked
+	// outside of a test or failed in TestMain.
+	claimed := map[string]bool{}
+	for _, pkg := range pkgOrder {
+		if !pkgFailed[pkg] || failedTests[pkg] {
+			continue
+		}
+		var lines []string
+		if build := failedBy[pkg]; build != "" {
+			lines = append(lines, buildOut[build]...)
+			claimed[build] = true
+		}
+		lines = goPackageOutput(append(lines, pkgOutput[pkg]...))
+		r.Errors = append(r.Errors, SuiteError{Suite: pkg, Output: strings.Join(lines, "\n")})
+	}
+	var orphans []string
+	for _, importPath := range buildFails {
+		if !claimed[importPath] {
+			orphans = append(orphans, goPackageOutput(buildOut[importPath])...)
+		}
+	}
+	sort.Strings(orphans)
+	r.Unparsed = strings.Join(append(unparsed, orphans...), "\n")
+	return r
+}
+
+func childFailed(tests map[string]*goTest, parent *goTest) bool {
+	for _, t := range tests {
+		if t.pkg == parent.pkg && t.status == "fail" && strings.HasPrefix(t.name, parent.name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// goTestOutput drops the lines go test adds around the output of a test.
+func goTestOutput(lines []string) string {
+	var kept []string
+	for _, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") {
+			continue
+		}
+		kept = append(kept, l)
+	}
+	return strings.Trim(strings.Join(dedent(kept), "\n"), "\n")
+}
+
+// dedent removes the indentation the lines have in common, go test indents
+// the output of a test by its depth.
+func dedent(lines []string) []string {
+	common := -1
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		indent := len(l) - len(strings.TrimLeft(l, " \t"))
+		if common < 0 || indent < common {
+			common = indent
+		}
+	}
+	if common <= 0 {
+		return lines
+	}
+	for i, l := range lines {
+		lines[i] = l[min(common, len(l)):]
+	}
+	return lines
+}
+
+// goPackageOutput drops the summary lines of a package and the headers of
+// its build output.
+func goPackageOutput(lines []string) []string {
+	var kept []string
+	for _, l := range lines {
+		if l == "FAIL" || strings.HasPrefix(l, "# ") || l == "PASS" || strings.HasPrefix(l, "FAIL\t") || strings.HasPrefix(l, "ok  \t") || strings.HasPrefix(l, "?   \t") {
+			continue
+		}
+		kept = append(kept, l)
+	}
+	return kept
+}
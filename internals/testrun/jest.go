@@ -0,0 +1,85 @@
+package testrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// jestReport is the report of jest --json, which vitest's json reporter
+// writes too.
+type jestReport struct {
+	TestResults []struct {
+		Name             string `json:"name"` // the absolute path of the test file
+		Status           string `json:"status"`
+		Message          string `json:"message"`
+		AssertionResults []struct {
+			FullName        string   `json:"fullName"`
+			Status          string   `json:"status"`
+			FailureMessages []string `json:"failureMessages"`
+			Location        *struct {
+				Line int `json:"line"`
+			} `json:"location"`
+		} `json:"assertionResults"`
+	} `json:"testResults"`
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// ParseJestJSON parses the report of jest --json or vitest --reporter=json.
+// An empty report means no test ran.
+func ParseJestJSON(data []byte) (*Report, error) {
+	r := &Report{}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return r, nil
+	}
+	var report jestReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid test report: %w", err)
+	}
+	for _, file := range report.TestResults {
+		if file.Status == "failed" && len(file.AssertionResults) == 0 {
+			r.Errors = append(r.Errors, SuiteError{Suite: file.Name, Output: cleanJestMessage(file.Message)})
+			continue
+		}
+		for _, a := range file.AssertionResults {
+			switch a.Status {
+			case "passed":
+				r.Passed++
+			case "failed":
+				r.Failed++
+				output := cleanJestMessage(strings.Join(a.FailureMessages, "\n"))
+				f := Failure{Name: a.FullName, Suite: file.Name, Output: output}
+				if a.Location != nil && a.Location.Line > 0 {
+					f.Location = fmt.Sprintf("%s:%d", file.Name, a.Location.Line)
+				} else {
+					f.Location = jestStackLocation(output, file.Name)
+				}
+				r.Failures = append(r.Failures, f)
+			default: // pending, skipped, todo and disabled
+				r.Skipped++
+			}
+		}
+	}
+	return r, nil
+}
+
+func cleanJestMessage(s string) string {
+	return strings.TrimSpace(ansiEscape.ReplaceAllString(s, ""))
+}
+
+// jestStackLocation finds where in the test file the failure happened from
+// the stack trace in its message.
+func jestStackLocation(message, file string) string {
+	re := regexp.MustCompile(regexp.QuoteMeta(file) + `:(\d+)(?::\d+)?`)
+	if m := re.FindStringSubmatch(message); m != nil {
+		return file + ":" + m[1]
+	}
+	base := regexp.MustCompile(`\b` + regexp.QuoteMeta(filepath.Base(file)) + `:(\d+)(?::\d+)?`)
+	if m := base.FindStringSubmatch(message); m != nil {
+		return file + ":" + m[1]
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package testrun
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type junitSuites struct {
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Cases  []junitCase  `xml:"testcase"`
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      string        `xml:"line,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *junitProblem `xml:"skipped"`
+	SystemOut string        `xml:"system-out"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// pytest reports where the assertion failed as "path.py:12: AssertionError".
+var pythonLocation = regexp.MustCompile(`(?m)^(\S+\.py):(\d+):`)
+
+// ParseJUnit parses a JUnit XML report, as written by pytest --junitxml. An
+// empty report means no test ran.
+func ParseJUnit(data []byte) (*Report, error) {
+	r := &Report{}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return r, nil
+	}
+	// The root is either <testsuites> or a single <testsuite>.
+	var root junitSuites
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid JUnit report: %w", err)
+	}
+	suites := root.Suites
+	if len(suites) == 0 {
+		var single junitSuite
+		if err := xml.Unmarshal(data, &single); err == nil {
+			suites = []junitSuite{single}
+		}
+	}
+	for _, s := range suites {
+		collectJUnitSuite(r, s)
+	}
+	return r, nil
+}
+
+func collectJUnitSuite(r *Report, s junitSuite) {
+	for _, c := range s.Cases {
+		problem := c.Failure
+		if problem == nil {
+			problem = c.Error
+		}
+		switch {
+		case problem != nil:
+			// A collection error is reported as a failed case without a
+			// name, the module couldn't even be imported.
+			if c.Name == "" {
+				r.Errors = append(r.Errors, SuiteError{Suite: c.ClassName, Output: strings.TrimSpace(problem.Text)})
+				continue
+			}
+			r.Failed++
+			f := Failure{Name: c.Name, Suite: c.ClassName, Output: strings.TrimSpace(problem.Text)}
+			if f.Output == "" {
+				f.Output = problem.Message
+			}
+			if c.File != "" {
+				f.Suite = c.File
+				if c.ClassName != "" && !strings.HasSuffix(strings.TrimSuffix(c.File, ".py"), strings.ReplaceAll(c.ClassName, ".", "/")) {
+					// A test method, the class is the last part of the classname.
+					f.Name = c.ClassName[strings.LastIndex(c.ClassName, ".")+1:] + "::" + c.Name
+				}
+			}
+			if m := pythonLocation.FindAllStringSubmatch(problem.Text, -1); len(m) > 0 {
+				last := m[len(m)-1]
+				f.Location = last[1] + ":" + last[2]
+			} else if c.File != "" && c.Line != "" {
+				f.Location = c.File + ":" + c.Line
+			}
+			r.Failures = append(r.Failures, f)
+		case c.Skipped != nil:
+			r.Skipped++
+		default:
+			r.Passed++
+		}
+	}
+	for _, nested := range s.Suites {
+		collectJUnitSuite(r, nested)
+	}
+}
@@ -0,0 +1,280 @@
+// Package testrun detects the test framework of a project, builds the command
+// running its tests with a machine readable report, and parses that report
+// into a summary.
+package testrun
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type Framework string
+
+const (
+	Go     Framework = "go"
+	Pytest Framework = "pytest"
+	Jest   Framework = "jest"
+	Vitest Framework = "vitest"
+)
+
+var Frameworks = []Framework{Go, Pytest, Jest, Vitest}
+
+// Report summarizes a test run.
+type Report struct {
+	Passed, Failed, Skipped int
+	Duration                time.Duration
+	Failures                []Failure
+	// Running are the tests that started but never finished, because the run
+	// was killed or crashed.
+	Running []string
+	// Errors are the packages or files that failed before their tests could
+	// run, e.g. because they didn't compile.
+	Errors []SuiteError
+	// Unparsed is what the run printed outside of the report, which usually
+	// explains why there is no report at all.
+	Unparsed string
+}
+
+type Failure struct {
+	Name     string
+	Suite    string // the package or file of the test
+	Location string // file:line, when known
+	Output   string
+}
+
+type SuiteError struct {
+	Suite  string
+	Output string
+}
+
+// Empty reports whether no test ran and nothing failed.
+func (r *Report) Empty() bool {
+	return r.Passed+r.Failed+r.Skipped == 0 && len(r.Errors) == 0 && len(r.Running) == 0
+}
+
+// Options select what a run covers.
+type Options struct {
+	// Dir is where the command runs, the project root of the framework.
+	Dir string
+	// Target is a package, directory or file relative to Dir, empty for all.
+	Target string
+	// Name selects tests by name, a regular expression for go test, a -k
+	// expression for pytest and a -t pattern for jest and vitest.
+	Name string
+	// ReportFile is where the framework writes its report.
+	ReportFile string
+}
+
+// Detect finds the framework of the project the directory belongs to, looking
+// for the closest go.mod, package.json or pytest configuration. It returns
+// the framework along with the root of its project.
+func Detect(dir string) (Framework, string, error) {
+	for cur := dir; ; {
+		if fw, ok := detectIn(cur); ok {
+			return fw, cur, nil
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return "", "", fmt.Errorf("no go.mod, package.json with jest or vitest, or pytest configuration found at or above %s", dir)
+		}
+		cur = parent
+	}
+}
+
+// Root finds the closest directory at or above dir that is a project of the
+// framework, or returns dir when there is none.
+func Root(fw Framework, dir string) string {
+	for cur := dir; ; {
+		found, ok := detectIn(cur)
+		switch {
+		case fw == Go && fileExists(filepath.Join(cur, "go.mod")),
+			(fw == Jest || fw == Vitest) && fileExists(filepath.Join(cur, "package.json")),
+			fw == Pytest && ok && found == Pytest:
+			return cur
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return dir
+		}
+		cur = parent
+	}
+}
+
+func detectIn(dir string) (Framework, bool) {
+	if fileExists(filepath.Join(dir, "go.mod")) {
+		return Go, true
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		switch {
+		case strings.Contains(string(data), `"vitest"`):
+			return Vitest, true
+		case strings.Contains(string(data), `"jest"`):
+			return Jest, true
+		}
+	}
+	for name, marker := range map[string]string{
+		"pytest.ini":     "",
+		"conftest.py":    "",
+		"pyproject.toml": "[tool.pytest",
+		"setup.cfg":      "[tool:pytest]",
+		"tox.ini":        "[pytest]",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil && strings.Contains(string(data), marker) {
+			return Pytest, true
+		}
+	}
+	return "", false
+}
+
+// Command returns the shell command running the tests of the framework.
+func Command(fw Framework, opts Options) (string, error) {
+	var args []string
+	switch fw {
+	case Go:
+		target := "./..."
+		if opts.Target != "" {
+			target = goPackage(opts.Target)
+		}
+		args = []string{"go", "test", "-json"}
+		if opts.Name != "" {
+			args = append(args, "-run", goRunPattern(opts.Name))
+		}
+		// go test -json writes the report to stdout.
+		return inDir(opts.Dir, shellJoin(append(args, target))+" > "+shellQuote(opts.ReportFile)+" 2>&1"), nil
+	case Pytest:
+		args = []string{"python3", "-m", "pytest", "-q", "-o", "junit_family=xunit1", "--junitxml=" + opts.ReportFile}
+		if opts.Name != "" {
+			args = append(args, "-k", opts.Name)
+		}
+	case Jest:
+		args = []string{"npx", "--no-install", "jest", "--json", "--testLocationInResults", "--outputFile=" + opts.ReportFile}
+		if opts.Name != "" {
+			args = append(args, "-t", opts.Name)
+		}
+	case Vitest:
+		args = []string{"npx", "--no-install", "vitest", "run", "--reporter=json", "--outputFile=" + opts.ReportFile}
+		if opts.Name != "" {
+			args = append(args, "-t", opts.Name)
+		}
+	default:
+		return "", fmt.Errorf("unsupported test framework %q", fw)
+	}
+	if opts.Target != "" {
+		args = append(args, opts.Target)
+	}
+	return inDir(opts.Dir, shellJoin(args)+" 2>&1"), nil
+}
+
+// inDir runs the command in a subshell, leaving the directory of the shell
+// it runs in alone.
+func inDir(dir, command string) string {
+	if dir == "" {
+		return command
+	}
+	return "(cd " + shellQuote(dir) + " && " + command + ")"
+}
+
+// Parse reads the report the framework wrote for the run with the options.
+// The output of the run is kept when the report doesn't explain the outcome.
+// The locations of the failures are relative to opts.Dir or absolute.
+func Parse(fw Framework, opts Options, output string) (*Report, error) {
+	data, err := os.ReadFile(opts.ReportFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var report *Report
+	switch fw {
+	case Go:
+		report = ParseGoTestJSON(string(data))
+		// go test only prints the base name of the files.
+		module := goModulePath(opts.Dir)
+		for i, f := range report.Failures {
+			if rel, ok := strings.CutPrefix(f.Suite, module); ok && module != "" && f.Location != "" {
+				report.Failures[i].Location = path.Join(strings.TrimPrefix(rel, "/"), f.Location)
+			}
+		}
+		return report, nil
+	case Pytest:
+		report, err = ParseJUnit(data)
+	case Jest, Vitest:
+		report, err = ParseJestJSON(data)
+	default:
+		return nil, fmt.Errorf("unsupported test framework %q", fw)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if report.Empty() || report.Failed > 0 && len(report.Failures) == 0 {
+		report.Unparsed = strings.TrimSpace(output)
+	}
+	return report, nil
+}
+
+var goIdentPath = regexp.MustCompile(`^\w+(/\w+)*$`)
+
+// goRunPattern anchors a plain test name like TestFoo or TestFoo/case so it
+// doesn't match TestFooBar too, regular expressions are left alone.
+func goRunPattern(name string) string {
+	if !goIdentPath.MatchString(name) {
+		return name
+	}
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		parts[i] = "^" + p + "$"
+	}
+	return strings.Join(parts, "/")
+}
+
+// goPackage turns a directory or file into the package pattern go test takes.
+func goPackage(target string) string {
+	target = filepath.ToSlash(target)
+	if strings.HasSuffix(target, ".go") {
+		target = filepath.ToSlash(filepath.Dir(target))
+	}
+	if target == "." || strings.HasPrefix(target, "./") || strings.HasPrefix(target, "../") || strings.HasPrefix(target, "/") {
+		return target
+	}
+	return "./" + target
+}
+
+func goModulePath(root string) string {
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}
+
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && !info.IsDir()
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+var shellSafe = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+
+func shellQuote(s string) string {
+	if s != "" && shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
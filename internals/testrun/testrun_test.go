@@ -0,0 +1,158 @@
+package testrun
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const goTestJSON = `{"ImportPath":"example.com/gt/bad [example.com/gt/bad.test]","Action":"build-output","Output":"# example.com/gt/bad [example.com/gt/bad.test]\n"}
+{"ImportPath":"example.com/gt/bad [example.com/gt/bad.test]","Action":"build-output","Output":"bad/bad.go:3:23: undefined: undefined\n"}
+{"ImportPath":"example.com/gt/bad [example.com/gt/bad.test]","Action":"build-fail"}
+{"Action":"output","Package":"example.com/gt/bad","Output":"FAIL\texample.com/gt/bad [build failed]\n","OutputType":"frame"}
+{"Action":"fail","Package":"example.com/gt/bad","FailedBuild":"example.com/gt/bad [example.com/gt/bad.test]"}
+{"Action":"run","Package":"example.com/gt/ok","Test":"TestPass"}
+{"Action":"pass","Package":"example.com/gt/ok","Test":"TestPass"}
+{"Action":"run","Package":"example.com/gt/ok","Test":"TestFail"}
+{"Action":"output","Package":"example.com/gt/ok","Test":"TestFail","Output":"=== RUN   TestFail\n","OutputType":"frame"}
+{"Action":"output","Package":"example.com/gt/ok","Test":"TestFail","Output":"    ok_test.go:7: context\n"}
+{"Action":"output","Package":"example.com/gt/ok","Test":"TestFail","Output":"    ok_test.go:8: expected 1, got 2\n","OutputType":"error"}
+{"Action":"output","Package":"example.com/gt/ok","Test":"TestFail","Output":"--- FAIL: TestFail (0.00s)\n","OutputType":"frame"}
+{"Action":"fail","Package":"example.com/gt/ok","Test":"TestFail"}
+{"Action":"run","Package":"example.com/gt/ok","Test":"TestSkip"}
+{"Action":"skip","Package":"example.com/gt/ok","Test":"TestSkip"}
+{"Action":"run","Package":"example.com/gt/ok","Test":"TestSub"}
+{"Action":"output","Package":"example.com/gt/ok","Test":"TestSub","Output":"=== RUN   TestSub\n","OutputType":"frame"}
+{"Action":"run","Package":"example.com/gt/ok","Test":"TestSub/a"}
+{"Action":"output","Package":"example.com/gt/ok","Test":"TestSub/a","Output":"=== RUN   TestSub/a\n","OutputType":"frame"}
+{"Action":"output","Package":"example.com/gt/ok","Test":"TestSub/a","Output":"--- PASS: TestSub/a (0.00s)\n","OutputType":"frame"}
+{"Action":"pass","Package":"example.com/gt/ok","Test":"TestSub/a"}
+{"Action":"run","Package":"example.com/gt/ok","Test":"TestSub/b"}
+{"Action":"output","Package":"example.com/gt/ok","Test":"TestSub/b","Output":"=== RUN   TestSub/b\n","OutputType":"frame"}
+{"Action":"output","Package":"example.com/gt/ok","Test":"TestSub/b","Output":"    ok_test.go:13: boom\n","OutputType":"error"}
+{"Action":"output","Package":"example.com/gt/ok","Test":"TestSub/b","Output":"--- FAIL: TestSub/b (0.00s)\n","OutputType":"frame"}
+{"Action":"fail","Package":"example.com/gt/ok","Test":"TestSub/b"}
+{"Action":"output","Package":"example.com/gt/ok","Test":"TestSub","Output":"--- FAIL: TestSub (0.00s)\n","OutputType":"frame"}
+{"Action":"fail","Package":"example.com/gt/ok","Test":"TestSub"}
+{"Action":"output","Package":"example.com/gt/ok","Output":"FAIL\n","OutputType":"frame"}
+{"Action":"output","Package":"example.com/gt/ok","Output":"FAIL\texample.com/gt/ok\t0.002s\n","OutputType":"frame"}
+{"Action":"fail","Package":"example.com/gt/ok"}`
+
+func TestParseGoTestJSON(t *testing.T) {
+	r := ParseGoTestJSON(goTestJSON)
+	if r.Passed != 2 || r.Failed != 2 || r.Skipped != 1 {
+		t.Fatalf("unexpected counts %d passed, %d failed, %d skipped", r.Passed, r.Failed, r.Skipped)
+	}
+	if len(r.Failures) != 2 {
+		t.Fatalf("expected TestFail and TestSub/b to fail, got %+v", r.Failures)
+	}
+	f := r.Failures[0]
+	if f.Name != "TestFail" || f.Suite != "example.com/gt/ok" || f.Location != "ok_test.go:7" || f.Output != "ok_test.go:7: context\nok_test.go:8: expected 1, got 2" {
+		t.Fatalf("unexpected failure %+v", f)
+	}
+	if r.Failures[1].Name != "TestSub/b" || r.Failures[1].Location != "ok_test.go:13" {
+		t.Fatalf("unexpected subtest failure %+v", r.Failures[1])
+	}
+	if len(r.Errors) != 1 || r.Errors[0].Suite != "example.com/gt/bad" || r.Errors[0].Output != "bad/bad.go:3:23: undefined: undefined" {
+		t.Fatalf("unexpected build errors %+v", r.Errors)
+	}
+	if r.Unparsed != "" {
+		t.Fatalf("unexpected unparsed output %q", r.Unparsed)
+	}
+
+	// A run killed in the middle of a test.
+	cut := goTestJSON[:strings.Index(goTestJSON, `{"Action":"fail","Package":"example.com/gt/ok","Test":"TestFail"}`)]
+	r = ParseGoTestJSON(cut + "signal: killed\n")
+	if len(r.Running) != 1 || r.Running[0] != "example.com/gt/ok TestFail" || r.Unparsed != "signal: killed" {
+		t.Fatalf("expected TestFail to be running, got %v and %q", r.Running, r.Unparsed)
+	}
+}
+
+func TestParseJUnit(t *testing.T) {
+	report := `<?xml version="1.0" encoding="utf-8"?>
+<testsuites><testsuite name="pytest" errors="1" failures="1" skipped="1" tests="4">
+<testcase classname="tests.test_api" name="test_ok" file="tests/test_api.py" line="3" time="0.001"/>
+<testcase classname="tests.test_api.TestGet" name="test_missing" file="tests/test_api.py" line="10" time="0.002">
+<failure message="assert 404 == 200">def test_missing(self):
+&gt;       assert get("/x") == 200
+E       assert 404 == 200
+
+tests/test_api.py:12: AssertionError</failure></testcase>
+<testcase classname="tests.test_api" name="test_later" file="tests/test_api.py" line="14" time="0"><skipped message="todo"/></testcase>
+<testcase classname="tests.test_broken" name="" time="0"><error message="collection failure">ImportError: No module named 'nope'</error></testcase>
+</testsuite></testsuites>`
+	r, err := ParseJUnit([]byte(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Passed != 1 || r.Failed != 1 || r.Skipped != 1 || len(r.Errors) != 1 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	f := r.Failures[0]
+	if f.Name != "TestGet::test_missing" || f.Suite != "tests/test_api.py" || f.Location != "tests/test_api.py:12" || !strings.Contains(f.Output, "E       assert 404 == 200") {
+		t.Fatalf("unexpected failure %+v", f)
+	}
+	if r.Errors[0].Suite != "tests.test_broken" || !strings.Contains(r.Errors[0].Output, "ImportError") {
+		t.Fatalf("unexpected collection error %+v", r.Errors[0])
+	}
+}
+
+func TestParseJestJSON(t *testing.T) {
+	report := `{"numFailedTests": 1, "testResults": [
+		{"name": "/app/src/sum.test.ts", "status": "failed", "assertionResults": [
+			{"fullName": "sum adds", "status": "passed", "failureMessages": []},
+			{"fullName": "sum handles negatives", "status": "failed", "failureMessages": ["\u001b[31mExpected: -1\nReceived: 1\u001b[39m\n    at Object.<anonymous> (/app/src/sum.test.ts:9:17)"]},
+			{"fullName": "sum todo", "status": "todo", "failureMessages": []}
+		]},
+		{"name": "/app/src/broken.test.ts", "status": "failed", "message": "SyntaxError: Unexpected token", "assertionResults": []}
+	]}`
+	r, err := ParseJestJSON([]byte(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Passed != 1 || r.Failed != 1 || r.Skipped != 1 || len(r.Errors) != 1 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	f := r.Failures[0]
+	if f.Name != "sum handles negatives" || f.Location != "/app/src/sum.test.ts:9" || !strings.HasPrefix(f.Output, "Expected: -1\nReceived: 1") {
+		t.Fatalf("unexpected failure %+v", f)
+	}
+}
+
+func TestDetectAndCommand(t *testing.T) {
+	root := t.TempDir()
+	write := func(p, content string) {
+		full := filepath.Join(root, p)
+		os.MkdirAll(filepath.Dir(full), 0o755)
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/app\n")
+	write("web/package.json", `{"devDependencies": {"vitest": "^1.0.0"}}`)
+	write("web/src/a.test.ts", "")
+	write("py/pyproject.toml", "[tool.pytest.ini_options]\n")
+
+	for dir, want := range map[string]Framework{".": Go, "web/src": Vitest, "py": Pytest} {
+		fw, fwRoot, err := Detect(filepath.Join(root, dir))
+		if err != nil || fw != want {
+			t.Fatalf("Detect(%s) = %s, %v, want %s", dir, fw, err, want)
+		}
+		if want == Vitest && fwRoot != filepath.Join(root, "web") {
+			t.Fatalf("unexpected vitest root %s", fwRoot)
+		}
+	}
+	if got := Root(Go, filepath.Join(root, "web/src")); got != root {
+		t.Fatalf("expected the go.mod directory, got %s", got)
+	}
+
+	cmd, _ := Command(Go, Options{Dir: "/app", Target: "internals/db", Name: "TestSave/empty", ReportFile: "/tmp/r"})
+	if cmd != "(cd /app && go test -json -run '^TestSave$/^empty$' ./internals/db > /tmp/r 2>&1)" {
+		t.Fatalf("unexpected go command %q", cmd)
+	}
+	cmd, _ = Command(Pytest, Options{Target: "tests/test_api.py::test_get", Name: "get and not slow", ReportFile: "/tmp/r"})
+	if cmd != "python3 -m pytest -q -o junit_family=xunit1 --junitxml=/tmp/r -k 'get and not slow' tests/test_api.py::test_get 2>&1" {
+		t.Fatalf("unexpected pytest command %q", cmd)
+	}
+}
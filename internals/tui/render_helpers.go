@@ -5,59 +5,65 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"slices"
 	"sort"
 	"strings"
 	"sync"
 	"unicode"
 
 	"github.com/charmbracelet/glamour"
-	"github.com/charmbracelet/lipgloss"
 
-	"github.com/sifatulrabbi/cli-agent/internals/agent"
 	"github.com/sifatulrabbi/cli-agent/internals/agent/tools"
+	"github.com/sifatulrabbi/cli-agent/internals/db"
+	"github.com/sifatulrabbi/cli-agent/internals/mcp"
+	"github.com/sifatulrabbi/cli-agent/internals/utils"
 )
 
 const DefaultTruncateLength = 200
 
-func renderHistory(width int) string {
+func renderHistory(messages []db.HistoryMessage, width int) string {
 	var b strings.Builder
 
-	for _, msg := range agent.History {
-		if msg.IsHumanMsg() {
-			hmsg := msg.ToHumanMessage()
+	toolNames := map[string]string{}
+	for _, msg := range messages {
+		if msg.IsUser() {
 			b.WriteString("\n")
 			contentBuf := strings.Builder{}
 			userInputArea := inputBoxSt.Width(width - 2)
 			maxW := userInputArea.GetWidth() - 2
-			contentBuf.WriteString(wrapLines(hmsg.Content, maxW))
+			contentBuf.WriteString(wrapLines(msg.Text, maxW))
 			b.WriteString(userInputArea.Padding(0, 1).Render(contentBuf.String()))
 			b.WriteString("\n")
 		}
 
-		if msg.IsAIMsg() {
-			aiMsg := msg.ToAIMessage()
-
-			if aiMsg.Reasoning != "" {
+		if msg.IsAI() {
+			if msg.Reasoning != "" {
 				b.WriteString("\n")
-				plainReasoning := strings.ReplaceAll(aiMsg.Reasoning, "\n\n", "\n")
+				plainReasoning := strings.ReplaceAll(msg.Reasoning, "\n\n", "\n")
 				b.WriteString(mutedText.Width(width).Render(
 					clipTopLines(wrapLines(plainReasoning, width), 4),
 				))
 				b.WriteString("\n")
 			}
 
-			if aiMsg.Output != "" {
-				b.WriteString(styledText(aiMsg.Output, width))
+			if msg.Text != "" {
+				b.WriteString(styledText(msg.Text, width))
 				b.WriteString("\n")
 			}
 
-			for _, tc := range aiMsg.ToolCalls {
+			for _, tc := range msg.ToolCalls {
+				toolNames[tc.CallID] = tc.Name
 				b.WriteString("\n")
 				b.WriteString(wrapLines(italicText.Bold(true).Render("🔧 CLI-Agent is using tools:"), width))
 				b.WriteString("\n")
 				toolLine := ""
-				if slices.Contains([]string{tools.ToolBash, tools.ToolGrep}, tc.Name) {
+				if tc.Name == tools.ToolSearch {
+					var args tools.SearchToolArgs
+					if err := json.Unmarshal([]byte(tc.Args), &args); err != nil {
+						toolLine = fmt.Sprintf("  ↳ %s → %s", tc.Name, mutedText.Render("Invalid args from the AI!"))
+					} else {
+						toolLine = fmt.Sprintf("  ↳ %s → %s", tc.Name, mutedText.Render(fmt.Sprintf("%q in %s", args.Pattern, utils.Ternary(args.Path == "", ".", args.Path))))
+					}
+				} else if tc.Name == tools.ToolBash {
 					var args struct {
 						Cmd string `json:"cmd"`
 					}
@@ -76,16 +82,10 @@ func renderHistory(width int) string {
 			}
 		}
 
-		if msg.IsToolMsg() {
-			toolMsg := msg.ToToolMessage()
-			b.WriteString(labelSt.Render(fmt.Sprintf("  ↳ %s", toolMsg.Name)))
+		if msg.IsTool() {
+			b.WriteString(labelSt.Render(fmt.Sprintf("  ↳ %s", toolNames[msg.ToolCallID])))
 			b.WriteString("\n")
-			if strings.Contains(toolMsg.Name, "todo") {
-				todoList := agent.GetFormattedTodoList()
-				b.WriteString(lipgloss.NewStyle().Bold(true).Padding(2).Render(wrapLines(todoList, width-2*2)))
-			} else {
-				b.WriteString(mutedText.Italic(true).PaddingLeft(2).Render(clipBottomLines(wrapLines(toolMsg.Content, width-2), 10)))
-			}
+			b.WriteString(mutedText.Italic(true).PaddingLeft(2).Render(clipBottomLines(wrapLines(msg.Text, width-2), 10)))
 			b.WriteString("\n")
 		}
 	}
@@ -369,3 +369,119 @@ func insertSuggestionIntoInput(raw string, frag string, chosen string) string {
 	}
 	return before + after + " "
 }
+
+const maxTodoLines = 8
+
+// renderTodoPanel shows the session's todo list above the input, it is empty
+// when there are no todos. Long lists show the todos around the first one not
+// done yet.
+func renderTodoPanel(todos []db.Todo, width int) string {
+	if len(todos) == 0 {
+		return ""
+	}
+	done := 0
+	first := -1
+	for i, t := range todos {
+		if t.Status == db.TodoCompleted || t.Status == db.TodoCancelled {
+			done++
+		} else if first < 0 {
+			first = i
+		}
+	}
+	start, end := 0, len(todos)
+	if len(todos) > maxTodoLines {
+		start = min(max(first-1, 0), len(todos)-maxTodoLines)
+		end = start + maxTodoLines
+	}
+
+	lines := []string{titleSt.Render(fmt.Sprintf("Todos %d/%d", done, len(todos)))}
+	if start > 0 {
+		lines = append(lines, helpSt.Render(fmt.Sprintf("  … %d done", start)))
+	}
+	for _, t := range todos[start:end] {
+		content := t.Content
+		if line, _, _ := strings.Cut(content, "\n"); line != content || len(line) > width-4 {
+			content = strings.ToValidUTF8(strings.TrimSpace(line[:min(len(line), max(width-5, 1))]), "") + "…"
+		}
+		switch t.Status {
+		case db.TodoCompleted:
+			lines = append(lines, mutedText.Strikethrough(true).Render("✓ "+content))
+		case db.TodoCancelled:
+			lines = append(lines, mutedText.Render("✗ "+content))
+		case db.TodoInProgress:
+			lines = append(lines, labelSt.Bold(true).Render("▸ "+content))
+		default:
+			lines = append(lines, "○ "+content)
+		}
+	}
+	if end < len(todos) {
+		lines = append(lines, helpSt.Render(fmt.Sprintf("  … %d more", len(todos)-end)))
+	}
+	return styled.Padding(0, 1).Render(strings.Join(lines, "\n"))
+}
+
+// mcpSummary is the footer's part about the MCP servers, e.g. "MCP 2/3", it
+// is empty when no server is configured.
+func mcpSummary(servers []mcp.ServerStatus) string {
+	if len(servers) == 0 {
+		return ""
+	}
+	ready, connecting := 0, 0
+	for _, s := range servers {
+		switch s.State {
+		case mcp.StateReady:
+			ready++
+		case mcp.StateConnecting:
+			connecting++
+		}
+	}
+	summary := fmt.Sprintf("MCP %d/%d", ready, len(servers))
+	if connecting > 0 {
+		summary += " connecting…"
+	}
+	return summary
+}
+
+// describeMCPPrompts is what /prompt shows, e.g. "github:review(pr, focus?)".
+func describeMCPPrompts(servers []mcp.ServerStatus) string {
+	prompts := []string{}
+	for _, s := range servers {
+		for _, p := range s.Prompts {
+			args := []string{}
+			for _, a := range p.Arguments {
+				args = append(args, a.Name+utils.Ternary(a.Required, "", "?"))
+			}
+			prompts = append(prompts, fmt.Sprintf("%s:%s(%s)", s.Name, p.Name, strings.Join(args, ", ")))
+		}
+	}
+	if len(prompts) == 0 {
+		return "No MCP server offers prompts."
+	}
+	return "Use /prompt <server>:<name> [arg=value ...] with " + strings.Join(prompts, " · ")
+}
+
+// describeMCPServers is what /mcp shows, e.g. "github: 12 tools, 1 prompt".
+func describeMCPServers(servers []mcp.ServerStatus) string {
+	if len(servers) == 0 {
+		return "No MCP server is configured, add them to the mcp settings."
+	}
+	parts := []string{}
+	for _, s := range servers {
+		switch s.State {
+		case mcp.StateReady:
+			offers := []string{utils.Plural(len(s.Tools), "tool")}
+			if len(s.Resources) > 0 {
+				offers = append(offers, utils.Plural(len(s.Resources), "resource"))
+			}
+			if len(s.Prompts) > 0 {
+				offers = append(offers, utils.Plural(len(s.Prompts), "prompt"))
+			}
+			parts = append(parts, s.Name+": "+strings.Join(offers, ", "))
+		case mcp.StateFailed:
+			parts = append(parts, fmt.Sprintf("%s: failed (%v)", s.Name, s.Err))
+		default:
+			parts = append(parts, s.Name+": "+string(s.State)+"…")
+		}
+	}
+	return strings.Join(parts, " · ")
+}
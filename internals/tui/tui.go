@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -11,8 +12,28 @@ import (
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/sifatulrabbi/cli-agent/internals/agent"
+	"github.com/sifatulrabbi/cli-agent/internals/agent/tools"
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
 	"github.com/sifatulrabbi/cli-agent/internals/db"
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
+)
+
+const maxPickerLines = 10
+
+type (
+	// streamChunkMsg is a signal of the running turn, see agent.UpdateSig.
+	streamChunkMsg string
+	// streamDoneMsg is sent once the turn ends.
+	streamDoneMsg struct{}
+	// mcpStatusMsg is sent whenever the status of an MCP server changes.
+	mcpStatusMsg struct{}
+	// mcpPromptMsg carries the prompt /prompt got from an MCP server.
+	mcpPromptMsg struct {
+		text string
+		err  error
+	}
 )
 
 type TuiModel struct {
@@ -28,13 +49,26 @@ type TuiModel struct {
 
 	escPressed bool
 
+	// picking a previous user message to rewind the conversation to
+	rewinding     bool
+	rewindTargets []int // indexes of the user messages in the history
+	rewindSel     int
+	rewindOffset  int
+	rewindConfirm bool // asking whether the file edits should be restored too
+
+	// a tool call is waiting for the user's permission
+	approving bool
+
 	maxWidth     int
 	maxHeight    int
 	inputHeight  int
 	headerHeight int
 	footerHeight int
 	statusHeight int
+	pickerHeight int
+	todoHeight   int
 
+	ch    <-chan string
 	agent *agent.CLIAgent
 }
 
@@ -50,7 +84,7 @@ func New() TuiModel {
 		footerHeight: 2,
 		statusHeight: 2,
 		busy:         false,
-		agent:        agent.NewAgent(&db.AgentHistory{}),
+		agent:        agent.NewAgent(db.NewHistory(configs.WorkingPath, "")),
 	}
 
 	m.ti.ShowLineNumbers = false
@@ -59,6 +93,9 @@ func New() TuiModel {
 	m.ti.SetHeight(m.inputHeight)
 	m.vp.MouseWheelEnabled = true
 	m.sp.Spinner = spinner.Points
+	if ignored := configs.IgnoredProjectSettings; len(ignored) > 0 {
+		m.logMessage = fmt.Sprintf("Ignored the project settings %s, add the project to trustedProjects in %s to use them.", strings.Join(ignored, ", "), configs.UserSettingsPath())
+	}
 
 	return m
 }
@@ -79,6 +116,13 @@ func (m TuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		if m.rewinding {
+			return m, m.handleRewindKeys(msg)
+		}
+		if m.approving {
+			return m, m.handleApprovalKeys(msg)
+		}
+
 		switch msg.String() {
 		case "ctrl+c":
 			return m, tea.Quit
@@ -105,7 +149,44 @@ func (m TuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.updateHeights()
 				return m, tea.Batch(m.updateTextinput(msg), m.updateViewport(msg))
 
+			case "/rewind":
+				m.ti.Reset()
+				m.startRewind()
+				m.updateHeights()
+				return m, m.updateTextinput(msg)
+
+			case "/mcp":
+				m.ti.Reset()
+				m.logMessage = describeMCPServers(tools.MCPStatus())
+				m.updateHeights()
+				return m, m.updateTextinput(msg)
+
+			case "/undo", "/undo all":
+				m.ti.Reset()
+				restored, err := m.agent.Undo(v == "/undo all")
+				if err != nil {
+					m.logMessage = fmt.Sprintf("Failed to undo the edits: %v", err)
+				} else if len(restored) < 1 {
+					m.logMessage = "Nothing to undo."
+				} else {
+					m.logMessage = fmt.Sprintf("Reverted %d file(s).", len(restored))
+				}
+				m.updateHeights()
+				return m, m.updateTextinput(msg)
+
 			default:
+				if v == "/prompt" || strings.HasPrefix(v, "/prompt ") {
+					m.ti.Reset()
+					cmd := m.getPrompt(strings.Fields(v)[1:])
+					m.updateHeights()
+					return m, tea.Batch(cmd, m.updateTextinput(msg))
+				}
+				if v == "/sandbox" || strings.HasPrefix(v, "/sandbox ") {
+					m.ti.Reset()
+					m.logMessage = setSandbox(strings.Fields(v)[1:])
+					m.updateHeights()
+					return m, m.updateTextinput(msg)
+				}
 				if strings.HasSuffix(v, "\\") {
 					m.ti.SetValue(strings.TrimSuffix(v, "\\"))
 					// increasing the textinput's height when the user adds more lines.
@@ -123,8 +204,12 @@ func (m TuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.ti.Reset()
 				m.logMessage = ""
 				m.escPressed = false
-				m.busy = false
-				m.busyStatus = ""
+				if m.busy {
+					// the turn ends, and busy is reset, once the agent
+					// notices the cancellation and closes the channel.
+					m.agent.Cancel()
+					m.busyStatus = "Cancelling…"
+				}
 			} else if m.busy || m.ti.Value() != "" {
 				m.escPressed = true
 				if m.busy {
@@ -142,6 +227,34 @@ func (m TuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, cmd)
 			m.sp = sp
 		}
+
+	case streamChunkMsg:
+		if string(msg) == agent.ApprovalSig {
+			m.startApproval()
+		} else if m.busyStatus != "Cancelling…" && !m.approving {
+			m.busyStatus = "Thinking…"
+		}
+		m.chatHistory = renderHistory(m.agent.Messages(), m.vp.Width-2)
+		cmds = append(cmds, m.waitForUpdate())
+
+	case mcpStatusMsg:
+		// the footer shows the new status once redrawn
+
+	case mcpPromptMsg:
+		if msg.err != nil {
+			m.logMessage = fmt.Sprintf("Unable to get the prompt: %v", msg.err)
+		} else {
+			m.ti.SetValue(msg.text)
+			m.inputHeight = min(max(m.ti.LineCount(), 1), 9)
+			m.logMessage = "Edit the prompt if needed and press Enter to send it."
+		}
+
+	case streamDoneMsg:
+		m.approving = false
+		m.busy = false
+		m.busyStatus = ""
+		m.ch = nil
+		m.chatHistory = renderHistory(m.agent.Messages(), m.vp.Width-2)
 	}
 
 	m.updateHeights()
@@ -150,6 +263,15 @@ func (m TuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m *TuiModel) updateHeights() {
+	m.pickerHeight = 0
+	if m.rewinding {
+		m.pickerHeight = min(len(m.rewindTargets), maxPickerLines) + 1
+	}
+	m.todoHeight = 0
+	if panel := m.renderTodoPanel(); panel != "" {
+		m.todoHeight = lipgloss.Height(panel)
+	}
+
 	if m.logMessage != "" && m.busyStatus != "" {
 		m.statusHeight = 3
 	} else if m.busyStatus != "" || m.logMessage != "" {
@@ -163,7 +285,7 @@ func (m *TuiModel) updateHeights() {
 
 	// This function calculates and updates the viewport's height based on the other
 	// components of the TUI.
-	remainingHeight := m.maxHeight - m.inputHeight - m.headerHeight - m.footerHeight - m.statusHeight
+	remainingHeight := m.maxHeight - m.inputHeight - m.headerHeight - m.footerHeight - m.statusHeight - m.pickerHeight - m.todoHeight
 	m.vp.Width = m.maxWidth
 	m.vp.Height = remainingHeight
 }
@@ -207,11 +329,178 @@ func (m *TuiModel) updateViewport(msg tea.Msg) tea.Cmd {
 }
 
 func (m *TuiModel) handleSubmit(userInput string) tea.Cmd {
-	m.chatHistory += fmt.Sprintf("USER: %s\n", userInput)
+	m.ch = m.agent.Invoke(userInput)
+	m.chatHistory = renderHistory(m.agent.Messages(), m.vp.Width-2)
 	m.busy = true
 	m.busyStatus = "Processing…"
 	m.updateHeights()
-	return tea.Tick(m.sp.Spinner.FPS, func(time.Time) tea.Msg { return m.sp.Tick() })
+	return tea.Batch(
+		tea.Tick(m.sp.Spinner.FPS, func(time.Time) tea.Msg { return m.sp.Tick() }),
+		m.waitForUpdate(),
+	)
+}
+
+func (m TuiModel) waitForUpdate() tea.Cmd {
+	ch := m.ch
+	return func() tea.Msg {
+		if s, ok := <-ch; ok {
+			return streamChunkMsg(s)
+		}
+		return streamDoneMsg{}
+	}
+}
+
+// startRewind lists the previous user messages so the user can pick the one
+// to rewind the conversation to.
+func (m *TuiModel) startRewind() {
+	m.rewindTargets = nil
+	for i, msg := range m.agent.Messages() {
+		if msg.IsUser() {
+			m.rewindTargets = append(m.rewindTargets, i)
+		}
+	}
+	if len(m.rewindTargets) < 1 {
+		m.logMessage = "Nothing to rewind yet."
+		return
+	}
+	m.rewinding = true
+	m.rewindSel = len(m.rewindTargets) - 1
+	m.rewindOffset = max(0, len(m.rewindTargets)-maxPickerLines)
+	m.logMessage = "Select a message to rewind to (Enter to confirm, Esc to cancel)."
+}
+
+func (m *TuiModel) handleRewindKeys(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c":
+		return tea.Quit
+
+	case "esc":
+		if m.rewindConfirm {
+			m.rewindTo(false)
+		} else {
+			m.rewinding = false
+			m.logMessage = ""
+		}
+
+	case "n", "N":
+		if m.rewindConfirm {
+			m.rewindTo(false)
+		}
+
+	case "y", "Y":
+		if m.rewindConfirm {
+			m.rewindTo(true)
+		}
+
+	case "up":
+		if m.rewindConfirm {
+			break
+		}
+		if m.rewindSel > 0 {
+			m.rewindSel--
+		}
+		if m.rewindSel < m.rewindOffset {
+			m.rewindOffset = m.rewindSel
+		}
+
+	case "down":
+		if m.rewindConfirm {
+			break
+		}
+		if m.rewindSel < len(m.rewindTargets)-1 {
+			m.rewindSel++
+		}
+		if m.rewindSel >= m.rewindOffset+maxPickerLines {
+			m.rewindOffset = m.rewindSel - maxPickerLines + 1
+		}
+
+	case "enter":
+		if m.rewindConfirm {
+			break
+		}
+		if m.agent.Checkpoints.HasChangesSince(m.rewindTargets[m.rewindSel]) {
+			m.rewindConfirm = true
+			m.logMessage = "Also restore the files changed after this message? (y/n)"
+			break
+		}
+		m.rewindTo(false)
+	}
+
+	m.updateHeights()
+	return m.updateViewport(msg)
+}
+
+// startApproval asks the user about the tool call the agent is waiting on.
+func (m *TuiModel) startApproval() {
+	req := m.agent.PendingApproval()
+	if req == nil {
+		return
+	}
+	m.approving = true
+	m.busyStatus = "Waiting for your approval…"
+	target := strings.Join(req.Subjects, ", ")
+	if len(target) > DefaultTruncateLength {
+		target = target[:DefaultTruncateLength] + "…"
+	}
+	if target != "" {
+		target = " → " + target
+	}
+	m.logMessage = fmt.Sprintf("Allow %s%s? (y)es · (a)lways allow %s this session · (n)o",
+		req.ToolName, target, strings.Join(req.SuggestedRules, ", "))
+}
+
+func (m *TuiModel) handleApprovalKeys(msg tea.KeyMsg) tea.Cmd {
+	answer := agent.ApproveOnce
+	switch msg.String() {
+	case "ctrl+c":
+		return tea.Quit
+	case "y", "Y", "enter":
+		answer = agent.ApproveOnce
+	case "a", "A":
+		answer = agent.ApproveAlways
+	case "n", "N", "esc":
+		answer = agent.Reject
+	default:
+		return nil
+	}
+	m.agent.Answer(answer)
+	m.approving = false
+	m.logMessage = ""
+	m.busyStatus = "Processing…"
+	m.updateHeights()
+	return m.updateViewport(msg)
+}
+
+func (m *TuiModel) rewindTo(restoreFiles bool) {
+	m.rewinding = false
+	m.rewindConfirm = false
+	userInput, forkID, err := m.agent.Rewind(m.rewindTargets[m.rewindSel], restoreFiles)
+	if err != nil {
+		m.logMessage = fmt.Sprintf("Failed to rewind: %v", err)
+		return
+	}
+	m.chatHistory = renderHistory(m.agent.Messages(), m.vp.Width-2)
+	m.ti.SetValue(userInput)
+	m.inputHeight = min(max(m.ti.LineCount(), 1), 9)
+	m.logMessage = fmt.Sprintf("Rewound the conversation. The original was saved as session %s.", forkID)
+}
+
+func (m TuiModel) renderRewindPicker() string {
+	messages := m.agent.Messages()
+	items := make([]string, 0, len(m.rewindTargets))
+	for _, idx := range m.rewindTargets {
+		firstLine, _, _ := strings.Cut(messages[idx].Text, "\n")
+		if len(firstLine) > DefaultTruncateLength {
+			firstLine = firstLine[:DefaultTruncateLength] + "…"
+		}
+		items = append(items, firstLine)
+	}
+	return helpSt.Padding(0, 1).Render("Rewind to:") + "\n" +
+		renderSuggestions(m.maxWidth, items, m.rewindSel, m.rewindOffset)
+}
+
+func (m TuiModel) renderTodoPanel() string {
+	return renderTodoPanel(m.agent.History.Todos.All(), m.maxWidth)
 }
 
 func (m TuiModel) View() string {
@@ -235,16 +524,88 @@ func (m TuiModel) View() string {
 		finalView.WriteString(styled.Padding(0, 1).PaddingBottom(1).Height(m.statusHeight).Render(statusView.String()))
 		finalView.WriteString("\n")
 	}
+	if m.rewinding {
+		finalView.WriteString(m.renderRewindPicker())
+		finalView.WriteString("\n")
+	}
+	if panel := m.renderTodoPanel(); panel != "" {
+		finalView.WriteString(panel)
+		finalView.WriteString("\n")
+	}
 	finalView.WriteString(m.ti.View())
 	finalView.WriteString("\n")
-	finalView.WriteString(footerSt.Height(m.footerHeight).Render("auto-accept mode on"))
+	footer := m.agent.Policy.Summary() + " · " + tools.SandboxConfig().String()
+	if summary := mcpSummary(tools.MCPStatus()); summary != "" {
+		footer += " · " + summary
+	}
+	finalView.WriteString(footerSt.Height(m.footerHeight).Render(footer))
 
 	return finalView.String()
 }
 
+// setSandbox handles "/sandbox [workspace|off]" and "/sandbox network on|off"
+// and returns the message to show.
+func setSandbox(args []string) string {
+	cfg := tools.SandboxConfig()
+	switch {
+	case len(args) == 0:
+		return fmt.Sprintf("Commands run with %s. Use /sandbox workspace|off or /sandbox network on|off to change it.", cfg)
+	case len(args) == 2 && args[0] == "network" && (args[1] == "on" || args[1] == "off"):
+		cfg.Network = args[1] == "on"
+	case len(args) == 1:
+		mode, err := sandbox.ParseMode(args[0])
+		if err != nil {
+			return err.Error()
+		}
+		cfg.Mode = mode
+	default:
+		return "Usage: /sandbox workspace|off or /sandbox network on|off"
+	}
+	if err := cfg.Check(); err != nil {
+		return fmt.Sprintf("Unable to change the sandbox: %v", err)
+	}
+	tools.UseSandbox(cfg)
+	return fmt.Sprintf("Commands now run with %s.", cfg)
+}
+
+// getPrompt handles "/prompt <server>:<name> [arg=value ...]", getting the
+// prompt of the MCP server in the background, and lists the prompts without
+// arguments.
+func (m *TuiModel) getPrompt(args []string) tea.Cmd {
+	if len(args) == 0 {
+		m.logMessage = describeMCPPrompts(tools.MCPStatus())
+		return nil
+	}
+	server, name, ok := strings.Cut(args[0], ":")
+	if !ok || server == "" || name == "" {
+		m.logMessage = "Usage: /prompt <server>:<name> [arg=value ...]"
+		return nil
+	}
+	params := map[string]string{}
+	for _, arg := range args[1:] {
+		k, v, ok := strings.Cut(arg, "=")
+		if !ok {
+			m.logMessage = fmt.Sprintf("The argument %q is not in the arg=value form.", arg)
+			return nil
+		}
+		params[k] = v
+	}
+	m.logMessage = fmt.Sprintf("Getting the prompt %s of %s…", name, server)
+	return func() tea.Msg {
+		text, err := tools.MCPPrompt(context.Background(), server, name, params)
+		return mcpPromptMsg{text: text, err: err}
+	}
+}
+
 func StartProgram() {
 	p := tea.NewProgram(New(), tea.WithMouseAllMotion())
-	if _, err := p.Run(); err != nil {
+	tools.OnMCPChange(func() { p.Send(mcpStatusMsg{}) })
+	_, err := p.Run()
+	tools.OnMCPChange(nil)
+	tools.CloseShell()
+	tools.CloseLanguageServers()
+	tools.CloseMCP()
+	if err != nil {
 		log.Println("Error:", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,234 @@
+package tui
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/sifatulrabbi/cli-agent/internals/agent"
+	"github.com/sifatulrabbi/cli-agent/internals/agent/tools"
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/db"
+)
+
+// newTestModel returns a sized model on a new session in a temporary
+// workspace, whose commands run without the sandbox.
+func newTestModel(t *testing.T) TuiModel {
+	t.Helper()
+	configs.SessionsPath = t.TempDir()
+	configs.WorkingPath = t.TempDir()
+	configs.AppSettings = configs.Settings{Sandbox: configs.SandboxSettings{Mode: "off"}}
+	t.Cleanup(func() {
+		tools.CloseShell()
+		tools.CloseLanguageServers()
+		tools.CloseMCP()
+	})
+	return update(t, New(), tea.WindowSizeMsg{Width: 100, Height: 40})
+}
+
+func update(t *testing.T, m TuiModel, msg tea.Msg) TuiModel {
+	t.Helper()
+	next, _ := m.Update(msg)
+	return next.(TuiModel)
+}
+
+func key(s string) tea.KeyMsg {
+	switch s {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	case "up":
+		return tea.KeyMsg{Type: tea.KeyUp}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+// submit types the input and presses enter.
+func submit(t *testing.T, m TuiModel, input string) TuiModel {
+	t.Helper()
+	m.ti.SetValue(input)
+	return update(t, m, key("enter"))
+}
+
+// fakeModel answers the chat completions with the messages in turn.
+func fakeModel(t *testing.T, messages ...string) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := min(int(calls.Add(1)), len(messages)) - 1
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "1", "object": "chat.completion", "created": 0, "model": "test", "choices": [{"index": 0, "finish_reason": "stop", "message": %s}]}`, messages[i])
+	}))
+	t.Cleanup(srv.Close)
+	configs.OpenRouterBaseURL = srv.URL
+}
+
+func TestTurnWithApproval(t *testing.T) {
+	m := newTestModel(t)
+	fakeModel(t,
+		`{"role": "assistant", "content": "", "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "bash", "arguments": "{\"cmd\": \"echo hello\"}"}}]}`,
+		`{"role": "assistant", "content": "Printed it."}`,
+	)
+	m.agent.ModelProvider = agent.ModelProvider{ModelName: "test", Provider: agent.ProviderOpenRouter}
+
+	m = submit(t, m, "print hello")
+	if !m.busy || m.ch == nil {
+		t.Fatal("expected the turn to start")
+	}
+	asked := false
+	for m.ch != nil {
+		msg := m.waitForUpdate()()
+		m = update(t, m, msg)
+		if m.approving {
+			asked = true
+			if !strings.Contains(m.logMessage, "Allow bash → echo hello?") {
+				t.Errorf("unexpected approval prompt %q", m.logMessage)
+			}
+			m = update(t, m, key("y"))
+			if m.approving || m.busyStatus != "Processing…" {
+				t.Error("expected the answer to resume the turn")
+			}
+		}
+	}
+	if !asked {
+		t.Fatal("expected the bash command to be approved first")
+	}
+	if m.busy || m.busyStatus != "" {
+		t.Error("expected the turn to be over")
+	}
+	messages := m.agent.Messages()
+	if last := messages[len(messages)-1]; last.Text != "Printed it." || !strings.Contains(messages[len(messages)-2].Text, "hello") {
+		t.Errorf("unexpected conversation %+v", messages)
+	}
+	if !strings.Contains(m.chatHistory, "Printed it.") {
+		t.Error("expected the answer to be rendered")
+	}
+}
+
+func TestRewindPicker(t *testing.T) {
+	m := newTestModel(t)
+	m.agent.History.Messages = []db.HistoryMessage{
+		{Role: db.MsgRoleSystem, Text: "system"},
+		{Role: db.MsgRoleUser, Text: "first"},
+		{Role: db.MsgRoleAI, Text: "done"},
+		{Role: db.MsgRoleUser, Text: "second"},
+		{Role: db.MsgRoleAI, Text: "done too"},
+	}
+	p := filepath.Join(configs.WorkingPath, "main.go")
+	if err := m.agent.Checkpoints.Snapshot(3, "write_file", p); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m = submit(t, m, "/rewind")
+	if !m.rewinding || m.rewindSel != 1 || m.pickerHeight != 3 {
+		t.Fatalf("expected the picker on the last message, got %+v", m.rewindTargets)
+	}
+	m = update(t, m, key("up"))
+	if m.rewindSel != 0 {
+		t.Error("expected up to select the previous message")
+	}
+	if view := m.View(); !strings.Contains(view, "Rewind to:") || !strings.Contains(view, "second") {
+		t.Errorf("expected the picker to be shown:\n%s", view)
+	}
+	m = update(t, m, key("esc"))
+	if m.rewinding {
+		t.Fatal("expected esc to close the picker")
+	}
+
+	// The second message was followed by an edit, so restoring it is asked.
+	m = submit(t, m, "/rewind")
+	m = update(t, m, key("enter"))
+	if !m.rewindConfirm {
+		t.Fatal("expected to be asked about the files")
+	}
+	m = update(t, m, key("y"))
+	if m.rewinding || m.ti.Value() != "second" || len(m.agent.Messages()) != 3 {
+		t.Fatalf("expected the conversation to be rewound, input %q", m.ti.Value())
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Error("expected the created file to be removed")
+	}
+
+	// The first message has no edits after it, so nothing is asked.
+	m.ti.Reset()
+	m = submit(t, m, "/rewind")
+	if len(m.rewindTargets) != 1 {
+		t.Fatalf("unexpected targets %v", m.rewindTargets)
+	}
+	m = update(t, m, key("enter"))
+	if m.rewinding || m.ti.Value() != "first" || len(m.agent.Messages()) != 1 {
+		t.Fatalf("expected the conversation to be rewound to the first message, input %q", m.ti.Value())
+	}
+}
+
+func TestCommandsAndPanels(t *testing.T) {
+	m := newTestModel(t)
+
+	m = submit(t, m, "/undo")
+	if m.logMessage != "Nothing to undo." || m.statusHeight != 2 {
+		t.Errorf("unexpected status %q", m.logMessage)
+	}
+	m = submit(t, m, "/mcp")
+	if !strings.Contains(m.logMessage, "No MCP server is configured") {
+		t.Errorf("unexpected status %q", m.logMessage)
+	}
+	m = submit(t, m, "/prompt")
+	if m.logMessage != "No MCP server offers prompts." {
+		t.Errorf("unexpected status %q", m.logMessage)
+	}
+	m = submit(t, m, "/prompt greet")
+	if !strings.HasPrefix(m.logMessage, "Usage: /prompt") {
+		t.Errorf("unexpected status %q", m.logMessage)
+	}
+	m = update(t, m, mcpPromptMsg{text: "Hello Ada"})
+	if m.ti.Value() != "Hello Ada" {
+		t.Errorf("expected the prompt in the input, got %q", m.ti.Value())
+	}
+	m.ti.Reset()
+	m = submit(t, m, "/rewind")
+	if m.rewinding || m.logMessage != "Nothing to rewind yet." {
+		t.Errorf("unexpected status %q", m.logMessage)
+	}
+	m = submit(t, m, "/sandbox off")
+	if !strings.HasPrefix(m.logMessage, "Commands now run with") {
+		t.Errorf("unexpected status %q", m.logMessage)
+	}
+
+	// A trailing backslash continues the input on a new line.
+	m = submit(t, m, `first line\`)
+	if m.inputHeight != 2 || m.ti.Value() != "first line\n" {
+		t.Errorf("expected a second input line, got %d lines of %q", m.inputHeight, m.ti.Value())
+	}
+	m = update(t, m, key("esc"))
+	if !m.escPressed || m.logMessage != "Press Esc again to clear the input." {
+		t.Errorf("unexpected status %q", m.logMessage)
+	}
+	m = update(t, m, key("esc"))
+	if m.ti.Value() != "" || m.logMessage != "" {
+		t.Error("expected the second esc to clear the input")
+	}
+
+	m = update(t, m, mcpStatusMsg{})
+	height := m.vp.Height
+	if _, err := tools.CurrentTodos().Replace([]db.Todo{{Content: "Write the parser", Status: db.TodoInProgress}, {Content: "Test it"}}); err != nil {
+		t.Fatal(err)
+	}
+	m = update(t, m, mcpStatusMsg{})
+	if m.todoHeight != 3 || m.vp.Height != height-3 {
+		t.Errorf("expected the todo panel to take 3 lines, got %d and the viewport %d of %d", m.todoHeight, m.vp.Height, height)
+	}
+	if view := m.View(); !strings.Contains(view, "Todos 0/2") || !strings.Contains(view, "▸ Write the parser") {
+		t.Errorf("expected the todos to be shown:\n%s", view)
+	}
+}
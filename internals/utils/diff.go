@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	DiffEqual  = ' '
+	DiffDelete = '-'
+	DiffInsert = '+'
+)
+
+type DiffLine struct {
+	Kind byte
+	Text string
+}
+
+// DiffLines returns the shortest edit script turning a into b using the Myers
+// diff algorithm.
+func DiffLines(a, b []string) []DiffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	out := make([]DiffLine, 0, len(a)+len(b))
+	for _, l := range a[:prefix] {
+		out = append(out, DiffLine{DiffEqual, l})
+	}
+	out = append(out, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		out = append(out, DiffLine{DiffEqual, l})
+	}
+	return out
+}
+
+func myers(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		out := make([]DiffLine, 0, n+m)
+		for _, l := range a {
+			out = append(out, DiffLine{DiffDelete, l})
+		}
+		for _, l := range b {
+			out = append(out, DiffLine{DiffInsert, l})
+		}
+		return out
+	}
+
+	maxD := n + m
+	offset := maxD
+	v := make([]int, 2*maxD+2)
+	trace := [][]int{}
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		done := false
+		for k := -d; k <= d; k += 2 {
+			x := 0
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	// Walk the trace backwards to recover the edit script.
+	out := []DiffLine{}
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := 0
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			out = append(out, DiffLine{DiffEqual, a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			out = append(out, DiffLine{DiffInsert, b[y-1]})
+			y--
+		} else {
+			out = append(out, DiffLine{DiffDelete, a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		out = append(out, DiffLine{DiffEqual, a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// UnifiedDiff renders the changes between from and to in the unified diff
+// format with the given number of context lines. It returns an empty string
+// when both are equal.
+func UnifiedDiff(fromName, toName, from, to string, context int) string {
+	lines := DiffLines(splitLines(from), splitLines(to))
+
+	type hunk struct{ start, end int }
+	hunks := []hunk{}
+	for i, l := range lines {
+		if l.Kind == DiffEqual {
+			continue
+		}
+		start, end := max(0, i-context), min(len(lines), i+context+1)
+		if len(hunks) > 0 && start <= hunks[len(hunks)-1].end {
+			hunks[len(hunks)-1].end = end
+		} else {
+			hunks = append(hunks, hunk{start, end})
+		}
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	oldLine, newLine, pos := 1, 1, 0
+	for _, h := range hunks {
+		for ; pos < h.start; pos++ {
+			oldLine, newLine = advance(lines[pos].Kind, oldLine, newLine)
+		}
+		oldCount, newCount := 0, 0
+		for _, l := range lines[h.start:h.end] {
+			if l.Kind != DiffInsert {
+				oldCount++
+			}
+			if l.Kind != DiffDelete {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+		for ; pos < h.end; pos++ {
+			sb.WriteByte(lines[pos].Kind)
+			sb.WriteString(lines[pos].Text)
+			sb.WriteByte('\n')
+			oldLine, newLine = advance(lines[pos].Kind, oldLine, newLine)
+		}
+	}
+	return sb.String()
+}
+
+func advance(kind byte, oldLine, newLine int) (int, int) {
+	if kind != DiffInsert {
+		oldLine++
+	}
+	if kind != DiffDelete {
+		newLine++
+	}
+	return oldLine, newLine
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(content string) []string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.TrimSuffix(content, "\n")
+	if content == "" {
+		return []string{}
+	}
+	return strings.Split(content, "\n")
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	a := strings.Split("a b c e f g", " ")
+	b := strings.Split("a c d e g h", " ")
+	var got, rebuiltA, rebuiltB []string
+	for _, l := range DiffLines(a, b) {
+		got = append(got, string(l.Kind)+l.Text)
+		if l.Kind != DiffInsert {
+			rebuiltA = append(rebuiltA, l.Text)
+		}
+		if l.Kind != DiffDelete {
+			rebuiltB = append(rebuiltB, l.Text)
+		}
+	}
+	if strings.Join(rebuiltA, " ") != strings.Join(a, " ") || strings.Join(rebuiltB, " ") != strings.Join(b, " ") {
+		t.Fatalf("edit script does not rebuild the inputs: %q", got)
+	}
+	changes := 0
+	for _, l := range got {
+		if l[0] != DiffEqual {
+			changes++
+		}
+	}
+	if changes != 4 {
+		t.Fatalf("expected the shortest edit script (4 changes), got %d: %q", changes, got)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\n"
+	to := "one\ntwo\nthree\nFOUR\nfive\nsix\nseven\neight\nnine\nten\n"
+	want := `--- a/f.txt
++++ b/f.txt
+@@ -3,3 +3,3 @@
+ three
+-four
++FOUR
+ five
+@@ -9 +9,2 @@
+ nine
++ten
+`
+	if got := UnifiedDiff("a/f.txt", "b/f.txt", from, to, 1); got != want {
+		t.Fatalf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	if got := UnifiedDiff("a", "b", from, from, 3); got != "" {
+		t.Fatalf("expected no diff for equal contents, got:\n%s", got)
+	}
+	if got := UnifiedDiff("/dev/null", "b/new.txt", "", "hi\n", 3); got != "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+hi\n" {
+		t.Fatalf("unexpected diff for a new file:\n%s", got)
+	}
+}
@@ -0,0 +1,8 @@
+package utils
+
+import "fmt"
+
+// Plural counts the noun, e.g. "1 file" or "3 files".
+func Plural(n int, noun string) string {
+	return fmt.Sprintf("%d %s%s", n, noun, Ternary(n == 1, "", "s"))
+}
@@ -0,0 +1,362 @@
+package webfetch
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skipped elements hold no readable content: scripts, styles, forms and the
+// navigation around the content of a page.
+var skipped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Button: true, atom.Select: true, atom.Input: true, atom.Textarea: true,
+	atom.Svg: true, atom.Iframe: true, atom.Object: true, atom.Embed: true, atom.Canvas: true,
+	atom.Head: true, atom.Dialog: true,
+}
+
+var blocks = map[atom.Atom]bool{
+	atom.Html: true, atom.Body: true, atom.Main: true, atom.Article: true, atom.Section: true,
+	atom.Div: true, atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Pre: true,
+	atom.Blockquote: true, atom.Hr: true, atom.Table: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Address: true, atom.Details: true,
+	atom.Summary: true, atom.Center: true,
+}
+
+var (
+	spaces    = regexp.MustCompile(`[ \t]+`)
+	blankRuns = regexp.MustCompile(`\n{3,}`)
+)
+
+// HTMLToMarkdown converts an HTML page to Markdown, keeping the headings,
+// paragraphs, lists, links, code and tables of its main content. Links are
+// resolved against base. It returns the title of the page too.
+func HTMLToMarkdown(doc string, base *url.URL) (title, markdown string) {
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		return "", ""
+	}
+	if t := find(root, atom.Title); t != nil {
+		title = strings.Join(strings.Fields(textContent(t)), " ")
+	}
+	// The main content leaves the navigation and the sidebars behind, when
+	// the page marks it.
+	content := find(root, atom.Main)
+	if content == nil {
+		content = find(root, atom.Article)
+	}
+	if content == nil {
+		content = root
+	}
+	c := converter{base: base}
+	markdown = blankRuns.ReplaceAllString(c.block(content), "\n\n")
+	return title, strings.TrimSpace(markdown)
+}
+
+type converter struct {
+	base *url.URL
+}
+
+// block renders the children of n as Markdown blocks separated by blank
+// lines, the inline content between block elements becomes a paragraph.
+func (c converter) block(n *html.Node) string {
+	var (
+		parts  []string
+		inline strings.Builder
+	)
+	flush := func() {
+		if p := cleanInline(inline.String()); p != "" {
+			parts = append(parts, p)
+		}
+		inline.Reset()
+	}
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type == html.ElementNode && skipped[ch.DataAtom] {
+			continue
+		}
+		if ch.Type != html.ElementNode || !blocks[ch.DataAtom] {
+			inline.WriteString(c.inline(ch))
+			continue
+		}
+		flush()
+		if b := c.blockElement(ch); strings.TrimSpace(b) != "" {
+			parts = append(parts, b)
+		}
+	}
+	flush()
+	return strings.Join(parts, "\n\n")
+}
+
+func (c converter) blockElement(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := strings.ReplaceAll(cleanInline(c.inlineChildren(n)), "\n", " ")
+		if text == "" {
+			return ""
+		}
+		return strings.Repeat("#", int(n.Data[1]-'0')) + " " + text
+	case atom.Ul, atom.Ol:
+		return c.list(n)
+	case atom.Pre:
+		lang := codeLanguage(n)
+		if code := find(n, atom.Code); code != nil && lang == "" {
+			lang = codeLanguage(code)
+		}
+		return "```" + lang + "\n" + strings.Trim(textContent(n), "\n") + "\n```"
+	case atom.Blockquote:
+		return prefixLines(c.block(n), "> ", "> ")
+	case atom.Hr:
+		return "---"
+	case atom.Table:
+		return c.table(n)
+	case atom.Dt:
+		if text := cleanInline(c.inlineChildren(n)); text != "" {
+			return "**" + text + "**"
+		}
+		return ""
+	}
+	return c.block(n)
+}
+
+func (c converter) list(n *html.Node) string {
+	var items []string
+	i := 1
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(i) + ". "
+			i++
+		}
+		content := c.block(li)
+		if content == "" {
+			continue
+		}
+		items = append(items, prefixLines(content, marker, strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (c converter) table(n *html.Node) string {
+	var rows [][]string
+	header := false
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			if ch.Type != html.ElementNode {
+				continue
+			}
+			if ch.DataAtom != atom.Tr {
+				walk(ch)
+				continue
+			}
+			var row []string
+			for cell := ch.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+					if len(rows) == 0 && cell.DataAtom == atom.Th {
+						header = true
+					}
+					text := strings.ReplaceAll(cleanInline(c.inlineChildren(cell)), "\n", " ")
+					row = append(row, strings.ReplaceAll(text, "|", `\|`))
+				}
+			}
+			if len(row) > 0 {
+				rows = append(rows, row)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	line := func(row []string) string {
+		cells := make([]string, width)
+		copy(cells, row)
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+	var lines []string
+	if !header {
+		// Markdown tables need a header row, an empty one keeps the first
+		// row of data a row of data.
+		lines = append(lines, line(nil))
+	} else {
+		lines = append(lines, line(rows[0]))
+		rows = rows[1:]
+	}
+	lines = append(lines, "|"+strings.Repeat(" --- |", width))
+	for _, row := range rows {
+		lines = append(lines, line(row))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inline renders n as inline Markdown. Whitespace is collapsed by
+// cleanInline once the paragraph is complete.
+func (c converter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return strings.Map(func(r rune) rune {
+			if r == '\n' || r == '\r' || r == '\t' || r == ' ' {
+				return ' '
+			}
+			return r
+		}, n.Data)
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	if skipped[n.DataAtom] {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+	case atom.A:
+		text := strings.TrimSpace(c.inlineChildren(n))
+		href := c.resolve(attr(n, "href"))
+		if text == "" || href == "" {
+			return text
+		}
+		return "[" + text + "](" + href + ")"
+	case atom.Img:
+		alt := strings.TrimSpace(attr(n, "alt"))
+		src := c.resolve(attr(n, "src"))
+		if alt == "" || src == "" {
+			return ""
+		}
+		return "![" + alt + "](" + src + ")"
+	case atom.Strong, atom.B:
+		return wrap(c.inlineChildren(n), "**")
+	case atom.Em, atom.I:
+		return wrap(c.inlineChildren(n), "_")
+	case atom.Code, atom.Kbd, atom.Samp:
+		return wrap(strings.Join(strings.Fields(textContent(n)), " "), "`")
+	}
+	if blocks[n.DataAtom] {
+		// A block inside an inline element, e.g. a <div> in a link.
+		return " " + c.inlineChildren(n) + " "
+	}
+	return c.inlineChildren(n)
+}
+
+func (c converter) inlineChildren(n *html.Node) string {
+	var sb strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		sb.WriteString(c.inline(ch))
+	}
+	return sb.String()
+}
+
+// resolve makes a link absolute, the links that don't lead anywhere are
+// dropped.
+func (c converter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if c.base != nil {
+		u = c.base.ResolveReference(u)
+	}
+	return u.String()
+}
+
+// cleanInline collapses the whitespace of a paragraph, keeping the line
+// breaks of <br>.
+func cleanInline(s string) string {
+	lines := strings.Split(spaces.ReplaceAllString(s, " "), "\n")
+	kept := lines[:0]
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" {
+			kept = append(kept, l)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// wrap puts the marker around the text, outside of its surrounding spaces
+// so the Markdown stays valid.
+func wrap(s, marker string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	lead := s[:strings.Index(s, trimmed)]
+	trail := s[len(lead)+len(trimmed):]
+	return lead + marker + trimmed + marker + trail
+}
+
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + l
+		case l == "":
+			lines[i] = strings.TrimRight(rest, " ")
+		default:
+			lines[i] = rest + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func codeLanguage(n *html.Node) string {
+	for _, class := range strings.Fields(attr(n, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if lang, ok := strings.CutPrefix(class, prefix); ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if found := find(ch, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		sb.WriteString(textContent(ch))
+	}
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,227 @@
+// Package webfetch fetches web pages for the agent and turns them into text
+// it can read: HTML becomes Markdown, JSON is indented and other text is kept
+// as it is.
+package webfetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	DefaultMaxBytes     = 5 << 20
+	DefaultMaxRedirects = 5
+	DefaultTimeout      = 30 * time.Second
+	userAgent           = "cli-agent/1.0 (+https://github.com/sifatulrabbi/cli-agent)"
+)
+
+// Options limit what a Fetcher fetches. Allow and Deny hold domains, a domain
+// matches its subdomains too. When Allow isn't empty only its domains can be
+// fetched, Deny wins over Allow.
+type Options struct {
+	Allow        []string
+	Deny         []string
+	MaxBytes     int64
+	MaxRedirects int
+	Timeout      time.Duration
+	// Transport is used instead of http.DefaultTransport when set.
+	Transport http.RoundTripper
+}
+
+// Page is a fetched page converted to text.
+type Page struct {
+	URL         string   // after the redirects
+	Redirects   []string // the URLs that redirected, in order
+	Status      int
+	StatusText  string
+	ContentType string
+	Title       string
+	Content     string
+	// Truncated is set when the body was larger than the MaxBytes and only
+	// its beginning was read.
+	Truncated bool
+	Cached    bool
+}
+
+// Fetcher fetches pages and caches the successful responses for as long as it
+// lives, a session of the agent.
+type Fetcher struct {
+	opts Options
+
+	mu    sync.Mutex
+	cache map[string]*Page
+}
+
+func New(opts Options) *Fetcher {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = DefaultMaxRedirects
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	return &Fetcher{opts: opts, cache: map[string]*Page{}}
+}
+
+// ErrBlocked is returned for the URLs the domain lists don't allow.
+var ErrBlocked = errors.New("the domain is not allowed")
+
+// Check reports whether the URL can be fetched.
+func (f *Fetcher) Check(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q, only http and https can be fetched", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("the URL %q has no host", u.String())
+	}
+	for _, d := range f.opts.Deny {
+		if matchDomain(host, d) {
+			return fmt.Errorf("%w: %s is blocked", ErrBlocked, host)
+		}
+	}
+	if len(f.opts.Allow) == 0 {
+		return nil
+	}
+	for _, d := range f.opts.Allow {
+		if matchDomain(host, d) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is not in the allowed domains", ErrBlocked, host)
+}
+
+func matchDomain(host, domain string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "*."))
+	return domain != "" && (host == domain || strings.HasSuffix(host, "."+domain))
+}
+
+// Fetch GETs the URL, following the redirects the domain lists allow, and
+// converts the response to text. Responses with an error status are returned
+// too, they often explain what went wrong, but they aren't cached.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	u.Fragment = ""
+	if err := f.Check(u); err != nil {
+		return nil, err
+	}
+	key := u.String()
+	f.mu.Lock()
+	cached := f.cache[key]
+	f.mu.Unlock()
+	if cached != nil {
+		page := *cached
+		page.Cached = true
+		return &page, nil
+	}
+
+	page := &Page{}
+	client := &http.Client{
+		Transport: f.opts.Transport,
+		Timeout:   f.opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > f.opts.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", f.opts.MaxRedirects)
+			}
+			if err := f.Check(req.URL); err != nil {
+				return fmt.Errorf("redirected to %s: %w", req.URL, err)
+			}
+			page.Redirects = append(page.Redirects, via[len(via)-1].URL.String())
+			return nil
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/json,text/plain;q=0.9,*/*;q=0.5")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.opts.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the response: %w", err)
+	}
+	if int64(len(body)) > f.opts.MaxBytes {
+		body = body[:f.opts.MaxBytes]
+		page.Truncated = true
+	}
+	page.URL = resp.Request.URL.String()
+	page.Status = resp.StatusCode
+	page.StatusText = http.StatusText(resp.StatusCode)
+	page.ContentType = resp.Header.Get("Content-Type")
+	if page.ContentType == "" {
+		page.ContentType = http.DetectContentType(body)
+	}
+	if err := convert(page, body, resp.Request.URL); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 400 {
+		f.mu.Lock()
+		f.cache[key] = page
+		f.mu.Unlock()
+	}
+	return page, nil
+}
+
+func convert(page *Page, body []byte, base *url.URL) error {
+	mediaType, params, _ := mime.ParseMediaType(page.ContentType)
+	// Decode the legacy charsets, the HTML meta tags included.
+	if cs := params["charset"]; (cs != "" && !strings.EqualFold(cs, "utf-8")) || mediaType == "text/html" {
+		if r, err := charset.NewReader(bytes.NewReader(body), page.ContentType); err == nil {
+			if decoded, err := io.ReadAll(r); err == nil {
+				body = decoded
+			}
+		}
+	}
+
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		page.Title, page.Content = HTMLToMarkdown(string(body), base)
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var indented bytes.Buffer
+		if json.Indent(&indented, body, "", "  ") == nil {
+			page.Content = indented.String()
+		} else {
+			page.Content = string(body)
+		}
+	case strings.HasPrefix(mediaType, "text/") || isTextual(mediaType):
+		page.Content = string(body)
+	default:
+		return fmt.Errorf("unsupported content type %q, only HTML, JSON and text can be read", page.ContentType)
+	}
+	return nil
+}
+
+func isTextual(mediaType string) bool {
+	switch mediaType {
+	case "application/xml", "application/javascript", "application/x-yaml", "application/yaml", "application/toml":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+xml")
+}
@@ -0,0 +1,136 @@
+package webfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const page = `<!doctype html>
+<html><head><title> The   Guide </title><style>body { color: red }</style><script>alert(1)</script></head>
+<body>
+<nav><a href="/">Home</a> <a href="/docs">Docs</a></nav>
+<main>
+  <h1>Getting   started</h1>
+  <p>Install the <strong>tool</strong> with <code>go install</code>,
+  then read the <a href="/docs/usage#flags">usage</a>.<br>Done.</p>
+  <ul>
+    <li>First</li>
+    <li>Second
+      <ol><li>Nested</li></ol>
+    </li>
+  </ul>
+  <pre><code class="language-go">func main() {
+	fmt.Println("hi")
+}</code></pre>
+  <blockquote><p>Quoted</p></blockquote>
+  <table><tr><th>Name</th><th>Value</th></tr><tr><td>a</td><td>1 | 2</td></tr></table>
+  <button>Subscribe</button>
+</main>
+<footer>Copyright</footer>
+</body></html>`
+
+func TestHTMLToMarkdown(t *testing.T) {
+	base, _ := url.Parse("https://example.com/guide/")
+	title, md := HTMLToMarkdown(page, base)
+	if title != "The Guide" {
+		t.Errorf("unexpected title %q", title)
+	}
+	want := "# Getting started\n\n" +
+		"Install the **tool** with `go install`, then read the [usage](https://example.com/docs/usage#flags).\nDone.\n\n" +
+		"- First\n- Second\n\n  1. Nested\n\n" +
+		"```go\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\n\n" +
+		"> Quoted\n\n" +
+		"| Name | Value |\n| --- | --- |\n| a | 1 \\| 2 |"
+	if md != want {
+		t.Errorf("unexpected markdown:\n%s\n\nwant:\n%s", md, want)
+	}
+	for _, unwanted := range []string{"alert", "color", "Home", "Copyright", "Subscribe"} {
+		if strings.Contains(md, unwanted) {
+			t.Errorf("the markdown contains %q", unwanted)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	hits := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://blocked.example.org/", http.StatusFound)
+	})
+	mux.HandleFunc("/data.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"a":[1,2]}`)
+	})
+	mux.HandleFunc("/big.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, strings.Repeat("x", 100))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	f := New(Options{Deny: []string{"example.org"}})
+
+	p, err := f.Fetch(ctx, srv.URL+"/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.URL != srv.URL+"/page" || len(p.Redirects) != 1 || p.Redirects[0] != srv.URL+"/old" || p.Title != "The Guide" || p.Status != 200 {
+		t.Fatalf("unexpected page %+v", p)
+	}
+	if p, err = f.Fetch(ctx, srv.URL+"/old#section"); err != nil || !p.Cached || hits != 1 {
+		t.Fatalf("expected the cached page, got %+v, %v after %d requests", p, err, hits)
+	}
+
+	if _, err := f.Fetch(ctx, srv.URL+"/loop"); err == nil || !strings.Contains(err.Error(), "stopped after 5 redirects") {
+		t.Errorf("expected the redirects to be limited, got %v", err)
+	}
+	if _, err := f.Fetch(ctx, srv.URL+"/away"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected the redirect to be blocked, got %v", err)
+	}
+	if _, err := f.Fetch(ctx, "https://docs.example.org/x"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected the subdomain to be blocked, got %v", err)
+	}
+	if _, err := f.Fetch(ctx, "file:///etc/passwd"); err == nil {
+		t.Error("expected the file URL to be refused")
+	}
+
+	if p, err := f.Fetch(ctx, srv.URL+"/data.json"); err != nil || p.Content != "{\n  \"a\": [\n    1,\n    2\n  ]\n}" {
+		t.Errorf("unexpected JSON page %+v, %v", p, err)
+	}
+	if p, err := New(Options{MaxBytes: 50}).Fetch(ctx, srv.URL+"/big.txt"); err != nil || !p.Truncated || len(p.Content) != 50 {
+		t.Errorf("expected the page to be truncated, got %+v, %v", p, err)
+	}
+	if _, err := f.Fetch(ctx, srv.URL+"/image.png"); err == nil || !strings.Contains(err.Error(), "unsupported content type") {
+		t.Errorf("expected the image to be refused, got %v", err)
+	}
+
+	allowed := New(Options{Allow: []string{"example.com"}})
+	if err := allowed.Check(&url.URL{Scheme: "https", Host: "api.example.com"}); err != nil {
+		t.Errorf("expected the subdomain to be allowed, got %v", err)
+	}
+	if err := allowed.Check(&url.URL{Scheme: "https", Host: "notexample.com"}); !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected the domain to be blocked, got %v", err)
+	}
+}
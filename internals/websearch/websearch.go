@@ -0,0 +1,230 @@
+// Package websearch queries the search engine the web_search tool is
+// configured with. A Backend returns ranked results, the pages themselves are
+// read with the webfetch package.
+package websearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+const requestTimeout = 30 * time.Second
+
+type Result struct {
+	Title   string
+	URL     string
+	Snippet string
+}
+
+// Backend runs a search and returns at most limit results, the best first.
+type Backend interface {
+	Search(ctx context.Context, query string, limit int) ([]Result, error)
+}
+
+// BackendFunc is a Backend made of a function, e.g. a stand-in for tests.
+type BackendFunc func(ctx context.Context, query string, limit int) ([]Result, error)
+
+func (f BackendFunc) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	return f(ctx, query, limit)
+}
+
+// Backends are the names the settings select a backend with.
+var Backends = []string{"searxng", "brave", "tavily"}
+
+// FromSettings returns the backend the settings select, or nil when none is
+// configured.
+func FromSettings(s configs.WebSearchSettings) (Backend, error) {
+	key := s.APIKey
+	if key == "" && s.APIKeyEnv != "" {
+		key = os.Getenv(s.APIKeyEnv)
+	}
+	switch s.Backend {
+	case "":
+		return nil, nil
+	case "searxng":
+		if s.URL == "" {
+			return nil, fmt.Errorf("the searxng backend needs the URL of the instance")
+		}
+		return &SearXNG{BaseURL: s.URL, APIKey: key}, nil
+	case "brave", "tavily":
+		if key == "" {
+			return nil, fmt.Errorf("the %s backend needs an API key, set apiKey or apiKeyEnv", s.Backend)
+		}
+		if s.Backend == "brave" {
+			return &Brave{APIKey: key, BaseURL: s.URL}, nil
+		}
+		return &Tavily{APIKey: key, BaseURL: s.URL}, nil
+	}
+	return nil, fmt.Errorf("unknown search backend %q, use one of %v", s.Backend, Backends)
+}
+
+// SearXNG queries a SearXNG instance, which must have the JSON format
+// enabled. APIKey is sent as a bearer token to the instances behind a proxy
+// that asks for one.
+type SearXNG struct {
+	BaseURL string
+	APIKey  string
+}
+
+func (b *SearXNG) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(b.BaseURL, "/")+"/search", nil)
+	if err != nil {
+		return nil, err
+	}
+	q := req.URL.Query()
+	q.Set("q", query)
+	q.Set("format", "json")
+	req.URL.RawQuery = q.Encode()
+	if b.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.APIKey)
+	}
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := do(req, &resp); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, r := range resp.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Content})
+	}
+	return clip(results, limit), nil
+}
+
+// Brave queries the Brave Search API.
+type Brave struct {
+	APIKey  string
+	BaseURL string // defaults to https://api.search.brave.com
+}
+
+func (b *Brave) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	base := b.BaseURL
+	if base == "" {
+		base = "https://api.search.brave.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(base, "/")+"/res/v1/web/search", nil)
+	if err != nil {
+		return nil, err
+	}
+	q := req.URL.Query()
+	q.Set("q", query)
+	q.Set("count", fmt.Sprint(min(limit, 20)))
+	req.URL.RawQuery = q.Encode()
+	req.Header.Set("X-Subscription-Token", b.APIKey)
+	var resp struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := do(req, &resp); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, r := range resp.Web.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: stripTags(r.Description)})
+	}
+	return clip(results, limit), nil
+}
+
+// Tavily queries the Tavily search API.
+type Tavily struct {
+	APIKey  string
+	BaseURL string // defaults to https://api.tavily.com
+}
+
+func (b *Tavily) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	base := b.BaseURL
+	if base == "" {
+		base = "https://api.tavily.com"
+	}
+	body, _ := json.Marshal(map[string]any{"query": query, "max_results": limit})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(base, "/")+"/search", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+b.APIKey)
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := do(req, &resp); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, r := range resp.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Content})
+	}
+	return clip(results, limit), nil
+}
+
+func do(req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	client := &http.Client{Timeout: requestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(data))
+		if len(msg) > 200 {
+			msg = msg[:200] + "..."
+		}
+		return fmt.Errorf("the search backend answered %s: %s", resp.Status, msg)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid response of the search backend: %w", err)
+	}
+	return nil
+}
+
+func clip(results []Result, limit int) []Result {
+	if limit > 0 && len(results) > limit {
+		return results[:limit]
+	}
+	return results
+}
+
+// stripTags removes the <strong> tags Brave highlights the matches with and
+// decodes the entities.
+func stripTags(s string) string {
+	var sb strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			sb.WriteRune(r)
+		}
+	}
+	return html.UnescapeString(sb.String())
+}
@@ -0,0 +1,92 @@
+package websearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sifatulrabbi/cli-agent/internals/configs"
+)
+
+func TestBackends(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			// Tavily
+			var body struct {
+				Query      string `json:"query"`
+				MaxResults int    `json:"max_results"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if r.Header.Get("Authorization") != "Bearer tavily-key" || body.Query != "go generics" || body.MaxResults != 2 {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"results": [{"title": "T1", "url": "https://a.test/1", "content": "first"}]}`)
+			return
+		}
+		if r.URL.Query().Get("format") != "json" || r.URL.Query().Get("q") != "go generics" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"results": [
+			{"title": "S1", "url": "https://a.test/1", "content": "one"},
+			{"title": "S2", "url": "https://a.test/2", "content": "two"},
+			{"title": "S3", "url": "https://a.test/3", "content": "three"}]}`)
+	})
+	mux.HandleFunc("/res/v1/web/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Subscription-Token") != "brave-key" {
+			http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"web": {"results": [{"title": "B1", "url": "https://b.test/", "description": "Using <strong>generics</strong> &amp; more"}]}}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	ctx := context.Background()
+
+	searxng, err := FromSettings(configs.WebSearchSettings{Backend: "searxng", URL: srv.URL + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := searxng.Search(ctx, "go generics", 2)
+	if err != nil || len(results) != 2 || results[1] != (Result{Title: "S2", URL: "https://a.test/2", Snippet: "two"}) {
+		t.Errorf("unexpected SearXNG results %+v, %v", results, err)
+	}
+
+	t.Setenv("BRAVE_TEST_KEY", "brave-key")
+	brave, err := FromSettings(configs.WebSearchSettings{Backend: "brave", URL: srv.URL, APIKeyEnv: "BRAVE_TEST_KEY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = brave.Search(ctx, "go generics", 5)
+	if err != nil || len(results) != 1 || results[0].Snippet != "Using generics & more" {
+		t.Errorf("unexpected Brave results %+v, %v", results, err)
+	}
+	_, err = (&Brave{APIKey: "wrong", BaseURL: srv.URL}).Search(ctx, "go generics", 5)
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("expected the error status to be reported, got %v", err)
+	}
+
+	tavily, err := FromSettings(configs.WebSearchSettings{Backend: "tavily", URL: srv.URL, APIKey: "tavily-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = tavily.Search(ctx, "go generics", 2)
+	if err != nil || len(results) != 1 || results[0].Title != "T1" {
+		t.Errorf("unexpected Tavily results %+v, %v", results, err)
+	}
+
+	if b, err := FromSettings(configs.WebSearchSettings{}); b != nil || err != nil {
+		t.Errorf("expected no backend, got %v, %v", b, err)
+	}
+	for _, s := range []configs.WebSearchSettings{{Backend: "searxng"}, {Backend: "tavily"}, {Backend: "bing", APIKey: "x"}} {
+		if _, err := FromSettings(s); err == nil {
+			t.Errorf("expected %+v to be refused", s)
+		}
+	}
+}
@@ -13,9 +13,13 @@ import (
 
 	"github.com/sifatulrabbi/cli-agent/cmd"
 	"github.com/sifatulrabbi/cli-agent/internals/configs"
+	"github.com/sifatulrabbi/cli-agent/internals/sandbox"
 )
 
 func main() {
+	// The sandboxed commands are started through this executable.
+	sandbox.MaybeExec()
+
 	if osName := runtime.GOOS; strings.HasPrefix(osName, "windows") {
 		log.Panicln("Platform windows is not supported. Please use within WSL.")
 	}